@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+
+	"go-webapp-example/internal/app"
+	"go-webapp-example/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+// nolint:gochecknoinits
+func init() {
+	authReconcileCmd.Flags().Bool("dry-run", false, "only report the drift without repairing it")
+	authCmd.AddCommand(authReconcileCmd)
+	rootCmd.AddCommand(authCmd)
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the access control policies",
+}
+
+var authReconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Repair role assignments",
+	Long: `This command compares the role assignments of the role_user table with the policies
+and changes the policies to match the role_user table.`,
+	Run: runAuthReconcile,
+}
+
+func runAuthReconcile(cmd *cobra.Command, _ []string) {
+	app := boot()
+	// nolint:errcheck
+	defer app.Shutdown(context.Background())
+
+	logger := app.Log.WithPrefix("cmd.auth")
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if err := reconcileAuth(app, !dryRun, logger); err != nil {
+		logger.Fatalf("failed to reconcile role assignments: %s", err)
+	}
+}
+
+// reconcileAuth logs and optionally repairs drift between the role_user table and the policies.
+func reconcileAuth(instance *app.Kernel, repair bool, logger log.Logger) error {
+	drift, err := instance.ReconcileAuth(context.Background(), repair)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		logger.Info("role assignments are in sync")
+	}
+	for _, d := range drift {
+		if repair {
+			logger.Warnf("repaired: %s", d)
+		} else {
+			logger.Warnf("drift: %s", d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"os"
+
+	"go-webapp-example/internal/app"
+	"go-webapp-example/internal/graphql/gqlallowlist"
+	"go-webapp-example/internal/graphql/gqlserver"
+	"go-webapp-example/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+// nolint:gochecknoinits
+func init() {
+	graphqlCmd.AddCommand(graphqlValidateManifestCmd)
+	rootCmd.AddCommand(graphqlCmd)
+}
+
+var graphqlCmd = &cobra.Command{
+	Use:   "graphql",
+	Short: "Manage the GraphQL api",
+}
+
+var graphqlValidateManifestCmd = &cobra.Command{
+	Use:   "validate-manifest [file]",
+	Short: "Validate a persisted query manifest",
+	Long: `This command checks that the hashes of a persisted query manifest match their queries
+and that the queries are valid for the schema. Without a file the manifest of the config is used.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runGraphQLValidateManifest,
+}
+
+func runGraphQLValidateManifest(_ *cobra.Command, args []string) {
+	logger := log.New(os.Stderr, "info", "").WithPrefix("cmd.graphql")
+
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		path = app.LoadConfig().PersistedQueries.Manifest
+	}
+	if path == "" {
+		logger.Fatalf("no manifest given and graphql.persisted_queries.manifest is not set")
+	}
+
+	manifest, err := gqlallowlist.LoadManifest(path)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	errs := manifest.Validate(gqlserver.NewExecutableSchema(gqlserver.Config{}).Schema())
+	for _, err := range errs {
+		logger.Error(err.Error())
+	}
+	if len(errs) > 0 {
+		logger.Fatalf("manifest %s has %d invalid operations", path, len(errs))
+	}
+	logger.Infof("manifest %s contains %d valid operations", path, len(manifest))
+}
@@ -49,6 +49,11 @@ func runServer(_ *cobra.Command, _ []string) {
 		return
 	}
 
+	// Repair role assignments that got out of sync between the role_user table and the policies.
+	if err = reconcileAuth(instance, true, logger); err != nil {
+		logger.Errorf("failed to reconcile role assignments: %s", err)
+	}
+
 	go instance.Listen(server)
 	go instance.StartDaemons()
 
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS tokens
+(
+    id           INT UNSIGNED      NOT NULL AUTO_INCREMENT,
+    user_id      SMALLINT UNSIGNED NOT NULL,
+    name         VARCHAR(191)      NOT NULL,
+    hash         CHAR(64)          NOT NULL,
+    permissions  TEXT,
+    expires_at   TIMESTAMP         NULL,
+    last_used_at TIMESTAMP         NULL,
+    created_at   TIMESTAMP         NULL,
+    updated_at   TIMESTAMP         NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX (hash),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    id              INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    scope           VARCHAR(16)  NOT NULL,
+    subject         VARCHAR(191) NOT NULL,
+    failures        INT UNSIGNED NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP    NULL,
+    locked_until    TIMESTAMP    NULL,
+    created_at      TIMESTAMP,
+    updated_at      TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (scope, subject)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
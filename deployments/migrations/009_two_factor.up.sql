@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS two_factors
+(
+    id           INT UNSIGNED      NOT NULL AUTO_INCREMENT,
+    user_id      SMALLINT UNSIGNED NOT NULL,
+    secret       VARCHAR(64)       NOT NULL,
+    confirmed_at TIMESTAMP         NULL,
+    last_step    BIGINT            NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP,
+    updated_at   TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (user_id),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id         INT UNSIGNED      NOT NULL AUTO_INCREMENT,
+    user_id    SMALLINT UNSIGNED NOT NULL,
+    hash       CHAR(64)          NOT NULL,
+    used_at    TIMESTAMP         NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    PRIMARY KEY (id),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+ALTER TABLE roles ADD COLUMN require_two_factor BOOL NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mails;
+DROP TABLE IF EXISTS password_resets;
+ALTER TABLE users DROP INDEX users_email_unique;
+ALTER TABLE users DROP COLUMN email;
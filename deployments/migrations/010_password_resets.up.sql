@@ -0,0 +1,37 @@
+ALTER TABLE users ADD COLUMN email VARCHAR(191) NULL;
+ALTER TABLE users ADD UNIQUE KEY users_email_unique (email);
+
+CREATE TABLE IF NOT EXISTS password_resets
+(
+    id         INT UNSIGNED      NOT NULL AUTO_INCREMENT,
+    user_id    SMALLINT UNSIGNED NOT NULL,
+    hash       CHAR(64)          NOT NULL,
+    expires_at TIMESTAMP         NULL,
+    used_at    TIMESTAMP         NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (hash),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS mails
+(
+    id              INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    recipient       VARCHAR(191) NOT NULL,
+    subject         VARCHAR(191) NOT NULL,
+    body            TEXT         NOT NULL,
+    attempts        INT UNSIGNED NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMP    NULL,
+    sent_at         TIMESTAMP    NULL,
+    created_at      TIMESTAMP,
+    updated_at      TIMESTAMP,
+    PRIMARY KEY (id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_sessions
+(
+    id           INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id      INT UNSIGNED NOT NULL,
+    `key`        CHAR(64)     NOT NULL,
+    ip           VARCHAR(45)  NOT NULL DEFAULT '',
+    user_agent   VARCHAR(255) NOT NULL DEFAULT '',
+    last_seen_at TIMESTAMP    NULL,
+    created_at   TIMESTAMP,
+    updated_at   TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (`key`),
+    KEY (user_id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
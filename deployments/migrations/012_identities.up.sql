@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS identities
+(
+    id         INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id    INT UNSIGNED NOT NULL,
+    provider   VARCHAR(32)  NOT NULL,
+    subject    VARCHAR(191) NOT NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (provider, subject),
+    KEY (user_id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
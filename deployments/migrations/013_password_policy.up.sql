@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP NULL;
+
+UPDATE users SET password_changed_at = CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS password_history
+(
+    id         INT UNSIGNED      NOT NULL AUTO_INCREMENT,
+    user_id    SMALLINT UNSIGNED NOT NULL,
+    hash       VARCHAR(60)       NOT NULL,
+    created_at TIMESTAMP,
+    PRIMARY KEY (id),
+    INDEX (user_id),
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+INSERT INTO password_history (user_id, hash, created_at)
+SELECT id, password, CURRENT_TIMESTAMP FROM users WHERE password IS NOT NULL;
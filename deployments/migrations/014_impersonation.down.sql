@@ -0,0 +1 @@
+ALTER TABLE auditlogs DROP COLUMN impersonated_user_id;
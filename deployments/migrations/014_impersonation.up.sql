@@ -0,0 +1 @@
+ALTER TABLE auditlogs ADD COLUMN impersonated_user_id INT UNSIGNED NULL;
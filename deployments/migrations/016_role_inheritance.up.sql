@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS role_parent
+(
+    id        INT                NOT NULL AUTO_INCREMENT,
+    role_id   MEDIUMINT UNSIGNED NOT NULL,
+    parent_id MEDIUMINT UNSIGNED NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (role_id, parent_id),
+    FOREIGN KEY (role_id)
+        REFERENCES roles (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (parent_id)
+        REFERENCES roles (id)
+        ON DELETE CASCADE
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
@@ -0,0 +1,2 @@
+ALTER TABLE quotes DROP FOREIGN KEY quotes_created_by_foreign;
+ALTER TABLE quotes DROP COLUMN created_by;
@@ -0,0 +1,2 @@
+ALTER TABLE quotes ADD COLUMN created_by SMALLINT UNSIGNED NULL;
+ALTER TABLE quotes ADD CONSTRAINT quotes_created_by_foreign FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
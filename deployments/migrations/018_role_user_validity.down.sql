@@ -0,0 +1,2 @@
+ALTER TABLE role_user DROP COLUMN valid_until;
+ALTER TABLE role_user DROP COLUMN valid_from;
@@ -0,0 +1,2 @@
+ALTER TABLE role_user ADD COLUMN valid_from TIMESTAMP NULL;
+ALTER TABLE role_user ADD COLUMN valid_until TIMESTAMP NULL;
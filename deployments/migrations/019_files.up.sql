@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS files
+(
+    id         INT UNSIGNED      NOT NULL AUTO_INCREMENT,
+    name       VARCHAR(191)      NOT NULL,
+    path       VARCHAR(191)      NOT NULL,
+    mime_type  VARCHAR(127)      NOT NULL,
+    size       BIGINT UNSIGNED   NOT NULL,
+    created_by SMALLINT UNSIGNED NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (path),
+    FOREIGN KEY (created_by)
+        REFERENCES users (id)
+        ON DELETE SET NULL
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
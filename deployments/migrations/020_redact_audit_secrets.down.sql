@@ -0,0 +1,2 @@
+-- The redacted values can not be restored.
+SELECT 1;
@@ -0,0 +1,4 @@
+UPDATE auditlogs
+SET value_old = '[redacted]',
+    value_new = '[redacted]'
+WHERE field IN ('password', 'hash', 'secret', 'key');
@@ -1,7 +1,16 @@
 package app
 
-// setupAuth makes sure all permissions are up to date.
-func (k *Kernel) setupAuth() {
-	// Make sure the admin user is always a member of the admin role
-	k.Auth.AddRoleForUser(1, 1)
+import (
+	"context"
+
+	"go-webapp-example/internal/pkg/role"
+)
+
+// ReconcileAuth compares the role assignments of the policies with the role_user table.
+// If repair is true, the policies are changed to match the role_user table.
+func (k *Kernel) ReconcileAuth(ctx context.Context, repair bool) ([]*role.Drift, error) {
+	if repair {
+		return k.services.Role.ReconcileAssignments(ctx)
+	}
+	return k.services.Role.CheckAssignments(ctx)
 }
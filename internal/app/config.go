@@ -1,22 +1,39 @@
 package app
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"go-webapp-example/internal/graphql/gqlallowlist"
+	"go-webapp-example/internal/graphql/gqllimits"
+	"go-webapp-example/internal/pkg/file"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/throttle"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
+// EnvProduction is the app.env value of production installations.
+const EnvProduction = "production"
+
 // LoadConfig loads the global application configuration from a config
 // file or if available from environment variables.
 func LoadConfig() *Config {
 	setDefaults()
 	loadConfig()
 
-	return &Config{
+	config := &Config{
 		App: appConfig{
 			Locale:      viper.GetString("app.locale"),
 			Environment: viper.GetString("app.env"),
+			URL:         viper.GetString("app.url"),
 		},
 		Server: serverConfig{
 			Host:       viper.GetString("server.host"),
@@ -40,21 +57,157 @@ func LoadConfig() *Config {
 			Level: viper.GetString("log.level"),
 			Dir:   viper.GetString("log.dir"),
 		},
+		Auth: authConfig{
+			DevBypass:  viper.GetBool("auth.dev_bypass"),
+			TOTPIssuer: viper.GetString("auth.totp_issuer"),
+			Throttle: throttle.Config{
+				MaxFailures:   viper.GetInt("auth.login_max_failures"),
+				MaxFailuresIP: viper.GetInt("auth.login_max_failures_ip"),
+				Delay:         viper.GetDuration("auth.login_delay"),
+				MaxDelay:      viper.GetDuration("auth.login_max_delay"),
+				Lockout:       viper.GetDuration("auth.login_lockout"),
+			},
+			PasswordResetTTL: viper.GetDuration("auth.password_reset_ttl"),
+			PasswordResetThrottle: throttle.Config{
+				MaxFailures:   viper.GetInt("auth.password_reset_max_requests"),
+				MaxFailuresIP: viper.GetInt("auth.password_reset_max_requests_ip"),
+				Lockout:       viper.GetDuration("auth.password_reset_window"),
+			},
+			PasswordPolicy: passwordpolicy.Config{
+				MinLength:     viper.GetInt("auth.password_min_length"),
+				RequireUpper:  viper.GetBool("auth.password_require_upper"),
+				RequireLower:  viper.GetBool("auth.password_require_lower"),
+				RequireDigit:  viper.GetBool("auth.password_require_digit"),
+				RequireSymbol: viper.GetBool("auth.password_require_symbol"),
+				Blocklist:     viper.GetString("auth.password_blocklist"),
+				History:       viper.GetInt("auth.password_history"),
+				MaxAge:        viper.GetDuration("auth.password_max_age"),
+			},
+		},
+		OIDC: oidcConfig{
+			Enabled:      viper.GetBool("oidc.enabled"),
+			Issuer:       viper.GetString("oidc.issuer"),
+			ClientID:     viper.GetString("oidc.client_id"),
+			ClientSecret: viper.GetString("oidc.client_secret"),
+			RedirectURL:  viper.GetString("oidc.redirect_url"),
+			Scopes:       viper.GetStringSlice("oidc.scopes"),
+			LinkByEmail:  viper.GetBool("oidc.link_by_email"),
+			CreateUsers:  viper.GetBool("oidc.create_users"),
+			GroupsClaim:  viper.GetString("oidc.groups_claim"),
+			GroupRoles:   viper.GetStringMapString("oidc.group_roles"),
+		},
+		LDAP: ldapConfig{
+			Enabled:            viper.GetBool("ldap.enabled"),
+			URL:                viper.GetString("ldap.url"),
+			StartTLS:           viper.GetBool("ldap.start_tls"),
+			CAFile:             viper.GetString("ldap.ca_file"),
+			Timeout:            viper.GetDuration("ldap.timeout"),
+			BindDN:             viper.GetString("ldap.bind_dn"),
+			BindPassword:       viper.GetString("ldap.bind_password"),
+			BaseDN:             viper.GetString("ldap.base_dn"),
+			UserFilter:         viper.GetString("ldap.user_filter"),
+			SubjectAttribute:   viper.GetString("ldap.subject_attribute"),
+			EmailAttribute:     viper.GetString("ldap.email_attribute"),
+			GroupAttribute:     viper.GetString("ldap.group_attribute"),
+			GroupBaseDN:        viper.GetString("ldap.group_base_dn"),
+			GroupFilter:        viper.GetString("ldap.group_filter"),
+			GroupNameAttribute: viper.GetString("ldap.group_name_attribute"),
+			LocalUsers:         viper.GetStringSlice("ldap.local_users"),
+			LinkByEmail:        viper.GetBool("ldap.link_by_email"),
+			CreateUsers:        viper.GetBool("ldap.create_users"),
+			GroupRoles:         viper.GetStringMapString("ldap.group_roles"),
+		},
+		GraphQL: gqllimits.Config{
+			MaxDepth:      viper.GetInt("graphql.max_depth"),
+			MaxComplexity: viper.GetInt("graphql.max_complexity"),
+			MaxAliases:    viper.GetInt("graphql.max_aliases"),
+		},
+		PersistedQueries: gqlallowlist.Config{
+			Mode:     gqlallowlist.Mode(viper.GetString("graphql.persisted_queries.mode")),
+			Manifest: viper.GetString("graphql.persisted_queries.manifest"),
+		},
+		Upload: file.Config{
+			Dir:        viper.GetString("server.storage_dir"),
+			MaxSize:    int64(viper.GetSizeInBytes("upload.max_size")),
+			Extensions: viper.GetStringSlice("upload.extensions"),
+		},
+		Mail: mailConfig{
+			Host:     viper.GetString("mail.host"),
+			Port:     viper.GetString("mail.port"),
+			Username: viper.GetString("mail.username"),
+			Password: viper.GetString("mail.password"),
+			From:     viper.GetString("mail.from"),
+		},
 	}
+	if config.OIDC.RedirectURL == "" {
+		config.OIDC.RedirectURL = config.App.URL + "/backend/login/oidc/callback"
+	}
+	// An empty mode is treated like a missing one, persisted queries stay off.
+	if config.PersistedQueries.Mode == "" {
+		config.PersistedQueries.Mode = gqlallowlist.ModeOff
+	}
+	return config
+}
+
+// Validate makes sure the loaded configuration is safe to run.
+func (c *Config) Validate() error {
+	if c.Auth.DevBypass && c.App.IsProduction() {
+		return errors.New("auth.dev_bypass cannot be enabled if app.env is production")
+	}
+	if c.OIDC.Enabled {
+		if c.OIDC.Issuer == "" || c.OIDC.ClientID == "" {
+			return errors.New("oidc.issuer and oidc.client_id are required if oidc is enabled")
+		}
+		if _, err := roleIDs("oidc", c.OIDC.GroupRoles); err != nil {
+			return err
+		}
+	}
+	if c.LDAP.Enabled {
+		if c.LDAP.URL == "" || c.LDAP.BaseDN == "" || !strings.Contains(c.LDAP.UserFilter, "{username}") {
+			return errors.New("ldap.url, ldap.base_dn and a ldap.user_filter containing {username} are required if ldap is enabled")
+		}
+		if _, err := roleIDs("ldap", c.LDAP.GroupRoles); err != nil {
+			return err
+		}
+		if _, err := c.LDAP.TLSConfig(); err != nil {
+			return err
+		}
+	}
+	if !c.PersistedQueries.Mode.Valid() {
+		return errors.Errorf("graphql.persisted_queries.mode must be one of off, observe or enforce, got %q", c.PersistedQueries.Mode)
+	}
+	if c.PersistedQueries.Mode != gqlallowlist.ModeOff && c.PersistedQueries.Manifest == "" {
+		return errors.New("graphql.persisted_queries.manifest is required if persisted queries are not off")
+	}
+	return nil
 }
 
 // Config represents the global application configuration.
 type Config struct {
-	App      appConfig
-	Server   serverConfig
-	Gets     getsConfig
-	Database dbConfig
-	Log      logConfig
+	App              appConfig
+	Server           serverConfig
+	Gets             getsConfig
+	Database         dbConfig
+	Log              logConfig
+	Auth             authConfig
+	OIDC             oidcConfig
+	LDAP             ldapConfig
+	GraphQL          gqllimits.Config
+	PersistedQueries gqlallowlist.Config
+	Upload           file.Config
+	Mail             mailConfig
 }
 
 type appConfig struct {
 	Environment string
 	Locale      string
+	// URL is the public base url of the application, it is used for links in mails.
+	URL string
+}
+
+// IsProduction returns true if the app runs in a production environment.
+func (a *appConfig) IsProduction() bool {
+	return a.Environment == EnvProduction
 }
 
 type getsConfig struct {
@@ -105,6 +258,105 @@ type logConfig struct {
 	Dir   string
 }
 
+type authConfig struct {
+	// DevBypass runs every request as the admin user. Never use this in production.
+	DevBypass bool
+	// Throttle limits the failed logins per username and client IP.
+	Throttle throttle.Config
+	// TOTPIssuer is the name authenticator apps show for two-factor codes.
+	TOTPIssuer string
+	// PasswordResetTTL is the time a password reset link is valid.
+	PasswordResetTTL time.Duration
+	// PasswordResetThrottle limits the password reset requests per email address and client IP.
+	PasswordResetThrottle throttle.Config
+	// PasswordPolicy contains the rules for new passwords.
+	PasswordPolicy passwordpolicy.Config
+}
+
+type oidcConfig struct {
+	Enabled      bool
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback url registered at the provider. It defaults
+	// to the callback route below app.url.
+	RedirectURL string
+	Scopes      []string
+	// LinkByEmail links unknown accounts to users with the same verified email address.
+	LinkByEmail bool
+	// CreateUsers creates a user on the first login of an unknown account.
+	CreateUsers bool
+	// GroupsClaim is the ID token claim that contains the groups of the user.
+	GroupsClaim string
+	// GroupRoles maps group names to role ids.
+	GroupRoles map[string]string
+}
+
+type ldapConfig struct {
+	Enabled  bool
+	URL      string
+	StartTLS bool
+	// CAFile is a PEM file with the certificates used to verify the server. The
+	// system certificates are used if it is empty.
+	CAFile       string
+	Timeout      time.Duration
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the user entry, {username} is replaced with the username.
+	UserFilter         string
+	SubjectAttribute   string
+	EmailAttribute     string
+	GroupAttribute     string
+	GroupBaseDN        string
+	GroupFilter        string
+	GroupNameAttribute string
+	// LocalUsers are checked against the database instead of the directory.
+	LocalUsers  []string
+	LinkByEmail bool
+	CreateUsers bool
+	// GroupRoles maps group names or DNs to role ids.
+	GroupRoles map[string]string
+}
+
+// TLSConfig returns the TLS settings used to connect to the server.
+func (l *ldapConfig) TLSConfig() (*tls.Config, error) {
+	if l.CAFile == "" {
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(l.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap.ca_file: failed to read certificates")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("ldap.ca_file: no certificates found in %s", l.CAFile)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// roleIDs parses a group to role mapping and returns it with lower case group names.
+func roleIDs(section string, groupRoles map[string]string) (map[string]int, error) {
+	roles := make(map[string]int, len(groupRoles))
+	for group, id := range groupRoles {
+		roleID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, errors.Errorf("%s.group_roles: invalid role id %q for group %q", section, id, group)
+		}
+		roles[strings.ToLower(group)] = roleID
+	}
+	return roles, nil
+}
+
+type mailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// From is the sender address of all outgoing mails.
+	From string
+}
+
 func setDefaults() {
 	env := "develop"
 	if envEnv := os.Getenv("EXAMPLE_ENV"); envEnv != "" {
@@ -113,6 +365,7 @@ func setDefaults() {
 
 	viper.SetDefault("app.locale", "de")
 	viper.SetDefault("app.env", env)
+	viper.SetDefault("app.url", "http://localhost:8888")
 
 	viper.SetDefault("server.host", "")
 	viper.SetDefault("server.port", "80")
@@ -132,6 +385,59 @@ func setDefaults() {
 
 	viper.SetDefault("log.level", "debug")
 	viper.SetDefault("log.dir", "/go-webapp-example/log")
+
+	viper.SetDefault("auth.dev_bypass", false)
+	viper.SetDefault("auth.login_max_failures", 5)
+	viper.SetDefault("auth.login_max_failures_ip", 20)
+	viper.SetDefault("auth.login_delay", "1s")
+	viper.SetDefault("auth.login_max_delay", "30s")
+	viper.SetDefault("auth.login_lockout", "15m")
+	viper.SetDefault("auth.totp_issuer", "Go Webapp Example")
+	viper.SetDefault("auth.password_reset_ttl", "1h")
+	viper.SetDefault("auth.password_reset_max_requests", 3)
+	viper.SetDefault("auth.password_reset_max_requests_ip", 10)
+	viper.SetDefault("auth.password_reset_window", "1h")
+	viper.SetDefault("auth.password_min_length", 10)
+	viper.SetDefault("auth.password_require_upper", false)
+	viper.SetDefault("auth.password_require_lower", false)
+	viper.SetDefault("auth.password_require_digit", false)
+	viper.SetDefault("auth.password_require_symbol", false)
+	viper.SetDefault("auth.password_blocklist", "")
+	viper.SetDefault("auth.password_history", 5)
+	viper.SetDefault("auth.password_max_age", "0")
+
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.redirect_url", "")
+	viper.SetDefault("oidc.scopes", []string{"openid", "email", "profile"})
+	viper.SetDefault("oidc.link_by_email", false)
+	viper.SetDefault("oidc.create_users", false)
+	viper.SetDefault("oidc.groups_claim", "groups")
+
+	viper.SetDefault("ldap.enabled", false)
+	viper.SetDefault("ldap.start_tls", false)
+	viper.SetDefault("ldap.timeout", "10s")
+	viper.SetDefault("ldap.user_filter", "(&(objectClass=person)(uid={username}))")
+	viper.SetDefault("ldap.email_attribute", "mail")
+	viper.SetDefault("ldap.group_attribute", "memberOf")
+	viper.SetDefault("ldap.group_name_attribute", "cn")
+	viper.SetDefault("ldap.local_users", []string{"admin"})
+	viper.SetDefault("ldap.link_by_email", false)
+	viper.SetDefault("ldap.create_users", false)
+
+	viper.SetDefault("graphql.max_depth", 10)
+	viper.SetDefault("graphql.max_complexity", 10000)
+	viper.SetDefault("graphql.max_aliases", 30)
+	viper.SetDefault("graphql.persisted_queries.mode", "off")
+	viper.SetDefault("graphql.persisted_queries.manifest", "")
+
+	viper.SetDefault("upload.max_size", "10MB")
+	viper.SetDefault("upload.extensions", []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".pdf"})
+
+	viper.SetDefault("mail.host", "127.0.0.1")
+	viper.SetDefault("mail.port", "1025")
+	viper.SetDefault("mail.username", "")
+	viper.SetDefault("mail.password", "")
+	viper.SetDefault("mail.from", "noreply@example.com")
 }
 
 func loadConfig() {
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"time"
+
 	"go-webapp-example/internal/daemon"
+	"go-webapp-example/pkg/mail"
 )
 
 // StartDaemons starts all the application's background jobs.
@@ -9,6 +12,13 @@ func (k *Kernel) StartDaemons() {
 	m := daemon.NewManager(k.context.ctx, k.wg, k.Log)
 
 	go m.Start(daemon.NewExampleDaemon())
+	go m.Start(daemon.NewMailer(
+		k.services.Outbox,
+		mail.NewSMTP(k.Config.Mail.Host, k.Config.Mail.Port, k.Config.Mail.Username, k.Config.Mail.Password),
+		k.Config.Mail.From,
+		10*time.Second,
+	))
+	go m.Start(daemon.NewRoleExpiry(k.services.Role, time.Minute))
 
 	if k.Config.Database.Backup {
 		go m.Start(daemon.NewBackup(
@@ -3,18 +3,26 @@ package app
 import (
 	"go-webapp-example/internal/graphql"
 	"go-webapp-example/pkg/router"
+
+	"github.com/pkg/errors"
 )
 
 // setupGraphQL attaches the /query and /graphql-playground to the application.
-func (k *Kernel) setupGraphQL() {
-	query, playground := graphql.New(
+func (k *Kernel) setupGraphQL() error {
+	query, playground, err := graphql.New(
 		k.services,
 		k.Session,
 		k.Auth,
 		k.Log,
 		k.Locale,
 		k.Config.Server.StorageDir,
+		k.Config.Auth.DevBypass,
+		k.Config.GraphQL,
+		k.Config.PersistedQueries,
 	)
+	if err != nil {
+		return errors.Wrap(err, "failed to setup graphql")
+	}
 
 	k.Router.Group(func(r *router.Mux) {
 		r.Handle("/backend/graphql-playground", playground)
@@ -24,4 +32,5 @@ func (k *Kernel) setupGraphQL() {
 		// removed after the appropriate http headers have been set.
 		r.Handle("/ws/backend/query", query)
 	})
+	return nil
 }
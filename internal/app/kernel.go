@@ -11,10 +11,21 @@ import (
 
 	"go-webapp-example/internal/pkg"
 	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/file"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/internal/pkg/ldapauth"
+	"go-webapp-example/internal/pkg/outbox"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/passwordreset"
 	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/internal/pkg/quote"
 	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
 	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
 	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/cache"
 	"go-webapp-example/pkg/db"
@@ -84,6 +95,10 @@ func New() (*Kernel, error) {
 	config := LoadConfig()
 	logger := log.New(os.Stderr, config.Log.Level, config.Log.Dir)
 
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid configuration")
+	}
+
 	database, err := db.New("mysql", config.Database.DSN(), logger.WithPrefix("db"))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to connect to db")
@@ -128,12 +143,16 @@ func New() (*Kernel, error) {
 		return nil, errors.WithStack(err)
 	}
 
-	app.setupServices()
-	app.setupAuth()
+	err = app.setupServices()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	app.setupRouter()
 	app.setupBackendRoutes()
 	app.setupFrontendRoutes()
-	app.setupGraphQL()
+	if err = app.setupGraphQL(); err != nil {
+		return nil, errors.WithStack(err)
+	}
 
 	app.state = StateRunning
 
@@ -156,14 +175,88 @@ func (k *Kernel) setupLogger() error {
 }
 
 // setupServices registers all services.
-func (k *Kernel) setupServices() {
+func (k *Kernel) setupServices() error {
 	k.services.DB = k.DB
 
+	policy, err := passwordpolicy.NewPolicy(k.Config.Auth.PasswordPolicy)
+	if err != nil {
+		return err
+	}
+	k.services.PasswordPolicy = passwordpolicy.NewService(passwordpolicy.NewStore(k.DB), policy)
+
+	k.services.Events = event.NewBroker()
 	k.services.Audit = audit.NewService(audit.NewStore(k.DB), k.Log.WithPrefix("audit"))
-	k.services.Quote = quote.NewService(quote.NewStore(k.DB, k.services.Audit))
-	k.services.User = user.NewService(user.NewStore(k.DB, k.Auth, k.services.Audit), k.Session)
-	k.services.Role = role.NewService(role.NewStore(k.DB, k.Auth))
-	k.services.Permission = permission.NewService(permission.NewStore(k.DB, k.Auth))
+	k.services.Quote = quote.NewService(quote.NewStore(k.DB, k.services.Audit, quote.WithEvents(k.services.Events)))
+	k.services.Session = usersession.NewService(usersession.NewStore(k.DB, k.services.Audit))
+	k.services.User = user.NewService(user.NewStore(k.DB, k.Auth, k.services.Audit, user.WithEvents(k.services.Events)), k.Session, k.services.Session, k.services.PasswordPolicy)
+	k.services.File, err = file.NewService(file.NewStore(k.DB, k.services.Audit), k.Config.Upload)
+	if err != nil {
+		return errors.Wrap(err, "upload.extensions")
+	}
+	registry, err := permission.NewRegistry(user.Permissions, role.Permissions, quote.Permissions, file.Permissions, permission.AuditPermissions)
+	if err != nil {
+		return err
+	}
+	k.services.Permission = permission.NewService(permission.NewStore(k.DB, k.Auth), registry)
+	k.services.Role = role.NewService(role.NewStore(k.DB, k.Auth, registry, k.services.Audit, role.WithEvents(k.services.Events)))
+	k.services.Token = token.NewService(token.NewStore(k.DB, k.services.Audit))
+	k.services.Throttle = throttle.NewService(throttle.NewStore(k.DB), k.services.Audit, k.Config.Auth.Throttle)
+	k.services.TwoFactor = twofactor.NewService(twofactor.NewStore(k.DB, k.services.Audit), k.Config.Auth.TOTPIssuer)
+	k.services.Identity = identity.NewService(identity.NewStore(k.DB, k.services.Audit), k.services.User)
+	if k.Config.LDAP.Enabled {
+		authenticator, err := k.ldapAuthenticator()
+		if err != nil {
+			return err
+		}
+		k.services.User.UseAuthenticator(authenticator)
+	}
+	k.services.Outbox = outbox.NewService(outbox.NewStore(k.DB))
+	k.services.PasswordReset = passwordreset.NewService(
+		passwordreset.NewStore(k.DB),
+		k.services.User,
+		k.services.Outbox,
+		throttle.NewService(throttle.NewStore(k.DB), k.services.Audit, k.Config.Auth.PasswordResetThrottle),
+		k.Locale,
+		passwordreset.Config{URL: k.Config.App.URL, TTL: k.Config.Auth.PasswordResetTTL},
+	)
+	return nil
+}
+
+// ldapAuthenticator returns the authenticator that checks credentials against the directory.
+// Invalid group roles or TLS settings fail the startup instead of being ignored.
+func (k *Kernel) ldapAuthenticator() (*ldapauth.Authenticator, error) {
+	c := k.Config.LDAP
+	roles, err := roleIDs("ldap", c.GroupRoles)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return ldapauth.NewAuthenticator(ldapauth.Config{
+		URL:                c.URL,
+		StartTLS:           c.StartTLS,
+		TLS:                tlsConfig,
+		Timeout:            c.Timeout,
+		BindDN:             c.BindDN,
+		BindPassword:       c.BindPassword,
+		BaseDN:             c.BaseDN,
+		UserFilter:         c.UserFilter,
+		SubjectAttribute:   c.SubjectAttribute,
+		EmailAttribute:     c.EmailAttribute,
+		GroupAttribute:     c.GroupAttribute,
+		GroupBaseDN:        c.GroupBaseDN,
+		GroupFilter:        c.GroupFilter,
+		GroupNameAttribute: c.GroupNameAttribute,
+		LocalUsers:         c.LocalUsers,
+		Identity: identity.Config{
+			Provider:    "ldap",
+			CreateUsers: c.CreateUsers,
+			LinkByEmail: c.LinkByEmail,
+			GroupRoles:  roles,
+		},
+	}, k.services.Identity, k.services.User), nil
 }
 
 // ServeHTTP serves the app using the registered router.
@@ -10,8 +10,12 @@ import (
 	"time"
 
 	"go-webapp-example/internal/pkg/auth"
+	"go-webapp-example/internal/pkg/file"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/pkg/fs"
 	"go-webapp-example/pkg/i18n"
 	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/oidc"
 	"go-webapp-example/pkg/render"
 	"go-webapp-example/pkg/router"
 
@@ -73,7 +77,9 @@ func (k *Kernel) setupFrontendRoutes() {
 		r.UseMiddleware(timeoutMiddleware(30 * time.Second))
 
 		// Serve all static frontend files (Vue, React) directly from the static folder.
-		r.Handle("/backend/storage/*", http.StripPrefix("/backend/storage/", http.FileServer(http.Dir(k.Config.Server.StorageDir))))
+		// Uploaded files are public, but the folders of the storage directory are not listed.
+		storage := fs.FilesOnly(http.Dir(k.Config.Server.StorageDir))
+		r.Handle(file.URLPath+"*", http.StripPrefix(file.URLPath, http.FileServer(storage)))
 		r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir(k.Config.Server.StaticDir))))
 
 		r.HandleFunc("/*", func(w http.ResponseWriter, r *http.Request) {
@@ -97,12 +103,81 @@ func (k *Kernel) setupBackendRoutes() {
 		r.UseMiddleware(timeoutMiddleware(30 * time.Second))
 		r.UseMiddleware(k.Session.Middleware)
 
-		r.Method(http.MethodPost, "/backend/login", auth.LoginHandler(k.services.User, k.services.Permission, k.services.Audit, k.Locale))
-		r.Method(http.MethodPost, "/backend/logout", auth.LogoutHandler(k.Session))
+		r.Method(http.MethodPost, "/backend/login", auth.LoginHandler(
+			k.services.User,
+			k.services.Permission,
+			k.services.Audit,
+			k.services.Throttle,
+			k.services.TwoFactor,
+			k.services.Session,
+			k.Locale,
+			k.Log.WithPrefix("auth.login"),
+		))
+		r.Method(http.MethodPost, "/backend/login/2fa", auth.TwoFactorHandler(
+			k.services.User,
+			k.services.Permission,
+			k.services.Audit,
+			k.services.Throttle,
+			k.services.TwoFactor,
+			k.services.Session,
+			k.Session,
+			k.Locale,
+		))
+		r.Method(http.MethodPost, "/backend/login/password", auth.PasswordChangeHandler(
+			k.services.User,
+			k.services.Permission,
+			k.services.Audit,
+			k.services.Throttle,
+			k.services.Session,
+			k.services.PasswordPolicy,
+			k.Session,
+			k.Locale,
+		))
+		if k.Config.OIDC.Enabled {
+			k.setupOIDCRoutes(r)
+		}
+		r.Method(http.MethodPost, "/backend/logout", auth.LogoutHandler(k.Session, k.services.Session))
 		r.Method(http.MethodGet, "/backend/locale/{locale}", i18n.HandleFunc(k.Config.Server.LocalesDir))
 	})
 }
 
+// setupOIDCRoutes registers the routes of the OpenID Connect login. The role
+// mapping was already checked by Config.Validate.
+func (k *Kernel) setupOIDCRoutes(r *router.Mux) {
+	roles, _ := roleIDs("oidc", k.Config.OIDC.GroupRoles)
+	config := auth.OIDCConfig{
+		Client: oidc.NewClient(oidc.Config{
+			Issuer:       k.Config.OIDC.Issuer,
+			ClientID:     k.Config.OIDC.ClientID,
+			ClientSecret: k.Config.OIDC.ClientSecret,
+			RedirectURL:  k.Config.OIDC.RedirectURL,
+			Scopes:       k.Config.OIDC.Scopes,
+		}, &http.Client{Timeout: 10 * time.Second}),
+		Identity: identity.Config{
+			Provider:    "oidc",
+			CreateUsers: k.Config.OIDC.CreateUsers,
+			LinkByEmail: k.Config.OIDC.LinkByEmail,
+			GroupRoles:  roles,
+		},
+		GroupsClaim: k.Config.OIDC.GroupsClaim,
+		URL:         k.Config.App.URL,
+	}
+	logger := k.Log.WithPrefix("oidc")
+
+	r.Method(http.MethodGet, "/backend/login/oidc", auth.OIDCLoginHandler(config, logger))
+	r.Method(http.MethodGet, "/backend/login/oidc/callback", auth.OIDCCallbackHandler(
+		config,
+		k.services.User,
+		k.services.Identity,
+		k.services.Audit,
+		k.services.Throttle,
+		k.services.TwoFactor,
+		k.services.Session,
+		k.Session,
+		logger,
+	))
+}
+
 // versionHeaderMiddleware adds the current backend version as a HTTP response header.
 func versionHeaderMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
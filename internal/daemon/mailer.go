@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/mail"
+)
+
+// outbox delivers queued mails.
+type outbox interface {
+	Deliver(ctx context.Context, sender mail.Sender, from string) (int, error)
+}
+
+// Mailer daemon sends the mails from the outbox.
+type Mailer struct {
+	outbox   outbox
+	sender   mail.Sender
+	from     string
+	interval time.Duration
+}
+
+// NewMailer returns a new mailer daemon.
+func NewMailer(outbox outbox, sender mail.Sender, from string, interval time.Duration) *Mailer {
+	return &Mailer{
+		outbox:   outbox,
+		sender:   sender,
+		from:     from,
+		interval: interval,
+	}
+}
+
+func (i *Mailer) Name() string { return "mailer" }
+
+// Mailer daemon delivers due mails in a fixed interval.
+func (i *Mailer) Run(ctx context.Context, wg *sync.WaitGroup, logger log.Logger) error {
+	wg.Add(1)
+	defer wg.Done()
+
+	for {
+		select {
+		case <-time.After(i.interval):
+			sent, err := i.outbox.Deliver(ctx, i.sender, i.from)
+			if err != nil {
+				logger.Errorf("mail delivery failed: %s", err)
+				continue
+			}
+			if sent > 0 {
+				logger.Infof("sent %d mails", sent)
+			}
+		case <-ctx.Done():
+			logger.Debug("mailer daemon is shutting down...")
+			return nil
+		}
+	}
+}
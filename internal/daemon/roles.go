@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/pkg/log"
+)
+
+// assignments reconciles the role assignments with the policies.
+type assignments interface {
+	ReconcileAssignments(ctx context.Context) ([]*role.Drift, error)
+}
+
+// RoleExpiry daemon grants roles whose period started and removes expired roles.
+type RoleExpiry struct {
+	assignments assignments
+	interval    time.Duration
+}
+
+// NewRoleExpiry returns a new role expiry daemon.
+func NewRoleExpiry(assignments assignments, interval time.Duration) *RoleExpiry {
+	return &RoleExpiry{
+		assignments: assignments,
+		interval:    interval,
+	}
+}
+
+func (i *RoleExpiry) Name() string { return "roles" }
+
+// RoleExpiry daemon updates the granted roles in a fixed interval.
+func (i *RoleExpiry) Run(ctx context.Context, wg *sync.WaitGroup, logger log.Logger) error {
+	wg.Add(1)
+	defer wg.Done()
+
+	for {
+		select {
+		case <-time.After(i.interval):
+			drift, err := i.assignments.ReconcileAssignments(ctx)
+			if err != nil {
+				logger.Errorf("role assignment update failed: %s", err)
+				continue
+			}
+			for _, d := range drift {
+				logger.Info(d.String())
+			}
+		case <-ctx.Done():
+			logger.Debug("roles daemon is shutting down...")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gqlallowlist
+
+import (
+	"context"
+
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// ErrPersistedQueryNotFound is returned for a hash that is not in the manifest, it is the
+	// same code that is used by automatic persisted queries.
+	ErrPersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+	// ErrOperationNotAllowed is returned for a query that is not in the manifest.
+	ErrOperationNotAllowed = "OPERATION_NOT_ALLOWED"
+)
+
+// Mode defines how operations that are not in the manifest are handled.
+type Mode string
+
+const (
+	// ModeOff runs all operations.
+	ModeOff Mode = "off"
+	// ModeObserve runs all operations, but logs the ones that are not in the manifest.
+	ModeObserve Mode = "observe"
+	// ModeEnforce only runs the operations of the manifest.
+	ModeEnforce Mode = "enforce"
+)
+
+// Valid returns true if the mode is known.
+func (m Mode) Valid() bool {
+	return m == ModeOff || m == ModeObserve || m == ModeEnforce
+}
+
+// Config of the allow-list.
+type Config struct {
+	Mode Mode
+	// Manifest is the path of the manifest, it is required unless the mode is off.
+	Manifest string
+}
+
+// AllowList only runs the operations of a manifest. The queries can be sent by their hash
+// as persisted queries or as the full query text.
+type AllowList struct {
+	mode     Mode
+	manifest Manifest
+	logger   log.Logger
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = &AllowList{}
+
+// New loads the manifest of the config and returns the extension.
+func New(config Config, logger log.Logger) (*AllowList, error) {
+	if config.Mode != ModeObserve && config.Mode != ModeEnforce {
+		return nil, errors.Errorf("invalid persisted query mode %q", config.Mode)
+	}
+	m, err := LoadManifest(config.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithManifest(config.Mode, m, logger), nil
+}
+
+// NewWithManifest returns the extension for an already loaded manifest.
+func NewWithManifest(mode Mode, m Manifest, logger log.Logger) *AllowList {
+	return &AllowList{mode: mode, manifest: m, logger: logger}
+}
+
+// Mode returns the mode of the allow-list.
+func (a *AllowList) Mode() Mode {
+	return a.mode
+}
+
+// ExtensionName returns the name of the extension.
+func (a *AllowList) ExtensionName() string {
+	return "PersistedQueryAllowList"
+}
+
+// Validate is called when the extension is added to the server.
+func (a *AllowList) Validate(es graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters replaces the hash of a persisted query by the query of the manifest
+// and checks that the query is in the manifest.
+func (a *AllowList) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if rawParams.Query == "" {
+		hash, err := persistedQueryHash(rawParams)
+		if err != nil || hash == "" {
+			return err
+		}
+		if query, ok := a.manifest[hash]; ok {
+			rawParams.Query = query
+			return nil
+		}
+		// In observe mode the hash can still be known by automatic persisted queries.
+		if a.mode == ModeObserve {
+			a.operationLogger(ctx, rawParams, hash).Warnf("persisted query would have been blocked, it is not in the manifest")
+			return nil
+		}
+		a.operationLogger(ctx, rawParams, hash).Warnf("blocked persisted query, it is not in the manifest")
+		err = gqlerror.Errorf("PersistedQueryNotFound")
+		errcode.Set(err, ErrPersistedQueryNotFound)
+		return err
+	}
+
+	hash := Hash(rawParams.Query)
+	if _, ok := a.manifest[hash]; ok {
+		return nil
+	}
+	if a.mode == ModeObserve {
+		a.operationLogger(ctx, rawParams, hash).Warnf("operation would have been blocked, it is not in the manifest")
+		return nil
+	}
+	a.operationLogger(ctx, rawParams, hash).Warnf("blocked operation, it is not in the manifest")
+	err := gqlerror.Errorf("operation is not allowed")
+	errcode.Set(err, ErrOperationNotAllowed)
+	return err
+}
+
+// operationLogger returns the logger with the fields of an operation that is not in the manifest.
+func (a *AllowList) operationLogger(ctx context.Context, rawParams *graphql.RawParams, hash string) log.Logger {
+	fields := log.Fields{"operation": rawParams.OperationName, "hash": hash, "mode": a.mode}
+	if u, err := session.UserFromContext(ctx); err == nil {
+		fields["user_id"] = u.ID
+		fields["user"] = u.Name
+	}
+	return a.logger.WithFields(fields)
+}
+
+// persistedQueryHash returns the hash of the persistedQuery extension, it is empty if
+// the extension was not sent.
+func persistedQueryHash(rawParams *graphql.RawParams) (string, *gqlerror.Error) {
+	if rawParams.Extensions["persistedQuery"] == nil {
+		return "", nil
+	}
+	var extension struct {
+		Sha256  string `mapstructure:"sha256Hash"`
+		Version int64  `mapstructure:"version"`
+	}
+	if err := mapstructure.Decode(rawParams.Extensions["persistedQuery"], &extension); err != nil {
+		return "", gqlerror.Errorf("invalid persisted query extension data")
+	}
+	if extension.Version != 1 {
+		return "", gqlerror.Errorf("unsupported persisted query version")
+	}
+	return extension.Sha256, nil
+}
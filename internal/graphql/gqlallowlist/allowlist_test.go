@@ -0,0 +1,166 @@
+package gqlallowlist_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-webapp-example/internal/graphql/gqlallowlist"
+	"go-webapp-example/internal/graphql/gqlserver"
+	"go-webapp-example/pkg/log"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	allowedQuery = `query Allowed { __typename }`
+	blockedQuery = `query Blocked { __typename }`
+)
+
+// response is the decoded body of a GraphQL response.
+type response struct {
+	Data   map[string]interface{}
+	Errors []struct {
+		Message    string
+		Extensions map[string]interface{}
+	}
+}
+
+// code returns the error code of the first error.
+func (r response) code() interface{} {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r.Errors[0].Extensions["code"]
+}
+
+func newServer(mode gqlallowlist.Mode, logger log.Logger) http.Handler {
+	srv := handler.New(gqlserver.NewExecutableSchema(gqlserver.Config{}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(gqlallowlist.NewWithManifest(mode, gqlallowlist.Manifest{gqlallowlist.Hash(allowedQuery): allowedQuery}, logger))
+	if mode != gqlallowlist.ModeEnforce {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New(100)})
+	}
+	return srv
+}
+
+// post sends the query, a hash is sent as persisted query extension.
+func post(t *testing.T, h http.Handler, query, hash string) response {
+	body := map[string]interface{}{"query": query}
+	if hash != "" {
+		body["extensions"] = map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash},
+		}
+	}
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var res response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return res
+}
+
+func TestAllowList_Enforce(t *testing.T) {
+	var logs bytes.Buffer
+	srv := newServer(gqlallowlist.ModeEnforce, log.NewFromWriter(&logs))
+
+	res := post(t, srv, "", gqlallowlist.Hash(allowedQuery))
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, "Query", res.Data["__typename"])
+
+	res = post(t, srv, allowedQuery, "")
+	assert.Empty(t, res.Errors)
+
+	res = post(t, srv, "", gqlallowlist.Hash(blockedQuery))
+	assert.Equal(t, gqlallowlist.ErrPersistedQueryNotFound, res.code())
+	assert.Contains(t, logs.String(), "blocked persisted query")
+
+	// A client can not register a query with automatic persisted queries.
+	res = post(t, srv, blockedQuery, gqlallowlist.Hash(blockedQuery))
+	assert.Equal(t, gqlallowlist.ErrOperationNotAllowed, res.code())
+	assert.Nil(t, res.Data)
+
+	res = post(t, srv, blockedQuery, "")
+	assert.Equal(t, gqlallowlist.ErrOperationNotAllowed, res.code())
+	assert.Contains(t, logs.String(), "blocked operation")
+	assert.Contains(t, logs.String(), "hash="+gqlallowlist.Hash(blockedQuery))
+}
+
+func TestAllowList_Observe(t *testing.T) {
+	var logs bytes.Buffer
+	srv := newServer(gqlallowlist.ModeObserve, log.NewFromWriter(&logs))
+
+	res := post(t, srv, "", gqlallowlist.Hash(allowedQuery))
+	assert.Empty(t, res.Errors)
+	assert.Empty(t, logs.String())
+
+	res = post(t, srv, blockedQuery, "")
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, "Query", res.Data["__typename"])
+	assert.Contains(t, logs.String(), "would have been blocked")
+	assert.Contains(t, logs.String(), "hash="+gqlallowlist.Hash(blockedQuery))
+
+	// Automatic persisted queries still work for queries that are not in the manifest.
+	logs.Reset()
+	res = post(t, srv, "", gqlallowlist.Hash(blockedQuery))
+	assert.Equal(t, "PERSISTED_QUERY_NOT_FOUND", res.code())
+	assert.Contains(t, logs.String(), "persisted query would have been blocked")
+	assert.Contains(t, logs.String(), "hash="+gqlallowlist.Hash(blockedQuery))
+	res = post(t, srv, blockedQuery, gqlallowlist.Hash(blockedQuery))
+	assert.Empty(t, res.Errors)
+	res = post(t, srv, "", gqlallowlist.Hash(blockedQuery))
+	assert.Empty(t, res.Errors)
+}
+
+func TestManifest_Validate(t *testing.T) {
+	schema := gqlserver.NewExecutableSchema(gqlserver.Config{}).Schema()
+	invalid := `query Invalid { unknownField }`
+	m := gqlallowlist.Manifest{
+		gqlallowlist.Hash(allowedQuery): allowedQuery,
+		gqlallowlist.Hash(invalid):      invalid,
+		gqlallowlist.Hash("changed"):    blockedQuery,
+	}
+
+	errs := m.Validate(schema)
+	require.Len(t, errs, 2)
+	messages := errs[0].Error() + "\n" + errs[1].Error()
+	assert.Contains(t, messages, gqlallowlist.Hash("changed")+": the hash does not match the query")
+	assert.Contains(t, messages, gqlallowlist.Hash(invalid)+": input:1: Cannot query field \"unknownField\"")
+
+	assert.Empty(t, gqlallowlist.Manifest{gqlallowlist.Hash(allowedQuery): allowedQuery}.Validate(schema))
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "manifest.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"`+gqlallowlist.Hash(allowedQuery)+`": "`+allowedQuery+`"}`), 0600))
+
+	m, err := gqlallowlist.LoadManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, allowedQuery, m[gqlallowlist.Hash(allowedQuery)])
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[]`), 0600))
+	_, err = gqlallowlist.LoadManifest(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse")
+
+	_, err = gqlallowlist.New(gqlallowlist.Config{Mode: gqlallowlist.ModeEnforce, Manifest: filepath.Join(dir, "missing.json")}, log.NewNullLogger())
+	require.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package gqlallowlist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Manifest maps the sha256 hashes of the allowed operations to their query. It is generated
+// from the frontend build, the queries have to be sent exactly as they are in the manifest.
+type Manifest map[string]string
+
+// Hash returns the hex encoded sha256 hash of a query, as it is sent by persisted query clients.
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadManifest reads a manifest from a JSON file that contains an object of hashes and queries.
+func LoadManifest(path string) (Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read persisted query manifest")
+	}
+	var m Manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse persisted query manifest %s", path)
+	}
+	return m, nil
+}
+
+// Validate returns an error for every operation whose hash does not match its query or
+// whose query is not valid for the schema. The errors are ordered by hash.
+func (m Manifest) Validate(schema *ast.Schema) []error {
+	hashes := make([]string, 0, len(m))
+	for hash := range m {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	var errs []error
+	for _, hash := range hashes {
+		query := m[hash]
+		if Hash(query) != hash {
+			errs = append(errs, errors.Errorf("%s: the hash does not match the query", hash))
+			continue
+		}
+		if _, gqlErrs := gqlparser.LoadQuery(schema, query); gqlErrs != nil {
+			for _, e := range gqlErrs {
+				errs = append(errs, errors.Errorf("%s: %s", hash, e.Error()))
+			}
+		}
+	}
+	return errs
+}
@@ -1,6 +1,8 @@
 //go:generate go run github.com/vektah/dataloaden RoleSliceLoader int []*go-webapp-example/internal/pkg/entity.Role
 //go:generate go run github.com/vektah/dataloaden UserSliceLoader int []*go-webapp-example/internal/pkg/entity.User
 //go:generate go run github.com/vektah/dataloaden PermissionSliceLoader int []*go-webapp-example/internal/pkg/entity.Permission
+//go:generate go run github.com/vektah/dataloaden UserLoader int *go-webapp-example/internal/pkg/entity.User
+//go:generate go run github.com/vektah/dataloaden RoleAssignmentSliceLoader int []*go-webapp-example/internal/pkg/entity.RoleAssignment
 package gqldataloaders
 
 import (
@@ -19,8 +21,15 @@ var ctxKey = ctxKeyType{"userCtx"}
 type Loaders struct {
 	RolesByUser       *RoleSliceLoader
 	PermissionsByUser *PermissionSliceLoader
+	UserPermissions   *PermissionSliceLoader
 	PermissionsByRole *PermissionSliceLoader
 	UsersByRole       *UserSliceLoader
+	UserByID          *UserLoader
+	ParentsByRole     *RoleSliceLoader
+
+	InheritedPermissionsByRole *PermissionSliceLoader
+	RoleAssignmentsByUser      *RoleAssignmentSliceLoader
+	UserAssignmentsByRole      *RoleAssignmentSliceLoader
 }
 
 func Middleware(services *pkg.Services) func(http.Handler) http.Handler {
@@ -74,6 +83,23 @@ func ContextWithLoaders(
 		},
 	}
 
+	// Fetch all permissions that were granted or denied directly for a given slice of user ids.
+	ldrs.UserPermissions = &PermissionSliceLoader{
+		maxBatch: 100,
+		wait:     wait,
+		fetch: func(ids []int) ([][]*entity.Permission, []error) {
+			result := make([][]*entity.Permission, len(ids))
+			items, err := services.Permission.GetDirectByUserID(ctx, ids)
+			if err != nil {
+				return result, []error{err}
+			}
+			for i, key := range ids {
+				result[i] = items[key]
+			}
+			return result, nil
+		},
+	}
+
 	// Fetch all permissions for a given slice of role ids.
 	ldrs.PermissionsByRole = &PermissionSliceLoader{
 		maxBatch: 100,
@@ -91,6 +117,40 @@ func ContextWithLoaders(
 		},
 	}
 
+	// Fetch all inherited permissions for a given slice of role ids.
+	ldrs.InheritedPermissionsByRole = &PermissionSliceLoader{
+		maxBatch: 100,
+		wait:     wait,
+		fetch: func(ids []int) ([][]*entity.Permission, []error) {
+			result := make([][]*entity.Permission, len(ids))
+			items, err := services.Permission.GetInheritedByRoleID(ctx, ids)
+			if err != nil {
+				return result, []error{err}
+			}
+			for i, key := range ids {
+				result[i] = items[key]
+			}
+			return result, nil
+		},
+	}
+
+	// Fetch all parent roles for a given slice of role ids.
+	ldrs.ParentsByRole = &RoleSliceLoader{
+		maxBatch: 100,
+		wait:     wait,
+		fetch: func(ids []int) ([][]*entity.Role, []error) {
+			result := make([][]*entity.Role, len(ids))
+			items, err := services.Role.GetParentsByRoleID(ctx, ids)
+			if err != nil {
+				return result, []error{err}
+			}
+			for i, key := range ids {
+				result[i] = items[key]
+			}
+			return result, nil
+		},
+	}
+
 	// Fetch all users for a given slice of role ids.
 	ldrs.UsersByRole = &UserSliceLoader{
 		maxBatch: 100,
@@ -108,9 +168,84 @@ func ContextWithLoaders(
 		},
 	}
 
+	// Fetch the users for a given slice of user ids.
+	ldrs.UserByID = &UserLoader{
+		maxBatch: 100,
+		wait:     wait,
+		fetch: func(ids []int) ([]*entity.User, []error) {
+			result := make([]*entity.User, len(ids))
+			items, err := services.User.GetByID(ctx, ids)
+			if err != nil {
+				return result, []error{err}
+			}
+			for i, key := range ids {
+				result[i] = items[key]
+			}
+			return result, nil
+		},
+	}
+
+	// Fetch all role assignments for a given slice of user ids.
+	ldrs.RoleAssignmentsByUser = &RoleAssignmentSliceLoader{
+		maxBatch: 100,
+		wait:     wait,
+		fetch: func(ids []int) ([][]*entity.RoleAssignment, []error) {
+			result := make([][]*entity.RoleAssignment, len(ids))
+			items, err := services.Role.GetAssignmentsByUserID(ctx, ids)
+			if err != nil {
+				return result, []error{err}
+			}
+			for i, key := range ids {
+				result[i] = items[key]
+			}
+			return result, nil
+		},
+	}
+
+	// Fetch all user assignments for a given slice of role ids.
+	ldrs.UserAssignmentsByRole = &RoleAssignmentSliceLoader{
+		maxBatch: 100,
+		wait:     wait,
+		fetch: func(ids []int) ([][]*entity.RoleAssignment, []error) {
+			result := make([][]*entity.RoleAssignment, len(ids))
+			items, err := services.Role.GetAssignmentsByRoleID(ctx, ids)
+			if err != nil {
+				return result, []error{err}
+			}
+			for i, key := range ids {
+				result[i] = items[key]
+			}
+			return result, nil
+		},
+	}
+
 	return context.WithValue(ctx, ctxKey, ldrs)
 }
 
 func CtxLoaders(ctx context.Context) Loaders {
 	return ctx.Value(ctxKey).(Loaders)
 }
+
+// Clear removes the relations of an entity from the caches of the loaders. Subscriptions use the
+// same loaders for all changes they send, so an entity is cleared before it is sent again.
+func Clear(ctx context.Context, e entity.Entity) {
+	ldrs, ok := ctx.Value(ctxKey).(Loaders)
+	if !ok {
+		return
+	}
+	id := e.Primary()
+	switch e.Type() {
+	case entity.KindUser:
+		ldrs.UserByID.Clear(id)
+		ldrs.RolesByUser.Clear(id)
+		ldrs.PermissionsByUser.Clear(id)
+		ldrs.UserPermissions.Clear(id)
+		ldrs.RoleAssignmentsByUser.Clear(id)
+	case entity.KindRole:
+		ldrs.PermissionsByRole.Clear(id)
+		ldrs.UsersByRole.Clear(id)
+		ldrs.ParentsByRole.Clear(id)
+		ldrs.InheritedPermissionsByRole.Clear(id)
+		ldrs.UserAssignmentsByRole.Clear(id)
+	}
+}
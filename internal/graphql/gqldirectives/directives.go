@@ -2,47 +2,155 @@ package gqldirectives
 
 import (
 	"context"
-	"strings"
 
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
 	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/session"
 
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/introspection"
 	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 const (
 	// Error codes that are using in the frontend.
 	ErrMissingAuth       = "MISSING_AUTH"
 	ErrMissingPermission = "MISSING_PERMISSION"
+	ErrTwoFactorSetup    = "TWO_FACTOR_SETUP_REQUIRED"
 )
 
+// restrictedDirective is the name of the directive in the schema.
+const restrictedDirective = "restricted"
+
+// permissionRegistry checks permissions against the codes declared by the modules.
+type permissionRegistry interface {
+	Check(p *entity.Permission) error
+}
+
 // RestrictedFn is the "has" directive function.
 type RestrictedFn func(ctx context.Context, obj interface{}, next graphql.Resolver, permissions []string) (res interface{}, err error)
 
 // Restricted checks if the currently authenticated user has a certain permission.
-func Restricted(a *auth.Manager) RestrictedFn {
+// Permissions that were not declared are never granted. On fields of object types
+// a missing permission only resolves the field to null, so these fields have to
+// be nullable in the schema.
+func Restricted(a *auth.Manager, registry permissionRegistry) RestrictedFn {
 	return func(ctx context.Context, obj interface{}, next graphql.Resolver, permissions []string) (res interface{}, err error) {
 		u, err := session.UserFromContext(ctx)
 		if err != nil {
 			return nil, errors.Errorf("auth check: %s: %v", ErrMissingAuth, err)
 		}
 
+		// Users that have to set up two-factor authentication can only access fields
+		// that require no permissions until they enabled it.
+		if len(permissions) > 0 && twofactor.SetupRequiredFromContext(ctx) {
+			return nil, errors.Errorf("auth check: %s", ErrTwoFactorSetup)
+		}
+
+		if err := checkPermissions(ctx, a, registry, u, permissions); err != nil {
+			return nil, err
+		}
+
+		return next(ctx)
+	}
+}
+
+// HideRestrictedFields is a field middleware that removes all fields from the introspection
+// the authenticated user cannot access because a permission of a @restricted directive is missing.
+func HideRestrictedFields(schema *ast.Schema, a *auth.Manager, registry permissionRegistry) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		res, err := next(ctx)
+		fc := graphql.GetFieldContext(ctx)
+		if err != nil || fc.Object != "__Type" || fc.Field.Name != "fields" || fc.Parent == nil {
+			return res, err
+		}
+		fields, ok := res.([]introspection.Field)
+		if !ok {
+			return res, err
+		}
+		parent, ok := fc.Parent.Result.(*introspection.Type)
+		if !ok || parent.Name() == nil || schema.Types[*parent.Name()] == nil {
+			return res, err
+		}
+		def := schema.Types[*parent.Name()]
+		u, userErr := session.UserFromContext(ctx)
+		visible := make([]introspection.Field, 0, len(fields))
+		for _, f := range fields {
+			permissions, restricted := restrictedPermissions(def.Fields.ForName(f.Name))
+			if restricted && (userErr != nil || checkPermissions(ctx, a, registry, u, permissions) != nil) {
+				continue
+			}
+			visible = append(visible, f)
+		}
+		return visible, nil
+	}
+}
+
+// restrictedPermissions returns the permissions of the @restricted directive of a field.
+func restrictedPermissions(field *ast.FieldDefinition) ([]string, bool) {
+	if field == nil {
+		return nil, false
+	}
+	directive := field.Directives.ForName(restrictedDirective)
+	if directive == nil {
+		return nil, false
+	}
+	var permissions []string
+	if arg := directive.Arguments.ForName("permission"); arg != nil && arg.Value != nil {
+		for _, child := range arg.Value.Children {
+			permissions = append(permissions, child.Value.Raw)
+		}
+	}
+	return permissions, true
+}
+
+// checkPermissions returns an error if the user is missing one of the permissions.
+func checkPermissions(ctx context.Context, a *auth.Manager, registry permissionRegistry, u *entity.User, permissions []string) error {
+	// Requests authenticated by a personal access token are limited to the token's permissions.
+	t, hasToken := token.FromContext(ctx)
+
+	for _, p := range permissions {
+		required, err := entity.ParseCodeLevel(p)
+		if err != nil {
+			return errors.Errorf("auth check: %v", err)
+		}
+		if err := registry.Check(required); err != nil {
+			return errors.Errorf("auth check: %v", err)
+		}
+		if hasToken && !t.Allows(required.Code, required.Level) {
+			return errors.Errorf("auth check: %s: %v", ErrMissingPermission, p)
+		}
 		// superusers can do everything.
 		if u.IsSuperuser {
-			return next(ctx)
+			continue
 		}
+		// Permissions limited to own records pass, the resolver has to check the owner of the records.
+		if !a.Can(u.ID, required.Code, required.Level.Action(false)) && !a.Can(u.ID, required.Code, required.Level.Action(true)) {
+			return errors.Errorf("auth check: %s: %v", ErrMissingPermission, p)
+		}
+	}
+	return nil
+}
 
-		for _, p := range permissions {
-			parts := strings.Split(p, "::")
-			if len(parts) != 2 {
-				return nil, errors.Errorf("auth check: invalid permission code, format \"permission.code::level\" expected: %v", p)
-			}
-			if !a.Can(u.ID, parts[0], parts[1]) {
-				return nil, errors.Errorf("auth check: %s: %v", ErrMissingPermission, p)
+// CheckPermissions makes sure all permissions required by @restricted directives of
+// the schema were declared, so a typo cannot lock out all users except superusers.
+func CheckPermissions(schema *ast.Schema, registry permissionRegistry) error {
+	for _, def := range schema.Types {
+		for _, field := range def.Fields {
+			permissions, _ := restrictedPermissions(field)
+			for _, p := range permissions {
+				required, err := entity.ParseCodeLevel(p)
+				if err == nil {
+					err = registry.Check(required)
+				}
+				if err != nil {
+					return errors.Wrapf(err, "invalid @%s directive on %s.%s", restrictedDirective, def.Name, field.Name)
+				}
 			}
 		}
-
-		return next(ctx)
 	}
+	return nil
 }
@@ -0,0 +1,36 @@
+package gqldirectives_test
+
+import (
+	"testing"
+
+	"go-webapp-example/internal/graphql/gqldirectives"
+	"go-webapp-example/internal/graphql/gqlserver"
+	"go-webapp-example/internal/pkg/file"
+	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/internal/pkg/quote"
+	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/internal/pkg/user"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	schema := gqlserver.NewExecutableSchema(gqlserver.Config{}).Schema()
+
+	t.Run("Declared", func(t *testing.T) {
+		registry, err := permission.NewRegistry(user.Permissions, role.Permissions, quote.Permissions, file.Permissions, permission.AuditPermissions)
+		require.NoError(t, err)
+
+		assert.NoError(t, gqldirectives.CheckPermissions(schema, registry))
+	})
+
+	t.Run("Undeclared", func(t *testing.T) {
+		registry, err := permission.NewRegistry(user.Permissions, role.Permissions)
+		require.NoError(t, err)
+
+		err = gqldirectives.CheckPermissions(schema, registry)
+		assert.Equal(t, permission.ErrUnknownCode, errors.Cause(err))
+	})
+}
@@ -0,0 +1,105 @@
+package gqllimits
+
+import (
+	"encoding/json"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// costDirective is the name of the directive in the schema.
+const costDirective = "cost"
+
+// maxInt is the largest complexity, larger values are capped.
+const maxInt = int(^uint(0) >> 1)
+
+// cost is the cost of a field declared by the @cost directive.
+type cost struct {
+	value  int
+	items  int
+	limits []string
+}
+
+// costSchema calculates the complexity of the fields with a @cost directive.
+type costSchema struct {
+	graphql.ExecutableSchema
+	costs map[string]cost
+}
+
+// WithCosts returns the schema with the complexity of the fields declared by @cost directives. The
+// complexity of the other fields is calculated by the schema, by default it is 1 plus the sub fields.
+func WithCosts(es graphql.ExecutableSchema) graphql.ExecutableSchema {
+	costs := make(map[string]cost)
+	for _, def := range es.Schema().Types {
+		for _, field := range def.Fields {
+			d := field.Directives.ForName(costDirective)
+			if d == nil {
+				continue
+			}
+			c := cost{value: 1, items: 1}
+			if v, ok := argValue(d, "value").(int64); ok {
+				c.value = int(v)
+			}
+			if v, ok := argValue(d, "items").(int64); ok {
+				c.items = int(v)
+			}
+			if limits, ok := argValue(d, "limits").([]interface{}); ok {
+				for _, l := range limits {
+					c.limits = append(c.limits, l.(string))
+				}
+			}
+			costs[def.Name+"."+field.Name] = c
+		}
+	}
+	return &costSchema{ExecutableSchema: es, costs: costs}
+}
+
+// Complexity returns the cost of the field plus the complexity of its sub fields multiplied with
+// the number of returned items.
+func (s *costSchema) Complexity(typeName, field string, childComplexity int, args map[string]interface{}) (int, bool) {
+	c, ok := s.costs[typeName+"."+field]
+	if !ok {
+		return s.ExecutableSchema.Complexity(typeName, field, childComplexity, args)
+	}
+	items := c.items
+	for _, name := range c.limits {
+		if n, ok := toInt(args[name]); ok {
+			items = n
+			break
+		}
+	}
+	if items < 0 {
+		items = 0
+	}
+	if items > 0 && childComplexity > (maxInt-c.value)/items {
+		return maxInt, true
+	}
+	return c.value + childComplexity*items, true
+}
+
+// argValue returns the value of a directive argument, it is nil if the argument is not set.
+func argValue(d *ast.Directive, name string) interface{} {
+	arg := d.Arguments.ForName(name)
+	if arg == nil {
+		return nil
+	}
+	v, _ := arg.Value.Value(nil)
+	return v
+}
+
+// toInt returns the value of an Int argument. Literal values are parsed as int64, the values
+// of variables are passed as they were decoded from the request.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	}
+	return 0, false
+}
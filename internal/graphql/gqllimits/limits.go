@@ -0,0 +1,138 @@
+package gqllimits
+
+import (
+	"context"
+	"strings"
+
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// Error codes of rejected operations.
+	ErrDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+	ErrComplexityLimit = "COMPLEXITY_LIMIT_EXCEEDED"
+	ErrAliasLimit      = "ALIAS_LIMIT_EXCEEDED"
+)
+
+// Config contains the limits of an operation, a limit of 0 disables the check.
+type Config struct {
+	// MaxDepth is the deepest nesting of fields. Introspection fields are not counted.
+	MaxDepth int
+	// MaxComplexity is the highest complexity, see WithCosts.
+	MaxComplexity int
+	// MaxAliases is the largest number of aliased fields.
+	MaxAliases int
+}
+
+// Limits rejects operations that exceed the limits before they are executed.
+type Limits struct {
+	config Config
+	logger log.Logger
+	es     graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = &Limits{}
+
+// New returns the extension that enforces the limits of the config.
+func New(config Config, logger log.Logger) *Limits {
+	return &Limits{config: config, logger: logger}
+}
+
+// ExtensionName returns the name of the extension.
+func (l *Limits) ExtensionName() string {
+	return "OperationLimits"
+}
+
+// Validate is called when the extension is added to the server.
+func (l *Limits) Validate(es graphql.ExecutableSchema) error {
+	l.es = es
+	return nil
+}
+
+// MutateOperationContext checks the operation before it is executed.
+func (l *Limits) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	op := rc.Doc.Operations.ForName(rc.OperationName)
+	if op == nil {
+		return nil
+	}
+	if depth := Depth(op.SelectionSet); l.config.MaxDepth > 0 && depth > l.config.MaxDepth {
+		return l.reject(ctx, rc, ErrDepthLimit, "operation has depth %d, which exceeds the limit of %d", depth, l.config.MaxDepth)
+	}
+	if aliases := Aliases(op.SelectionSet); l.config.MaxAliases > 0 && aliases > l.config.MaxAliases {
+		return l.reject(ctx, rc, ErrAliasLimit, "operation has %d aliases, which exceeds the limit of %d", aliases, l.config.MaxAliases)
+	}
+	if l.config.MaxComplexity > 0 {
+		if c := complexity.Calculate(l.es, op, rc.Variables); c > l.config.MaxComplexity {
+			return l.reject(ctx, rc, ErrComplexityLimit, "operation has complexity %d, which exceeds the limit of %d", c, l.config.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+// reject logs a rejected operation together with the user that sent it and returns the error.
+func (l *Limits) reject(ctx context.Context, rc *graphql.OperationContext, code, msg string, args ...interface{}) *gqlerror.Error {
+	err := gqlerror.Errorf(msg, args...)
+	errcode.Set(err, code)
+
+	fields := log.Fields{"operation": rc.OperationName, "code": code}
+	if u, userErr := session.UserFromContext(ctx); userErr == nil {
+		fields["user_id"] = u.ID
+		fields["user"] = u.Name
+	}
+	l.logger.WithFields(fields).Warnf("rejected operation: %s", err.Message)
+	return err
+}
+
+// Depth returns the deepest nesting of fields in the selection set. Fragments
+// do not add a level and introspection fields are not counted.
+func Depth(set ast.SelectionSet) int {
+	var max int
+	for _, selection := range set {
+		var depth int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = 1 + Depth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			depth = Depth(s.Definition.SelectionSet)
+		case *ast.InlineFragment:
+			depth = Depth(s.SelectionSet)
+		}
+		if depth > max {
+			max = depth
+		}
+	}
+	return max
+}
+
+// Aliases returns the number of aliased fields in the selection set. Fragments
+// are counted every time they are spread.
+func Aliases(set ast.SelectionSet) int {
+	var count int
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if s.Alias != "" && s.Alias != s.Name {
+				count++
+			}
+			count += Aliases(s.SelectionSet)
+		case *ast.FragmentSpread:
+			count += Aliases(s.Definition.SelectionSet)
+		case *ast.InlineFragment:
+			count += Aliases(s.SelectionSet)
+		}
+	}
+	return count
+}
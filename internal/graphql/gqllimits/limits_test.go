@@ -0,0 +1,84 @@
+package gqllimits_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go-webapp-example/internal/graphql/gqllimits"
+	"go-webapp-example/internal/graphql/gqlserver"
+	"go-webapp-example/pkg/log"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// nestedQuery selects users of roles of users of roles.
+const nestedQuery = `{
+	roles {
+		edges { node { users { roles { users { name } } } } }
+	}
+}`
+
+func operation(t *testing.T, query string) *ast.OperationDefinition {
+	schema := gqlserver.NewExecutableSchema(gqlserver.Config{}).Schema()
+	doc, errs := gqlparser.LoadQuery(schema, query)
+	require.Nil(t, errs)
+	return doc.Operations[0]
+}
+
+func TestDepth(t *testing.T) {
+	assert.Equal(t, 1, gqllimits.Depth(operation(t, `{ authUser { __typename } }`).SelectionSet))
+	assert.Equal(t, 7, gqllimits.Depth(operation(t, nestedQuery).SelectionSet))
+	assert.Equal(t, 4, gqllimits.Depth(operation(t, `
+		query { authUser { ...roles } }
+		fragment roles on User { roles { ... on Role { users { name } } } }
+	`).SelectionSet))
+	// Introspection fields are not counted.
+	assert.Equal(t, 0, gqllimits.Depth(operation(t, `{ __schema { types { fields { type { ofType { name } } } } } }`).SelectionSet))
+}
+
+func TestAliases(t *testing.T) {
+	assert.Equal(t, 0, gqllimits.Aliases(operation(t, `{ authUser { name } }`).SelectionSet))
+	// The alias of the fragment is counted for both spreads.
+	assert.Equal(t, 4, gqllimits.Aliases(operation(t, `
+		query { a: authUser { ...names } b: authUser { ...names } }
+		fragment names on User { n: name }
+	`).SelectionSet))
+}
+
+func TestWithCosts(t *testing.T) {
+	es := gqllimits.WithCosts(gqlserver.NewExecutableSchema(gqlserver.Config{}))
+	query := `query($first: Int) { roles(first: $first) { edges { node { name users { name } } } } }`
+	op := operation(t, query)
+
+	// users: 1 + 1 * 50, node: 1 + 1 + 51, edges: 1 + 53, roles: 1 + 54 * first
+	assert.Equal(t, 109, complexity.Calculate(es, op, map[string]interface{}{"first": json.Number("2")}))
+	assert.Equal(t, 1+54*20, complexity.Calculate(es, op, nil))
+	// Fields without a cost are counted as 1 plus their sub fields.
+	assert.Equal(t, 2, complexity.Calculate(es, operation(t, `{ authUser { name } }`), nil))
+}
+
+func TestLimits(t *testing.T) {
+	srv := handler.New(gqllimits.WithCosts(gqlserver.NewExecutableSchema(gqlserver.Config{})))
+	srv.AddTransport(transport.POST{})
+	srv.Use(gqllimits.New(gqllimits.Config{MaxDepth: 6, MaxComplexity: 1000, MaxAliases: 2}, log.NewNullLogger()))
+	c := client.New(srv)
+
+	err := c.Post(nestedQuery, &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), gqllimits.ErrDepthLimit)
+
+	err = c.Post(`{ roles { edges { node { users { roles { name } } } } } }`, &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), gqllimits.ErrComplexityLimit)
+
+	err = c.Post(`{ a: authUser { name } b: authUser { name } c: authUser { name } }`, &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), gqllimits.ErrAliasLimit)
+}
@@ -4,14 +4,137 @@ package gqlmodels
 
 import (
 	"fmt"
+	"go-webapp-example/internal/pkg/entity"
 	"io"
 	"strconv"
+	"time"
 )
 
+// Input to assign a role to a user or a user to a role, optionally only for a period of time
+type AssignmentInput struct {
+	ID         int        `json:"id"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// A page of audit logs
+type AuditLogConnection struct {
+	Edges    []*AuditLogEdge `json:"edges"`
+	PageInfo *PageInfo       `json:"pageInfo"`
+	// Number of audit logs on all pages
+	TotalCount int `json:"totalCount"`
+}
+
+// An audit log of a page together with its cursor
+type AuditLogEdge struct {
+	Cursor string           `json:"cursor"`
+	Node   *entity.AuditLog `json:"node"`
+}
+
+// Input to filter audit logs, all fields are optional
+type AuditLogFilter struct {
+	// Matches the audit logs of a kind of entity, e.g. user
+	EntityType *string `json:"entity_type"`
+	EntityID   *int    `json:"entity_id"`
+	// Matches the changes made by a user, including the changes made while impersonating other users
+	UserID  *int       `json:"user_id"`
+	Action  *string    `json:"action"`
+	Field   *string    `json:"field"`
+	Created *TimeRange `json:"created"`
+}
+
+// Input to sort audit logs, the first field has the highest priority
+type AuditLogSort struct {
+	Field     AuditLogSortField `json:"field"`
+	Direction *SortDirection    `json:"direction"`
+}
+
+// Returned once when a token is created
+type CreatedToken struct {
+	Token *entity.Token `json:"token"`
+	// The secret to send as a bearer token, it cannot be retrieved again
+	Secret string `json:"secret"`
+}
+
+// Information about a page of a connection
+type PageInfo struct {
+	HasNextPage     bool `json:"hasNextPage"`
+	HasPreviousPage bool `json:"hasPreviousPage"`
+	// Cursor of the first edge of the page
+	StartCursor *string `json:"startCursor"`
+	// Cursor of the last edge of the page
+	EndCursor *string `json:"endCursor"`
+}
+
+// Input to set a new password using a password reset token
+type PasswordResetInput struct {
+	Token          string `json:"token"`
+	Password       string `json:"password"`
+	PasswordRepeat string `json:"password_repeat"`
+}
+
+// The result of a permission check of the authenticated user
+type PermissionCheck struct {
+	Permission string `json:"permission"`
+	Allowed    bool   `json:"allowed"`
+}
+
+// A permission granted by a role that is revoked by a deny rule
+type PermissionConflict struct {
+	Permission *entity.Permission `json:"permission"`
+	// The user the permission is denied for, empty if it is denied for all users of the role
+	User *entity.User `json:"user"`
+}
+
+// A permission code that can be assigned to a role
+type PermissionDefinition struct {
+	Code string `json:"code"`
+	// Translated label of the permission
+	Label string `json:"label"`
+	// Allowed levels, ordered from the lowest to the highest
+	Levels []string `json:"levels"`
+	// True if the permission can be limited to records created by the user
+	Ownable bool `json:"ownable"`
+}
+
 // Input to define permissions of a role
 type PermissionInput struct {
 	Code  string `json:"code"`
 	Level string `json:"level"`
+	// Limits the permission to records created by the user
+	Own *bool `json:"own"`
+	// Denies the permission even if another role grants it
+	Deny *bool `json:"deny"`
+}
+
+// A quote was created, updated or deleted
+type QuoteChange struct {
+	Action ChangeAction `json:"action"`
+	// Names of the changed fields of an updated quote
+	Fields []string `json:"fields"`
+	// The quote after the change, deleted quotes are sent as they were before
+	Quote *entity.Quote `json:"quote"`
+}
+
+// A page of quotes
+type QuoteConnection struct {
+	Edges    []*QuoteEdge `json:"edges"`
+	PageInfo *PageInfo    `json:"pageInfo"`
+	// Number of quotes on all pages
+	TotalCount int `json:"totalCount"`
+}
+
+// A quote of a page together with its cursor
+type QuoteEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *entity.Quote `json:"node"`
+}
+
+// Input to filter quotes, all fields are optional
+type QuoteFilter struct {
+	// Matches quotes whose author or content contains the value
+	Search  *string    `json:"search"`
+	Created *TimeRange `json:"created"`
 }
 
 // Input to create or update a quote
@@ -21,12 +144,59 @@ type QuoteInput struct {
 	Content string `json:"content"`
 }
 
+// Input to sort quotes, the first field has the highest priority
+type QuoteSort struct {
+	Field     QuoteSortField `json:"field"`
+	Direction *SortDirection `json:"direction"`
+}
+
+// A role was created, updated or deleted
+type RoleChange struct {
+	Action ChangeAction `json:"action"`
+	// Names of the changed fields of an updated role
+	Fields []string `json:"fields"`
+	// The role after the change, deleted roles are sent as they were before
+	Role *entity.Role `json:"role"`
+}
+
+// A page of roles
+type RoleConnection struct {
+	Edges    []*RoleEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+	// Number of roles on all pages
+	TotalCount int `json:"totalCount"`
+}
+
+// A role of a page together with its cursor
+type RoleEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *entity.Role `json:"node"`
+}
+
+// Input to filter roles, all fields are optional
+type RoleFilter struct {
+	// Matches roles whose name contains the value
+	Name    *string    `json:"name"`
+	Created *TimeRange `json:"created"`
+}
+
 // Input to create or update a role
 type RoleInput struct {
-	ID          *int               `json:"id"`
-	Name        string             `json:"name"`
-	Permissions []*PermissionInput `json:"permissions"`
-	Users       []int              `json:"users"`
+	ID   *int   `json:"id"`
+	Name string `json:"name"`
+	// Only superusers can change this setting
+	RequireTwoFactor *bool              `json:"require_two_factor"`
+	Permissions      []*PermissionInput `json:"permissions"`
+	// Users to assign, the id is the id of the user
+	Users []*AssignmentInput `json:"users"`
+	// Roles to inherit all permissions from
+	Parents []int `json:"parents"`
+}
+
+// Input to sort roles, the first field has the highest priority
+type RoleSort struct {
+	Field     RoleSortField  `json:"field"`
+	Direction *SortDirection `json:"direction"`
 }
 
 // Input to update the sort order of an entity
@@ -35,20 +205,275 @@ type SortOrderInput struct {
 	Position int `json:"position"`
 }
 
+// Input to filter a date and time, both ends are optional and inclusive
+type TimeRange struct {
+	From  *time.Time `json:"from"`
+	Until *time.Time `json:"until"`
+}
+
+// Input to create a token
+type TokenInput struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// A subset of the user's permissions in the permission.code::level format
+	Permissions []string `json:"permissions"`
+}
+
+// Returned when a two-factor enrollment is started
+type TwoFactorEnrollment struct {
+	// The base32 encoded secret for manual entry in an authenticator app
+	Secret string `json:"secret"`
+	// The otpauth provisioning URI, usually displayed as QR code
+	URI string `json:"uri"`
+}
+
+// The two-factor authentication state of a user
+type TwoFactorStatus struct {
+	Enabled bool `json:"enabled"`
+	// True if a role of the user makes two-factor authentication mandatory
+	Required bool `json:"required"`
+}
+
 // UploadResult is returned when a file upload succeeded
 type UploadResult struct {
+	ID int `json:"id"`
+	// Name the file was uploaded with
 	Filename string `json:"filename"`
-	Path     string `json:"path"`
+	// Location of the stored file relative to the storage directory
+	Path string `json:"path"`
+	// URL the stored file is served at
+	URL string `json:"url"`
+	// Content type detected from the content of the file
+	MimeType string `json:"mime_type"`
+	// Size of the file in bytes
+	Size int `json:"size"`
+}
+
+// A user was created, updated or deleted
+type UserChange struct {
+	Action ChangeAction `json:"action"`
+	// Names of the changed fields of an updated user
+	Fields []string `json:"fields"`
+	// The user after the change, deleted users are sent as they were before
+	User *entity.User `json:"user"`
+}
+
+// A page of users
+type UserConnection struct {
+	Edges    []*UserEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+	// Number of users on all pages
+	TotalCount int `json:"totalCount"`
+}
+
+// A user of a page together with its cursor
+type UserEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *entity.User `json:"node"`
+}
+
+// Input to filter users, all fields are optional
+type UserFilter struct {
+	// Matches users whose name contains the value
+	Name    *string    `json:"name"`
+	Created *TimeRange `json:"created"`
+	// Matches users that have at least one of the roles
+	RoleIds []int `json:"role_ids"`
 }
 
 // Input to create or update a user
 type UserInput struct {
-	ID             *int   `json:"id"`
-	Name           string `json:"name"`
-	Password       string `json:"password"`
-	PasswordRepeat string `json:"password_repeat"`
-	IsSuperuser    bool   `json:"is_superuser"`
-	Roles          []int  `json:"roles"`
+	ID   *int   `json:"id"`
+	Name string `json:"name"`
+	// Used to send password reset links, must be unique
+	Email          *string `json:"email"`
+	Password       string  `json:"password"`
+	PasswordRepeat string  `json:"password_repeat"`
+	IsSuperuser    bool    `json:"is_superuser"`
+	// Roles to assign, the id is the id of the role
+	Roles []*AssignmentInput `json:"roles"`
+	// Permissions to grant or deny for this user instead of a role
+	Permissions []*PermissionInput `json:"permissions"`
+}
+
+// Input to sort users, the first field has the highest priority
+type UserSort struct {
+	Field     UserSortField  `json:"field"`
+	Direction *SortDirection `json:"direction"`
+}
+
+// Fields audit logs can be sorted by
+type AuditLogSortField string
+
+const (
+	AuditLogSortFieldID        AuditLogSortField = "ID"
+	AuditLogSortFieldCreatedAt AuditLogSortField = "CREATED_AT"
+)
+
+var AllAuditLogSortField = []AuditLogSortField{
+	AuditLogSortFieldID,
+	AuditLogSortFieldCreatedAt,
+}
+
+func (e AuditLogSortField) IsValid() bool {
+	switch e {
+	case AuditLogSortFieldID, AuditLogSortFieldCreatedAt:
+		return true
+	}
+	return false
+}
+
+func (e AuditLogSortField) String() string {
+	return string(e)
+}
+
+func (e *AuditLogSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuditLogSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AuditLogSortField", str)
+	}
+	return nil
+}
+
+func (e AuditLogSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Kinds of changes of an entity
+type ChangeAction string
+
+const (
+	ChangeActionCreated ChangeAction = "CREATED"
+	ChangeActionUpdated ChangeAction = "UPDATED"
+	ChangeActionDeleted ChangeAction = "DELETED"
+)
+
+var AllChangeAction = []ChangeAction{
+	ChangeActionCreated,
+	ChangeActionUpdated,
+	ChangeActionDeleted,
+}
+
+func (e ChangeAction) IsValid() bool {
+	switch e {
+	case ChangeActionCreated, ChangeActionUpdated, ChangeActionDeleted:
+		return true
+	}
+	return false
+}
+
+func (e ChangeAction) String() string {
+	return string(e)
+}
+
+func (e *ChangeAction) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ChangeAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ChangeAction", str)
+	}
+	return nil
+}
+
+func (e ChangeAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Fields quotes can be sorted by
+type QuoteSortField string
+
+const (
+	QuoteSortFieldID        QuoteSortField = "ID"
+	QuoteSortFieldAuthor    QuoteSortField = "AUTHOR"
+	QuoteSortFieldCreatedAt QuoteSortField = "CREATED_AT"
+)
+
+var AllQuoteSortField = []QuoteSortField{
+	QuoteSortFieldID,
+	QuoteSortFieldAuthor,
+	QuoteSortFieldCreatedAt,
+}
+
+func (e QuoteSortField) IsValid() bool {
+	switch e {
+	case QuoteSortFieldID, QuoteSortFieldAuthor, QuoteSortFieldCreatedAt:
+		return true
+	}
+	return false
+}
+
+func (e QuoteSortField) String() string {
+	return string(e)
+}
+
+func (e *QuoteSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = QuoteSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid QuoteSortField", str)
+	}
+	return nil
+}
+
+func (e QuoteSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Fields roles can be sorted by
+type RoleSortField string
+
+const (
+	RoleSortFieldID        RoleSortField = "ID"
+	RoleSortFieldName      RoleSortField = "NAME"
+	RoleSortFieldCreatedAt RoleSortField = "CREATED_AT"
+)
+
+var AllRoleSortField = []RoleSortField{
+	RoleSortFieldID,
+	RoleSortFieldName,
+	RoleSortFieldCreatedAt,
+}
+
+func (e RoleSortField) IsValid() bool {
+	switch e {
+	case RoleSortFieldID, RoleSortFieldName, RoleSortFieldCreatedAt:
+		return true
+	}
+	return false
+}
+
+func (e RoleSortField) String() string {
+	return string(e)
+}
+
+func (e *RoleSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RoleSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RoleSortField", str)
+	}
+	return nil
+}
+
+func (e RoleSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
 // Possible sort directions
@@ -92,3 +517,47 @@ func (e *SortDirection) UnmarshalGQL(v interface{}) error {
 func (e SortDirection) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+// Fields users can be sorted by
+type UserSortField string
+
+const (
+	UserSortFieldID        UserSortField = "ID"
+	UserSortFieldName      UserSortField = "NAME"
+	UserSortFieldCreatedAt UserSortField = "CREATED_AT"
+)
+
+var AllUserSortField = []UserSortField{
+	UserSortFieldID,
+	UserSortFieldName,
+	UserSortFieldCreatedAt,
+}
+
+func (e UserSortField) IsValid() bool {
+	switch e {
+	case UserSortFieldID, UserSortFieldName, UserSortFieldCreatedAt:
+		return true
+	}
+	return false
+}
+
+func (e UserSortField) String() string {
+	return string(e)
+}
+
+func (e *UserSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserSortField", str)
+	}
+	return nil
+}
+
+func (e UserSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,108 @@
+package gqlresolvers
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqldataloaders"
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/db"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// redacted replaces the values of sensitive fields in audit logs.
+const redacted = "[redacted]"
+
+// sensitiveAuditFields contains the fields whose values are never returned, older
+// audit logs may still contain password hashes and secrets.
+var sensitiveAuditFields = map[string]bool{
+	"password": true,
+	"hash":     true,
+	"secret":   true,
+	"key":      true,
+}
+
+type auditLogResolver struct{ *Resolver }
+
+func (r *auditLogResolver) User(ctx context.Context, obj *entity.AuditLog) (*entity.User, error) {
+	if obj.UserID < 1 {
+		return nil, nil
+	}
+	// Users that were deleted in the meantime are returned as nil.
+	return gqldataloaders.CtxLoaders(ctx).UserByID.Load(obj.UserID)
+}
+
+func (r *auditLogResolver) ImpersonatedUserID(ctx context.Context, obj *entity.AuditLog) (*int, error) {
+	return nullIntPtr(obj.ImpersonatedUserID), nil
+}
+
+func (r *auditLogResolver) EntityType(ctx context.Context, obj *entity.AuditLog) (string, error) {
+	return string(obj.EntityType), nil
+}
+
+func (r *auditLogResolver) EntityID(ctx context.Context, obj *entity.AuditLog) (*int, error) {
+	return nullIntPtr(obj.EntityID), nil
+}
+
+func (r *auditLogResolver) CreatedAt(ctx context.Context, obj *entity.AuditLog) (*time.Time, error) {
+	return obj.CreatedAt.Ptr(), nil
+}
+
+// Queries
+
+func (r *queryResolver) AuditLogs(
+	ctx context.Context,
+	first *int,
+	after *string,
+	last *int,
+	before *string,
+	filter *gqlmodels.AuditLogFilter,
+	sort []*gqlmodels.AuditLogSort,
+) (*gqlmodels.AuditLogConnection, error) {
+	var f audit.Filter
+	if filter != nil {
+		if filter.EntityType != nil {
+			f.EntityType = entity.Kind(*filter.EntityType)
+		}
+		if filter.EntityID != nil {
+			f.EntityID = null.IntFrom(int64(*filter.EntityID))
+		}
+		if filter.UserID != nil {
+			f.UserID = null.IntFrom(int64(*filter.UserID))
+		}
+		f.Action = handleStringPtr(filter.Action).String
+		f.Field = handleStringPtr(filter.Field).String
+		f.CreatedFrom, f.CreatedUntil = timeRange(filter.Created)
+	}
+	sorts := make([]db.Sort, len(sort))
+	for i, s := range sort {
+		sorts[i] = toSort(string(s.Field), s.Direction)
+	}
+	logs, info, err := r.Services.Audit.GetPage(ctx, f, sorts, pageArgs(first, after, last, before))
+	if err != nil {
+		return nil, err
+	}
+	conn := &gqlmodels.AuditLogConnection{Edges: make([]*gqlmodels.AuditLogEdge, len(logs)), TotalCount: info.TotalCount}
+	ids := make([]int, len(logs))
+	for i, l := range logs {
+		if sensitiveAuditFields[l.Field] {
+			l.ValueOld, l.ValueNew = redacted, redacted
+		}
+		conn.Edges[i] = &gqlmodels.AuditLogEdge{Cursor: db.EncodeCursor(l.ID), Node: l}
+		ids[i] = l.ID
+	}
+	conn.PageInfo = toPageInfo(info, ids)
+	return conn, nil
+}
+
+// nullIntPtr returns a pointer to the value of a null.Int, it is nil if the value is not set.
+func nullIntPtr(i null.Int) *int {
+	if !i.Valid {
+		return nil
+	}
+	v := int(i.Int64)
+	return &v
+}
@@ -0,0 +1,154 @@
+package gqlresolvers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+type auditLogFields struct {
+	ID   string
+	User *struct {
+		ID   string
+		Name string `json:"name"`
+	}
+	Action     string `json:"action"`
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Field      string `json:"field"`
+	ValueOld   string `json:"value_old"`
+	ValueNew   string `json:"value_new"`
+}
+
+type auditLogsResponse struct {
+	AuditLogs struct {
+		Edges    []struct{ Node auditLogFields }
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   string
+		}
+		TotalCount int
+	}
+}
+
+const auditLogsQuery = `query($first: Int, $after: String, $filter: AuditLogFilter, $sort: [AuditLogSort!]) {
+	auditLogs(first: $first, after: $after, filter: $filter, sort: $sort) {
+		edges { node { id user { id name } action entity_type entity_id field value_old value_new } }
+		pageInfo { hasNextPage endCursor }
+		totalCount
+	}
+}`
+
+func TestGraphQL_AuditLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, _, cleanup := testClient(t)
+	defer cleanup()
+
+	var created struct{ CreateQuote quoteFields }
+	c.MustPost(`mutation { createQuote(input: {author: "Me", content: "Something"}) { id } }`, &created)
+	id, err := strconv.Atoi(created.CreateQuote.ID)
+	require.NoError(t, err)
+	var updated struct{ UpdateQuote quoteFields }
+	c.MustPost(`mutation($id: Int) { updateQuote(input: {id: $id, author: "You", content: "Something"}) { id } }`,
+		&updated, client.Var("id", id))
+
+	quoteFilter := map[string]interface{}{"entity_type": entity.KindQuote, "entity_id": id}
+
+	var resp auditLogsResponse
+	c.MustPost(auditLogsQuery, &resp, client.Var("filter", quoteFilter))
+	require.Len(t, resp.AuditLogs.Edges, 2)
+	assert.Equal(t, 2, resp.AuditLogs.TotalCount)
+	first, second := resp.AuditLogs.Edges[0].Node, resp.AuditLogs.Edges[1].Node
+	assert.Equal(t, "created", first.Action)
+	assert.Equal(t, created.CreateQuote.ID, first.EntityID)
+	if assert.NotNil(t, first.User) {
+		assert.Equal(t, "admin", first.User.Name)
+	}
+	assert.Equal(t, "updated", second.Action)
+	assert.Equal(t, "author", second.Field)
+	assert.Equal(t, "Me", second.ValueOld)
+	assert.Equal(t, "You", second.ValueNew)
+
+	t.Run("Filter", func(t *testing.T) {
+		filter := map[string]interface{}{"entity_type": entity.KindQuote, "entity_id": id, "action": "updated", "field": "author"}
+		c.MustPost(auditLogsQuery, &resp, client.Var("filter", filter))
+		require.Len(t, resp.AuditLogs.Edges, 1)
+		assert.Equal(t, second.ID, resp.AuditLogs.Edges[0].Node.ID)
+
+		var none auditLogsResponse
+		c.MustPost(auditLogsQuery, &none, client.Var("filter", map[string]interface{}{"user_id": 2}))
+		assert.Len(t, none.AuditLogs.Edges, 0)
+
+		c.MustPost(auditLogsQuery, &none, client.Var("filter", map[string]interface{}{
+			"entity_type": entity.KindQuote,
+			"created":     map[string]interface{}{"from": "2000-01-01T00:00:00Z", "until": "2000-12-31T00:00:00Z"},
+		}))
+		assert.Len(t, none.AuditLogs.Edges, 0)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		sort := []map[string]interface{}{{"field": "ID", "direction": "DESC"}}
+		c.MustPost(auditLogsQuery, &resp, client.Var("filter", quoteFilter), client.Var("sort", sort), client.Var("first", 1))
+		require.Len(t, resp.AuditLogs.Edges, 1)
+		assert.Equal(t, second.ID, resp.AuditLogs.Edges[0].Node.ID)
+		assert.True(t, resp.AuditLogs.PageInfo.HasNextPage)
+
+		after := resp.AuditLogs.PageInfo.EndCursor
+		c.MustPost(auditLogsQuery, &resp, client.Var("filter", quoteFilter), client.Var("sort", sort),
+			client.Var("first", 1), client.Var("after", after))
+		require.Len(t, resp.AuditLogs.Edges, 1)
+		assert.Equal(t, first.ID, resp.AuditLogs.Edges[0].Node.ID)
+		assert.False(t, resp.AuditLogs.PageInfo.HasNextPage)
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		c, services, cleanup := testClient(t)
+		defer cleanup()
+
+		c.MustPost(`mutation {
+			updateUser(input: {id: 2, name: "user", password: "Ch4nged-Passw0rd!", password_repeat: "Ch4nged-Passw0rd!", is_superuser: false, roles: []}) { id }
+		}`, &struct{ UpdateUser struct{ ID string } }{})
+		// Logs written before the password was excluded from the diff still contain the hashes.
+		_, err := services.Audit.Create(context.Background(), nil, &entity.AuditLog{
+			EntityType: entity.KindUser, EntityID: null.IntFrom(2), Action: "updated",
+			Field: "password", ValueOld: "$2a$10$old", ValueNew: "$2a$10$new",
+		})
+		require.NoError(t, err)
+
+		var resp auditLogsResponse
+		c.MustPost(auditLogsQuery, &resp, client.Var("filter", map[string]interface{}{"entity_type": entity.KindUser, "entity_id": 2}))
+		require.NotEmpty(t, resp.AuditLogs.Edges)
+		passwords := 0
+		for _, e := range resp.AuditLogs.Edges {
+			assert.NotContains(t, e.Node.ValueOld, "$2a$")
+			assert.NotContains(t, e.Node.ValueNew, "$2a$")
+			if e.Node.Field == "password" {
+				passwords++
+			}
+		}
+		// The password change itself is not logged.
+		assert.Equal(t, 1, passwords)
+		last := resp.AuditLogs.Edges[len(resp.AuditLogs.Edges)-1].Node
+		assert.Equal(t, "password", last.Field)
+		assert.Equal(t, "[redacted]", last.ValueOld)
+	})
+
+	t.Run("Restricted", func(t *testing.T) {
+		c, _, cleanup := testClientAs(t, &entity.User{ID: 2})
+		defer cleanup()
+
+		err := c.Post(auditLogsQuery, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MISSING_PERMISSION")
+	})
+}
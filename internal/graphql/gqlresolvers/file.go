@@ -0,0 +1,30 @@
+package gqlresolvers
+
+import (
+	"context"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/file"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Mutations
+
+func (r *mutationResolver) UploadFile(ctx context.Context, upload graphql.Upload, folder *string) (*gqlmodels.UploadResult, error) {
+	f, err := r.Services.File.Save(ctx, upload.File, upload.Filename, handleStringPtr(folder).String)
+	if err != nil {
+		if errs := file.ValidateUpload(err, r.Services.File.Config()); errs.Failed() {
+			return nil, addErrors(ctx, errs)
+		}
+		return nil, err
+	}
+	return &gqlmodels.UploadResult{
+		ID:       f.ID,
+		Filename: f.Name,
+		Path:     f.Path,
+		URL:      file.URL(f),
+		MimeType: f.MimeType,
+		Size:     int(f.Size),
+	}, nil
+}
@@ -0,0 +1,122 @@
+package gqlresolvers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadMiddleware turns the JSON requests of the test client into multipart requests that
+// upload a file as the file variable, the client cannot send multipart requests itself.
+func uploadMiddleware(t *testing.T, name string, content []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			operations, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			body := &bytes.Buffer{}
+			form := multipart.NewWriter(body)
+			require.NoError(t, form.WriteField("operations", string(operations)))
+			require.NoError(t, form.WriteField("map", `{"0": ["variables.file"]}`))
+			part, err := form.CreateFormFile("0", name)
+			require.NoError(t, err)
+			_, err = part.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, form.Close())
+
+			r.Body = ioutil.NopCloser(body)
+			r.ContentLength = int64(body.Len())
+			r.Header.Set("Content-Type", form.FormDataContentType())
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type uploadResponse struct {
+	UploadFile struct {
+		ID       string
+		Filename string
+		Path     string
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+		Size     int
+	}
+}
+
+const uploadQuery = `mutation($file: Upload!, $folder: String) {
+	uploadFile(file: $file, folder: $folder) { id filename path url mime_type size }
+}`
+
+func TestGraphQL_UploadFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	content := []byte("some notes")
+	c, services, cleanup := testClientAs(t, testAdmin, uploadMiddleware(t, "My Notes.txt", content))
+	defer cleanup()
+
+	var resp uploadResponse
+	c.MustPost(uploadQuery, &resp, client.Var("file", nil), client.Var("folder", "docs"))
+	assert.Equal(t, "My Notes.txt", resp.UploadFile.Filename)
+	assert.Equal(t, "docs/my-notes.txt", resp.UploadFile.Path)
+	assert.Equal(t, "/backend/storage/docs/my-notes.txt", resp.UploadFile.URL)
+	assert.Equal(t, "text/plain", resp.UploadFile.MimeType)
+	assert.Equal(t, len(content), resp.UploadFile.Size)
+
+	stored, err := ioutil.ReadFile(filepath.Join(services.File.Config().Dir, "docs", "my-notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+
+	id, err := strconv.Atoi(resp.UploadFile.ID)
+	require.NoError(t, err)
+	f, err := services.File.Find(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "docs/my-notes.txt", f.Path)
+	assert.Equal(t, int64(testAdmin.ID), f.CreatedBy.Int64)
+
+	// A second upload with the same name does not replace the first file.
+	c.MustPost(uploadQuery, &resp, client.Var("file", nil), client.Var("folder", "docs"))
+	assert.Regexp(t, `^docs/my-notes-[0-9a-f]{8}\.txt$`, resp.UploadFile.Path)
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := []struct {
+			name    string
+			content []byte
+			folder  string
+			message string
+		}{
+			{"image.png", content, "", "validation.file_content"},
+			{"notes.pdf", content, "", "validation.file_extension"},
+			{"notes.txt", bytes.Repeat([]byte("a"), 2<<10), "", "validation.file_size"},
+			{"notes.txt", content, "../..", "validation.folder"},
+		}
+		for _, tc := range cases {
+			c, _, cleanup := testClientAs(t, testAdmin, uploadMiddleware(t, tc.name, tc.content))
+			err := c.Post(uploadQuery, &resp, client.Var("file", nil), client.Var("folder", tc.folder))
+			cleanup()
+			require.Error(t, err, tc.name)
+			assert.Contains(t, err.Error(), tc.message, tc.name)
+		}
+	})
+
+	t.Run("Restricted", func(t *testing.T) {
+		c, _, cleanup := testClientAs(t, &entity.User{ID: 2}, uploadMiddleware(t, "notes.txt", content))
+		defer cleanup()
+
+		err := c.Post(uploadQuery, &resp, client.Var("file", nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MISSING_PERMISSION")
+	})
+}
@@ -0,0 +1,42 @@
+package gqlresolvers
+
+import (
+	"context"
+
+	"go-webapp-example/internal/graphql/gqldirectives"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// ownOnly returns the authenticated user and true if the user can only access own records
+// with a permission. This is the case if the role or the used token limits the permission.
+func (r *Resolver) ownOnly(ctx context.Context, code string, level entity.PermissionLevel) (*entity.User, bool, error) {
+	u, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if t, ok := token.FromContext(ctx); ok && t.OwnOnly(code, level) {
+		return u, true, nil
+	}
+	return u, r.Services.Permission.OwnOnly(u, code, level), nil
+}
+
+// checkOwner returns an error if the authenticated user cannot access a record of the owner.
+func (r *Resolver) checkOwner(ctx context.Context, code string, level entity.PermissionLevel, owner null.Int) error {
+	u, own, err := r.ownOnly(ctx, code, level)
+	if err != nil {
+		return err
+	}
+	allowed := r.Services.Permission.CanAccess(u, code, level, owner)
+	if own {
+		allowed = owner.Valid && int(owner.Int64) == u.ID
+	}
+	if !allowed {
+		return errors.Errorf("auth check: %s: %s", gqldirectives.ErrMissingPermission, entity.Permission{Code: code, Level: level}.CodeLevel())
+	}
+	return nil
+}
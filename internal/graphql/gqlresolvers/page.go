@@ -0,0 +1,41 @@
+package gqlresolvers
+
+import (
+	"strings"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/pkg/db"
+)
+
+// pageArgs returns the arguments of a connection.
+func pageArgs(first *int, after *string, last *int, before *string) db.PageArgs {
+	return db.PageArgs{First: first, After: after, Last: last, Before: before}
+}
+
+// toSort turns the field and direction of a sort input into a sort. The values of
+// the sort field enums are the upper case column names.
+func toSort(field string, direction *gqlmodels.SortDirection) db.Sort {
+	return db.Sort{
+		Column: strings.ToLower(field),
+		Desc:   direction != nil && *direction == gqlmodels.SortDirectionDesc,
+	}
+}
+
+// timeRange returns both ends of a time range, they are nil if they are not set.
+func timeRange(r *gqlmodels.TimeRange) (from, until *time.Time) {
+	if r == nil {
+		return nil, nil
+	}
+	return r.From, r.Until
+}
+
+// toPageInfo returns the page info of a connection whose edges contain the rows with the ids.
+func toPageInfo(info *db.PageInfo, ids []int) *gqlmodels.PageInfo {
+	p := &gqlmodels.PageInfo{HasNextPage: info.HasNextPage, HasPreviousPage: info.HasPreviousPage}
+	if len(ids) > 0 {
+		start, end := db.EncodeCursor(ids[0]), db.EncodeCursor(ids[len(ids)-1])
+		p.StartCursor, p.EndCursor = &start, &end
+	}
+	return p
+}
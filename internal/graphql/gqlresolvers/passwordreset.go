@@ -0,0 +1,50 @@
+package gqlresolvers
+
+import (
+	"context"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/passwordreset"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/pkg/validation"
+)
+
+// Mutations
+
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	if err := passwordreset.ValidateRequest(email); err.Failed() {
+		return false, addErrors(ctx, err)
+	}
+	err := r.Services.PasswordReset.Request(ctx, email, throttle.ClientIPFromContext(ctx))
+	if err == throttle.ErrLocked {
+		errs := validation.NewErrorBag("passwordreset")
+		errs.Add("email", "throttled")
+		return false, addErrors(ctx, errs)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) ResetPassword(ctx context.Context, input gqlmodels.PasswordResetInput) (bool, error) {
+	if err := passwordreset.ValidateResetRequest(&input, r.Services.PasswordPolicy); err.Failed() {
+		return false, addErrors(ctx, err)
+	}
+	_, err := r.Services.PasswordReset.Reset(ctx, input.Token, input.Password)
+	if err == passwordreset.ErrInvalidToken {
+		errs := validation.NewErrorBag("passwordreset")
+		errs.Add("token", "invalid_code")
+		return false, addErrors(ctx, errs)
+	}
+	if err == user.ErrPasswordReused {
+		errs := validation.NewErrorBag("passwordreset")
+		errs.Add("password", "password_reused")
+		return false, addErrors(ctx, errs)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
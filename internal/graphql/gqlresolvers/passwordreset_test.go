@@ -0,0 +1,104 @@
+package gqlresolvers
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"go-webapp-example/internal/pkg/outbox"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQL_PasswordReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	var token string
+
+	t.Run("updateUser email", func(t *testing.T) {
+		var resp struct {
+			UpdateUser struct {
+				Email string
+			}
+		}
+		c.MustPost(`
+			mutation {
+				updateUser(input: {
+					id: 1
+					name: "admin"
+					email: "admin@example.com"
+					password: ""
+					password_repeat: ""
+					is_superuser: true
+				}) { email }
+			}`, &resp)
+
+		assert.Equal(t, "admin@example.com", resp.UpdateUser.Email)
+	})
+
+	t.Run("requestPasswordReset", func(t *testing.T) {
+		var resp struct {
+			RequestPasswordReset bool
+		}
+		query := `mutation Request($email: String!) { requestPasswordReset(email: $email) }`
+
+		// Unknown addresses must not be revealed.
+		c.MustPost(query, &resp, client.Var("email", "unknown@example.com"))
+		assert.True(t, resp.RequestPasswordReset)
+
+		c.MustPost(query, &resp, client.Var("email", "admin@example.com"))
+		assert.True(t, resp.RequestPasswordReset)
+
+		mails, err := services.Outbox.GetDue(context.Background(), outbox.MaxAttempts, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, mails, 1) {
+			assert.Equal(t, "admin@example.com", mails[0].Recipient)
+			link, err := url.Parse(mails[0].Body)
+			assert.NoError(t, err)
+			token = link.Query().Get("token")
+		}
+		assert.NotEmpty(t, token)
+	})
+
+	t.Run("resetPassword", func(t *testing.T) {
+		var resp struct {
+			ResetPassword bool
+		}
+		query := `mutation Reset($token: String!) {
+			resetPassword(input: { token: $token, password: "changed", password_repeat: "changed" })
+		}`
+
+		err := c.Post(query, &resp, client.Var("token", "invalid"))
+		assert.Error(t, err)
+
+		c.MustPost(query, &resp, client.Var("token", token))
+		assert.True(t, resp.ResetPassword)
+
+		_, err = services.User.Authenticate(context.Background(), "admin", "changed")
+		assert.NoError(t, err)
+
+		// The token can only be used once.
+		err = c.Post(query, &resp, client.Var("token", token))
+		assert.Error(t, err)
+	})
+
+	t.Run("requestPasswordReset throttled", func(t *testing.T) {
+		var resp struct {
+			RequestPasswordReset bool
+		}
+		query := `mutation Request($email: String!) { requestPasswordReset(email: $email) }`
+
+		// The test client allows two requests per address, known or not.
+		c.MustPost(query, &resp, client.Var("email", "unknown@example.com"))
+		err := c.Post(query, &resp, client.Var("email", "Unknown@example.com"))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "validation.throttled")
+		}
+	})
+}
@@ -7,15 +7,61 @@ import (
 	"go-webapp-example/internal/pkg/entity"
 	"go-webapp-example/internal/pkg/quote"
 	"go-webapp-example/pkg/db"
+
+	"gopkg.in/guregu/null.v3"
 )
 
+// permissionQuote is the permission code of the quote module.
+const permissionQuote = "admin.quote"
+
 // Queries
 
-func (r *queryResolver) Quotes(ctx context.Context) ([]*entity.Quote, error) {
-	return r.Services.Quote.Get(ctx)
+func (r *queryResolver) Quotes(
+	ctx context.Context,
+	first *int,
+	after *string,
+	last *int,
+	before *string,
+	filter *gqlmodels.QuoteFilter,
+	sort []*gqlmodels.QuoteSort,
+) (*gqlmodels.QuoteConnection, error) {
+	u, own, err := r.ownOnly(ctx, permissionQuote, entity.PermissionLevelRead)
+	if err != nil {
+		return nil, err
+	}
+	var f quote.Filter
+	if own {
+		f.CreatedBy = null.IntFrom(int64(u.ID))
+	}
+	if filter != nil {
+		if filter.Search != nil {
+			f.Search = *filter.Search
+		}
+		f.CreatedFrom, f.CreatedUntil = timeRange(filter.Created)
+	}
+	sorts := make([]db.Sort, len(sort))
+	for i, s := range sort {
+		sorts[i] = toSort(string(s.Field), s.Direction)
+	}
+	quotes, info, err := r.Services.Quote.GetPage(ctx, f, sorts, pageArgs(first, after, last, before))
+	if err != nil {
+		return nil, err
+	}
+	conn := &gqlmodels.QuoteConnection{Edges: make([]*gqlmodels.QuoteEdge, len(quotes)), TotalCount: info.TotalCount}
+	ids := make([]int, len(quotes))
+	for i, q := range quotes {
+		conn.Edges[i] = &gqlmodels.QuoteEdge{Cursor: db.EncodeCursor(q.ID), Node: q}
+		ids[i] = q.ID
+	}
+	conn.PageInfo = toPageInfo(info, ids)
+	return conn, nil
 }
 func (r *queryResolver) Quote(ctx context.Context, id int) (*entity.Quote, error) {
-	return r.Services.Quote.Find(ctx, id)
+	q, err := r.Services.Quote.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return q, r.checkOwner(ctx, permissionQuote, entity.PermissionLevelRead, q.CreatedBy)
 }
 
 // Mutations
@@ -31,10 +77,26 @@ func (r *mutationResolver) UpdateQuote(ctx context.Context, input gqlmodels.Quot
 	if err := quote.ValidateUpdateRequest(&input); err.Failed() {
 		return nil, addErrors(ctx, err)
 	}
+	current, err := r.Services.Quote.Find(ctx, handleIntPtr(input.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkOwner(ctx, permissionQuote, entity.PermissionLevelWrite, current.CreatedBy); err != nil {
+		return nil, err
+	}
 	return r.Services.Quote.Update(ctx, toQuoteEntity(input))
 }
 
 func (r *mutationResolver) DeleteQuote(ctx context.Context, ids []int) ([]*entity.Quote, error) {
+	quotes, err := r.Services.Quote.GetByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range quotes {
+		if err := r.checkOwner(ctx, permissionQuote, entity.PermissionLevelManage, q.CreatedBy); err != nil {
+			return nil, err
+		}
+	}
 	tx, err := r.Services.DB.Begin()
 	if err != nil {
 		return nil, err
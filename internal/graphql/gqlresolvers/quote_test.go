@@ -4,11 +4,15 @@ import (
 	"context"
 	"strconv"
 	"testing"
+	"time"
 
 	"go-webapp-example/internal/pkg"
+	"go-webapp-example/internal/pkg/entity"
 
 	"github.com/99designs/gqlgen/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 )
 
 type quoteFields struct {
@@ -35,23 +39,29 @@ func TestGraphQL_Quote(t *testing.T) {
 func testQuotesQuery(c *client.Client) func(t *testing.T) {
 	return func(t *testing.T) {
 		var resp struct {
-			Quotes []quoteFields
+			Quotes struct {
+				Edges []struct{ Node quoteFields }
+			}
 		}
 
 		err := c.Post(`
 			query quotes {
 				  quotes {
-					id
-					author
-					content
+					edges {
+						node {
+							id
+							author
+							content
+						}
+					}
 			    }
 			}`, &resp)
 
 		assert.NoError(t, err)
-		assert.Len(t, resp.Quotes, 2)
+		assert.Len(t, resp.Quotes.Edges, 2)
 
-		if len(resp.Quotes) > 0 {
-			checkQuotesResponse(t, resp.Quotes[0])
+		if len(resp.Quotes.Edges) > 0 {
+			checkQuotesResponse(t, resp.Quotes.Edges[0].Node)
 		}
 	}
 }
@@ -167,3 +177,161 @@ func testDeleteQuote(c *client.Client, services *pkg.Services) func(t *testing.T
 		assert.Error(t, err)
 	}
 }
+
+// TestGraphQL_QuoteOwnership runs as a user that can only manage own quotes.
+// nolint:funlen
+func TestGraphQL_QuoteOwnership(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClientAs(t, &entity.User{Name: "user", ID: 2})
+	defer cleanup()
+
+	_, err := services.Role.SyncUsers(context.Background(), &entity.Role{ID: 2}, []int{2})
+	require.NoError(t, err)
+	_, err = services.Role.SyncPermissions(context.Background(), &entity.Role{ID: 2}, []*entity.Permission{
+		{Code: "admin.quote", Level: entity.PermissionLevelManage, Own: true},
+	})
+	require.NoError(t, err)
+
+	var created struct {
+		CreateQuote quoteFields
+	}
+	c.MustPost(`mutation { createQuote(input: { author: "Own", content: "Own quote" }) { id } }`, &created)
+
+	id, _ := strconv.Atoi(created.CreateQuote.ID)
+	own, err := services.Quote.Find(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(2), own.CreatedBy)
+
+	t.Run("Quotes Query", func(t *testing.T) {
+		var resp struct {
+			Quotes struct {
+				Edges []struct{ Node quoteFields }
+			}
+		}
+
+		err := c.Post(`query { quotes { edges { node { id } } } }`, &resp)
+
+		assert.NoError(t, err)
+		if assert.Len(t, resp.Quotes.Edges, 1) {
+			assert.Equal(t, created.CreateQuote.ID, resp.Quotes.Edges[0].Node.ID)
+		}
+	})
+
+	t.Run("Quote Query", func(t *testing.T) {
+		var resp struct {
+			Quote quoteFields
+		}
+
+		assert.NoError(t, c.Post(`query { quote(id: `+created.CreateQuote.ID+`) { id } }`, &resp))
+		assert.Error(t, c.Post(`query { quote(id: 1) { id } }`, &resp))
+	})
+
+	t.Run("updateQuote", func(t *testing.T) {
+		var resp struct {
+			UpdateQuote quoteFields
+		}
+
+		err := c.Post(`mutation { updateQuote(input: { id: `+created.CreateQuote.ID+`, author: "Own", content: "Updated" }) { id } }`, &resp)
+		assert.NoError(t, err)
+
+		err = c.Post(`mutation { updateQuote(input: { id: 1, author: "Other", content: "Updated" }) { id } }`, &resp)
+		assert.Error(t, err)
+
+		updated, err := services.Quote.Find(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, null.IntFrom(2), updated.CreatedBy)
+	})
+
+	t.Run("deleteQuote", func(t *testing.T) {
+		var resp struct {
+			DeleteQuote []quoteFields
+		}
+
+		err := c.Post(`mutation { deleteQuote(id: [1, `+created.CreateQuote.ID+`]) { id } }`, &resp)
+		assert.Error(t, err)
+
+		_, err = services.Quote.Find(context.Background(), 1)
+		assert.NoError(t, err)
+
+		err = c.Post(`mutation { deleteQuote(id: [`+created.CreateQuote.ID+`]) { id } }`, &resp)
+		assert.NoError(t, err)
+	})
+}
+
+func TestGraphQL_QuoteChanged(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	type change struct {
+		QuoteChanged struct {
+			Action string
+			Fields []string
+			Quote  quoteFields
+		} `json:"quoteChanged"`
+	}
+	// subscribe starts a subscription and waits until the resolver receives changes.
+	subscribe := func(query string) *client.Subscription {
+		open := services.Events.Subscribers(entity.KindQuote)
+		sub := c.Websocket(query)
+		require.Eventually(t, func() bool {
+			return services.Events.Subscribers(entity.KindQuote) > open
+		}, time.Second, 10*time.Millisecond)
+		return sub
+	}
+
+	sub := subscribe(`subscription { quoteChanged { action fields quote { id author content } } }`)
+	defer sub.Close()
+
+	var created struct{ CreateQuote quoteFields }
+	c.MustPost(`mutation { createQuote(input: { author: "Author", content: "Content" }) { id } }`, &created)
+
+	var resp change
+	require.NoError(t, sub.Next(&resp))
+	assert.Equal(t, "CREATED", resp.QuoteChanged.Action)
+	assert.Equal(t, created.CreateQuote.ID, resp.QuoteChanged.Quote.ID)
+	assert.Empty(t, resp.QuoteChanged.Fields)
+
+	single := subscribe(`subscription { quoteChanged(id: 1) { action fields quote { id author } } }`)
+	defer single.Close()
+
+	var updated struct{ UpdateQuote quoteFields }
+	id, _ := strconv.Atoi(created.CreateQuote.ID)
+	c.MustPost(`mutation($id: Int) { updateQuote(input: { id: $id, author: "Other", content: "Content" }) { id } }`,
+		&updated, client.Var("id", id))
+	c.MustPost(`mutation { updateQuote(input: { id: 1, author: "A author", content: "Changed" }) { id } }`, &updated)
+
+	require.NoError(t, sub.Next(&resp))
+	assert.Equal(t, "UPDATED", resp.QuoteChanged.Action)
+	assert.Equal(t, []string{"author"}, resp.QuoteChanged.Fields)
+	assert.Equal(t, "Other", resp.QuoteChanged.Quote.Author)
+
+	// The subscription of quote 1 skips the first update.
+	require.NoError(t, single.Next(&resp))
+	assert.Equal(t, "1", resp.QuoteChanged.Quote.ID)
+	assert.Equal(t, []string{"content"}, resp.QuoteChanged.Fields)
+
+	var deleted struct{ DeleteQuote []quoteFields }
+	c.MustPost(`mutation($id: [ID!]!) { deleteQuote(id: $id) { id } }`, &deleted, client.Var("id", []int{id}))
+	require.NoError(t, sub.Next(&resp)) // The update of quote 1.
+	require.NoError(t, sub.Next(&resp))
+	assert.Equal(t, "DELETED", resp.QuoteChanged.Action)
+	assert.Equal(t, created.CreateQuote.ID, resp.QuoteChanged.Quote.ID)
+
+	t.Run("Restricted", func(t *testing.T) {
+		c, _, cleanup := testClientAs(t, &entity.User{ID: 2})
+		defer cleanup()
+
+		sub := c.Websocket(`subscription { quoteChanged { action } }`)
+		defer sub.Close()
+		err := sub.Next(&resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MISSING_PERMISSION")
+	})
+}
@@ -2,12 +2,20 @@ package gqlresolvers
 
 import (
 	"context"
+	"time"
 
 	"go-webapp-example/internal/graphql/gqldataloaders"
 	"go-webapp-example/internal/graphql/gqlmodels"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/pkg/db"
+	"go-webapp-example/pkg/i18n"
 	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
 )
 
 type roleResolver struct{ *Resolver }
@@ -20,81 +28,263 @@ func (r *roleResolver) Permissions(ctx context.Context, obj *entity.Role) ([]*en
 	return gqldataloaders.CtxLoaders(ctx).PermissionsByRole.Load(obj.ID)
 }
 
+func (r *roleResolver) InheritedPermissions(ctx context.Context, obj *entity.Role) ([]*entity.Permission, error) {
+	return gqldataloaders.CtxLoaders(ctx).InheritedPermissionsByRole.Load(obj.ID)
+}
+
+func (r *roleResolver) Conflicts(ctx context.Context, obj *entity.Role) ([]*gqlmodels.PermissionConflict, error) {
+	conflicts := r.Services.Permission.GetConflictsForRoleID(ctx, obj.ID)
+	var userIDs []int
+	for _, c := range conflicts {
+		if c.UserID > 0 {
+			userIDs = append(userIDs, c.UserID)
+		}
+	}
+	users := make(map[int]*entity.User)
+	if len(userIDs) > 0 {
+		var err error
+		if users, err = r.Services.User.GetByID(ctx, userIDs); err != nil {
+			return nil, err
+		}
+	}
+	ret := make([]*gqlmodels.PermissionConflict, len(conflicts))
+	for i, c := range conflicts {
+		ret[i] = &gqlmodels.PermissionConflict{Permission: c.Permission, User: users[c.UserID]}
+	}
+	return ret, nil
+}
+
+func (r *roleResolver) Parents(ctx context.Context, obj *entity.Role) ([]*entity.Role, error) {
+	return gqldataloaders.CtxLoaders(ctx).ParentsByRole.Load(obj.ID)
+}
+
+func (r *roleResolver) UserAssignments(ctx context.Context, obj *entity.Role) ([]*entity.RoleAssignment, error) {
+	return gqldataloaders.CtxLoaders(ctx).UserAssignmentsByRole.Load(obj.ID)
+}
+
+type roleAssignmentResolver struct{ *Resolver }
+
+func (r *roleAssignmentResolver) User(ctx context.Context, obj *entity.RoleAssignment) (*entity.User, error) {
+	return r.Services.User.Find(ctx, obj.UserID)
+}
+
+func (r *roleAssignmentResolver) Role(ctx context.Context, obj *entity.RoleAssignment) (*entity.Role, error) {
+	return r.Services.Role.Find(ctx, obj.RoleID)
+}
+
+func (r *roleAssignmentResolver) ValidFrom(ctx context.Context, obj *entity.RoleAssignment) (*time.Time, error) {
+	return obj.ValidFrom.Ptr(), nil
+}
+
+func (r *roleAssignmentResolver) ValidUntil(ctx context.Context, obj *entity.RoleAssignment) (*time.Time, error) {
+	return obj.ValidUntil.Ptr(), nil
+}
+
 type permissionResolver struct{ *Resolver }
 
 func (r *permissionResolver) Level(ctx context.Context, obj *entity.Permission) (string, error) {
 	return string(obj.Level), nil
 }
 
-// Queries
+type policyMatchResolver struct{ *Resolver }
 
-func (r *queryResolver) Roles(ctx context.Context) ([]*entity.Role, error) {
-	var filtered []*entity.Role
-	roles, err := r.Services.Role.Get(ctx)
+func (r *policyMatchResolver) Roles(ctx context.Context, obj *entity.PolicyMatch) ([]*entity.Role, error) {
+	if len(obj.RoleIDs) == 0 {
+		return []*entity.Role{}, nil
+	}
+	roles, err := r.Services.Role.GetByID(ctx, obj.RoleIDs)
 	if err != nil {
-		return filtered, err
+		return nil, err
+	}
+	chain := make([]*entity.Role, 0, len(obj.RoleIDs))
+	for _, id := range obj.RoleIDs {
+		if role, ok := roles[id]; ok {
+			chain = append(chain, role)
+		}
 	}
+	return chain, nil
+}
+
+// Queries
+
+func (r *queryResolver) Roles(
+	ctx context.Context,
+	first *int,
+	after *string,
+	last *int,
+	before *string,
+	filter *gqlmodels.RoleFilter,
+	sort []*gqlmodels.RoleSort,
+) (*gqlmodels.RoleConnection, error) {
 	authUser, err := session.UserFromContext(ctx)
 	if err != nil {
-		return filtered, err
+		return nil, err
 	}
-	// Return all roles if a super user is logged in. Otherwise, remove
-	// other admin rule from the result.
-	if authUser.IsSuperuser {
-		return roles, nil
+	// The admin role is only returned if a superuser is logged in.
+	var f role.Filter
+	if !authUser.IsSuperuser {
+		f.ExcludeIDs = []int{1}
 	}
-	for _, u := range roles {
-		if u.ID > 1 {
-			filtered = append(filtered, u)
+	if filter != nil {
+		if filter.Name != nil {
+			f.Name = *filter.Name
 		}
+		f.CreatedFrom, f.CreatedUntil = timeRange(filter.Created)
+	}
+	sorts := make([]db.Sort, len(sort))
+	for i, s := range sort {
+		sorts[i] = toSort(string(s.Field), s.Direction)
+	}
+	roles, info, err := r.Services.Role.GetPage(ctx, f, sorts, pageArgs(first, after, last, before))
+	if err != nil {
+		return nil, err
+	}
+	conn := &gqlmodels.RoleConnection{Edges: make([]*gqlmodels.RoleEdge, len(roles)), TotalCount: info.TotalCount}
+	ids := make([]int, len(roles))
+	for i, e := range roles {
+		conn.Edges[i] = &gqlmodels.RoleEdge{Cursor: db.EncodeCursor(e.ID), Node: e}
+		ids[i] = e.ID
 	}
-	return filtered, nil
+	conn.PageInfo = toPageInfo(info, ids)
+	return conn, nil
 }
 
 func (r *queryResolver) Role(ctx context.Context, id int) (*entity.Role, error) {
 	return r.Services.Role.Find(ctx, id)
 }
 
+func (r *queryResolver) PermissionCatalog(ctx context.Context) ([]*gqlmodels.PermissionDefinition, error) {
+	locale := i18n.CtxLocale(ctx)
+	definitions := r.Services.Permission.Definitions()
+	catalog := make([]*gqlmodels.PermissionDefinition, len(definitions))
+	for i, d := range definitions {
+		levels := make([]string, len(d.Levels))
+		for j, l := range d.Levels {
+			levels[j] = string(l)
+		}
+		catalog[i] = &gqlmodels.PermissionDefinition{
+			Code:    d.Code,
+			Label:   locale.Get(d.Label),
+			Levels:  levels,
+			Ownable: d.Ownable,
+		}
+	}
+	return catalog, nil
+}
+
+func (r *queryResolver) ExplainPermission(ctx context.Context, userID int, p string) (*entity.PermissionExplanation, error) {
+	required, err := r.parsePermission(p)
+	if err != nil {
+		return nil, err
+	}
+	u, err := r.Services.User.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return r.Services.Permission.Explain(u, required), nil
+}
+
+func (r *queryResolver) Can(ctx context.Context, permissions []string) ([]*gqlmodels.PermissionCheck, error) {
+	u, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, hasToken := token.FromContext(ctx)
+	setupRequired := twofactor.SetupRequiredFromContext(ctx)
+
+	checks := make([]*gqlmodels.PermissionCheck, len(permissions))
+	for i, p := range permissions {
+		required, err := r.parsePermission(p)
+		if err != nil {
+			return nil, err
+		}
+		allowed := !setupRequired && r.Services.Permission.Granted(u, required)
+		if hasToken && !t.Allows(required.Code, required.Level) {
+			allowed = false
+		}
+		checks[i] = &gqlmodels.PermissionCheck{Permission: p, Allowed: allowed}
+	}
+	return checks, nil
+}
+
+// parsePermission parses a permission in the code::level format and makes sure it was declared.
+func (r *queryResolver) parsePermission(p string) (*entity.Permission, error) {
+	required, err := entity.ParseCodeLevel(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Services.Permission.Check(required); err != nil {
+		return nil, errors.Wrapf(err, "invalid permission %s", p)
+	}
+	return required, nil
+}
+
 // Mutations
 
 func (r *mutationResolver) CreateRole(ctx context.Context, input gqlmodels.RoleInput) (*entity.Role, error) {
-	if err := role.ValidateCreateRequest(&input); err.Failed() {
+	if err := role.ValidateCreateRequest(&input, r.Services.Permission); err.Failed() {
 		return nil, addErrors(ctx, err)
 	}
-	u, err := r.Services.Role.Create(ctx, toRoleEntity(input))
+	e := toRoleEntity(input)
+	if err := checkRequireTwoFactor(ctx, false, e.RequireTwoFactor); err != nil {
+		return nil, err
+	}
+	u, err := r.Services.Role.Create(ctx, e)
 	if err != nil {
 		return nil, err
 	}
 	if input.Permissions != nil {
-		u, err = r.Services.Role.SyncPermissions(ctx, u, mapPermissions(input.Permissions))
+		u, err = r.Services.Role.SyncPermissions(ctx, u, permission.FromInput(input.Permissions))
 		if err != nil {
-			return nil, err
+			return nil, grantErrors(ctx, "role", "permissions", err)
 		}
 	}
 	if input.Users != nil {
-		u, err = r.Services.Role.SyncUsers(ctx, u, input.Users)
+		u, err = r.Services.Role.SyncUserAssignments(ctx, u, role.UsersFromInput(u.ID, input.Users))
+		if err != nil {
+			return nil, grantErrors(ctx, "role", "users", err)
+		}
+	}
+	if input.Parents != nil {
+		u, err = r.syncParents(ctx, u, input.Parents)
 	}
 	return u, err
 }
 
 func (r *mutationResolver) UpdateRole(ctx context.Context, input gqlmodels.RoleInput) (*entity.Role, error) {
-	if err := role.ValidateUpdateRequest(&input); err.Failed() {
+	if err := role.ValidateUpdateRequest(&input, r.Services.Permission); err.Failed() {
 		return nil, addErrors(ctx, err)
 	}
-	u, err := r.Services.Role.Update(ctx, toRoleEntity(input))
+	existing, err := r.Services.Role.Find(ctx, handleIntPtr(input.ID))
 	if err != nil {
 		return nil, err
 	}
-	if input.Permissions != nil {
-		u, err = r.Services.Role.SyncPermissions(ctx, u, mapPermissions(input.Permissions))
-		if err != nil {
+	e := toRoleEntity(input)
+	if input.RequireTwoFactor == nil {
+		e.RequireTwoFactor = existing.RequireTwoFactor
+	}
+	if err := checkRequireTwoFactor(ctx, existing.RequireTwoFactor, e.RequireTwoFactor); err != nil {
+		return nil, err
+	}
+	if input.Parents != nil {
+		if _, err = r.syncParents(ctx, existing, input.Parents); err != nil {
 			return nil, err
 		}
 	}
+	u, err := r.Services.Role.Update(ctx, e)
+	if err != nil {
+		return nil, err
+	}
 	if input.Permissions != nil {
-		u, err = r.Services.Role.SyncUsers(ctx, u, input.Users)
+		u, err = r.Services.Role.SyncPermissions(ctx, u, permission.FromInput(input.Permissions))
 		if err != nil {
-			return nil, err
+			return nil, grantErrors(ctx, "role", "permissions", err)
+		}
+	}
+	if input.Users != nil {
+		u, err = r.Services.Role.SyncUserAssignments(ctx, u, role.UsersFromInput(u.ID, input.Users))
+		if err != nil {
+			return nil, grantErrors(ctx, "role", "users", err)
 		}
 	}
 	return u, err
@@ -104,21 +294,46 @@ func (r *mutationResolver) DeleteRole(ctx context.Context, ids []int) ([]*entity
 	return r.Services.Role.Delete(ctx, ids)
 }
 
+// syncParents sets the parents of a role and returns cycles as validation errors.
+func (r *mutationResolver) syncParents(ctx context.Context, e *entity.Role, parents []int) (*entity.Role, error) {
+	u, err := r.Services.Role.SyncParents(ctx, e, parents)
+	if errs := role.ValidateParents(err); errs.Failed() {
+		return nil, addErrors(ctx, errs)
+	}
+	return u, err
+}
+
+// grantErrors turns an error of a sync that grants more permissions than the authenticated
+// user has into a validation error of the field.
+func grantErrors(ctx context.Context, subject, field string, err error) error {
+	if errs := permission.ValidateGrant(subject, field, err); errs.Failed() {
+		return addErrors(ctx, errs)
+	}
+	return err
+}
+
+// checkRequireTwoFactor makes sure only superusers change the two-factor requirement of a role.
+func checkRequireTwoFactor(ctx context.Context, from, to bool) error {
+	if from == to {
+		return nil
+	}
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !authUser.IsSuperuser {
+		return errors.New("only superusers can change the two-factor requirement of a role")
+	}
+	return nil
+}
+
 func toRoleEntity(input gqlmodels.RoleInput) *entity.Role {
-	return &entity.Role{
+	e := &entity.Role{
 		ID:   handleIntPtr(input.ID),
 		Name: input.Name,
 	}
-}
-
-// mapPermissions turns a DisplayGroupInput into a map of SectionIDs to CallTypeIDs
-func mapPermissions(input []*gqlmodels.PermissionInput) []*entity.Permission {
-	var permissions []*entity.Permission
-	for _, p := range input {
-		permissions = append(permissions, &entity.Permission{
-			Code:  p.Code,
-			Level: entity.PermissionLevel(p.Level),
-		})
+	if input.RequireTwoFactor != nil {
+		e.RequireTwoFactor = *input.RequireTwoFactor
 	}
-	return permissions
+	return e
 }
@@ -4,11 +4,17 @@ import (
 	"context"
 	"strconv"
 	"testing"
+	"time"
 
+	"go-webapp-example/internal/graphql/gqldirectives"
 	"go-webapp-example/internal/pkg"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/pkg/auth"
 
 	"github.com/99designs/gqlgen/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type roleFields struct {
@@ -30,32 +36,40 @@ func TestGraphQL_Role(t *testing.T) {
 
 	t.Run("Roles Query", testRolesQuery(c))
 	t.Run("Role Query", testRoleQuery(c))
+	t.Run("permissionCatalog Query", testPermissionCatalogQuery(c))
 	t.Run("createRole", testCreateRole(c, services))
 	t.Run("updateRole", testUpdateRole(c, services))
+	t.Run("Role inheritance", testRoleInheritance(c))
 	t.Run("deleteRole", testDeleteRole(c, services))
 }
 
 func testRolesQuery(c *client.Client) func(t *testing.T) {
 	return func(t *testing.T) {
 		var resp struct {
-			Roles []roleFields
+			Roles struct {
+				Edges []struct{ Node roleFields }
+			}
 		}
 
 		err := c.Post(`
 			query roles {
 				  roles {
-					id
-					name
-
-					permissions { code_level }
+					edges {
+						node {
+							id
+							name
+
+							permissions { code_level }
+						}
+					}
 			    }
 			}`, &resp)
 
 		assert.NoError(t, err)
-		assert.Len(t, resp.Roles, 3)
+		assert.Len(t, resp.Roles.Edges, 3)
 
-		if len(resp.Roles) >= 2 {
-			checkRolesResponse(t, resp.Roles[1])
+		if len(resp.Roles.Edges) >= 2 {
+			checkRolesResponse(t, resp.Roles.Edges[1].Node)
 		}
 	}
 }
@@ -80,6 +94,39 @@ func testRoleQuery(c *client.Client) func(t *testing.T) {
 	}
 }
 
+func testPermissionCatalogQuery(c *client.Client) func(t *testing.T) {
+	return func(t *testing.T) {
+		var resp struct {
+			PermissionCatalog []struct {
+				Code   string
+				Label  string
+				Levels []string
+			}
+		}
+
+		err := c.Post(`
+			query catalog {
+				  permissionCatalog {
+					code
+					label
+					levels
+			    }
+			}`, &resp)
+
+		assert.NoError(t, err)
+		if assert.Len(t, resp.PermissionCatalog, 5) {
+			assert.Equal(t, "admin.audit", resp.PermissionCatalog[0].Code)
+			assert.Equal(t, []string{"read"}, resp.PermissionCatalog[0].Levels)
+			assert.Equal(t, "admin.file", resp.PermissionCatalog[1].Code)
+			assert.Equal(t, "admin.quote", resp.PermissionCatalog[2].Code)
+			assert.Equal(t, "quote.permissions.admin", resp.PermissionCatalog[2].Label)
+			assert.Equal(t, []string{"read", "write", "manage"}, resp.PermissionCatalog[2].Levels)
+			assert.Equal(t, "admin.role", resp.PermissionCatalog[3].Code)
+			assert.Equal(t, "admin.user", resp.PermissionCatalog[4].Code)
+		}
+	}
+}
+
 func checkRolesResponse(t *testing.T, fields roleFields) {
 	assert.Equal(t, fields.ID, "2")
 	assert.Equal(t, fields.Name, "reseller")
@@ -101,7 +148,7 @@ func testCreateRole(c *client.Client, services *pkg.Services) func(t *testing.T)
 				  createRole(input: {
 					name: "Created"
 					permissions: [
-						{ code: "test-code", level: "read" } 
+						{ code: "admin.quote", level: "read" } 
 					]
 				  }) {
 					id
@@ -126,7 +173,7 @@ func testCreateRole(c *client.Client, services *pkg.Services) func(t *testing.T)
 
 		assert.Len(t, resp.CreateRole.Permissions, 1)
 		if len(resp.CreateRole.Permissions) > 0 {
-			assert.Equal(t, resp.CreateRole.Permissions[0].CodeLevel, "test-code::read")
+			assert.Equal(t, resp.CreateRole.Permissions[0].CodeLevel, "admin.quote::read")
 		}
 	}
 }
@@ -142,8 +189,9 @@ func testUpdateRole(c *client.Client, services *pkg.Services) func(t *testing.T)
 				  updateRole(input: {
 					id: 1,
 					name: "Updated"
+					require_two_factor: true
 					permissions: [
-						{ code: "test-code", level: "manage" } 
+						{ code: "admin.quote", level: "manage" } 
 					]
 				  }) {
 					id
@@ -163,6 +211,7 @@ func testUpdateRole(c *client.Client, services *pkg.Services) func(t *testing.T)
 
 		assert.NotEqual(t, updated.ID, 0)
 		assert.Equal(t, "Updated", updated.Name)
+		assert.True(t, updated.RequireTwoFactor)
 		assert.NotNil(t, updated.CreatedAt)
 		assert.NotNil(t, updated.UpdatedAt)
 		assert.NotEqual(t, updated.UpdatedAt, updated.CreatedAt)
@@ -171,13 +220,55 @@ func testUpdateRole(c *client.Client, services *pkg.Services) func(t *testing.T)
 		if len(resp.UpdateRole.Permissions) > 0 {
 			var found bool
 			for _, permission := range resp.UpdateRole.Permissions {
-				if permission.CodeLevel == "test-code::manage" {
+				if permission.CodeLevel == "admin.quote::manage" {
 					found = true
 					break
 				}
 			}
-			assert.Truef(t, found, "permission 'test-code::manage' not found")
+			assert.Truef(t, found, "permission 'admin.quote::manage' not found")
+		}
+	}
+}
+
+func testRoleInheritance(c *client.Client) func(t *testing.T) {
+	return func(t *testing.T) {
+		var resp struct {
+			UpdateRole struct {
+				Parents []struct {
+					ID string
+				}
+				InheritedPermissions []struct {
+					CodeLevel string `json:"code_level"`
+				} `json:"inherited_permissions"`
+			}
 		}
+
+		err := c.Post(`
+			mutation update {
+				  updateRole(input: { id: 3, name: "user", parents: [1] }) {
+					parents { id }
+					inherited_permissions { code_level }
+			    }
+			}`, &resp)
+
+		assert.NoError(t, err)
+		if assert.Len(t, resp.UpdateRole.Parents, 1) {
+			assert.Equal(t, "1", resp.UpdateRole.Parents[0].ID)
+		}
+		var inherited []string
+		for _, p := range resp.UpdateRole.InheritedPermissions {
+			inherited = append(inherited, p.CodeLevel)
+		}
+		assert.ElementsMatch(t, []string{"admin.quote::read", "admin.quote::write", "admin.quote::manage"}, inherited)
+
+		// Role 1 cannot inherit from its own child.
+		err = c.Post(`mutation update { updateRole(input: { id: 1, name: "Updated", parents: [3] }) { id } }`, &resp)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.role_cycle")
+
+		err = c.Post(`mutation update { updateRole(input: { id: 3, name: "user", parents: [999] }) { id } }`, &resp)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.role_parent_not_found")
 	}
 }
 
@@ -201,3 +292,549 @@ func testDeleteRole(c *client.Client, services *pkg.Services) func(t *testing.T)
 		assert.Error(t, err)
 	}
 }
+
+// TestGraphQL_DenyRules denies a permission of a role for a single user.
+func TestGraphQL_DenyRules(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	_, err := services.Role.SyncUsers(context.Background(), &entity.Role{ID: 2}, []int{2})
+	require.NoError(t, err)
+
+	var updated map[string]interface{}
+	c.MustPost(`
+		mutation {
+			updateRole(input: { id: 2, name: "staff", permissions: [{ code: "admin.user", level: "write" }] }) { id }
+			updateUser(input: {
+				id: 2, name: "user", password: "", password_repeat: "", is_superuser: false
+				permissions: [{ code: "admin.user", level: "read", deny: true }]
+			}) { id }
+		}`, &updated)
+
+	var resp struct {
+		User struct {
+			Permissions []struct {
+				CodeLevel string `json:"code_level"`
+			}
+			UserPermissions []struct {
+				CodeLevel string `json:"code_level"`
+				Deny      bool
+			} `json:"user_permissions"`
+		}
+		Role struct {
+			Conflicts []struct {
+				Permission struct {
+					CodeLevel string `json:"code_level"`
+				}
+				User *struct{ ID string }
+			}
+		}
+	}
+	c.MustPost(`
+		query {
+			user(id: 2) {
+				permissions { code_level }
+				user_permissions { code_level deny }
+			}
+			role(id: 2) {
+				conflicts {
+					permission { code_level }
+					user { id }
+				}
+			}
+		}`, &resp)
+
+	assert.Empty(t, resp.User.Permissions)
+	assert.Len(t, resp.User.UserPermissions, 3)
+	for _, p := range resp.User.UserPermissions {
+		assert.True(t, p.Deny)
+	}
+
+	var conflicts []string
+	for _, c := range resp.Role.Conflicts {
+		if assert.NotNil(t, c.User) {
+			assert.Equal(t, "2", c.User.ID)
+		}
+		conflicts = append(conflicts, c.Permission.CodeLevel)
+	}
+	assert.ElementsMatch(t, []string{"admin.user::read", "admin.user::write"}, conflicts)
+}
+
+// nolint:funlen
+func TestGraphQL_ExplainPermission(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	_, err := services.Role.SyncUsers(ctx, &entity.Role{ID: 2}, []int{2})
+	require.NoError(t, err)
+	_, err = services.Role.SyncParents(ctx, &entity.Role{ID: 2}, []int{3})
+	require.NoError(t, err)
+	_, err = services.Role.SyncPermissions(ctx, &entity.Role{ID: 3}, []*entity.Permission{
+		{Code: "admin.quote", Level: entity.PermissionLevelRead},
+		{Code: "admin.user", Level: entity.PermissionLevelRead},
+	})
+	require.NoError(t, err)
+	_, err = services.User.SyncPermissions(ctx, &entity.User{ID: 2}, []*entity.Permission{
+		{Code: "admin.user", Level: entity.PermissionLevelRead, Deny: true},
+	})
+	require.NoError(t, err)
+
+	type explanation struct {
+		Allowed  bool
+		Policies []struct {
+			Policy string
+			Roles  []struct{ ID string }
+		}
+	}
+	var resp struct {
+		Granted explanation
+		Denied  explanation
+	}
+	c.MustPost(`
+		query {
+			granted: explainPermission(userId: 2, permission: "admin.quote::read") {
+				allowed
+				policies { policy roles { id } }
+			}
+			denied: explainPermission(userId: 2, permission: "admin.user::read") {
+				allowed
+				policies { policy roles { id } }
+			}
+		}`, &resp)
+
+	assert.True(t, resp.Granted.Allowed)
+	require.Len(t, resp.Granted.Policies, 1)
+	assert.Equal(t, "p, role-3, admin.quote, read, allow", resp.Granted.Policies[0].Policy)
+	assert.Equal(t, []struct{ ID string }{{"2"}, {"3"}}, resp.Granted.Policies[0].Roles)
+
+	assert.False(t, resp.Denied.Allowed)
+	var policies []string
+	for _, p := range resp.Denied.Policies {
+		policies = append(policies, p.Policy)
+	}
+	assert.ElementsMatch(t, []string{"p, role-3, admin.user, read, allow", "p, user-2, admin.user, read, deny"}, policies)
+
+	t.Run("Undeclared", func(t *testing.T) {
+		err := c.Post(`query { explainPermission(userId: 2, permission: "admin.unknown::read") { allowed } }`, &resp)
+		assert.Error(t, err)
+	})
+}
+
+func TestGraphQL_Can(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClientAs(t, &entity.User{Name: "user", ID: 2})
+	defer cleanup()
+
+	ctx := context.Background()
+	_, err := services.Role.SyncUsers(ctx, &entity.Role{ID: 2}, []int{2})
+	require.NoError(t, err)
+	_, err = services.Role.SyncPermissions(ctx, &entity.Role{ID: 2}, []*entity.Permission{
+		{Code: "admin.quote", Level: entity.PermissionLevelWrite, Own: true},
+	})
+	require.NoError(t, err)
+
+	var resp struct {
+		Can []struct {
+			Permission string
+			Allowed    bool
+		}
+	}
+	c.MustPost(`query { can(permissions: ["admin.quote::write", "admin.quote::manage", "admin.role::read"]) { permission allowed } }`, &resp)
+
+	require.Len(t, resp.Can, 3)
+	assert.Equal(t, "admin.quote::write", resp.Can[0].Permission)
+	assert.True(t, resp.Can[0].Allowed)
+	assert.False(t, resp.Can[1].Allowed)
+	assert.False(t, resp.Can[2].Allowed)
+}
+
+func TestRoleAssignments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	_, services, cleanup := testClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	roleCodes := func() []string {
+		var codes []string
+		for _, p := range services.Permission.GetForUserID(ctx, 2) {
+			codes = append(codes, p.Code)
+		}
+		return codes
+	}
+
+	// The seeded assignment of user 2 to role 2 is missing in the policies.
+	drift, err := services.Role.CheckAssignments(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*role.Drift{{Assignment: auth.Assignment{UserID: 2, RoleID: 2}, Missing: true}}, drift)
+	assert.Empty(t, roleCodes())
+
+	drift, err = services.Role.ReconcileAssignments(ctx)
+	require.NoError(t, err)
+	assert.Len(t, drift, 1)
+	assert.Equal(t, []string{"test"}, roleCodes())
+
+	t.Run("Rollback", func(t *testing.T) {
+		_, err := services.User.SyncRoles(ctx, &entity.User{ID: 2}, []int{1, 999})
+		assert.Error(t, err)
+
+		drift, err := services.Role.CheckAssignments(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+		assert.Equal(t, []string{"test"}, roleCodes())
+	})
+
+	t.Run("SyncUsers", func(t *testing.T) {
+		_, err := services.Role.SyncUsers(ctx, &entity.Role{ID: 2}, []int{1})
+		require.NoError(t, err)
+
+		drift, err := services.Role.CheckAssignments(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+		assert.Empty(t, roleCodes())
+	})
+}
+
+func TestGraphQL_RoleAssignmentPeriod(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	var resp struct {
+		UpdateRole struct {
+			UserAssignments []struct {
+				User       struct{ ID string }
+				ValidUntil *string `json:"valid_until"`
+			} `json:"user_assignments"`
+		}
+	}
+	err := c.Post(`mutation update($until: Time) {
+			updateRole(input: { id: 2, name: "Temporary", users: [{ id: 2, valid_until: $until }] }) {
+				user_assignments { user { id } valid_until }
+			}
+		}`, &resp, client.Var("until", until))
+	require.NoError(t, err)
+	require.Len(t, resp.UpdateRole.UserAssignments, 1)
+	assert.Equal(t, "2", resp.UpdateRole.UserAssignments[0].User.ID)
+	assert.Equal(t, until, *resp.UpdateRole.UserAssignments[0].ValidUntil)
+	assert.Len(t, services.Permission.GetForUserID(ctx, 2), 1)
+
+	// Let the assignment expire.
+	_, err = services.DB.ExecContext(ctx, "UPDATE role_user SET valid_until = ? WHERE role_id = 2", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	drift, err := services.Role.ReconcileAssignments(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*role.Drift{{Assignment: auth.Assignment{UserID: 2, RoleID: 2}, Expired: true}}, drift)
+	assert.Empty(t, services.Permission.GetForUserID(ctx, 2))
+
+	var count int
+	err = services.DB.GetContext(ctx, &count, "SELECT COUNT(*) FROM auditlogs WHERE action = 'expired' AND entity_id = 2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	t.Run("Invalid period", func(t *testing.T) {
+		err := c.Post(`mutation update {
+				updateRole(input: { id: 2, name: "Temporary", users: [{
+					id: 2, valid_from: "2020-02-01T00:00:00Z", valid_until: "2020-01-01T00:00:00Z"
+				}] }) { id }
+			}`, &resp)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.assignment_period")
+	})
+}
+
+func TestGraphQL_RestrictedFields(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClientAs(t, &entity.User{Name: "user", ID: 2})
+	defer cleanup()
+
+	ctx := context.Background()
+	_, err := services.Role.SyncUsers(ctx, &entity.Role{ID: 2}, []int{2})
+	require.NoError(t, err)
+	_, err = services.Role.SyncPermissions(ctx, &entity.Role{ID: 2}, []*entity.Permission{
+		{Code: "admin.user", Level: entity.PermissionLevelRead},
+	})
+	require.NoError(t, err)
+
+	t.Run("Null", func(t *testing.T) {
+		var resp struct {
+			User struct {
+				Name        string
+				Permissions []struct{ Code string }
+			}
+		}
+		err := c.Post(`query { user(id: 2) { name permissions { code } } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), gqldirectives.ErrMissingPermission)
+		assert.Contains(t, err.Error(), `"path":["user","permissions"]`)
+		assert.Equal(t, "user", resp.User.Name)
+		assert.Nil(t, resp.User.Permissions)
+	})
+
+	t.Run("Introspection", func(t *testing.T) {
+		var resp struct {
+			Type struct {
+				Fields []struct{ Name string }
+			} `json:"__type"`
+		}
+		c.MustPost(`query { __type(name: "User") { fields { name } } }`, &resp)
+
+		var fields []string
+		for _, f := range resp.Type.Fields {
+			fields = append(fields, f.Name)
+		}
+		assert.Contains(t, fields, "roles")
+		assert.NotContains(t, fields, "permissions")
+		assert.NotContains(t, fields, "user_permissions")
+	})
+}
+
+func TestGraphQL_PrivilegeEscalation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClientAs(t, &entity.User{Name: "user", ID: 2})
+	defer cleanup()
+
+	ctx := context.Background()
+	_, err := services.Role.SyncUsers(ctx, &entity.Role{ID: 2}, []int{2})
+	require.NoError(t, err)
+	_, err = services.Role.SyncPermissions(ctx, &entity.Role{ID: 2}, []*entity.Permission{
+		{Code: "admin.role", Level: entity.PermissionLevelWrite},
+		{Code: "admin.user", Level: entity.PermissionLevelRead},
+	})
+	require.NoError(t, err)
+
+	var resp struct{ UpdateRole struct{ ID string } }
+
+	t.Run("Permissions", func(t *testing.T) {
+		err := c.Post(`mutation {
+				updateRole(input: { id: 2, name: "user", permissions: [
+					{ code: "admin.role", level: "write" },
+					{ code: "admin.quote", level: "manage" }
+				] }) { id }
+			}`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+		assert.Contains(t, err.Error(), `"field":"permissions"`)
+		assert.Len(t, services.Permission.GetForUserID(ctx, 2), 3)
+
+		err = c.Post(`mutation {
+				updateRole(input: { id: 2, name: "user", permissions: [
+					{ code: "admin.role", level: "write" },
+					{ code: "admin.quote", level: "read", deny: true }
+				] }) { id }
+			}`, &resp)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		err := c.Post(`mutation { updateRole(input: { id: 1, name: "admin", users: [{ id: 1 }, { id: 2 }] }) { id } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+		assert.False(t, services.Permission.Granted(&entity.User{ID: 2}, &entity.Permission{Code: "quote", Level: "edit"}))
+	})
+
+	t.Run("Parents", func(t *testing.T) {
+		err := c.Post(`mutation { updateRole(input: { id: 2, name: "user", parents: [1] }) { id } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+	})
+
+	t.Run("ExtendedAssignment", func(t *testing.T) {
+		_, err := services.DB.ExecContext(ctx, "INSERT INTO role_user (user_id, role_id, valid_until) VALUES (2, 1, ?)",
+			time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+
+		until := time.Now().Add(time.Hour).Format(time.RFC3339)
+		err = c.Post(`mutation($until: Time) {
+				updateRole(input: { id: 1, name: "admin", users: [{ id: 1 }, { id: 2, valid_until: $until }] }) { id }
+			}`, &resp, client.Var("until", until))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+
+		_, err = services.DB.ExecContext(ctx, "UPDATE role_user SET valid_from = ?, valid_until = NULL WHERE user_id = 2 AND role_id = 1",
+			time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		err = c.Post(`mutation { updateRole(input: { id: 1, name: "admin", users: [{ id: 1 }, { id: 2 }] }) { id } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+		assert.False(t, services.Permission.Granted(&entity.User{ID: 2}, &entity.Permission{Code: "quote", Level: "edit"}))
+
+		_, err = services.DB.ExecContext(ctx, "DELETE FROM role_user WHERE user_id = 2 AND role_id = 1")
+		require.NoError(t, err)
+	})
+
+	t.Run("RemovedDeny", func(t *testing.T) {
+		_, err := services.Role.SyncPermissions(ctx, &entity.Role{ID: 2}, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelWrite},
+			{Code: "admin.user", Level: entity.PermissionLevelWrite},
+			{Code: "admin.quote", Level: entity.PermissionLevelManage},
+		})
+		require.NoError(t, err)
+		_, err = services.Role.SyncPermissions(ctx, &entity.Role{ID: 3}, []*entity.Permission{
+			{Code: "admin.quote", Level: entity.PermissionLevelManage, Deny: true},
+		})
+		require.NoError(t, err)
+		_, err = services.Role.SyncUsers(ctx, &entity.Role{ID: 3}, []int{2})
+		require.NoError(t, err)
+		_, err = services.User.SyncPermissions(ctx, &entity.User{ID: 2}, []*entity.Permission{
+			{Code: "admin.quote", Level: entity.PermissionLevelWrite, Deny: true},
+		})
+		require.NoError(t, err)
+
+		// Removing a deny on yourself grants the denied levels.
+		var updated struct{ UpdateUser struct{ ID string } }
+		err = c.Post(`mutation {
+				updateUser(input: {
+					id: 2, name: "user", password: "", password_repeat: "", is_superuser: false, permissions: []
+				}) { id }
+			}`, &updated)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+		assert.Contains(t, err.Error(), "admin.quote::write")
+		assert.False(t, services.Permission.Granted(&entity.User{ID: 2}, &entity.Permission{Code: "admin.quote", Level: entity.PermissionLevelWrite}))
+
+		// Removing a deny from your own role as well.
+		err = c.Post(`mutation { updateRole(input: { id: 3, name: "user", permissions: [] }) { id } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "validation.permission_escalation")
+		assert.Contains(t, err.Error(), "admin.quote::manage")
+
+		// Denies can still be extended.
+		err = c.Post(`mutation {
+				updateUser(input: {
+					id: 2, name: "user", password: "", password_repeat: "", is_superuser: false,
+					permissions: [{ code: "admin.quote", level: "read", deny: true }]
+				}) { id }
+			}`, &updated)
+		assert.NoError(t, err)
+		assert.False(t, services.Permission.Granted(&entity.User{ID: 2}, &entity.Permission{Code: "admin.quote", Level: entity.PermissionLevelRead}))
+	})
+}
+
+func TestGraphQL_Pagination(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, _, cleanup := testClient(t)
+	defer cleanup()
+
+	type rolePage struct {
+		Roles struct {
+			Edges []struct {
+				Cursor string
+				Node   struct{ Name string }
+			}
+			PageInfo struct {
+				HasNextPage     bool   `json:"hasNextPage"`
+				HasPreviousPage bool   `json:"hasPreviousPage"`
+				EndCursor       string `json:"endCursor"`
+			} `json:"pageInfo"`
+			TotalCount int `json:"totalCount"`
+		}
+	}
+	names := func(p rolePage) []string {
+		n := make([]string, len(p.Roles.Edges))
+		for i, e := range p.Roles.Edges {
+			n[i] = e.Node.Name
+		}
+		return n
+	}
+	fields := `edges { cursor node { name } } pageInfo { hasNextPage hasPreviousPage endCursor } totalCount`
+
+	t.Run("Forward", func(t *testing.T) {
+		var first rolePage
+		c.MustPost(`query { roles(first: 2) { `+fields+` } }`, &first)
+		assert.Equal(t, []string{"admin", "reseller"}, names(first))
+		assert.True(t, first.Roles.PageInfo.HasNextPage)
+		assert.False(t, first.Roles.PageInfo.HasPreviousPage)
+		assert.Equal(t, 3, first.Roles.TotalCount)
+
+		var next rolePage
+		c.MustPost(`query($after: String) { roles(first: 2, after: $after) { `+fields+` } }`, &next,
+			client.Var("after", first.Roles.PageInfo.EndCursor))
+		assert.Equal(t, []string{"user"}, names(next))
+		assert.False(t, next.Roles.PageInfo.HasNextPage)
+		assert.True(t, next.Roles.PageInfo.HasPreviousPage)
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		var all rolePage
+		c.MustPost(`query { roles { `+fields+` } }`, &all)
+		require.Len(t, all.Roles.Edges, 3)
+
+		var prev rolePage
+		c.MustPost(`query($before: String) { roles(last: 1, before: $before) { `+fields+` } }`, &prev,
+			client.Var("before", all.Roles.Edges[2].Cursor))
+		assert.Equal(t, []string{"reseller"}, names(prev))
+		assert.True(t, prev.Roles.PageInfo.HasNextPage)
+		assert.True(t, prev.Roles.PageInfo.HasPreviousPage)
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		var sorted rolePage
+		c.MustPost(`query { roles(first: 2, sort: [{ field: NAME, direction: DESC }]) { `+fields+` } }`, &sorted)
+		assert.Equal(t, []string{"user", "reseller"}, names(sorted))
+
+		var next rolePage
+		c.MustPost(`query($after: String) {
+				roles(after: $after, sort: [{ field: NAME, direction: DESC }]) { `+fields+` }
+			}`, &next, client.Var("after", sorted.Roles.PageInfo.EndCursor))
+		assert.Equal(t, []string{"admin"}, names(next))
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		var filtered rolePage
+		c.MustPost(`query { roles(filter: { name: "sell" }) { `+fields+` } }`, &filtered)
+		assert.Equal(t, []string{"reseller"}, names(filtered))
+		assert.Equal(t, 1, filtered.Roles.TotalCount)
+
+		var users struct {
+			Users struct {
+				Edges []struct{ Node struct{ Name string } }
+			}
+		}
+		c.MustPost(`query { users(filter: { role_ids: [2] }) { edges { node { name } } } }`, &users)
+		if assert.Len(t, users.Users.Edges, 1) {
+			assert.Equal(t, "user", users.Users.Edges[0].Node.Name)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var resp rolePage
+		err := c.Post(`query { roles(after: "invalid") { `+fields+` } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid cursor")
+
+		err = c.Post(`query { roles(first: 1, last: 1) { `+fields+` } }`, &resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid page arguments")
+	})
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/vektah/gqlparser/v2/gqlerror"
+	"gopkg.in/guregu/null.v3"
 )
 
 type Resolver struct {
@@ -29,15 +30,33 @@ func (r *Resolver) Mutation() gqlserver.MutationResolver {
 func (r *Resolver) Query() gqlserver.QueryResolver {
 	return &queryResolver{r}
 }
+func (r *Resolver) Subscription() gqlserver.SubscriptionResolver {
+	return &subscriptionResolver{r}
+}
 func (r *Resolver) User() gqlserver.UserResolver {
 	return &userResolver{r}
 }
 func (r *Resolver) Role() gqlserver.RoleResolver {
 	return &roleResolver{r}
 }
+func (r *Resolver) RoleAssignment() gqlserver.RoleAssignmentResolver {
+	return &roleAssignmentResolver{r}
+}
 func (r *Resolver) Permission() gqlserver.PermissionResolver {
 	return &permissionResolver{r}
 }
+func (r *Resolver) PolicyMatch() gqlserver.PolicyMatchResolver {
+	return &policyMatchResolver{r}
+}
+func (r *Resolver) Token() gqlserver.TokenResolver {
+	return &tokenResolver{r}
+}
+func (r *Resolver) Session() gqlserver.SessionResolver {
+	return &sessionResolver{r}
+}
+func (r *Resolver) AuditLog() gqlserver.AuditLogResolver {
+	return &auditLogResolver{r}
+}
 
 type mutationResolver struct{ *Resolver }
 
@@ -72,3 +91,11 @@ func handleIntPtr(id *int) int {
 	}
 	return *id
 }
+
+// handleStringPtr returns a null string for a nil pointer or an empty value.
+func handleStringPtr(s *string) null.String {
+	if s == nil || *s == "" {
+		return null.String{}
+	}
+	return null.StringFrom(*s)
+}
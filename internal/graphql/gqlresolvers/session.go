@@ -0,0 +1,62 @@
+package gqlresolvers
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
+)
+
+type sessionResolver struct{ *Resolver }
+
+func (r *sessionResolver) Current(ctx context.Context, obj *entity.Session) (bool, error) {
+	current, ok := usersession.FromContext(ctx)
+	return ok && current.ID == obj.ID, nil
+}
+func (r *sessionResolver) LastSeenAt(ctx context.Context, obj *entity.Session) (*time.Time, error) {
+	return obj.LastSeenAt.Ptr(), nil
+}
+func (r *sessionResolver) CreatedAt(ctx context.Context, obj *entity.Session) (*time.Time, error) {
+	return obj.CreatedAt.Ptr(), nil
+}
+
+// Mutations
+
+func (r *mutationResolver) RevokeSession(ctx context.Context, id int) (*entity.Session, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := r.Services.Session.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Only superusers can revoke the sessions of other users.
+	if s.UserID != authUser.ID && !authUser.IsSuperuser {
+		return nil, usersession.ErrNotFound
+	}
+	// A token narrowed down to some permissions must not carry this right of its superuser.
+	if _, isToken := token.FromContext(ctx); isToken && s.UserID != authUser.ID {
+		return nil, errors.New("the sessions of other users cannot be revoked using a token")
+	}
+	return s, r.Services.Session.Revoke(ctx, s)
+}
+
+func (r *mutationResolver) RevokeAllSessions(ctx context.Context, userID int) ([]*entity.Session, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userID != authUser.ID && !authUser.IsSuperuser {
+		return nil, errors.New("only superusers can revoke the sessions of other users")
+	}
+	if _, isToken := token.FromContext(ctx); isToken && userID != authUser.ID {
+		return nil, errors.New("the sessions of other users cannot be revoked using a token")
+	}
+	return r.Services.Session.RevokeAll(ctx, userID)
+}
@@ -0,0 +1,219 @@
+package gqlresolvers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/usersession"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionFields struct {
+	ID        string
+	IP        string
+	UserAgent string `json:"user_agent"`
+	Current   bool
+}
+
+func TestGraphQL_Session(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// current is the session all requests are sent with.
+	current := &entity.Session{}
+	withSession := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(usersession.WithContext(r.Context(), current)))
+		})
+	}
+
+	c, services, cleanup := testClientAs(t, testAdmin, withSession)
+	defer cleanup()
+
+	ctx := context.Background()
+	start := func(userID int, userAgent string) *entity.Session {
+		s, err := services.Session.Start(ctx, userID, "127.0.0.1", userAgent)
+		assert.NoError(t, err)
+		return s
+	}
+	*current = *start(1, "Current")
+	other := start(1, "Other")
+	start(2, "User")
+
+	authSessions := func(t *testing.T) []sessionFields {
+		var resp struct {
+			AuthUser struct {
+				Sessions []sessionFields
+			}
+		}
+		c.MustPost(`query { authUser { sessions { id ip user_agent current } } }`, &resp)
+		return resp.AuthUser.Sessions
+	}
+
+	t.Run("authUser sessions", func(t *testing.T) {
+		sessions := authSessions(t)
+		assert.Len(t, sessions, 2)
+		for _, s := range sessions {
+			assert.Equal(t, "127.0.0.1", s.IP)
+			assert.Equal(t, s.UserAgent == "Current", s.Current)
+		}
+	})
+
+	t.Run("user sessions", func(t *testing.T) {
+		var resp struct {
+			User struct {
+				Sessions []sessionFields
+			}
+		}
+		c.MustPost(`query { user(id: 2) { sessions { id user_agent } } }`, &resp)
+		if assert.Len(t, resp.User.Sessions, 1) {
+			assert.Equal(t, "User", resp.User.Sessions[0].UserAgent)
+		}
+	})
+
+	t.Run("revokeSession", func(t *testing.T) {
+		var resp struct {
+			RevokeSession sessionFields
+		}
+		c.MustPost(`mutation Revoke($id: ID!) { revokeSession(id: $id) { id } }`, &resp, client.Var("id", other.ID))
+		assert.Equal(t, strconv.Itoa(other.ID), resp.RevokeSession.ID)
+
+		_, err := services.Session.Authenticate(ctx, other.Key, 1)
+		assert.Equal(t, usersession.ErrNotFound, errors.Cause(err))
+		assert.Len(t, authSessions(t), 1)
+	})
+
+	t.Run("updateUser password", func(t *testing.T) {
+		start(1, "Another")
+		assert.Len(t, authSessions(t), 2)
+
+		var resp struct {
+			UpdateUser struct {
+				ID string
+			}
+		}
+		c.MustPost(`
+			mutation {
+				updateUser(input: {
+					id: 1
+					name: "admin"
+					password: "changed"
+					password_repeat: "changed"
+					is_superuser: true
+				}) { id }
+			}`, &resp)
+
+		// Only the session that changed the password is left.
+		sessions := authSessions(t)
+		if assert.Len(t, sessions, 1) {
+			assert.True(t, sessions[0].Current)
+		}
+	})
+
+	t.Run("revokeAllSessions", func(t *testing.T) {
+		var resp struct {
+			RevokeAllSessions []sessionFields
+		}
+		c.MustPost(`mutation { revokeAllSessions(userId: 2) { id } }`, &resp)
+		assert.Len(t, resp.RevokeAllSessions, 1)
+
+		sessions, err := services.Session.GetByUserID(ctx, 2)
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 0)
+	})
+}
+
+// TestGraphQL_SubscriptionRevoked ends subscriptions once the session or the token they were started with is revoked.
+// nolint:funlen
+func TestGraphQL_SubscriptionRevoked(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	ctx := context.Background()
+	// subscribe starts a quote subscription and waits until the resolver receives changes.
+	subscribe := func(t *testing.T, c *client.Client, services *pkg.Services) *client.Subscription {
+		open := services.Events.Subscribers(entity.KindQuote)
+		sub := c.Websocket(`subscription { quoteChanged { action } }`)
+		require.Eventually(t, func() bool {
+			return services.Events.Subscribers(entity.KindQuote) > open
+		}, time.Second, 10*time.Millisecond)
+		return sub
+	}
+	var resp struct {
+		QuoteChanged struct{ Action string } `json:"quoteChanged"`
+	}
+
+	t.Run("Session", func(t *testing.T) {
+		current := &entity.Session{}
+		withSession := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(usersession.WithContext(r.Context(), current)))
+			})
+		}
+		c, services, cleanup := testClientAs(t, testAdmin, withSession)
+		defer cleanup()
+		s, err := services.Session.Start(ctx, 1, "127.0.0.1", "Current")
+		require.NoError(t, err)
+		*current = *s
+
+		sub := subscribe(t, c, services)
+		defer sub.Close()
+
+		_, err = services.Quote.Create(ctx, &entity.Quote{Author: "Author", Content: "Content"})
+		require.NoError(t, err)
+		require.NoError(t, sub.Next(&resp))
+		assert.Equal(t, "CREATED", resp.QuoteChanged.Action)
+
+		require.NoError(t, services.Session.Revoke(ctx, s))
+		_, err = services.Quote.Create(ctx, &entity.Quote{Author: "Author", Content: "Content"})
+		require.NoError(t, err)
+		err = sub.Next(&resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "complete")
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		current := &entity.Token{}
+		withToken := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(token.WithContext(r.Context(), current)))
+			})
+		}
+		c, services, cleanup := testClientAs(t, testAdmin, withToken)
+		defer cleanup()
+		created, _, err := services.Token.Create(ctx, &entity.Token{
+			UserID:      1,
+			Name:        "script",
+			Permissions: entity.TokenPermissions{"admin.quote::read"},
+		})
+		require.NoError(t, err)
+		*current = *created
+
+		sub := subscribe(t, c, services)
+		defer sub.Close()
+
+		_, err = services.Quote.Create(ctx, &entity.Quote{Author: "Author", Content: "Content"})
+		require.NoError(t, err)
+		require.NoError(t, sub.Next(&resp))
+
+		_, err = services.Token.Delete(ctx, created)
+		require.NoError(t, err)
+		_, err = services.Quote.Create(ctx, &entity.Quote{Author: "Author", Content: "Content"})
+		require.NoError(t, err)
+		err = sub.Next(&resp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "complete")
+	})
+}
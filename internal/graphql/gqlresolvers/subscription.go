@@ -0,0 +1,166 @@
+package gqlresolvers
+
+import (
+	"context"
+	"strings"
+
+	"go-webapp-example/internal/graphql/gqldataloaders"
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/session"
+)
+
+// subscriptionResolver sends the changes the stores publish. The @restricted directive only checks
+// the permissions when a subscription starts, so each change is checked again before it is sent.
+// The subscription ends once the session or the token it was started with is no longer valid.
+type subscriptionResolver struct{ *Resolver }
+
+func (r *subscriptionResolver) UserChanged(ctx context.Context, id *int) (<-chan *gqlmodels.UserChange, error) {
+	if _, err := session.UserFromContext(ctx); err != nil {
+		return nil, err
+	}
+	read := &entity.Permission{Code: "admin.user", Level: entity.PermissionLevelRead}
+	changes := r.Services.Events.Subscribe(ctx, entity.KindUser)
+	out := make(chan *gqlmodels.UserChange)
+	go relay(ctx, changes, id, func() { close(out) }, func(c *event.Change) bool {
+		current, authUser, err := r.subscriber(ctx)
+		if err != nil {
+			return false
+		}
+		u := c.Entity.(*entity.User)
+		// Superusers are only sent to superusers.
+		if (u.IsSuperuser && !authUser.IsSuperuser) || !r.granted(current, authUser, read) {
+			return true
+		}
+		select {
+		case out <- &gqlmodels.UserChange{Action: changeAction(c), Fields: changeFields(c), User: u}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	return out, nil
+}
+
+func (r *subscriptionResolver) RoleChanged(ctx context.Context, id *int) (<-chan *gqlmodels.RoleChange, error) {
+	if _, err := session.UserFromContext(ctx); err != nil {
+		return nil, err
+	}
+	read := &entity.Permission{Code: "admin.role", Level: entity.PermissionLevelRead}
+	changes := r.Services.Events.Subscribe(ctx, entity.KindRole)
+	out := make(chan *gqlmodels.RoleChange)
+	go relay(ctx, changes, id, func() { close(out) }, func(c *event.Change) bool {
+		current, authUser, err := r.subscriber(ctx)
+		if err != nil {
+			return false
+		}
+		role := c.Entity.(*entity.Role)
+		// The admin role is only sent to superusers.
+		if (role.ID == 1 && !authUser.IsSuperuser) || !r.granted(current, authUser, read) {
+			return true
+		}
+		select {
+		case out <- &gqlmodels.RoleChange{Action: changeAction(c), Fields: changeFields(c), Role: role}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	return out, nil
+}
+
+func (r *subscriptionResolver) QuoteChanged(ctx context.Context, id *int) (<-chan *gqlmodels.QuoteChange, error) {
+	if _, err := session.UserFromContext(ctx); err != nil {
+		return nil, err
+	}
+	changes := r.Services.Events.Subscribe(ctx, entity.KindQuote)
+	out := make(chan *gqlmodels.QuoteChange)
+	go relay(ctx, changes, id, func() { close(out) }, func(c *event.Change) bool {
+		current, _, err := r.subscriber(ctx)
+		if err != nil {
+			return false
+		}
+		q := c.Entity.(*entity.Quote)
+		// Users that can only read their own quotes are only sent changes of their quotes.
+		if !tokenAllows(current, permissionQuote, entity.PermissionLevelRead) ||
+			r.checkOwner(current, permissionQuote, entity.PermissionLevelRead, q.CreatedBy) != nil {
+			return true
+		}
+		select {
+		case out <- &gqlmodels.QuoteChange{Action: changeAction(c), Fields: changeFields(c), Quote: q}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	return out, nil
+}
+
+// subscriber checks the session or the token a subscription was started with before a change is sent
+// and loads the authenticated user again. It returns an error once they were revoked or expired.
+func (r *subscriptionResolver) subscriber(ctx context.Context) (context.Context, *entity.User, error) {
+	if t, ok := token.FromContext(ctx); ok {
+		if err := r.Services.Token.Check(ctx, t); err != nil {
+			return nil, nil, err
+		}
+	}
+	if s, ok := usersession.FromContext(ctx); ok {
+		current, err := r.Services.Session.Authenticate(ctx, s.Key, s.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx = usersession.WithContext(ctx, current)
+	}
+	u, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u, err = r.Services.User.Find(ctx, u.ID); err != nil {
+		return nil, nil, err
+	}
+	return context.WithValue(ctx, session.CtxKey, u), u, nil
+}
+
+// granted checks a permission of the subscriber, a token limits it to the permissions of the token.
+func (r *subscriptionResolver) granted(ctx context.Context, u *entity.User, p *entity.Permission) bool {
+	return tokenAllows(ctx, p.Code, p.Level) && r.Services.Permission.Granted(u, p)
+}
+
+// tokenAllows returns false if the request was authenticated by a token without the permission.
+func tokenAllows(ctx context.Context, code string, level entity.PermissionLevel) bool {
+	t, ok := token.FromContext(ctx)
+	return !ok || t.Allows(code, level)
+}
+
+// relay passes the changes to send until the subscription ends or send returns false, then done
+// is called. If an id is set, only the changes of the entity with this id are passed.
+func relay(ctx context.Context, changes <-chan *event.Change, id *int, done func(), send func(c *event.Change) bool) {
+	defer done()
+	for c := range changes {
+		if id != nil && c.Entity.Primary() != *id {
+			continue
+		}
+		// The loaders of a subscription live as long as the subscription,
+		// the relations of a changed entity have to be loaded again.
+		gqldataloaders.Clear(ctx, c.Entity)
+		if !send(c) {
+			return
+		}
+	}
+}
+
+// changeAction returns the schema enum value of the action of a change.
+func changeAction(c *event.Change) gqlmodels.ChangeAction {
+	return gqlmodels.ChangeAction(strings.ToUpper(string(c.Action)))
+}
+
+// changeFields returns the changed fields of a change, it is never nil.
+func changeFields(c *event.Change) []string {
+	if c.Fields == nil {
+		return []string{}
+	}
+	return c.Fields
+}
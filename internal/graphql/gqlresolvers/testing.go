@@ -1,33 +1,60 @@
 package gqlresolvers
 
 import (
-	"context"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
+	"time"
 
 	"go-webapp-example/internal/graphql/gqldataloaders"
+	"go-webapp-example/internal/graphql/gqldirectives"
+	"go-webapp-example/internal/graphql/gqllimits"
 	"go-webapp-example/internal/graphql/gqlserver"
 	"go-webapp-example/internal/pkg"
 	"go-webapp-example/internal/pkg/audit"
+	internalauth "go-webapp-example/internal/pkg/auth"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/file"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/internal/pkg/outbox"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/passwordreset"
 	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/internal/pkg/quote"
 	"go-webapp-example/internal/pkg/role"
 	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
 	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
 	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/i18n"
 	"go-webapp-example/pkg/log"
 	"go-webapp-example/pkg/session"
 
 	"github.com/99designs/gqlgen/client"
-	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 )
 
-// testClient returns a graphql client for testing.
-// nolint:funlen
+// testAdmin is the superuser most tests run as.
+var testAdmin = &entity.User{Name: "admin", ID: 1, IsSuperuser: true}
+
+// testClient returns a graphql client for testing that runs as the admin user.
 func testClient(t *testing.T) (cli *client.Client, services *pkg.Services, cleanup func()) {
+	return testClientAs(t, testAdmin)
+}
+
+// testClientAs returns a graphql client for testing that runs as the provided user.
+// Additional middleware is applied before the user is added to the context.
+// nolint:funlen
+func testClientAs(
+	t *testing.T,
+	u *entity.User,
+	middleware ...func(http.Handler) http.Handler,
+) (cli *client.Client, services *pkg.Services, cleanup func()) {
 	db, cleanup := test.DB(t)
 
 	logger := log.NewNullLogger()
@@ -41,15 +68,66 @@ func testClient(t *testing.T) (cli *client.Client, services *pkg.Services, clean
 
 	sess := session.New(db.Connection())
 
+	registry, err := permission.NewRegistry(user.Permissions, role.Permissions, quote.Permissions, file.Permissions, permission.AuditPermissions)
+	if err != nil {
+		t.Fatalf("failed to create permission registry: %s", err)
+	}
+
 	auditor := audit.NewService(audit.NewStore(db), logger)
 
+	sessionService := usersession.NewService(usersession.NewStore(db, auditor))
+	policy, _ := passwordpolicy.NewPolicy(passwordpolicy.Config{MinLength: 4, History: 3})
+	passwordPolicy := passwordpolicy.NewService(passwordpolicy.NewStore(db), policy)
+	events := event.NewBroker()
+	storageDir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatalf("failed to create storage directory: %s", err)
+	}
+	cleanupDB := cleanup
+	cleanup = func() {
+		_ = os.RemoveAll(storageDir)
+		cleanupDB()
+	}
+	fileService, err := file.NewService(file.NewStore(db, auditor), file.Config{
+		Dir:        storageDir,
+		MaxSize:    1 << 10,
+		Extensions: []string{".png", ".txt"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create file service: %s", err)
+	}
+	userService := user.NewService(user.NewStore(db, authManager, auditor, user.WithEvents(events)), sess, sessionService, passwordPolicy)
+	outboxService := outbox.NewService(outbox.NewStore(db))
+	locale := &i18n.Locale{Data: map[string]interface{}{
+		"passwordreset": map[string]interface{}{
+			"mail": map[string]interface{}{"subject": "Reset", "body": "{url}"},
+		},
+	}}
+
 	services = &pkg.Services{
-		DB:         db,
-		User:       user.NewService(user.NewStore(db, authManager, auditor), sess),
-		Role:       role.NewService(role.NewStore(db, authManager)),
-		Permission: permission.NewService(permission.NewStore(db, authManager)),
-		Quote:      quote.NewService(quote.NewStore(db, auditor)),
-		Audit:      auditor,
+		DB:             db,
+		User:           userService,
+		Role:           role.NewService(role.NewStore(db, authManager, registry, auditor, role.WithEvents(events))),
+		Permission:     permission.NewService(permission.NewStore(db, authManager), registry),
+		Quote:          quote.NewService(quote.NewStore(db, auditor, quote.WithEvents(events))),
+		File:           fileService,
+		Token:          token.NewService(token.NewStore(db, auditor)),
+		Throttle:       throttle.NewService(throttle.NewStore(db), auditor, throttle.Config{MaxFailures: 3, Lockout: time.Hour}),
+		TwoFactor:      twofactor.NewService(twofactor.NewStore(db, auditor), "test"),
+		Outbox:         outboxService,
+		Session:        sessionService,
+		Identity:       identity.NewService(identity.NewStore(db, auditor), userService),
+		PasswordPolicy: passwordPolicy,
+		PasswordReset: passwordreset.NewService(
+			passwordreset.NewStore(db),
+			userService,
+			outboxService,
+			throttle.NewService(throttle.NewStore(db), auditor, throttle.Config{MaxFailures: 2, MaxFailuresIP: 10, Lockout: time.Hour}),
+			locale,
+			passwordreset.Config{URL: "http://localhost", TTL: time.Hour},
+		),
+		Audit:  auditor,
+		Events: events,
 	}
 
 	// resolver contains all shared dependencies.
@@ -60,32 +138,24 @@ func testClient(t *testing.T) (cli *client.Client, services *pkg.Services, clean
 	// Build the graphql config.
 	c := gqlserver.Config{Resolvers: resolver}
 	// Add directives.
-	c.Directives.Restricted = func(ctx context.Context, obj interface{}, next graphql.Resolver, permissions []string) (res interface{}, err error) {
-		return next(ctx)
-	}
+	c.Directives.Restricted = gqldirectives.Restricted(authManager, registry)
 
 	schema := gqlserver.NewExecutableSchema(c)
+	srv := handler.NewDefaultServer(gqllimits.WithCosts(schema))
+	srv.Use(gqllimits.New(gqllimits.Config{MaxDepth: 10, MaxComplexity: 10000, MaxAliases: 30}, logger))
+	srv.AroundFields(gqldirectives.HideRestrictedFields(schema.Schema(), authManager, registry))
 
 	query := withMiddleware(
-		handler.NewDefaultServer(schema),
-		authMiddleware,
+		withMiddleware(srv, middleware...),
+		internalauth.UserMiddleware(u),
 		i18n.Middleware(&i18n.Locale{}),
 		gqldataloaders.Middleware(services),
+		throttle.Middleware,
 	)
 
 	return client.New(query), services, cleanup
 }
 
-// authMiddleware injects a test admin user into the request context.
-func authMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.WithValue(r.Context(), session.CtxKey, &entity.User{Name: "admin", ID: 1, IsSuperuser: true})
-
-		r = r.WithContext(ctx)
-		next.ServeHTTP(w, r)
-	})
-}
-
 // withMiddleware applies multiple middleware to a http.Handler.
 func withMiddleware(h http.Handler, middleware ...func(http.Handler) http.Handler) http.Handler {
 	for _, mw := range middleware {
@@ -0,0 +1,86 @@
+package gqlresolvers
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+type tokenResolver struct{ *Resolver }
+
+func (r *tokenResolver) Permissions(ctx context.Context, obj *entity.Token) ([]string, error) {
+	return obj.Permissions, nil
+}
+func (r *tokenResolver) ExpiresAt(ctx context.Context, obj *entity.Token) (*time.Time, error) {
+	return obj.ExpiresAt.Ptr(), nil
+}
+func (r *tokenResolver) LastUsedAt(ctx context.Context, obj *entity.Token) (*time.Time, error) {
+	return obj.LastUsedAt.Ptr(), nil
+}
+func (r *tokenResolver) CreatedAt(ctx context.Context, obj *entity.Token) (*time.Time, error) {
+	return obj.CreatedAt.Ptr(), nil
+}
+
+// Queries
+
+func (r *queryResolver) Tokens(ctx context.Context) ([]*entity.Token, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.Services.Token.GetByUserID(ctx, authUser.ID)
+}
+
+// Mutations
+
+func (r *mutationResolver) CreateToken(ctx context.Context, input gqlmodels.TokenInput) (*gqlmodels.CreatedToken, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A token must never be able to create a token with more permissions than itself.
+	if _, isToken := token.FromContext(ctx); isToken {
+		return nil, errors.New("tokens cannot be created using a token")
+	}
+	// A token would outlive the impersonation and no longer record the superuser.
+	if _, ok := session.ImpersonatorFromContext(ctx); ok {
+		return nil, errors.New("tokens cannot be created while impersonating a user")
+	}
+	granted := r.Services.Permission.GetForUserID(ctx, authUser.ID)
+	if err := token.ValidateCreateRequest(&input, authUser, granted, time.Now()); err.Failed() {
+		return nil, addErrors(ctx, err)
+	}
+	t, secret, err := r.Services.Token.Create(ctx, &entity.Token{
+		UserID:      authUser.ID,
+		Name:        input.Name,
+		Permissions: input.Permissions,
+		ExpiresAt:   null.TimeFrom(input.ExpiresAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.CreatedToken{Token: t, Secret: secret}, nil
+}
+
+func (r *mutationResolver) RevokeToken(ctx context.Context, id int) (*entity.Token, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.Services.Token.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Only superusers can revoke the tokens of other users.
+	if t.UserID != authUser.ID && !authUser.IsSuperuser {
+		return nil, token.ErrNotFound
+	}
+	return r.Services.Token.Delete(ctx, t)
+}
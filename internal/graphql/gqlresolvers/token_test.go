@@ -0,0 +1,200 @@
+package gqlresolvers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqldirectives"
+	"go-webapp-example/internal/pkg"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQL_Token(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	t.Run("createToken", testCreateToken(c, services))
+	t.Run("Tokens Query", testTokensQuery(c))
+	t.Run("revokeToken", testRevokeToken(c, services))
+}
+
+func testCreateToken(c *client.Client, services *pkg.Services) func(t *testing.T) {
+	return func(t *testing.T) {
+		var resp struct {
+			CreateToken struct {
+				Token struct {
+					ID          string
+					Name        string
+					Permissions []string
+				}
+				Secret string
+			}
+		}
+
+		c.MustPost(`
+			mutation CreateToken($input: TokenInput!) {
+				createToken(input: $input) {
+					token {
+						id
+						name
+						permissions
+					}
+					secret
+				}
+			}`,
+			&resp,
+			client.Var("input", map[string]interface{}{
+				"name":        "script",
+				"expires_at":  time.Now().Add(time.Hour),
+				"permissions": []string{"admin.quote::read"},
+			}),
+		)
+
+		assert.Equal(t, "script", resp.CreateToken.Token.Name)
+		assert.Equal(t, []string{"admin.quote::read"}, resp.CreateToken.Token.Permissions)
+		assert.NotEmpty(t, resp.CreateToken.Secret)
+
+		authenticated, err := services.Token.Authenticate(context.Background(), resp.CreateToken.Secret)
+		assert.NoError(t, err)
+		assert.Equal(t, resp.CreateToken.Token.ID, strconv.Itoa(authenticated.ID))
+	}
+}
+
+func testTokensQuery(c *client.Client) func(t *testing.T) {
+	return func(t *testing.T) {
+		var resp struct {
+			Tokens []struct {
+				Name       string
+				LastUsedAt *string `json:"last_used_at"`
+			}
+		}
+
+		c.MustPost(`query { tokens { name last_used_at } }`, &resp)
+
+		assert.Len(t, resp.Tokens, 1)
+		assert.Equal(t, "script", resp.Tokens[0].Name)
+		assert.NotNil(t, resp.Tokens[0].LastUsedAt)
+	}
+}
+
+func testRevokeToken(c *client.Client, services *pkg.Services) func(t *testing.T) {
+	return func(t *testing.T) {
+		tokens, err := services.Token.GetByUserID(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Len(t, tokens, 1)
+
+		var resp struct {
+			RevokeToken struct {
+				Name string
+			}
+		}
+
+		c.MustPost(`mutation RevokeToken($id: ID!) { revokeToken(id: $id) { name } }`, &resp, client.Var("id", tokens[0].ID))
+
+		assert.Equal(t, "script", resp.RevokeToken.Name)
+
+		_, err = services.Token.Find(context.Background(), tokens[0].ID)
+		assert.Equal(t, token.ErrNotFound, errors.Cause(err))
+	}
+}
+
+func TestGraphQL_TokenScope(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	scoped := &entity.Token{ID: 1, UserID: 1, Permissions: entity.TokenPermissions{"admin.quote::read"}}
+	withToken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(token.WithContext(r.Context(), scoped)))
+		})
+	}
+
+	c, _, cleanup := testClientAs(t, testAdmin, withToken)
+	defer cleanup()
+
+	t.Run("Quotes Query", func(t *testing.T) {
+		var resp struct {
+			Quotes struct {
+				Edges []struct{ Node struct{ ID string } }
+			}
+		}
+		c.MustPost(`query { quotes { edges { node { id } } } }`, &resp)
+	})
+
+	t.Run("Users Query", func(t *testing.T) {
+		var resp struct {
+			Users struct {
+				Edges []struct{ Node struct{ ID string } }
+			}
+		}
+		err := c.Post(`query { users { edges { node { id } } } }`, &resp)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), gqldirectives.ErrMissingPermission)
+	})
+
+	t.Run("createToken", func(t *testing.T) {
+		var resp struct {
+			CreateToken struct {
+				Secret string
+			}
+		}
+		err := c.Post(`
+			mutation CreateToken($input: TokenInput!) {
+				createToken(input: $input) { secret }
+			}`,
+			&resp,
+			client.Var("input", map[string]interface{}{
+				"name":       "nested",
+				"expires_at": time.Now().Add(time.Hour),
+			}),
+		)
+		assert.Error(t, err)
+	})
+
+	t.Run("unlockUser", func(t *testing.T) {
+		var resp struct {
+			UnlockUser struct {
+				Name string
+			}
+		}
+		err := c.Post(`mutation UnlockUser($id: ID!) { unlockUser(id: $id) { name } }`, &resp, client.Var("id", 2))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be unlocked using a token")
+	})
+
+	t.Run("resetTwoFactor", func(t *testing.T) {
+		var resp struct {
+			ResetTwoFactor struct {
+				Name string
+			}
+		}
+		err := c.Post(`mutation Reset($id: ID!) { resetTwoFactor(id: $id) { name } }`, &resp, client.Var("id", 2))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be managed using a token")
+	})
+
+	t.Run("revokeAllSessions", func(t *testing.T) {
+		var resp struct {
+			RevokeAllSessions []struct {
+				ID string
+			}
+		}
+		err := c.Post(`mutation Revoke($id: ID!) { revokeAllSessions(userId: $id) { id } }`, &resp, client.Var("id", 2))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be revoked using a token")
+		c.MustPost(`mutation Revoke($id: ID!) { revokeAllSessions(userId: $id) { id } }`, &resp, client.Var("id", 1))
+	})
+}
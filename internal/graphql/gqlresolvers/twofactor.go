@@ -0,0 +1,108 @@
+package gqlresolvers
+
+import (
+	"context"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/pkg/session"
+	"go-webapp-example/pkg/validation"
+
+	"github.com/pkg/errors"
+)
+
+// Queries
+
+func (r *queryResolver) TwoFactor(ctx context.Context) (*gqlmodels.TwoFactorStatus, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := r.Services.TwoFactor.Enabled(ctx, authUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := r.Services.TwoFactor.RequiredForUser(ctx, authUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.TwoFactorStatus{Enabled: enabled, Required: required}, nil
+}
+
+// Mutations
+
+func (r *mutationResolver) EnrollTwoFactor(ctx context.Context) (*gqlmodels.TwoFactorEnrollment, error) {
+	authUser, err := twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tf, uri, err := r.Services.TwoFactor.Enroll(ctx, authUser)
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.TwoFactorEnrollment{Secret: tf.Secret, URI: uri}, nil
+}
+
+func (r *mutationResolver) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	authUser, err := twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := r.Services.TwoFactor.Confirm(ctx, authUser, code)
+	if err == twofactor.ErrInvalidCode {
+		return nil, addErrors(ctx, invalidCodeError())
+	}
+	return codes, err
+}
+
+func (r *mutationResolver) DisableTwoFactor(ctx context.Context, code string) (bool, error) {
+	authUser, err := twoFactorUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	err = r.Services.TwoFactor.Disable(ctx, authUser, code)
+	if err == twofactor.ErrInvalidCode {
+		return false, addErrors(ctx, invalidCodeError())
+	}
+	return err == nil, err
+}
+
+func (r *mutationResolver) ResetTwoFactor(ctx context.Context, id int) (*entity.User, error) {
+	authUser, err := twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !authUser.IsSuperuser {
+		return nil, errors.New("only superusers can reset the two-factor authentication of a user")
+	}
+	u, err := r.Services.User.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Services.TwoFactor.Reset(ctx, u.ID); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// twoFactorUser returns the authenticated user. The second factor cannot be
+// managed by requests that are authenticated using a personal access token.
+func twoFactorUser(ctx context.Context) (*entity.User, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, isToken := token.FromContext(ctx); isToken {
+		return nil, errors.New("two-factor authentication cannot be managed using a token")
+	}
+	return authUser, nil
+}
+
+// invalidCodeError returns the validation error for a wrong two-factor code.
+func invalidCodeError() *validation.ErrorBag {
+	errs := validation.NewErrorBag("twofactor")
+	errs.Add("code", "invalid_code")
+	return errs
+}
@@ -0,0 +1,133 @@
+package gqlresolvers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqldirectives"
+	"go-webapp-example/internal/pkg"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/pkg/totp"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQL_TwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	var secret string
+	var recoveryCodes []string
+
+	t.Run("enrollTwoFactor", func(t *testing.T) {
+		var resp struct {
+			EnrollTwoFactor struct {
+				Secret string
+				URI    string
+			}
+		}
+		c.MustPost(`mutation { enrollTwoFactor { secret uri } }`, &resp)
+
+		secret = resp.EnrollTwoFactor.Secret
+		uri, err := url.Parse(resp.EnrollTwoFactor.URI)
+		assert.NoError(t, err)
+		assert.Equal(t, "otpauth", uri.Scheme)
+		assert.Equal(t, secret, uri.Query().Get("secret"))
+	})
+
+	t.Run("confirmTwoFactor", func(t *testing.T) {
+		var resp struct {
+			ConfirmTwoFactor []string
+		}
+		err := c.Post(`mutation Confirm($code: String!) { confirmTwoFactor(code: $code) }`, &resp, client.Var("code", "000000"))
+		assert.Error(t, err)
+
+		code, err := totp.Code(secret, totp.Step(time.Now()))
+		assert.NoError(t, err)
+		c.MustPost(`mutation Confirm($code: String!) { confirmTwoFactor(code: $code) }`, &resp, client.Var("code", code))
+
+		recoveryCodes = resp.ConfirmTwoFactor
+		assert.Len(t, recoveryCodes, 10)
+	})
+
+	t.Run("TwoFactor Query", func(t *testing.T) {
+		var resp struct {
+			TwoFactor struct {
+				Enabled  bool
+				Required bool
+			}
+		}
+		c.MustPost(`query { twoFactor { enabled required } }`, &resp)
+
+		assert.True(t, resp.TwoFactor.Enabled)
+		assert.False(t, resp.TwoFactor.Required)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		ctx := context.Background()
+		// Recovery codes are single-use.
+		assert.NoError(t, services.TwoFactor.Verify(ctx, 1, recoveryCodes[0]))
+		assert.Equal(t, twofactor.ErrInvalidCode, services.TwoFactor.Verify(ctx, 1, recoveryCodes[0]))
+	})
+
+	t.Run("resetTwoFactor", testResetTwoFactor(c, services))
+}
+
+func testResetTwoFactor(c *client.Client, services *pkg.Services) func(t *testing.T) {
+	return func(t *testing.T) {
+		var resp struct {
+			ResetTwoFactor struct {
+				Name string
+			}
+		}
+		c.MustPost(`mutation Reset($id: ID!) { resetTwoFactor(id: $id) { name } }`, &resp, client.Var("id", 1))
+
+		enabled, err := services.TwoFactor.Enabled(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.False(t, enabled)
+	}
+}
+
+func TestGraphQL_TwoFactorSetupRequired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	setupRequired := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(twofactor.WithSetupRequired(r.Context())))
+		})
+	}
+
+	c, _, cleanup := testClientAs(t, testAdmin, setupRequired)
+	defer cleanup()
+
+	t.Run("Quotes Query", func(t *testing.T) {
+		var resp struct {
+			Quotes struct {
+				Edges []struct{ Node struct{ ID string } }
+			}
+		}
+		err := c.Post(`query { quotes { edges { node { id } } } }`, &resp)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), gqldirectives.ErrTwoFactorSetup)
+	})
+
+	t.Run("enrollTwoFactor", func(t *testing.T) {
+		var resp struct {
+			EnrollTwoFactor struct {
+				Secret string
+			}
+		}
+		c.MustPost(`mutation { enrollTwoFactor { secret } }`, &resp)
+		assert.NotEmpty(t, resp.EnrollTwoFactor.Secret)
+	})
+}
@@ -5,48 +5,92 @@ import (
 
 	"go-webapp-example/internal/graphql/gqldataloaders"
 	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/audit"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/internal/pkg/token"
 	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/db"
 	"go-webapp-example/pkg/session"
+	"go-webapp-example/pkg/validation"
 
 	"github.com/pkg/errors"
 )
 
 type userResolver struct{ *Resolver }
 
+func (r *userResolver) Email(ctx context.Context, obj *entity.User) (*string, error) {
+	return obj.Email.Ptr(), nil
+}
 func (r *userResolver) Roles(ctx context.Context, obj *entity.User) ([]*entity.Role, error) {
 	return gqldataloaders.CtxLoaders(ctx).RolesByUser.Load(obj.ID)
 }
 func (r *userResolver) Permissions(ctx context.Context, obj *entity.User) ([]*entity.Permission, error) {
 	return gqldataloaders.CtxLoaders(ctx).PermissionsByUser.Load(obj.ID)
 }
+func (r *userResolver) UserPermissions(ctx context.Context, obj *entity.User) ([]*entity.Permission, error) {
+	return gqldataloaders.CtxLoaders(ctx).UserPermissions.Load(obj.ID)
+}
+func (r *userResolver) RoleAssignments(ctx context.Context, obj *entity.User) ([]*entity.RoleAssignment, error) {
+	return gqldataloaders.CtxLoaders(ctx).RoleAssignmentsByUser.Load(obj.ID)
+}
+
+func (r *userResolver) Sessions(ctx context.Context, obj *entity.User) ([]*entity.Session, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if obj.ID != authUser.ID && !authUser.IsSuperuser {
+		return nil, errors.New("only superusers can list the sessions of other users")
+	}
+	return r.Services.Session.GetByUserID(ctx, obj.ID)
+}
 
 // Queries
 
 func (r *queryResolver) User(ctx context.Context, id int) (*entity.User, error) {
 	return r.Services.User.Find(ctx, id)
 }
-func (r *queryResolver) Users(ctx context.Context) ([]*entity.User, error) {
-	var filtered []*entity.User
-	users, err := r.Services.User.Get(ctx)
-	if err != nil {
-		return filtered, err
-	}
+func (r *queryResolver) Users(
+	ctx context.Context,
+	first *int,
+	after *string,
+	last *int,
+	before *string,
+	filter *gqlmodels.UserFilter,
+	sort []*gqlmodels.UserSort,
+) (*gqlmodels.UserConnection, error) {
 	authUser, err := session.UserFromContext(ctx)
 	if err != nil {
-		return filtered, err
-	}
-	// Return all users if a super user is logged in. Otherwise, remove
-	// other superusers from the result.
-	if authUser.IsSuperuser {
-		return users, nil
+		return nil, err
 	}
-	for _, u := range users {
-		if !u.IsSuperuser {
-			filtered = append(filtered, u)
+	// Superusers are only returned if a superuser is logged in.
+	f := user.Filter{WithoutSuperusers: !authUser.IsSuperuser}
+	if filter != nil {
+		if filter.Name != nil {
+			f.Name = *filter.Name
 		}
+		f.CreatedFrom, f.CreatedUntil = timeRange(filter.Created)
+		f.RoleIDs = filter.RoleIds
+	}
+	sorts := make([]db.Sort, len(sort))
+	for i, s := range sort {
+		sorts[i] = toSort(string(s.Field), s.Direction)
+	}
+	users, info, err := r.Services.User.GetPage(ctx, f, sorts, pageArgs(first, after, last, before))
+	if err != nil {
+		return nil, err
 	}
-	return filtered, nil
+	conn := &gqlmodels.UserConnection{Edges: make([]*gqlmodels.UserEdge, len(users)), TotalCount: info.TotalCount}
+	ids := make([]int, len(users))
+	for i, u := range users {
+		conn.Edges[i] = &gqlmodels.UserEdge{Cursor: db.EncodeCursor(u.ID), Node: u}
+		ids[i] = u.ID
+	}
+	conn.PageInfo = toPageInfo(info, ids)
+	return conn, nil
 }
 func (r *queryResolver) AuthUser(ctx context.Context) (*entity.User, error) {
 	u, err := session.UserFromContext(ctx)
@@ -56,6 +100,11 @@ func (r *queryResolver) AuthUser(ctx context.Context) (*entity.User, error) {
 	return u, nil
 }
 
+func (r *queryResolver) Impersonator(ctx context.Context) (*entity.User, error) {
+	impersonator, _ := session.ImpersonatorFromContext(ctx)
+	return impersonator, nil
+}
+
 // Mutations
 
 func (r *mutationResolver) CreateUser(ctx context.Context, input gqlmodels.UserInput) (*entity.User, error) {
@@ -66,7 +115,7 @@ func (r *mutationResolver) CreateUser(ctx context.Context, input gqlmodels.UserI
 	if !authUser.IsSuperuser && input.IsSuperuser {
 		return nil, errors.New("non superuser accounts cannot create superuser accounts")
 	}
-	if err := user.ValidateCreateRequest(&input); err.Failed() {
+	if err := user.ValidateCreateRequest(&input, r.Services.PasswordPolicy, r.Services.Permission); err.Failed() {
 		return nil, addErrors(ctx, err)
 	}
 	u, err := r.Services.User.Create(ctx, toUserEntity(input))
@@ -74,9 +123,15 @@ func (r *mutationResolver) CreateUser(ctx context.Context, input gqlmodels.UserI
 		return nil, err
 	}
 	if input.Roles != nil {
-		u, err = r.Services.User.SyncRoles(ctx, u, input.Roles)
+		u, err = r.Services.User.SyncRoleAssignments(ctx, u, role.RolesFromInput(u.ID, input.Roles))
 		if err != nil {
-			return nil, err
+			return nil, grantErrors(ctx, "user", "roles", err)
+		}
+	}
+	if input.Permissions != nil {
+		u, err = r.Services.User.SyncPermissions(ctx, u, permission.FromInput(input.Permissions))
+		if err != nil {
+			return nil, grantErrors(ctx, "user", "permissions", err)
 		}
 	}
 	return u, err
@@ -90,17 +145,28 @@ func (r *mutationResolver) UpdateUser(ctx context.Context, input gqlmodels.UserI
 	if !authUser.IsSuperuser && input.IsSuperuser {
 		return nil, errors.New("non superuser accounts cannot edit superuser accounts")
 	}
-	if err := user.ValidateUpdateRequest(&input); err.Failed() {
+	if err := user.ValidateUpdateRequest(&input, r.Services.PasswordPolicy, r.Services.Permission); err.Failed() {
 		return nil, addErrors(ctx, err)
 	}
 	u, err := r.Services.User.Update(ctx, toUserEntity(input))
+	if err == user.ErrPasswordReused {
+		errs := validation.NewErrorBag("user")
+		errs.Add("password", "password_reused")
+		return nil, addErrors(ctx, errs)
+	}
 	if err != nil {
 		return nil, err
 	}
 	if input.Roles != nil {
-		u, err = r.Services.User.SyncRoles(ctx, u, input.Roles)
+		u, err = r.Services.User.SyncRoleAssignments(ctx, u, role.RolesFromInput(u.ID, input.Roles))
+		if err != nil {
+			return nil, grantErrors(ctx, "user", "roles", err)
+		}
+	}
+	if input.Permissions != nil {
+		u, err = r.Services.User.SyncPermissions(ctx, u, permission.FromInput(input.Permissions))
 		if err != nil {
-			return nil, err
+			return nil, grantErrors(ctx, "user", "permissions", err)
 		}
 	}
 	return u, err
@@ -110,10 +176,83 @@ func (r *mutationResolver) DeleteUser(ctx context.Context, ids []int) ([]*entity
 	return r.Services.User.Delete(ctx, ids)
 }
 
+func (r *mutationResolver) UnlockUser(ctx context.Context, id int) (*entity.User, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !authUser.IsSuperuser {
+		return nil, errors.New("only superusers can unlock user accounts")
+	}
+	// A token narrowed down to some permissions must not carry this right of its superuser.
+	if _, isToken := token.FromContext(ctx); isToken {
+		return nil, errors.New("user accounts cannot be unlocked using a token")
+	}
+	u, err := r.Services.User.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Services.Throttle.Unlock(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *mutationResolver) ImpersonateUser(ctx context.Context, id int) (*entity.User, error) {
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := session.ImpersonatorFromContext(ctx); ok {
+		return nil, errors.New("another user is already impersonated")
+	}
+	if !authUser.IsSuperuser {
+		return nil, errors.New("only superusers can impersonate other users")
+	}
+	// Tokens have no session that could be switched.
+	if _, ok := usersession.FromContext(ctx); !ok {
+		return nil, errors.New("impersonation requires a login session")
+	}
+	if id == authUser.ID {
+		return nil, errors.New("users cannot impersonate themselves")
+	}
+	u, err := r.Services.User.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Services.User.Impersonate(ctx, authUser, u); err != nil {
+		return nil, err
+	}
+	if err := r.Services.Audit.LogSystem(ctx, nil, audit.ActionImpersonated, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *mutationResolver) StopImpersonation(ctx context.Context) (*entity.User, error) {
+	impersonator, ok := session.ImpersonatorFromContext(ctx)
+	if !ok {
+		return nil, errors.New("no user is impersonated")
+	}
+	authUser, err := session.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Logged before the switch, so both users are recorded.
+	if err := r.Services.Audit.LogSystem(ctx, nil, audit.ActionImpersonationEnded, authUser); err != nil {
+		return nil, err
+	}
+	if err := r.Services.User.StopImpersonation(ctx, impersonator); err != nil {
+		return nil, err
+	}
+	return impersonator, nil
+}
+
 func toUserEntity(input gqlmodels.UserInput) *entity.User {
 	return &entity.User{
 		ID:          handleIntPtr(input.ID),
 		Name:        input.Name,
+		Email:       handleStringPtr(input.Email),
 		Password:    input.Password,
 		IsSuperuser: input.IsSuperuser,
 	}
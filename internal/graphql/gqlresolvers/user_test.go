@@ -4,11 +4,16 @@ import (
 	"context"
 	"strconv"
 	"testing"
+	"time"
 
+	"go-webapp-example/internal/graphql/gqldirectives"
 	"go-webapp-example/internal/pkg"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/throttle"
 
 	"github.com/99designs/gqlgen/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGraphQL_User(t *testing.T) {
@@ -29,17 +34,21 @@ func TestGraphQL_User(t *testing.T) {
 func testUsersQuery(c *client.Client) func(t *testing.T) {
 	return func(t *testing.T) {
 		var resp struct {
-			Users []struct {
-				ID          string `json:"id"`
-				Name        string
-				IsSuperuser bool `json:"is_superuser"`
-				Roles       []struct {
-					ID   string
-					Name string
-				}
-				Permissions []struct {
-					Code  string
-					Level string
+			Users struct {
+				Edges []struct {
+					Node struct {
+						ID          string `json:"id"`
+						Name        string
+						IsSuperuser bool `json:"is_superuser"`
+						Roles       []struct {
+							ID   string
+							Name string
+						}
+						Permissions []struct {
+							Code  string
+							Level string
+						}
+					}
 				}
 			}
 		}
@@ -47,26 +56,31 @@ func testUsersQuery(c *client.Client) func(t *testing.T) {
 		c.MustPost(`
 			query Users {
 				users {
-					id
-					name
-					is_superuser
-					roles {
-						id
-						name
-					}
-					permissions {
-						code
-						level
+					edges {
+						node {
+							id
+							name
+							is_superuser
+							roles {
+								id
+								name
+							}
+							permissions {
+								code
+								level
+							}
+						}
 					}
 				}
 			}`, &resp)
 
-		assert.Equal(t, resp.Users[0].ID, "1")
-		assert.Equal(t, resp.Users[0].Name, "admin")
-		assert.Equal(t, resp.Users[0].IsSuperuser, true)
-		assert.Equal(t, resp.Users[0].Roles[0].ID, "1")
-		assert.Equal(t, resp.Users[0].Permissions[0].Code, "device")
-		assert.Equal(t, resp.Users[0].Permissions[0].Level, "edit")
+		users := resp.Users.Edges
+		assert.Equal(t, users[0].Node.ID, "1")
+		assert.Equal(t, users[0].Node.Name, "admin")
+		assert.Equal(t, users[0].Node.IsSuperuser, true)
+		assert.Equal(t, users[0].Node.Roles[0].ID, "1")
+		assert.Equal(t, users[0].Node.Permissions[0].Code, "device")
+		assert.Equal(t, users[0].Node.Permissions[0].Level, "edit")
 	}
 }
 
@@ -198,3 +212,153 @@ func testDeleteUser(c *client.Client, services *pkg.Services) func(t *testing.T)
 		assert.Error(t, err)
 	}
 }
+
+func TestGraphQL_UserRestricted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, _, cleanup := testClientAs(t, &entity.User{Name: "user", ID: 2})
+	defer cleanup()
+
+	t.Run("Users Query", func(t *testing.T) {
+		var resp struct {
+			Users struct {
+				Edges []struct{ Node struct{ ID string } }
+			}
+		}
+		err := c.Post(`query { users { edges { node { id } } } }`, &resp)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), gqldirectives.ErrMissingPermission)
+	})
+
+	t.Run("authUser Query", func(t *testing.T) {
+		var resp struct {
+			AuthUser struct {
+				ID string
+			}
+		}
+		c.MustPost(`query { authUser { id } }`, &resp)
+		assert.Equal(t, "2", resp.AuthUser.ID)
+	})
+
+	t.Run("unlockUser", func(t *testing.T) {
+		var resp struct {
+			UnlockUser struct {
+				Name string
+			}
+		}
+		err := c.Post(`mutation UnlockUser($id: ID!) { unlockUser(id: $id) { name } }`, &resp, client.Var("id", 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("revokeAllSessions", func(t *testing.T) {
+		var resp struct {
+			RevokeAllSessions []struct {
+				ID string
+			}
+		}
+		err := c.Post(`mutation Revoke($id: ID!) { revokeAllSessions(userId: $id) { id } }`, &resp, client.Var("id", 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("impersonateUser", func(t *testing.T) {
+		var resp struct {
+			ImpersonateUser struct {
+				ID string
+			}
+		}
+		err := c.Post(`mutation Impersonate($id: ID!) { impersonateUser(id: $id) { id } }`, &resp, client.Var("id", 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("stopImpersonation", func(t *testing.T) {
+		var resp struct {
+			StopImpersonation struct {
+				ID string
+			}
+		}
+		err := c.Post(`mutation { stopImpersonation { id } }`, &resp)
+		assert.Error(t, err)
+	})
+
+	t.Run("impersonator Query", func(t *testing.T) {
+		var resp struct {
+			Impersonator *struct {
+				ID string
+			}
+		}
+		c.MustPost(`query { impersonator { id } }`, &resp)
+		assert.Nil(t, resp.Impersonator)
+	})
+}
+
+func TestGraphQL_UnlockUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, services.Throttle.Fail(ctx, "admin", "127.0.0.1"))
+	}
+	_, err := services.Throttle.Check(ctx, "admin", "")
+	assert.Equal(t, throttle.ErrLocked, err)
+
+	var resp struct {
+		UnlockUser struct {
+			Name string
+		}
+	}
+	c.MustPost(`mutation UnlockUser($id: ID!) { unlockUser(id: $id) { name } }`, &resp, client.Var("id", 1))
+
+	assert.Equal(t, "admin", resp.UnlockUser.Name)
+	_, err = services.Throttle.Check(ctx, "admin", "")
+	assert.NoError(t, err)
+}
+
+func TestGraphQL_UserChanged(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	c, services, cleanup := testClient(t)
+	defer cleanup()
+
+	sub := c.Websocket(`subscription { userChanged(id: 2) { action fields user { name roles { id } } } }`)
+	defer sub.Close()
+	require.Eventually(t, func() bool {
+		return services.Events.Subscribers(entity.KindUser) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	var resp struct {
+		UserChanged struct {
+			Action string
+			Fields []string
+			User   struct {
+				Name  string
+				Roles []struct{ ID string }
+			}
+		} `json:"userChanged"`
+	}
+	// The update of the user is followed by the update of its roles.
+	for _, roleID := range []string{"3", "2"} {
+		var updated struct{ UpdateUser struct{ ID string } }
+		c.MustPost(`mutation($role: ID!) {
+				updateUser(input: {
+					id: 2, name: "user", password: "", password_repeat: "", is_superuser: false, roles: [{ id: $role }]
+				}) { id }
+			}`, &updated, client.Var("role", roleID))
+
+		require.NoError(t, sub.Next(&resp))
+		assert.Equal(t, "UPDATED", resp.UserChanged.Action)
+		require.NoError(t, sub.Next(&resp))
+		assert.Contains(t, resp.UserChanged.Fields, "roles")
+		if assert.Len(t, resp.UserChanged.User.Roles, 1) {
+			assert.Equal(t, roleID, resp.UserChanged.User.Roles[0].ID)
+		}
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"runtime/debug"
 	"time"
 
+	"go-webapp-example/internal/graphql/gqlallowlist"
 	"go-webapp-example/internal/graphql/gqldataloaders"
 	"go-webapp-example/internal/graphql/gqldirectives"
+	"go-webapp-example/internal/graphql/gqllimits"
 	"go-webapp-example/internal/graphql/gqlresolvers"
 	"go-webapp-example/internal/graphql/gqlserver"
 	"go-webapp-example/internal/pkg"
 	internalauth "go-webapp-example/internal/pkg/auth"
+	"go-webapp-example/internal/pkg/throttle"
 	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/i18n"
 	"go-webapp-example/pkg/log"
@@ -36,9 +39,12 @@ func New(
 	logger log.Logger,
 	locale *i18n.Locale,
 	storageDir string,
-) (http.Handler, http.Handler) {
+	devBypass bool,
+	limits gqllimits.Config,
+	persistedQueries gqlallowlist.Config,
+) (http.Handler, http.Handler, error) {
 	// authMiddleware is used to authenticate the user and apply directives (like @has)
-	authMiddleware := internalauth.Middleware(services.User, sess, logger.WithPrefix("auth.mdlwr"), true)
+	authMiddleware := internalauth.Middleware(services.User, services.Token, services.TwoFactor, services.Session, sess, logger.WithPrefix("auth.mdlwr"), true, devBypass)
 
 	// resolver contains all shared dependencies.
 	resolver := &gqlresolvers.Resolver{
@@ -48,11 +54,23 @@ func New(
 	}
 
 	c := gqlserver.Config{Resolvers: resolver}
-	c.Directives.Restricted = gqldirectives.Restricted(authMngr)
+	c.Directives.Restricted = gqldirectives.Restricted(authMngr, services.Permission)
 
 	schema := gqlserver.NewExecutableSchema(c)
+	if err := gqldirectives.CheckPermissions(schema.Schema(), services.Permission); err != nil {
+		return nil, nil, err
+	}
+
+	var allowList *gqlallowlist.AllowList
+	if persistedQueries.Mode != gqlallowlist.ModeOff {
+		var err error
+		if allowList, err = gqlallowlist.New(persistedQueries, logger.WithPrefix("graphql.allowlist")); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	srv := newServer(schema, logger)
+	srv := newServer(schema, logger, services.File.Config().MaxSize, limits, allowList)
+	srv.AroundFields(gqldirectives.HideRestrictedFields(schema.Schema(), authMngr, services.Permission))
 
 	// query is the global GraphQL endpoint each query is sent to.
 	query := withMiddleware(
@@ -60,11 +78,12 @@ func New(
 		authMiddleware,
 		i18n.Middleware(locale),
 		gqldataloaders.Middleware(services),
+		throttle.Middleware,
 	)
 	// playground is used to directly access the graphql api.
 	pg := withMiddleware(playground.Handler("GraphQL playground", "/backend/query"), authMiddleware)
 
-	return query, pg
+	return query, pg, nil
 }
 
 // withMiddleware applies multiple middleware to a http.Handler.
@@ -75,8 +94,17 @@ func withMiddleware(h http.Handler, middleware ...func(http.Handler) http.Handle
 	return h
 }
 
-func newServer(es graphql.ExecutableSchema, logger log.Logger) *handler.Server {
-	srv := handler.New(es)
+// newServer returns the GraphQL server. Multipart requests can be slightly larger than
+// the largest file that can be uploaded, they also contain the operation. Without an allow-list
+// automatic persisted queries are supported, in enforce mode only the operations of the allow-list are.
+func newServer(
+	es graphql.ExecutableSchema,
+	logger log.Logger,
+	maxUploadSize int64,
+	limits gqllimits.Config,
+	allowList *gqlallowlist.AllowList,
+) *handler.Server {
+	srv := handler.New(gqllimits.WithCosts(es))
 
 	srv.AddTransport(transport.Websocket{
 		KeepAlivePingInterval: 15 * time.Second,
@@ -89,13 +117,20 @@ func newServer(es graphql.ExecutableSchema, logger log.Logger) *handler.Server {
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
-	srv.AddTransport(transport.MultipartForm{})
+	srv.AddTransport(transport.MultipartForm{MaxUploadSize: maxUploadSize + 1<<20})
 
 	srv.SetQueryCache(lru.New(1000))
 	srv.Use(extension.Introspection{})
-	srv.Use(extension.AutomaticPersistedQuery{
-		Cache: lru.New(100),
-	})
+	srv.Use(gqllimits.New(limits, logger.WithPrefix("graphql.limits")))
+	if allowList != nil {
+		// The allow-list resolves the hashes of the manifest before automatic persisted queries.
+		srv.Use(allowList)
+	}
+	if allowList == nil || allowList.Mode() != gqlallowlist.ModeEnforce {
+		srv.Use(extension.AutomaticPersistedQuery{
+			Cache: lru.New(100),
+		})
+	}
 	srv.SetRecoverFunc(func(ctx context.Context, err interface{}) (userMessage error) {
 		logger.Errorf("%+v", err)
 		logger.Errorf("%s", debug.Stack())
@@ -10,6 +10,7 @@ import (
 )
 
 var _ ChangeAuditor = &MockAuditor{}
+var _ ExpiryAuditor = &MockAuditor{}
 
 type SystemAuditor interface {
 	LogSystem(ctx context.Context, tx *db.Tx, action string, e entity.Entity) error
@@ -20,6 +21,7 @@ type MockAuditor struct {
 	Updated []entity.AuditLog
 	Deleted []entity.AuditLog
 	Synced  []entity.AuditLog
+	Expired []entity.AuditLog
 }
 
 func NewMockAuditor() *MockAuditor {
@@ -48,10 +50,16 @@ func (a *MockAuditor) LogSync(ctx context.Context, tx *db.Tx, e entity.Entity, r
 	return nil
 }
 
+func (a *MockAuditor) LogExpired(ctx context.Context, tx *db.Tx, e entity.Entity, relation string, value interface{}) error {
+	a.Expired = append(a.Expired, getMockLog(e))
+	return nil
+}
+
 func (a *MockAuditor) Clear() {
 	a.Created = []entity.AuditLog{}
 	a.Updated = []entity.AuditLog{}
 	a.Deleted = []entity.AuditLog{}
+	a.Expired = []entity.AuditLog{}
 }
 
 func getMockLog(e entity.Entity) entity.AuditLog {
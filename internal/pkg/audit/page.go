@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+	"gopkg.in/guregu/null.v3"
+)
+
+// sortColumns contains the columns audit logs can be sorted by.
+var sortColumns = []string{"id", "created_at"}
+
+// Filter limits the audit logs of a page. Empty fields match all audit logs.
+type Filter struct {
+	EntityType entity.Kind
+	EntityID   null.Int
+	// UserID matches the audit logs of the changes made by a user, including
+	// the changes made while they impersonated other users.
+	UserID       null.Int
+	Action       string
+	Field        string
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+}
+
+// where returns the conditions of the filter.
+func (f Filter) where() []sq.Sqlizer {
+	where := []sq.Sqlizer{db.Between("created_at", f.CreatedFrom, f.CreatedUntil)}
+	if f.EntityType != "" {
+		where = append(where, sq.Eq{"entity_type": f.EntityType})
+	}
+	if f.EntityID.Valid {
+		where = append(where, sq.Eq{"entity_id": f.EntityID.Int64})
+	}
+	if f.UserID.Valid {
+		where = append(where, sq.Eq{"user_id": f.UserID.Int64})
+	}
+	if f.Action != "" {
+		where = append(where, sq.Eq{"action": f.Action})
+	}
+	if f.Field != "" {
+		where = append(where, sq.Eq{"field": f.Field})
+	}
+	return where
+}
+
+// GetPage returns a page of the audit logs that match the filter.
+func (s Store) GetPage(ctx context.Context, filter Filter, sorts []db.Sort, args db.PageArgs) ([]*entity.AuditLog, *db.PageInfo, error) {
+	if err := db.CheckSorts(sorts, sortColumns...); err != nil {
+		return nil, nil, err
+	}
+	var logs []*entity.AuditLog
+	info, err := s.db.Paginate(ctx, &logs, "auditlogs", filter.where(), sorts, args)
+	return logs, info, err
+}
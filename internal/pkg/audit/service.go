@@ -22,15 +22,28 @@ type ChangeAuditor interface {
 	LogSync(ctx context.Context, tx *db.Tx, e entity.Entity, relation string, valuesNew interface{}, valuesOld interface{}) error
 }
 
+// ExpiryAuditor logs relationships that ended because they expired.
+type ExpiryAuditor interface {
+	LogExpired(ctx context.Context, tx *db.Tx, e entity.Entity, relation string, value interface{}) error
+}
+
 var _ ChangeAuditor = &Service{}
+var _ ExpiryAuditor = &Service{}
 
 const (
 	ActionCreated = "created"
 	ActionUpdated = "updated"
 	ActionDeleted = "deleted"
 
-	ActionLoggedIn = "loggedin"
-	ActionUnknown  = "unknown"
+	ActionLoggedIn    = "loggedin"
+	ActionLoginFailed = "loginfailed"
+	ActionLockedOut   = "lockedout"
+	ActionUnlocked    = "unlocked"
+
+	ActionImpersonated       = "impersonated"
+	ActionImpersonationEnded = "impersonationended"
+	ActionExpired            = "expired"
+	ActionUnknown            = "unknown"
 )
 
 // Service is used to interact with the entity. It
@@ -120,12 +133,29 @@ func (s Service) LogSync(ctx context.Context, tx *db.Tx, e entity.Entity, relati
 	return s.persist(ctx, tx, l)
 }
 
+// LogExpired creates a log entry for a relationship that expired.
+func (s Service) LogExpired(ctx context.Context, tx *db.Tx, e entity.Entity, relation string, value interface{}) error {
+	l := &entity.AuditLog{
+		EntityID:   null.IntFrom(int64(e.Primary())),
+		EntityType: e.Type(),
+		Field:      relation,
+		ValueOld:   fmt.Sprintf("%v", value),
+		Action:     ActionExpired,
+	}
+	return s.persist(ctx, tx, l)
+}
+
 // createLogEntry creates the log entry in the database.
 func (s Service) persist(ctx context.Context, tx *db.Tx, l *entity.AuditLog) error {
-	// Try to fetch the acting user from the context.
+	// Try to fetch the acting user from the context. During an impersonation the
+	// superuser is recorded as actor together with the impersonated user.
 	u, err := session.UserFromContext(ctx)
 	if err == nil {
 		l.UserID = u.Primary()
+		if impersonator, ok := session.ImpersonatorFromContext(ctx); ok {
+			l.UserID = impersonator.Primary()
+			l.ImpersonatedUserID = null.IntFrom(int64(u.Primary()))
+		}
 	}
 
 	_, err = s.Create(ctx, tx, l)
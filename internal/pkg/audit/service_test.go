@@ -10,6 +10,7 @@ import (
 	"go-webapp-example/pkg/clock"
 	"go-webapp-example/pkg/db"
 	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -46,6 +47,7 @@ func TestAuditService(t *testing.T) {
 	t.Run("Get", get(mock, service))
 	t.Run("Find", find(mock, service))
 	t.Run("Create", create(mock, tx, service))
+	t.Run("CreateWithoutTx", createWithoutTx(mock, service))
 	t.Run("Update", update(mock, tx, service))
 	t.Run("Delete", del(mock, tx, service))
 	t.Run("LogSystem", logSystem(mock, tx, service))
@@ -53,6 +55,7 @@ func TestAuditService(t *testing.T) {
 	t.Run("LogUpdate", logChange(mock, tx, service))
 	t.Run("LogDelete", logDelete(mock, tx, service))
 	t.Run("LogSync", logSync(mock, tx, service))
+	t.Run("LogImpersonated", logImpersonated(mock, tx, service))
 }
 
 func get(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
@@ -111,6 +114,7 @@ func create(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.T
 				l.EntityID,
 				l.EntityType,
 				l.Field,
+				l.ImpersonatedUserID,
 				l.Meta,
 				now,
 				l.UserID,
@@ -129,6 +133,22 @@ func create(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.T
 	}
 }
 
+func createWithoutTx(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO auditlogs").
+			WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectCommit()
+
+		result, err := service.Create(context.Background(), nil, &entity.AuditLog{Action: ActionUnlocked})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 4, result.ID)
+	}
+}
+
 func update(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.T) {
 	return func(t *testing.T) {
 		l := &entity.AuditLog{
@@ -151,6 +171,7 @@ func update(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.T
 				l.EntityID,
 				l.EntityType,
 				l.Field,
+				l.ImpersonatedUserID,
 				l.Meta,
 				now,
 				l.UserID,
@@ -194,6 +215,7 @@ func logSystem(mock sqlmock.Sqlmock, tx *db.Tx, service SystemAuditor) func(t *t
 				1,
 				entity.KindUser,
 				"",
+				null.Int{},
 				"",
 				now,
 				0,
@@ -219,6 +241,7 @@ func logCreate(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testin
 				2,
 				entity.KindUser,
 				"",
+				null.Int{},
 				"",
 				now,
 				0,
@@ -249,6 +272,7 @@ func logChange(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testin
 				2,
 				entity.KindQuote,
 				"author",
+				null.Int{},
 				"",
 				now,
 				0,
@@ -277,6 +301,7 @@ func logDelete(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testin
 				2,
 				entity.KindUser,
 				"",
+				null.Int{},
 				"",
 				now,
 				0,
@@ -304,6 +329,7 @@ func logSync(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.
 				2,
 				entity.KindQuote,
 				"roles",
+				null.Int{},
 				"",
 				now,
 				0,
@@ -323,3 +349,32 @@ func logSync(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.
 		assert.NoError(t, err)
 	}
 }
+
+func logImpersonated(mock sqlmock.Sqlmock, tx *db.Tx, service *Service) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock.
+			ExpectExec("INSERT INTO auditlogs").
+			WithArgs(
+				ActionDeleted,
+				now,
+				2,
+				entity.KindQuote,
+				"",
+				null.IntFrom(5),
+				"",
+				now,
+				1,
+				"",
+				"",
+			).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+
+		ctx := context.WithValue(context.Background(), session.CtxKey, &entity.User{ID: 5})
+		ctx = context.WithValue(ctx, session.ImpersonatorCtxKey, &entity.User{ID: 1, IsSuperuser: true})
+
+		err := service.LogDelete(ctx, tx, entity.Quote{ID: 2})
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.NoError(t, err)
+	}
+}
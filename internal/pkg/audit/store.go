@@ -44,7 +44,7 @@ func (s Store) Get(ctx context.Context) ([]*entity.AuditLog, error) {
 
 // Create creates a new entity.
 func (s Store) Create(ctx context.Context, tx *db.Tx, log *entity.AuditLog) (*entity.AuditLog, error) {
-	tx, err := s.ensureTx(tx)
+	tx, done, err := s.ensureTx(tx)
 	if err != nil {
 		return log, errors.WithStack(err)
 	}
@@ -53,21 +53,21 @@ func (s Store) Create(ctx context.Context, tx *db.Tx, log *entity.AuditLog) (*en
 
 	query, params, err := sq.Insert("auditlogs").SetMap(mapCols(log)).ToSql()
 	if err != nil {
-		return log, errors.WithStack(err)
+		return log, done(errors.WithStack(err))
 	}
 
 	res, err := tx.ExecContext(ctx, query, params...)
 	if err != nil {
-		return log, errors.WithStack(err)
+		return log, done(errors.WithStack(err))
 	}
 
 	id, err := res.LastInsertId()
 	if err != nil {
-		return log, errors.WithStack(err)
+		return log, done(errors.WithStack(err))
 	}
 
 	log.ID = int(id)
-	return log, nil
+	return log, done(nil)
 }
 
 // Update saves an updated entity to the database.
@@ -75,7 +75,7 @@ func (s Store) Update(ctx context.Context, tx *db.Tx, log *entity.AuditLog) (*en
 	if log.ID < 1 {
 		return log, errors.WithStack(db.ErrNotExists)
 	}
-	tx, err := s.ensureTx(tx)
+	tx, done, err := s.ensureTx(tx)
 	if err != nil {
 		return log, errors.WithStack(err)
 	}
@@ -84,11 +84,11 @@ func (s Store) Update(ctx context.Context, tx *db.Tx, log *entity.AuditLog) (*en
 
 	query, params, err := sq.Update("auditlogs").SetMap(mapCols(log)).Where(sq.Eq{"id": log.ID}).ToSql()
 	if err != nil {
-		return log, errors.WithStack(err)
+		return log, done(errors.WithStack(err))
 	}
 
 	_, err = tx.ExecContext(ctx, query, params...)
-	return log, errors.WithStack(err)
+	return log, done(errors.WithStack(err))
 }
 
 // Delete removes an entity from the database.
@@ -96,39 +96,47 @@ func (s Store) Delete(ctx context.Context, tx *db.Tx, log *entity.AuditLog) (*en
 	if log.ID < 1 {
 		return log, nil
 	}
-	tx, err := s.ensureTx(tx)
+	tx, done, err := s.ensureTx(tx)
 	if err != nil {
 		return log, errors.WithStack(err)
 	}
 	_, err = tx.ExecContext(ctx, "DELETE FROM auditlogs WHERE id = ? LIMIT 1", log.ID)
-	return log, errors.WithStack(err)
+	return log, done(errors.WithStack(err))
 }
 
-// ensureTx makes sure a "nil" db.Tx is transformed into a proper Transaction.
-func (s Store) ensureTx(tx *db.Tx) (*db.Tx, error) {
-	if tx == nil {
-		newTx, err := s.db.Begin()
+// ensureTx makes sure a "nil" db.Tx is transformed into a proper Transaction. The returned
+// function has to be called with the result of the query, it commits or rolls back a
+// transaction that was started here and leaves the transaction of the caller untouched.
+func (s Store) ensureTx(tx *db.Tx) (*db.Tx, func(err error) error, error) {
+	if tx != nil {
+		return tx, func(err error) error { return err }, nil
+	}
+	newTx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return newTx, func(err error) error {
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return db.RollbackError(newTx, err)
 		}
-		return newTx, nil
-	}
-	return tx, nil
+		return errors.WithStack(newTx.Commit())
+	}, nil
 }
 
 // mapCols maps the entity to all default columns.
 func mapCols(log *entity.AuditLog) db.ColumnMap {
 	return db.ColumnMap{
-		"action":      log.Action,
-		"entity_id":   log.EntityID,
-		"entity_type": log.EntityType,
-		"field":       log.Field,
-		"meta":        log.Meta,
-		"user_id":     log.UserID,
-		"value_new":   log.ValueNew,
-		"value_old":   log.ValueOld,
-		"created_at":  log.CreatedAt,
-		"updated_at":  log.UpdatedAt,
+		"action":               log.Action,
+		"entity_id":            log.EntityID,
+		"entity_type":          log.EntityType,
+		"field":                log.Field,
+		"impersonated_user_id": log.ImpersonatedUserID,
+		"meta":                 log.Meta,
+		"user_id":              log.UserID,
+		"value_new":            log.ValueNew,
+		"value_old":            log.ValueOld,
+		"created_at":           log.CreatedAt,
+		"updated_at":           log.UpdatedAt,
 	}
 }
 
@@ -5,97 +5,393 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"go-webapp-example/internal/pkg/audit"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/passwordpolicy"
 	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
 	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
 	"go-webapp-example/pkg/i18n"
 	"go-webapp-example/pkg/log"
 	"go-webapp-example/pkg/render"
 	"go-webapp-example/pkg/session"
 	"go-webapp-example/pkg/validation"
 
+	"github.com/pkg/errors"
 	"gopkg.in/guregu/null.v3"
 )
 
-// LoginHandler takes in a username and password and creates a new user session.
+// loginUser is the user returned after a successful login.
+type loginUser struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	IsSuperuser bool     `json:"is_superuser"`
+	Permissions []string `json:"permissions"`
+}
+
+// loginResponse is returned by both login steps.
+type loginResponse struct {
+	User loginUser `json:"user"`
+	Ok   bool      `json:"ok"`
+	// TwoFactor is set if the password was correct but the second factor is missing.
+	TwoFactor bool `json:"two_factor"`
+	// PasswordExpired is set if the credentials were correct but the password has to be changed.
+	PasswordExpired bool              `json:"password_expired"`
+	Errors          validation.Errors `json:"errors"`
+}
+
+// pendingLoginTimeout is the time a user has to provide the second factor after the password.
+const pendingLoginTimeout = 5 * time.Minute
+
+// LoginHandler takes in a username and password and creates a new user session. Failed logins
+// are counted per username and client IP, further attempts are delayed or locked by the throttle.
+// If the user enabled two-factor authentication, the session is only created by the
+// TwoFactorHandler after the second factor was checked. Errors of an external authenticator are
+// logged, the user only sees a generic message so no details of the directory are leaked.
 // nolint:errcheck,funlen
-func LoginHandler(service *user.Service, permissonService *permission.Service, auditService *audit.Service, locale *i18n.Locale) http.HandlerFunc {
+func LoginHandler(
+	service *user.Service,
+	permissonService *permission.Service,
+	auditService *audit.Service,
+	throttleService *throttle.Service,
+	twoFactorService *twofactor.Service,
+	sessionService *usersession.Service,
+	locale *i18n.Locale,
+	logger log.Logger,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		type request struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
 		}
 
-		type userResponse struct {
-			ID          int      `json:"id"`
-			Name        string   `json:"name"`
-			IsSuperuser bool     `json:"is_superuser"`
-			Permissions []string `json:"permissions"`
-		}
-
-		type response struct {
-			User   userResponse      `json:"user"`
-			Ok     bool              `json:"ok"`
-			Errors validation.Errors `json:"errors"`
-		}
-
 		var req request
 
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			render.JSON(w, http.StatusUnprocessableEntity, response{Ok: false, Errors: validation.ConvertError("username", err)})
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
 			return
 		}
 
 		v := user.ValidateAuthRequest(req.Username, req.Password)
 		if v.Failed() {
-			render.JSON(w, http.StatusUnprocessableEntity, response{Ok: false, Errors: v.TranslatedErrors(locale)})
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: v.TranslatedErrors(locale)})
 			return
 		}
 
-		u, err := service.Login(r.Context(), req.Username, req.Password)
+		ip := clientIP(r)
+
+		if !checkThrottle(w, r, throttleService, locale, req.Username, ip) {
+			return
+		}
+
+		u, err := service.Authenticate(r.Context(), req.Username, req.Password)
 		if err != nil {
+			err = errors.Cause(err)
+			// Errors of an external authenticator, e.g. an unreachable directory, are no failed logins.
+			if err != entity.ErrUserInvalidPassword && err != entity.ErrNotFound {
+				logger.WithFields(log.Fields{"username": req.Username, "ip": ip}).Errorf("failed to authenticate user: %s", err)
+				render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.NewFromString("username", locale.Get("user.errors.login_unavailable"))})
+				return
+			}
+			if err := throttleService.Fail(r.Context(), req.Username, ip); err != nil {
+				render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
+				return
+			}
 			if err == entity.ErrUserInvalidPassword {
-				render.JSON(w, http.StatusUnprocessableEntity, response{Ok: false, Errors: validation.NewFromString("password", locale.Get("user.errors.wrong_password"))})
+				render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.NewFromString("password", locale.Get("user.errors.wrong_password"))})
 			} else {
-				render.JSON(w, http.StatusUnprocessableEntity, response{Ok: false, Errors: validation.NewFromString("username", locale.Get("user.errors.unknown"))})
+				render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.NewFromString("username", locale.Get("user.errors.unknown"))})
 			}
 			return
 		}
 
-		auditService.Create(r.Context(), nil, &entity.AuditLog{
-			EntityType: entity.KindUser,
-			EntityID:   null.IntFrom(int64(u.ID)),
-			UserID:     u.ID,
-			Action:     audit.ActionLoggedIn,
-		})
+		enabled, err := twoFactorService.Enabled(r.Context(), u.ID)
+		if err != nil {
+			render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
+			return
+		}
+		if enabled {
+			// The password was correct, remember the user until the second factor is provided.
+			if err := service.Session.RenewToken(r.Context()); err != nil {
+				render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
+				return
+			}
+			service.Session.Put(r.Context(), session.PendingAuthKey, u.ID)
+			service.Session.Put(r.Context(), session.PendingAuthExpiryKey, time.Now().Add(pendingLoginTimeout).Unix())
+			render.JSON(w, http.StatusOK, loginResponse{Ok: false, TwoFactor: true})
+			return
+		}
+		if service.PasswordExpired(u) {
+			requirePasswordChange(w, r, u, service)
+			return
+		}
+
+		completeLogin(w, r, u, service, permissonService, auditService, throttleService, sessionService)
+	}
+}
 
-		var permissionStrings []string
-		for _, permission := range permissonService.GetForUserID(r.Context(), u.ID) {
-			permissionStrings = append(permissionStrings, permission.CodeLevel())
+// TwoFactorHandler checks the second factor of a user that passed the password check of
+// the LoginHandler and creates the user session. Both TOTP and recovery codes are accepted.
+// nolint:errcheck,funlen
+func TwoFactorHandler(
+	service *user.Service,
+	permissonService *permission.Service,
+	auditService *audit.Service,
+	throttleService *throttle.Service,
+	twoFactorService *twofactor.Service,
+	sessionService *usersession.Service,
+	sess *session.Store,
+	locale *i18n.Locale,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type request struct {
+			Code string `json:"code"`
+		}
+
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.ConvertError("code", err)})
+			return
 		}
 
-		render.JSON(w, http.StatusOK, response{Ok: true, User: userResponse{
-			ID:          u.ID,
-			Name:        u.Name,
-			IsSuperuser: u.IsSuperuser,
-			Permissions: permissionStrings,
-		}})
+		userID, ok := sess.Get(r.Context(), session.PendingAuthKey).(int)
+		expiresAt, _ := sess.Get(r.Context(), session.PendingAuthExpiryKey).(int64)
+		if !ok || time.Now().Unix() > expiresAt {
+			sess.Remove(r.Context(), session.PendingAuthKey)
+			sess.Remove(r.Context(), session.PendingAuthExpiryKey)
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.NewFromString("code", locale.Get("user.errors.no_pending_login"))})
+			return
+		}
+
+		u, err := service.Find(r.Context(), userID)
+		if err != nil {
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.NewFromString("code", locale.Get("user.errors.unknown"))})
+			return
+		}
+
+		ip := clientIP(r)
+
+		if !checkThrottle(w, r, throttleService, locale, u.Name, ip) {
+			return
+		}
+
+		err = twoFactorService.Verify(r.Context(), u.ID, req.Code)
+		if err != nil {
+			if err != twofactor.ErrInvalidCode {
+				render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("code", err)})
+				return
+			}
+			if err := throttleService.Fail(r.Context(), u.Name, ip); err != nil {
+				render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("code", err)})
+				return
+			}
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, TwoFactor: true, Errors: validation.NewFromString("code", locale.Get("user.errors.wrong_code"))})
+			return
+		}
+
+		sess.Remove(r.Context(), session.PendingAuthKey)
+		sess.Remove(r.Context(), session.PendingAuthExpiryKey)
+
+		if service.PasswordExpired(u) {
+			requirePasswordChange(w, r, u, service)
+			return
+		}
+
+		completeLogin(w, r, u, service, permissonService, auditService, throttleService, sessionService)
 	}
 }
 
+// PasswordChangeHandler sets a new password for a user whose password expired. The user
+// has to pass all other login steps first, the session is created after the change.
+// nolint:errcheck,funlen
+func PasswordChangeHandler(
+	service *user.Service,
+	permissonService *permission.Service,
+	auditService *audit.Service,
+	throttleService *throttle.Service,
+	sessionService *usersession.Service,
+	policy *passwordpolicy.Service,
+	sess *session.Store,
+	locale *i18n.Locale,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type request struct {
+			Password       string `json:"password"`
+			PasswordRepeat string `json:"password_repeat"`
+		}
+
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.ConvertError("new_password", err)})
+			return
+		}
+
+		userID, ok := sess.Get(r.Context(), session.PendingAuthKey).(int)
+		pending, _ := sess.Get(r.Context(), session.PendingPasswordChangeKey).(bool)
+		expiresAt, _ := sess.Get(r.Context(), session.PendingAuthExpiryKey).(int64)
+		if !ok || !pending || time.Now().Unix() > expiresAt {
+			sess.Remove(r.Context(), session.PendingAuthKey)
+			sess.Remove(r.Context(), session.PendingAuthExpiryKey)
+			sess.Remove(r.Context(), session.PendingPasswordChangeKey)
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.NewFromString("new_password", locale.Get("user.errors.no_pending_login"))})
+			return
+		}
+
+		v := user.ValidatePasswordChangeRequest(req.Password, req.PasswordRepeat, policy)
+		if v.Failed() {
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, PasswordExpired: true, Errors: v.TranslatedErrors(locale)})
+			return
+		}
+
+		u, err := service.Find(r.Context(), userID)
+		if err != nil {
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, Errors: validation.NewFromString("new_password", locale.Get("user.errors.unknown"))})
+			return
+		}
+		u.Password = req.Password
+		u, err = service.Update(r.Context(), u)
+		if err == user.ErrPasswordReused {
+			v.Add("new_password", "password_reused")
+			render.JSON(w, http.StatusUnprocessableEntity, loginResponse{Ok: false, PasswordExpired: true, Errors: v.TranslatedErrors(locale)})
+			return
+		}
+		if err != nil {
+			render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("new_password", err)})
+			return
+		}
+
+		sess.Remove(r.Context(), session.PendingAuthKey)
+		sess.Remove(r.Context(), session.PendingAuthExpiryKey)
+		sess.Remove(r.Context(), session.PendingPasswordChangeKey)
+
+		completeLogin(w, r, u, service, permissonService, auditService, throttleService, sessionService)
+	}
+}
+
+// requirePasswordChange remembers a user whose credentials were correct until the expired password is changed.
+func requirePasswordChange(w http.ResponseWriter, r *http.Request, u *entity.User, service *user.Service) {
+	if err := service.Session.RenewToken(r.Context()); err != nil {
+		render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
+		return
+	}
+	service.Session.Put(r.Context(), session.PendingAuthKey, u.ID)
+	service.Session.Put(r.Context(), session.PendingAuthExpiryKey, time.Now().Add(pendingLoginTimeout).Unix())
+	service.Session.Put(r.Context(), session.PendingPasswordChangeKey, true)
+	render.JSON(w, http.StatusOK, loginResponse{Ok: false, PasswordExpired: true})
+}
+
+// checkThrottle renders an error and returns false if the login of username from ip is throttled.
+func checkThrottle(w http.ResponseWriter, r *http.Request, throttleService *throttle.Service, locale *i18n.Locale, username, ip string) bool {
+	wait, err := throttleService.Check(r.Context(), username, ip)
+	if err == nil {
+		return true
+	}
+	if err != throttle.ErrLocked && err != throttle.ErrThrottled {
+		render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
+		return false
+	}
+	seconds := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+	key := "user.errors.throttled"
+	if err == throttle.ErrLocked {
+		key = "user.errors.locked"
+	}
+	w.Header().Set("Retry-After", seconds)
+	render.JSON(w, http.StatusTooManyRequests, loginResponse{Ok: false, Errors: validation.NewFromString("username", locale.GetVar(key, map[string]string{"seconds": seconds}))})
+	return false
+}
+
+// completeLogin creates and tracks the session of an authenticated user and renders the login response.
+// nolint:errcheck
+func completeLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	u *entity.User,
+	service *user.Service,
+	permissonService *permission.Service,
+	auditService *audit.Service,
+	throttleService *throttle.Service,
+	sessionService *usersession.Service,
+) {
+	if err := startSession(r, u, service, auditService, throttleService, sessionService); err != nil {
+		render.JSON(w, http.StatusInternalServerError, loginResponse{Ok: false, Errors: validation.ConvertError("username", err)})
+		return
+	}
+
+	var permissionStrings []string
+	for _, permission := range permissonService.GetForUserID(r.Context(), u.ID) {
+		permissionStrings = append(permissionStrings, permission.CodeLevel())
+	}
+
+	render.JSON(w, http.StatusOK, loginResponse{Ok: true, User: loginUser{
+		ID:          u.ID,
+		Name:        u.Name,
+		IsSuperuser: u.IsSuperuser,
+		Permissions: permissionStrings,
+	}})
+}
+
+// startSession creates the session of an authenticated user, tracks it so it can be
+// listed and revoked, resets the failed logins and logs the login.
+// nolint:errcheck
+func startSession(
+	r *http.Request,
+	u *entity.User,
+	service *user.Service,
+	auditService *audit.Service,
+	throttleService *throttle.Service,
+	sessionService *usersession.Service,
+) error {
+	if err := service.StartSession(r.Context(), u); err != nil {
+		return err
+	}
+
+	us, err := sessionService.Start(r.Context(), u.ID, clientIP(r), r.UserAgent())
+	if err != nil {
+		return err
+	}
+	service.Session.Put(r.Context(), session.IDKey, us.Key)
+
+	throttleService.Succeed(r.Context(), u.Name)
+
+	auditService.Create(r.Context(), nil, &entity.AuditLog{
+		EntityType: entity.KindUser,
+		EntityID:   null.IntFrom(int64(u.ID)),
+		UserID:     u.ID,
+		Action:     audit.ActionLoggedIn,
+	})
+	return nil
+}
+
 // LogoutHandler invalidates the current session.
 // nolint:errcheck,funlen
-func LogoutHandler(s *session.Store) http.HandlerFunc {
+func LogoutHandler(s *session.Store, sessionService *usersession.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		type response struct {
 			Ok    bool   `json:"ok"`
 			Error string `json:"error"`
 		}
 
+		if key, ok := s.Get(r.Context(), session.IDKey).(string); ok {
+			if err := sessionService.DeleteByKey(r.Context(), key); err != nil {
+				render.JSON(w, http.StatusInternalServerError, response{Ok: false, Error: fmt.Sprintf("failed to remove user session: %s", err)})
+				return
+			}
+		}
+
 		err := s.Destroy(r.Context())
 		if err != nil {
 			render.JSON(w, http.StatusInternalServerError, response{Ok: false, Error: fmt.Sprintf("failed to remove user session: %s", err)})
@@ -105,57 +401,125 @@ func LogoutHandler(s *session.Store) http.HandlerFunc {
 	}
 }
 
-// Middleware checks the session cookies against the sessions database table. If the cookies does
-// not match our data, the user receives a forbidden response.
-// nolint:errcheck
-func Middleware(userStore *user.Service, sess *session.Store, logger log.Logger, allowAnonymous bool) func(http.Handler) http.Handler {
+// DevUser is the user every request runs as if the authentication is bypassed.
+var DevUser = &entity.User{Name: "admin", ID: 1, IsSuperuser: true}
+
+// Middleware checks the session cookies against the sessions database table. Requests that send
+// an "Authorization: Bearer" header are authenticated using a personal access token instead. If
+// neither matches our data, the user receives a forbidden response. Sessions that were revoked
+// are destroyed. If devBypass is set, no session is required and every request runs as the
+// DevUser. Requests of users that have to set up two-factor authentication are marked, the
+// graphql directive limits their access. If a superuser impersonates another user, the request
+// runs as that user and the superuser is added to the context.
+// nolint:errcheck,funlen
+func Middleware(
+	userStore *user.Service,
+	tokenService *token.Service,
+	twoFactorService *twofactor.Service,
+	sessionService *usersession.Service,
+	sess *session.Store,
+	logger log.Logger,
+	allowAnonymous,
+	devBypass bool,
+) func(http.Handler) http.Handler {
+	if devBypass {
+		logger.Warn("authentication is bypassed, all requests run as the admin user")
+		return UserMiddleware(DevUser)
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Bypass Authentication completely for testing purposes. Remove this if block for production use.
-			// This is added so you can test the server without having to log in.
-			if true {
-				ctx := context.WithValue(r.Context(), session.CtxKey, &entity.User{Name: "admin", ID: 1, IsSuperuser: true})
-
-				r = r.WithContext(ctx)
-				next.ServeHTTP(w, r)
-				return
-			}
-
 			handleMissingAuth := missingAuthHandler(w, logger, allowAnonymous, func() {
 				next.ServeHTTP(w, r)
 			})
 
-			c, err := r.Cookie(session.CookieName)
+			ctx := r.Context()
 
-			if err != nil || c == nil {
-				handleMissingAuth("unauthenticated user (no cookie provided)", http.StatusForbidden)
-				return
-			}
+			var userID, impersonatorID int
+			if secret := bearerToken(r); secret != "" {
+				t, err := tokenService.Authenticate(ctx, secret)
+				if err != nil {
+					handleMissingAuth(fmt.Sprintf("unauthenticated user (invalid bearer token): %s", err), http.StatusForbidden)
+					return
+				}
+				userID = t.UserID
+				ctx = token.WithContext(ctx, t)
+			} else {
+				c, err := r.Cookie(session.CookieName)
 
-			sessionUserID := sess.Get(r.Context(), session.AuthKey)
-			if sessionUserID == nil {
-				handleMissingAuth("unauthenticated user (no session available)", http.StatusForbidden)
-				return
-			}
+				if err != nil || c == nil {
+					handleMissingAuth("unauthenticated user (no cookie provided)", http.StatusForbidden)
+					return
+				}
 
-			userID, valid := sessionUserID.(int)
-			if !valid {
-				msg := fmt.Sprintf("invalid session id value fetched from session: %v", sessionUserID)
-				handleMissingAuth(msg, http.StatusInternalServerError)
-				return
+				sessionUserID := sess.Get(ctx, session.AuthKey)
+				if sessionUserID == nil {
+					handleMissingAuth("unauthenticated user (no session available)", http.StatusForbidden)
+					return
+				}
+
+				var valid bool
+				userID, valid = sessionUserID.(int)
+				if !valid {
+					msg := fmt.Sprintf("invalid session id value fetched from session: %v", sessionUserID)
+					handleMissingAuth(msg, http.StatusInternalServerError)
+					return
+				}
+
+				// During an impersonation the tracked session belongs to the superuser.
+				sessionOwner := userID
+				if id, ok := sess.Get(ctx, session.ImpersonatorKey).(int); ok {
+					impersonatorID, sessionOwner = id, id
+				}
+
+				key, _ := sess.Get(ctx, session.IDKey).(string)
+				us, err := sessionService.Authenticate(ctx, key, sessionOwner)
+				if err != nil {
+					if errors.Cause(err) != usersession.ErrNotFound {
+						handleMissingAuth(fmt.Sprintf("failed to check session: %s", err), http.StatusInternalServerError)
+						return
+					}
+					// The session was revoked, remove the stale session data.
+					sess.Destroy(ctx)
+					handleMissingAuth("unauthenticated user (session was revoked)", http.StatusForbidden)
+					return
+				}
+				ctx = usersession.WithContext(ctx, us)
 			}
 
 			// get the user from the database
-			u, err := userStore.Find(r.Context(), userID)
+			u, err := userStore.Find(ctx, userID)
 			if err != nil {
 				msg := fmt.Sprintf("invalid user id %d provided: %s", userID, err)
 				handleMissingAuth(msg, http.StatusForbidden)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), session.CtxKey, u)
+			ctx = context.WithValue(ctx, session.CtxKey, u)
+
+			// The second factor is checked for the user that logged in.
+			actor := u
+			if impersonatorID != 0 {
+				impersonator, err := userStore.Find(ctx, impersonatorID)
+				if err != nil || !impersonator.IsSuperuser {
+					// The superuser was removed or demoted, end the impersonation with the session.
+					sess.Destroy(ctx)
+					handleMissingAuth(fmt.Sprintf("invalid impersonator id %d provided", impersonatorID), http.StatusForbidden)
+					return
+				}
+				ctx = context.WithValue(ctx, session.ImpersonatorCtxKey, impersonator)
+				actor = impersonator
+			}
+
+			setupRequired, err := twoFactorService.SetupRequired(ctx, actor.ID)
+			if err != nil {
+				handleMissingAuth(fmt.Sprintf("failed to check two-factor authentication: %s", err), http.StatusInternalServerError)
+				return
+			}
+			if setupRequired {
+				ctx = twofactor.WithSetupRequired(ctx)
+			}
 
-			logger.Tracef("logged in user is %s (%d)", u.Name, u.ID)
+			logger.Tracef("logged in user is %s (%d), authenticated as %s (%d)", u.Name, u.ID, actor.Name, actor.ID)
 
 			r = r.WithContext(ctx)
 			next.ServeHTTP(w, r)
@@ -163,6 +527,32 @@ func Middleware(userStore *user.Service, sess *session.Store, logger log.Logger,
 	}
 }
 
+// clientIP returns the IP of the client without the port.
+func clientIP(r *http.Request) string {
+	return throttle.ClientIP(r)
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// UserMiddleware runs every request as the provided user. It is used to bypass
+// the authentication during development and to run tests as a specific user.
+func UserMiddleware(u *entity.User) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), session.CtxKey, u)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // missingAuthHandler returns a function that correctly handles the missing auth case.
 func missingAuthHandler(w io.Writer, logger log.Logger, allowAnonymous bool, ignoreAuthAndProceed func()) func(msg string, status int) {
 	type response struct {
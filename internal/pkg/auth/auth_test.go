@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/pkg/i18n"
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("DevBypass", func(t *testing.T) {
+		var u *entity.User
+		h := Middleware(nil, nil, nil, nil, nil, log.NewNullLogger(), false, true)(userRecorder(&u))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, DevUser, u)
+	})
+
+	t.Run("MissingCookie", func(t *testing.T) {
+		var u *entity.User
+		h := Middleware(nil, nil, nil, nil, nil, log.NewNullLogger(), false, false)(userRecorder(&u))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Nil(t, u)
+	})
+
+	t.Run("MissingCookieAnonymous", func(t *testing.T) {
+		var u *entity.User
+		h := Middleware(nil, nil, nil, nil, nil, log.NewNullLogger(), true, false)(userRecorder(&u))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, u)
+	})
+
+	t.Run("BearerToken", func(t *testing.T) {
+		conn, mock := test.MockDB(t)
+		tokenService := token.NewService(token.NewStore(conn, nil))
+		userService := user.NewService(user.NewStore(conn, nil, nil), nil, nil, nil)
+
+		mock.
+			ExpectQuery("SELECT .+ FROM tokens WHERE hash = . LIMIT 1").
+			WithArgs(token.Hash("secret")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow(3, 2, time.Now().Add(time.Hour)))
+		mock.ExpectExec("UPDATE tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery("SELECT .+ FROM users WHERE id = . LIMIT 1").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "user"))
+		mock.
+			ExpectQuery("SELECT COUNT.+ FROM role_user INNER JOIN roles").
+			WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		var u *entity.User
+		h := Middleware(userService, tokenService, twofactor.NewService(twofactor.NewStore(conn, nil), "test"), nil, nil, log.NewNullLogger(), false, false)(userRecorder(&u))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 2, u.ID)
+	})
+
+	t.Run("InvalidBearerToken", func(t *testing.T) {
+		conn, mock := test.MockDB(t)
+		tokenService := token.NewService(token.NewStore(conn, nil))
+
+		mock.
+			ExpectQuery("SELECT .+ FROM tokens WHERE hash = . LIMIT 1").
+			WithArgs(token.Hash("secret")).
+			WillReturnError(sql.ErrNoRows)
+
+		var u *entity.User
+		h := Middleware(nil, tokenService, nil, nil, nil, log.NewNullLogger(), false, false)(userRecorder(&u))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Nil(t, u)
+	})
+}
+
+func TestLoginHandlerLocked(t *testing.T) {
+	conn, mock := test.MockDB(t)
+	throttleService := throttle.NewService(throttle.NewStore(conn), nil, throttle.Config{MaxFailures: 3, Lockout: time.Hour})
+
+	mock.
+		ExpectQuery("SELECT .+ FROM login_attempts WHERE scope = . AND subject = . LIMIT 1").
+		WithArgs(entity.LoginScopeUsername, "admin").
+		WillReturnRows(sqlmock.
+			NewRows([]string{"id", "scope", "subject", "failures", "last_failure_at", "locked_until"}).
+			AddRow(1, entity.LoginScopeUsername, "admin", 3, time.Now(), time.Now().Add(time.Minute)))
+	mock.
+		ExpectQuery("SELECT .+ FROM login_attempts WHERE scope = . AND subject = . LIMIT 1").
+		WithArgs(entity.LoginScopeIP, "192.0.2.1").
+		WillReturnError(sql.ErrNoRows)
+
+	h := LoginHandler(nil, nil, nil, throttleService, nil, nil, &i18n.Locale{}, log.NewNullLogger())
+
+	req := httptest.NewRequest(http.MethodPost, "/backend/login", strings.NewReader(`{"username":"admin","password":"admin"}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
+
+// failingAuthenticator fails like an unreachable directory server.
+type failingAuthenticator struct{}
+
+func (failingAuthenticator) Authenticate(ctx context.Context, username, password string) (*entity.User, error) {
+	return nil, errors.New("ldap: dial tcp 10.0.0.5:636: connection refused")
+}
+
+func TestLoginHandlerAuthenticatorError(t *testing.T) {
+	conn, mock := test.MockDB(t)
+	throttleService := throttle.NewService(throttle.NewStore(conn), nil, throttle.Config{MaxFailures: 3, Lockout: time.Hour})
+	userService := user.NewService(nil, nil, nil, nil)
+	userService.UseAuthenticator(failingAuthenticator{})
+
+	mock.
+		ExpectQuery("SELECT .+ FROM login_attempts WHERE scope = . AND subject = . LIMIT 1").
+		WithArgs(entity.LoginScopeUsername, "admin").
+		WillReturnError(sql.ErrNoRows)
+	mock.
+		ExpectQuery("SELECT .+ FROM login_attempts WHERE scope = . AND subject = . LIMIT 1").
+		WithArgs(entity.LoginScopeIP, "192.0.2.1").
+		WillReturnError(sql.ErrNoRows)
+
+	var logs bytes.Buffer
+	locale := &i18n.Locale{Data: map[string]interface{}{
+		"user": map[string]interface{}{"errors": map[string]interface{}{"login_unavailable": "try again later"}},
+	}}
+	h := LoginHandler(userService, nil, nil, throttleService, nil, nil, locale, log.NewFromWriter(&logs))
+
+	req := httptest.NewRequest(http.MethodPost, "/backend/login", strings.NewReader(`{"username":"admin","password":"admin"}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	// The failure is no failed login and its details are only logged.
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "try again later")
+	assert.NotContains(t, rec.Body.String(), "10.0.0.5")
+	assert.Contains(t, logs.String(), "connection refused")
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", clientIP(req))
+
+	// The RealIP middleware sets the remote address without a port.
+	req.RemoteAddr = "2001:db8::1"
+	assert.Equal(t, "2001:db8::1", clientIP(req))
+}
+
+func TestUserMiddleware(t *testing.T) {
+	var u *entity.User
+	fake := &entity.User{ID: 5, Name: "fake"}
+	h := UserMiddleware(fake)(userRecorder(&u))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, fake, u)
+}
+
+// userRecorder returns a handler that stores the user of the request context in u.
+func userRecorder(u **entity.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ctxUser, err := session.UserFromContext(r.Context()); err == nil {
+			*u = ctxUser
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
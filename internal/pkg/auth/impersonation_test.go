@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/auth"
+	"go-webapp-example/pkg/i18n"
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImpersonation switches the session of a superuser to another user and back.
+// nolint:funlen
+func TestImpersonation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, cleanup := test.DB(t)
+	defer cleanup()
+
+	logger := log.NewNullLogger()
+	authManager, err := auth.New(db, logger)
+	require.NoError(t, err)
+	sess := session.New(db.Connection())
+	auditService := audit.NewService(audit.NewStore(db), logger)
+	sessionService := usersession.NewService(usersession.NewStore(db, auditService))
+	policy, err := passwordpolicy.NewPolicy(passwordpolicy.Config{})
+	require.NoError(t, err)
+	userService := user.NewService(
+		user.NewStore(db, authManager, auditService),
+		sess,
+		sessionService,
+		passwordpolicy.NewService(passwordpolicy.NewStore(db), policy),
+	)
+	permissionService := permission.NewService(permission.NewStore(db, authManager), nil)
+	throttleService := throttle.NewService(throttle.NewStore(db), auditService, throttle.Config{MaxFailures: 3, Lockout: time.Hour})
+	twoFactorService := twofactor.NewService(twofactor.NewStore(db, auditService), "test")
+	tokenService := token.NewService(token.NewStore(db, auditService))
+
+	type whoami struct {
+		User         int `json:"user"`
+		Impersonator int `json:"impersonator"`
+	}
+
+	protected := Middleware(userService, tokenService, twoFactorService, sessionService, sess, logger, false, false)
+	mux := http.NewServeMux()
+	mux.Handle("/backend/login", LoginHandler(userService, permissionService, auditService, throttleService, twoFactorService, sessionService, &i18n.Locale{}, log.NewNullLogger()))
+	mux.Handle("/whoami", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res whoami
+		u, _ := session.UserFromContext(r.Context())
+		res.User = u.ID
+		if impersonator, ok := session.ImpersonatorFromContext(r.Context()); ok {
+			res.Impersonator = impersonator.ID
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	})))
+	mux.Handle("/impersonate", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := session.UserFromContext(r.Context())
+		target, err := userService.Find(r.Context(), 2)
+		require.NoError(t, err)
+		require.NoError(t, userService.Impersonate(r.Context(), u, target))
+	})))
+	mux.Handle("/stop", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		impersonator, ok := session.ImpersonatorFromContext(r.Context())
+		require.True(t, ok)
+		require.NoError(t, userService.StopImpersonation(r.Context(), impersonator))
+	})))
+	app := httptest.NewServer(sess.Middleware(mux))
+	defer app.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	get := func(t *testing.T, path string) (int, whoami) {
+		res, err := client.Get(app.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out whoami
+		if path == "/whoami" && res.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		}
+		return res.StatusCode, out
+	}
+
+	res, err := client.Post(app.URL+"/backend/login", "application/json", strings.NewReader(`{"username":"admin","password":"admin"}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	t.Run("Impersonate", func(t *testing.T) {
+		code, _ := get(t, "/impersonate")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, me := get(t, "/whoami")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, whoami{User: 2, Impersonator: 1}, me)
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		code, _ := get(t, "/stop")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, me := get(t, "/whoami")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, whoami{User: 1}, me)
+	})
+
+	t.Run("ImpersonatorDemoted", func(t *testing.T) {
+		code, _ := get(t, "/impersonate")
+		assert.Equal(t, http.StatusOK, code)
+		_, err := db.Exec("UPDATE users SET is_superuser = 0 WHERE id = 1")
+		require.NoError(t, err)
+
+		code, _ = get(t, "/whoami")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/oidc"
+	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
+)
+
+// oidcCookieName is the cookie that holds the state, nonce and PKCE verifier of a running OpenID
+// Connect login. The session cookie cannot be used, browsers do not send strict same-site cookies
+// when the provider redirects back to the callback.
+const oidcCookieName = "gowebapp_oidc"
+
+// oidcCookieTimeout is the time a user has to log in at the provider.
+const oidcCookieTimeout = 10 * time.Minute
+
+// Error codes that are passed to the frontend login page if an OpenID Connect login fails.
+const (
+	oidcErrFailed    = "oidc_failed"
+	oidcErrState     = "oidc_state"
+	oidcErrNoAccount = "oidc_no_account"
+)
+
+// OIDCConfig contains the settings of the OpenID Connect login.
+type OIDCConfig struct {
+	Client   *oidc.Client
+	Identity identity.Config
+	// GroupsClaim is the ID token claim that contains the groups of the user.
+	GroupsClaim string
+	// URL is the base url of the frontend, users are redirected to it after the login.
+	URL string
+}
+
+// OIDCLoginHandler starts the authorization code flow and redirects the user to the identity provider.
+func OIDCLoginHandler(config OIDCConfig, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var values [3]string
+		for i := range values {
+			v, err := oidc.RandomString()
+			if err != nil {
+				redirectLoginError(w, r, config.URL, oidcErrFailed, logger, err)
+				return
+			}
+			values[i] = v
+		}
+		state, nonce, verifier := values[0], values[1], values[2]
+
+		target, err := config.Client.AuthCodeURL(r.Context(), state, nonce, verifier)
+		if err != nil {
+			redirectLoginError(w, r, config.URL, oidcErrFailed, logger, err)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcCookieName,
+			Value:    strings.Join([]string{state, nonce, verifier}, "."),
+			Path:     "/backend/login/oidc",
+			MaxAge:   int(oidcCookieTimeout.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, target, http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler receives the authorization code, resolves the linked user and creates
+// the user session. Users with two-factor authentication still have to provide the second factor
+// using the TwoFactorHandler. The user is redirected to the frontend in any case.
+// nolint:funlen
+func OIDCCallbackHandler(
+	config OIDCConfig,
+	service *user.Service,
+	identityService *identity.Service,
+	auditService *audit.Service,
+	throttleService *throttle.Service,
+	twoFactorService *twofactor.Service,
+	sessionService *usersession.Service,
+	sess *session.Store,
+	logger log.Logger,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var state, nonce, verifier string
+		if c, err := r.Cookie(oidcCookieName); err == nil {
+			if parts := strings.Split(c.Value, "."); len(parts) == 3 {
+				state, nonce, verifier = parts[0], parts[1], parts[2]
+			}
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcCookieName, Path: "/backend/login/oidc", MaxAge: -1, HttpOnly: true})
+
+		query := r.URL.Query()
+		if state == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+			redirectLoginError(w, r, config.URL, oidcErrState, logger, errors.New("invalid oidc state"))
+			return
+		}
+		if e := query.Get("error"); e != "" {
+			redirectLoginError(w, r, config.URL, oidcErrFailed, logger, errors.Errorf("oidc provider returned error %q", e))
+			return
+		}
+
+		claims, err := config.Client.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+		if err != nil {
+			redirectLoginError(w, r, config.URL, oidcErrFailed, logger, err)
+			return
+		}
+
+		username := claims.PreferredUsername
+		if username == "" {
+			username = claims.Email
+		}
+		u, err := identityService.Login(r.Context(), config.Identity, identity.Claims{
+			Subject:       claims.Subject,
+			Email:         claims.Email,
+			EmailVerified: claims.EmailVerified,
+			Username:      username,
+			Groups:        claims.Strings(config.GroupsClaim),
+		})
+		if err != nil {
+			code := oidcErrFailed
+			if err == identity.ErrNoAccount || err == identity.ErrNameTaken {
+				code = oidcErrNoAccount
+			}
+			redirectLoginError(w, r, config.URL, code, logger, err)
+			return
+		}
+
+		enabled, err := twoFactorService.Enabled(r.Context(), u.ID)
+		if err != nil {
+			redirectLoginError(w, r, config.URL, oidcErrFailed, logger, err)
+			return
+		}
+		if enabled {
+			if err := sess.RenewToken(r.Context()); err != nil {
+				redirectLoginError(w, r, config.URL, oidcErrFailed, logger, err)
+				return
+			}
+			sess.Put(r.Context(), session.PendingAuthKey, u.ID)
+			sess.Put(r.Context(), session.PendingAuthExpiryKey, time.Now().Add(pendingLoginTimeout).Unix())
+			http.Redirect(w, r, config.URL+"/login?two_factor=1", http.StatusFound)
+			return
+		}
+
+		if err := startSession(r, u, service, auditService, throttleService, sessionService); err != nil {
+			redirectLoginError(w, r, config.URL, oidcErrFailed, logger, err)
+			return
+		}
+		http.Redirect(w, r, config.URL+"/", http.StatusFound)
+	}
+}
+
+// redirectLoginError logs a failed login and redirects to the frontend login page with an error code.
+func redirectLoginError(w http.ResponseWriter, r *http.Request, base, code string, logger log.Logger, err error) {
+	logger.Warnf("oidc login failed: %s", err)
+	http.Redirect(w, r, base+"/login?error="+url.QueryEscape(code), http.StatusFound)
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/auth"
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/oidc"
+	"go-webapp-example/pkg/oidc/oidctest"
+	"go-webapp-example/pkg/session"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOIDCLogin runs the complete authorization code flow against a stub provider.
+// nolint:funlen
+func TestOIDCLogin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, cleanup := test.DB(t)
+	defer cleanup()
+
+	logger := log.NewNullLogger()
+	authManager, err := auth.New(db, logger)
+	if err != nil {
+		t.Fatalf("failed to load auth manager: %s", err)
+	}
+	sess := session.New(db.Connection())
+	auditService := audit.NewService(audit.NewStore(db), logger)
+	sessionService := usersession.NewService(usersession.NewStore(db, auditService))
+	policy, _ := passwordpolicy.NewPolicy(passwordpolicy.Config{})
+	userService := user.NewService(
+		user.NewStore(db, authManager, auditService),
+		sess,
+		sessionService,
+		passwordpolicy.NewService(passwordpolicy.NewStore(db), policy),
+	)
+	identityService := identity.NewService(identity.NewStore(db, auditService), userService)
+
+	idp := oidctest.NewServer("webapp", "secret")
+	defer idp.Close()
+
+	throttleService := throttle.NewService(throttle.NewStore(db), auditService, throttle.Config{MaxFailures: 3, Lockout: time.Hour})
+	twoFactorService := twofactor.NewService(twofactor.NewStore(db, auditService), "test")
+
+	// newApp starts the login routes with the given identity settings.
+	newApp := func(identityConfig identity.Config) *httptest.Server {
+		mux := http.NewServeMux()
+		app := httptest.NewServer(sess.Middleware(mux))
+		config := OIDCConfig{
+			Client:      oidc.NewClient(idp.Config(app.URL+"/backend/login/oidc/callback"), nil),
+			Identity:    identityConfig,
+			GroupsClaim: "groups",
+			URL:         app.URL,
+		}
+		mux.Handle("/backend/login/oidc", OIDCLoginHandler(config, logger))
+		mux.Handle("/backend/login/oidc/callback", OIDCCallbackHandler(
+			config,
+			userService,
+			identityService,
+			auditService,
+			throttleService,
+			twoFactorService,
+			sessionService,
+			sess,
+			logger,
+		))
+		return app
+	}
+
+	app := newApp(identity.Config{
+		Provider:    "oidc",
+		CreateUsers: true,
+		GroupRoles:  map[string]int{"resellers": 2, "users": 3},
+	})
+	defer app.Close()
+
+	// get requests the url and follows all redirects until the frontend is reached.
+	get := func(t *testing.T, target string) *url.URL {
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Path == "/" || req.URL.Path == "/login" {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		}
+		res, err := client.Get(target)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+		location, err := res.Location()
+		assert.NoError(t, err)
+		return location
+	}
+	login := func(t *testing.T) *url.URL {
+		return get(t, app.URL+"/backend/login/oidc")
+	}
+
+	t.Run("CreateUser", func(t *testing.T) {
+		idp.Claims = map[string]interface{}{
+			"sub":                "jane-1",
+			"preferred_username": "jane",
+			"email":              "jane@example.com",
+			"email_verified":     true,
+			"groups":             []string{"Resellers"},
+		}
+
+		location := login(t)
+
+		assert.Equal(t, "/", location.Path)
+		u, err := userService.FindByName(context.Background(), "jane")
+		assert.NoError(t, err)
+		assert.Equal(t, "jane@example.com", u.Email.String)
+		roles, err := userService.GetRoleIDs(context.Background(), u.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2}, roles)
+		sessions, err := sessionService.GetByUserID(context.Background(), u.ID)
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 1)
+	})
+
+	t.Run("SyncGroups", func(t *testing.T) {
+		idp.Claims["groups"] = []string{"users"}
+
+		location := login(t)
+
+		assert.Equal(t, "/", location.Path)
+		u, err := userService.FindByName(context.Background(), "jane")
+		assert.NoError(t, err)
+		roles, err := userService.GetRoleIDs(context.Background(), u.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3}, roles)
+	})
+
+	t.Run("NoAccount", func(t *testing.T) {
+		app := newApp(identity.Config{Provider: "oidc"})
+		defer app.Close()
+		idp.Claims = map[string]interface{}{"sub": "unknown"}
+
+		location := get(t, app.URL+"/backend/login/oidc")
+
+		assert.Equal(t, "/login", location.Path)
+		assert.Equal(t, "oidc_no_account", location.Query().Get("error"))
+	})
+
+	t.Run("InvalidState", func(t *testing.T) {
+		location := get(t, app.URL+"/backend/login/oidc/callback?code=abc&state=xyz")
+
+		assert.Equal(t, "/login", location.Path)
+		assert.Equal(t, "oidc_state", location.Query().Get("error"))
+	})
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/twofactor"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
+	"go-webapp-example/pkg/auth"
+	"go-webapp-example/pkg/i18n"
+	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPasswordExpired logs in with an expired password and sets a new one.
+// nolint:funlen
+func TestPasswordExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, cleanup := test.DB(t)
+	defer cleanup()
+
+	logger := log.NewNullLogger()
+	authManager, err := auth.New(db, logger)
+	require.NoError(t, err)
+	sess := session.New(db.Connection())
+	auditService := audit.NewService(audit.NewStore(db), logger)
+	sessionService := usersession.NewService(usersession.NewStore(db, auditService))
+	policy, err := passwordpolicy.NewPolicy(passwordpolicy.Config{MinLength: 5, History: 3, MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	policyService := passwordpolicy.NewService(passwordpolicy.NewStore(db), policy)
+	userService := user.NewService(user.NewStore(db, authManager, auditService), sess, sessionService, policyService)
+	permissionService := permission.NewService(permission.NewStore(db, authManager), nil)
+	throttleService := throttle.NewService(throttle.NewStore(db), auditService, throttle.Config{MaxFailures: 3, Lockout: time.Hour})
+	twoFactorService := twofactor.NewService(twofactor.NewStore(db, auditService), "test")
+	locale := &i18n.Locale{}
+
+	// The seeded admin password "admin" was set two days ago and is part of the history.
+	_, err = db.Exec("UPDATE users SET password_changed_at = ? WHERE id = 1", time.Now().Add(-48*time.Hour))
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO password_history (user_id, hash, created_at) SELECT id, password, NOW() FROM users WHERE id = 1")
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/backend/login", LoginHandler(userService, permissionService, auditService, throttleService, twoFactorService, sessionService, locale, logger))
+	mux.Handle("/backend/login/password", PasswordChangeHandler(userService, permissionService, auditService, throttleService, sessionService, policyService, sess, locale))
+	app := httptest.NewServer(sess.Middleware(mux))
+	defer app.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	post := func(t *testing.T, path, body string) (int, loginResponse) {
+		res, err := client.Post(app.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out loginResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		return res.StatusCode, out
+	}
+
+	t.Run("NoPendingLogin", func(t *testing.T) {
+		code, res := post(t, "/backend/login/password", `{"password":"new-secret-1","password_repeat":"new-secret-1"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.False(t, res.Ok)
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		code, res := post(t, "/backend/login", `{"username":"admin","password":"admin"}`)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.False(t, res.Ok)
+		assert.True(t, res.PasswordExpired)
+	})
+
+	t.Run("PolicyViolation", func(t *testing.T) {
+		code, res := post(t, "/backend/login/password", `{"password":"abc","password_repeat":"abc"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.True(t, res.PasswordExpired)
+		assert.Equal(t, "validation.min_length", res.Errors["new_password"][0].Message)
+	})
+
+	t.Run("Reused", func(t *testing.T) {
+		code, res := post(t, "/backend/login/password", `{"password":"admin","password_repeat":"admin"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.True(t, res.PasswordExpired)
+		assert.Equal(t, "validation.password_reused", res.Errors["new_password"][0].Message)
+	})
+
+	t.Run("Change", func(t *testing.T) {
+		code, res := post(t, "/backend/login/password", `{"password":"new-secret-1","password_repeat":"new-secret-1"}`)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, res.Ok)
+		assert.Equal(t, "admin", res.User.Name)
+
+		u, err := userService.Find(context.Background(), 1)
+		require.NoError(t, err)
+		assert.False(t, userService.PasswordExpired(u))
+	})
+}
@@ -4,11 +4,13 @@ import "gopkg.in/guregu/null.v3"
 
 // AuditLog represents an audit log entry.
 type AuditLog struct {
-	ID       int    `json:"id"`
-	UserID   int    `json:"user_id"`
-	Field    string `json:"field"`
-	ValueOld string `json:"value_old"`
-	ValueNew string `json:"value_new"`
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// ImpersonatedUserID is set if UserID is a superuser that acted as this user.
+	ImpersonatedUserID null.Int `json:"impersonated_user_id"`
+	Field              string   `json:"field"`
+	ValueOld           string   `json:"value_old"`
+	ValueNew           string   `json:"value_new"`
 	// The action describes the kind of action logged (created, updated, deleted).
 	Action string `json:"action"`
 	// EntityType contains the primary key of the edited entity.
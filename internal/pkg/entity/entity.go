@@ -9,20 +9,36 @@ type Entity interface {
 type Kind string
 
 const (
-	KindUser       Kind = "user"
-	KindPermission Kind = "permission"
-	KindRole       Kind = "role"
-	KindQuote      Kind = "quote"
-	KindUnknown    Kind = "unknown"
+	KindUser          Kind = "user"
+	KindPermission    Kind = "permission"
+	KindRole          Kind = "role"
+	KindQuote         Kind = "quote"
+	KindToken         Kind = "token"
+	KindLoginAttempt  Kind = "loginattempt"
+	KindTwoFactor     Kind = "twofactor"
+	KindMail          Kind = "mail"
+	KindPasswordReset Kind = "passwordreset"
+	KindSession       Kind = "session"
+	KindIdentity      Kind = "identity"
+	KindFile          Kind = "file"
+	KindUnknown       Kind = "unknown"
 )
 
 // ResolveKind turns a string representation of a kind into a Kind type.
 func ResolveKind(in string) Kind {
 	types := map[string]Kind{
-		"user":       KindUser,
-		"permission": KindPermission,
-		"role":       KindRole,
-		"quote":      KindQuote,
+		"user":          KindUser,
+		"permission":    KindPermission,
+		"role":          KindRole,
+		"quote":         KindQuote,
+		"token":         KindToken,
+		"loginattempt":  KindLoginAttempt,
+		"twofactor":     KindTwoFactor,
+		"mail":          KindMail,
+		"passwordreset": KindPasswordReset,
+		"session":       KindSession,
+		"identity":      KindIdentity,
+		"file":          KindFile,
 	}
 
 	k, ok := types[in]
@@ -0,0 +1,29 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+// File is an uploaded file in the storage directory.
+type File struct {
+	ID int `json:"id"`
+	// Name is the name the file was uploaded with.
+	Name string `json:"name"`
+	// Path is the location of the stored file relative to the storage directory.
+	Path     string `json:"path"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	// CreatedBy is the id of the user that uploaded the file.
+	CreatedBy null.Int `json:"created_by" diff:"-"`
+
+	CreatedAt null.Time `json:"created_at" diff:"-"`
+	UpdatedAt null.Time `json:"updated_at" diff:"-"`
+}
+
+// Primary returns the primary key of this entity.
+func (f File) Primary() int {
+	return f.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (f File) Type() Kind {
+	return KindFile
+}
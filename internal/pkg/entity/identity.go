@@ -0,0 +1,26 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+// Identity links a user to an account of an external identity provider.
+type Identity struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// Provider is the name of the identity provider, e.g. "oidc".
+	Provider string `json:"provider"`
+	// Subject is the stable identifier of the account at the provider.
+	Subject string `json:"subject"`
+
+	CreatedAt null.Time `json:"created_at" diff:"-"`
+	UpdatedAt null.Time `json:"updated_at" diff:"-"`
+}
+
+// Primary returns the primary key of this entity.
+func (i Identity) Primary() int {
+	return i.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (i Identity) Type() Kind {
+	return KindIdentity
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+const (
+	// LoginScopeUsername counts failed logins per username.
+	LoginScopeUsername = "username"
+	// LoginScopeIP counts failed logins per client IP.
+	LoginScopeIP = "ip"
+	// LoginScopeResetEmail counts password reset requests per email address.
+	LoginScopeResetEmail = "reset_email"
+	// LoginScopeResetIP counts password reset requests per client IP.
+	LoginScopeResetIP = "reset_ip"
+)
+
+// LoginAttempt counts the failed logins of a username or client IP. It also counts
+// requests that are limited by the throttle, like password resets.
+type LoginAttempt struct {
+	ID int `json:"id"`
+	// Scope is one of the LoginScope constants.
+	Scope string `json:"scope"`
+	// Subject is the username or IP the failures are counted for.
+	Subject       string    `json:"subject"`
+	Failures      int       `json:"failures"`
+	LastFailureAt null.Time `json:"last_failure_at"`
+	LockedUntil   null.Time `json:"locked_until"`
+
+	CreatedAt null.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+}
+
+// Primary returns the primary key of this entity.
+func (a LoginAttempt) Primary() int {
+	return a.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (a LoginAttempt) Type() Kind {
+	return KindLoginAttempt
+}
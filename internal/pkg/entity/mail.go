@@ -0,0 +1,29 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+// Mail is an outgoing mail in the outbox. It is sent by the mailer daemon.
+type Mail struct {
+	ID        int    `json:"id"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	// Attempts is the number of failed delivery attempts.
+	Attempts      int         `json:"attempts"`
+	LastError     null.String `json:"last_error"`
+	NextAttemptAt null.Time   `json:"next_attempt_at"`
+	SentAt        null.Time   `json:"sent_at"`
+
+	CreatedAt null.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+}
+
+// Primary returns the primary key of this entity.
+func (m Mail) Primary() int {
+	return m.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (m Mail) Type() Kind {
+	return KindMail
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+// PasswordReset is a single-use token that allows a user to set a new password.
+type PasswordReset struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// Hash is the SHA-256 hash of the token. The token itself is only sent by mail.
+	Hash      string    `json:"-" diff:"-"`
+	ExpiresAt null.Time `json:"expires_at"`
+	UsedAt    null.Time `json:"used_at"`
+
+	CreatedAt null.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+}
+
+// Primary returns the primary key of this entity.
+func (p PasswordReset) Primary() int {
+	return p.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (p PasswordReset) Type() Kind {
+	return KindPasswordReset
+}
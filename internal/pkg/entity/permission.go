@@ -1,6 +1,11 @@
 package entity
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 // Permission is a single action a user can execute. It belongs to
 // one or many roles.
@@ -8,8 +13,16 @@ type Permission struct {
 	ID    int             `json:"id"`
 	Code  string          `json:"code"`
 	Level PermissionLevel `json:"level"`
+	// Own limits the permission to records created by the user.
+	Own bool `json:"own"`
+	// Deny revokes the permission even if another role grants it.
+	Deny bool `json:"deny"`
 }
 
+// ownSuffix is appended to the level of permissions that are limited to own records,
+// it has to match the suffix the auth manager uses in its matcher.
+const ownSuffix = ":own"
+
 type PermissionLevel string
 
 const (
@@ -19,6 +32,87 @@ const (
 	PermissionLevelManage PermissionLevel = "manage"
 )
 
+// levelRanks orders the permission levels. Higher levels include all lower ones.
+var levelRanks = map[PermissionLevel]int{
+	PermissionLevelNone:   0,
+	PermissionLevelRead:   1,
+	PermissionLevelWrite:  2,
+	PermissionLevelManage: 3,
+}
+
+// Includes checks if the level grants the other level as well.
+func (l PermissionLevel) Includes(other PermissionLevel) bool {
+	rank, ok := levelRanks[l]
+	if !ok {
+		return l == other
+	}
+	otherRank, ok := levelRanks[other]
+	if !ok {
+		return false
+	}
+	return rank >= otherRank
+}
+
+// ParseCodeLevel turns a "permission.code::level" or "permission.code::level:own" string into a Permission.
+func ParseCodeLevel(in string) (*Permission, error) {
+	parts := strings.Split(in, "::")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid permission code, format \"permission.code::level\" expected: %v", in)
+	}
+	return PermissionFromAction(parts[0], parts[1]), nil
+}
+
+// PermissionFromAction returns the permission for a code and an action as it is stored in the auth manager.
+func PermissionFromAction(code, action string) *Permission {
+	return &Permission{
+		Code:  code,
+		Level: PermissionLevel(strings.TrimSuffix(action, ownSuffix)),
+		Own:   strings.HasSuffix(action, ownSuffix),
+	}
+}
+
+// PermissionFromPolicy returns the permission of a "subject, code, action, effect" policy of the auth manager.
+func PermissionFromPolicy(policy []string) *Permission {
+	p := PermissionFromAction(policy[1], policy[2])
+	p.Deny = len(policy) > 3 && policy[3] == "deny"
+	return p
+}
+
+// Action returns the level as it is stored in the auth manager.
+func (p Permission) Action() string {
+	return p.Level.Action(p.Own)
+}
+
+// Action returns the level as it is stored in the auth manager, optionally limited to own records.
+func (l PermissionLevel) Action(own bool) string {
+	if own {
+		return string(l) + ownSuffix
+	}
+	return string(l)
+}
+
+// Actions returns all actions that have to be stored in the auth manager for the permission. Granted
+// levels include all lower levels, denied levels include all higher levels for all records.
+func (p Permission) Actions() []string {
+	var actions []string
+	for _, level := range []PermissionLevel{PermissionLevelRead, PermissionLevelWrite, PermissionLevelManage} {
+		switch {
+		case p.Level == PermissionLevelNone:
+		case p.Deny && level.Includes(p.Level):
+			actions = append(actions, string(level))
+		case !p.Deny && p.Level.Includes(level):
+			actions = append(actions, level.Action(p.Own))
+		}
+	}
+	return actions
+}
+
+// Grants checks if the permission includes the other permission. Permissions
+// limited to own records never grant access to all records.
+func (p Permission) Grants(other *Permission) bool {
+	return p.Code == other.Code && p.Level.Includes(other.Level) && (!p.Own || other.Own)
+}
+
 // Primary returns the primary key of this entity.
 func (p Permission) Primary() int {
 	return p.ID
@@ -29,7 +123,35 @@ func (p Permission) Type() Kind {
 	return KindPermission
 }
 
+// String returns the code and level, denied permissions are prefixed with "deny".
+func (p Permission) String() string {
+	if p.Deny {
+		return "deny " + p.CodeLevel()
+	}
+	return p.CodeLevel()
+}
+
 // CodeLevel returns the combined code and level as a string.
 func (p Permission) CodeLevel() string {
-	return fmt.Sprintf("%s::%s", p.Code, p.Level)
+	return fmt.Sprintf("%s::%s", p.Code, p.Action())
+}
+
+// PermissionExplanation describes why a user is granted or denied a permission.
+type PermissionExplanation struct {
+	Permission *Permission `json:"permission"`
+	Allowed    bool        `json:"allowed"`
+	// Superuser is true if the permission is granted because the user is a superuser.
+	Superuser bool `json:"superuser"`
+	// Own is true if the permission is only granted for records created by the user.
+	Own      bool           `json:"own"`
+	Policies []*PolicyMatch `json:"policies"`
+}
+
+// PolicyMatch is a policy that grants or denies a permission to a user.
+type PolicyMatch struct {
+	Permission *Permission `json:"permission"`
+	// Policy is the policy line as it is stored in the auth manager.
+	Policy string `json:"policy"`
+	// RoleIDs is the chain of roles from a role of the user to the role of the policy.
+	RoleIDs []int `json:"-"`
 }
@@ -2,6 +2,8 @@ package entity
 
 import (
 	"time"
+
+	"gopkg.in/guregu/null.v3"
 )
 
 // A single quote entity.
@@ -9,6 +11,8 @@ type Quote struct {
 	ID      int    `json:"id"`
 	Author  string `json:"author"`
 	Content string `json:"content"`
+	// CreatedBy is the id of the user that owns the quote.
+	CreatedBy null.Int `json:"created_by" diff:"-"`
 
 	CreatedAt time.Time `json:"created_at" diff:"-"`
 	UpdatedAt time.Time `json:"updated_at" diff:"-"`
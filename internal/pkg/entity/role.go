@@ -1,11 +1,18 @@
 package entity
 
-import "gopkg.in/guregu/null.v3"
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
 
 // Role belongs to a user, has many permissions.
 type Role struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	// RequireTwoFactor makes two-factor authentication mandatory for all users of this role.
+	RequireTwoFactor bool `json:"require_two_factor"`
 
 	CreatedAt null.Time `json:"created_at" diff:"-"`
 	UpdatedAt null.Time `json:"updated_at" diff:"-"`
@@ -20,3 +27,45 @@ func (r Role) Primary() int {
 func (r Role) Type() Kind {
 	return KindRole
 }
+
+// RoleAssignment assigns a role to a user, optionally only for a period of time.
+type RoleAssignment struct {
+	UserID int `json:"user_id"`
+	RoleID int `json:"role_id"`
+	// ValidFrom is the time the role is granted from, the role is granted immediately if it is empty.
+	ValidFrom null.Time `json:"valid_from"`
+	// ValidUntil is the time the role expires, the role never expires if it is empty.
+	ValidUntil null.Time `json:"valid_until"`
+}
+
+// Active returns true if the role is granted at the given time.
+func (a RoleAssignment) Active(now time.Time) bool {
+	if a.ValidFrom.Valid && a.ValidFrom.Time.After(now) {
+		return false
+	}
+	return !a.ValidUntil.Valid || a.ValidUntil.Time.After(now)
+}
+
+// Extends returns true if the assignment grants the role at a time the previous assignment did not,
+// either because it is active now or because its period of time is longer. Every new assignment extends.
+func (a RoleAssignment) Extends(previous *RoleAssignment, now time.Time) bool {
+	if previous == nil || a.Active(now) && !previous.Active(now) {
+		return true
+	}
+	if previous.ValidFrom.Valid && (!a.ValidFrom.Valid || a.ValidFrom.Time.Before(previous.ValidFrom.Time)) {
+		return true
+	}
+	return previous.ValidUntil.Valid && (!a.ValidUntil.Valid || a.ValidUntil.Time.After(previous.ValidUntil.Time))
+}
+
+// String returns the role id and the period of time the role is granted for.
+func (a RoleAssignment) String() string {
+	s := fmt.Sprintf("%d", a.RoleID)
+	if a.ValidFrom.Valid {
+		s += " from " + a.ValidFrom.Time.Format(time.RFC3339)
+	}
+	if a.ValidUntil.Valid {
+		s += " until " + a.ValidUntil.Time.Format(time.RFC3339)
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+// Session is a login session of a user. The session data itself is managed by
+// the session store, this entity only tracks it so it can be listed and revoked.
+type Session struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// Key identifies the session, it is stored in the session data.
+	Key       string `json:"-" diff:"-"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+
+	LastSeenAt null.Time `json:"last_seen_at" diff:"-"`
+	CreatedAt  null.Time `json:"created_at" diff:"-"`
+	UpdatedAt  null.Time `json:"updated_at" diff:"-"`
+}
+
+// Primary returns the primary key of this entity.
+func (s Session) Primary() int {
+	return s.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (s Session) Type() Kind {
+	return KindSession
+}
@@ -0,0 +1,95 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Token is a personal access token that is used by API clients to authenticate as a user.
+type Token struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	// Hash is the SHA-256 hash of the secret. The secret itself is never stored.
+	Hash string `json:"-" diff:"-"`
+	// Permissions narrows the token down to a subset of the user's permissions.
+	// If it is empty, the token carries all permissions of its user.
+	Permissions TokenPermissions `json:"permissions"`
+
+	ExpiresAt  null.Time `json:"expires_at"`
+	LastUsedAt null.Time `json:"last_used_at" diff:"-"`
+	CreatedAt  null.Time `json:"created_at" diff:"-"`
+	UpdatedAt  null.Time `json:"updated_at" diff:"-"`
+}
+
+// Primary returns the primary key of this entity.
+func (t Token) Primary() int {
+	return t.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (t Token) Type() Kind {
+	return KindToken
+}
+
+// Allows checks if the token grants a permission, either for all or only for own records.
+// Tokens without explicit permissions allow everything their user is allowed to do.
+func (t Token) Allows(code string, level PermissionLevel) bool {
+	return t.grants(&Permission{Code: code, Level: level, Own: true})
+}
+
+// OwnOnly returns true if the token grants a permission only for records created by its user.
+func (t Token) OwnOnly(code string, level PermissionLevel) bool {
+	return t.Allows(code, level) && !t.grants(&Permission{Code: code, Level: level})
+}
+
+// grants checks if any permission of the token includes the requested permission.
+func (t Token) grants(requested *Permission) bool {
+	if len(t.Permissions) == 0 {
+		return true
+	}
+	for _, p := range t.Permissions {
+		granted, err := ParseCodeLevel(p)
+		if err != nil {
+			continue
+		}
+		if granted.Grants(requested) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenPermissions is a list of "code::level" strings that is stored as a comma separated string.
+type TokenPermissions []string
+
+// Scan implements the sql.Scanner interface.
+func (p *TokenPermissions) Scan(value interface{}) error {
+	var str string
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	default:
+		return errors.Errorf("cannot scan %T into TokenPermissions", value)
+	}
+	*p = nil
+	for _, part := range strings.Split(str, ",") {
+		if part != "" {
+			*p = append(*p, part)
+		}
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (p TokenPermissions) Value() (driver.Value, error) {
+	return strings.Join(p, ","), nil
+}
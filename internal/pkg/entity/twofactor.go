@@ -0,0 +1,44 @@
+package entity
+
+import "gopkg.in/guregu/null.v3"
+
+// TwoFactor holds the TOTP secret of a user.
+type TwoFactor struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Secret string `json:"-" diff:"-"`
+	// ConfirmedAt is set once the user confirmed the enrollment with a valid code.
+	ConfirmedAt null.Time `json:"confirmed_at"`
+	// LastStep is the time step of the last accepted code. Codes are single-use.
+	LastStep int64 `json:"last_step" diff:"-"`
+
+	CreatedAt null.Time `json:"created_at" diff:"-"`
+	UpdatedAt null.Time `json:"updated_at" diff:"-"`
+}
+
+// Primary returns the primary key of this entity.
+func (t TwoFactor) Primary() int {
+	return t.ID
+}
+
+// Type returns a string representation of this entity's type.
+func (t TwoFactor) Type() Kind {
+	return KindTwoFactor
+}
+
+// Enabled returns true if the enrollment was confirmed.
+func (t TwoFactor) Enabled() bool {
+	return t.ConfirmedAt.Valid
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code.
+type RecoveryCode struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// Hash is the SHA-256 hash of the code. The code itself is never stored.
+	Hash   string    `json:"-" diff:"-"`
+	UsedAt null.Time `json:"used_at"`
+
+	CreatedAt null.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+}
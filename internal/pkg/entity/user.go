@@ -9,10 +9,13 @@ var ErrUserInvalidPassword = errors.New("wrong password")
 
 // User is the central user identity used for authentication.
 type User struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Password    string `json:"-"`
-	IsSuperuser bool   `json:"is_superuser"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Email       null.String `json:"email"`
+	Password    string      `json:"-" diff:"-"`
+	IsSuperuser bool        `json:"is_superuser"`
+	// PasswordChangedAt is used to expire old passwords.
+	PasswordChangedAt null.Time `json:"password_changed_at" diff:"-"`
 
 	CreatedAt null.Time `json:"created_at" diff:"-"`
 	UpdatedAt null.Time `json:"updated_at" diff:"-"`
@@ -0,0 +1,65 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"go-webapp-example/internal/pkg/entity"
+)
+
+// bufferSize is the number of changes a subscriber can fall behind before changes are dropped.
+const bufferSize = 32
+
+var _ Publisher = &Broker{}
+
+// Broker distributes the changes of entities to the subscribers of their kind.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[entity.Kind]map[chan *Change]struct{}
+}
+
+// NewBroker returns a pointer to a new Broker.
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[entity.Kind]map[chan *Change]struct{})}
+}
+
+// Subscribe returns a channel that receives the changes of all entities of a kind.
+// The channel is closed once the context is done.
+func (b *Broker) Subscribe(ctx context.Context, kind entity.Kind) <-chan *Change {
+	ch := make(chan *Change, bufferSize)
+	b.mu.Lock()
+	if b.subscribers[kind] == nil {
+		b.subscribers[kind] = make(map[chan *Change]struct{})
+	}
+	b.subscribers[kind][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers[kind], ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+	return ch
+}
+
+// Publish sends a change to all subscribers of its entity kind. It never blocks the
+// publishing store: changes for subscribers that fall too far behind are dropped.
+func (b *Broker) Publish(c *Change) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[c.Entity.Type()] {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
+
+// Subscribers returns the number of open subscriptions of a kind.
+func (b *Broker) Subscribers(kind entity.Kind) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers[kind])
+}
@@ -0,0 +1,36 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	users := b.Subscribe(ctx, entity.KindUser)
+	quotes := b.Subscribe(ctx, entity.KindQuote)
+	assert.Equal(t, 1, b.Subscribers(entity.KindUser))
+
+	b.Publish(Created(&entity.User{ID: 2}))
+	c := <-users
+	assert.Equal(t, ActionCreated, c.Action)
+	assert.Equal(t, 2, c.Entity.Primary())
+	assert.Len(t, quotes, 0)
+
+	// Slow subscribers never block the publisher.
+	for i := 0; i < bufferSize+1; i++ {
+		b.Publish(Deleted(&entity.Quote{ID: i}))
+	}
+	assert.Len(t, quotes, bufferSize)
+
+	cancel()
+	for range users {
+	}
+	assert.Equal(t, 0, b.Subscribers(entity.KindUser))
+}
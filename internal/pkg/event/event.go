@@ -0,0 +1,68 @@
+package event
+
+import (
+	"reflect"
+	"strings"
+
+	"go-webapp-example/internal/pkg/entity"
+)
+
+// Action describes what happened to an entity.
+type Action string
+
+const (
+	ActionCreated Action = "created"
+	ActionUpdated Action = "updated"
+	ActionDeleted Action = "deleted"
+)
+
+// Change is published after an entity was created, updated or deleted.
+type Change struct {
+	Action Action
+	Entity entity.Entity
+	// Fields contains the json names of the changed fields of an updated entity.
+	Fields []string
+}
+
+// Publisher delivers changes to the subscribers of their entity kind.
+type Publisher interface {
+	Publish(c *Change)
+}
+
+// Created returns the change of a created entity.
+func Created(e entity.Entity) *Change {
+	return &Change{Action: ActionCreated, Entity: e}
+}
+
+// Updated returns the change of an updated entity. The changed fields are the ones
+// that differ between from and to, and the relations that were updated with it.
+func Updated(from, to entity.Entity, relations ...string) *Change {
+	return &Change{Action: ActionUpdated, Entity: to, Fields: append(ChangedFields(from, to), relations...)}
+}
+
+// Deleted returns the change of a deleted entity.
+func Deleted(e entity.Entity) *Change {
+	return &Change{Action: ActionDeleted, Entity: e}
+}
+
+// ChangedFields returns the json names of the fields that differ between two entities of the
+// same type. Fields that are excluded from the json representation or the audit log are skipped,
+// so timestamps and secrets like passwords are never reported.
+func ChangedFields(from, to entity.Entity) []string {
+	a, b := reflect.Indirect(reflect.ValueOf(from)), reflect.Indirect(reflect.ValueOf(to))
+	if a.Type() != b.Type() || a.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []string
+	for i := 0; i < a.NumField(); i++ {
+		f := a.Type().Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || name == "" || f.Tag.Get("diff") == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,24 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestChangedFields(t *testing.T) {
+	from := &entity.User{ID: 2, Name: "user", Password: "old", Email: null.StringFrom("user@example.com")}
+	to := &entity.User{ID: 2, Name: "renamed", Password: "new", UpdatedAt: null.TimeFrom(time.Now())}
+
+	assert.Equal(t, []string{"name", "email"}, ChangedFields(from, to))
+	assert.Empty(t, ChangedFields(to, to))
+	assert.Empty(t, ChangedFields(from, &entity.Role{ID: 2}))
+
+	c := Updated(from, to, "roles")
+	assert.Equal(t, ActionUpdated, c.Action)
+	assert.Equal(t, []string{"name", "email", "roles"}, c.Fields)
+}
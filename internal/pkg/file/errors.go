@@ -0,0 +1,18 @@
+package file
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a requested file could not be found.
+	ErrNotFound = errors.New("file not found")
+	// ErrTooLarge is returned if an uploaded file exceeds the maximum size.
+	ErrTooLarge = errors.New("file is too large")
+	// ErrExtension is returned if files with the extension cannot be uploaded.
+	ErrExtension = errors.New("file extension is not allowed")
+	// ErrContentType is returned if the content of a file does not match its extension.
+	ErrContentType = errors.New("file content does not match its extension")
+	// ErrFolder is returned for folders that are not a relative path of simple names.
+	ErrFolder = errors.New("invalid folder")
+)
@@ -0,0 +1,10 @@
+package file
+
+import (
+	"go-webapp-example/internal/pkg/permission"
+)
+
+// Permissions are the permission codes declared by this module.
+var Permissions = []permission.Definition{
+	{Code: "admin.file", Label: "file.permissions.admin", Levels: permission.AllLevels},
+}
@@ -0,0 +1,228 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/fs"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// URLPath is the path the storage directory is served at.
+	URLPath = "/backend/storage/"
+	// DefaultFolder is the folder files are stored in if no folder is requested.
+	DefaultFolder = "uploads"
+	// sniffLen is the number of bytes http.DetectContentType considers.
+	sniffLen = 512
+	// maxNameLen is the maximum length of a stored file name without its extension.
+	maxNameLen = 100
+	// maxAttempts is the number of names that are tried if a file name is taken.
+	maxAttempts = 10
+)
+
+// contentTypes are the types http.DetectContentType returns for the content of files with an
+// extension. Only these extensions can be allowed, so the content of every file is checked.
+var contentTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+	".pdf":  {"application/pdf"},
+	".zip":  {"application/zip"},
+	".txt":  {"text/plain"},
+	".csv":  {"text/plain"},
+}
+
+var (
+	// folderPart matches a single directory name of a folder.
+	folderPart = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// unsafeName matches all characters that are replaced in file names.
+	unsafeName = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// Config contains the settings for uploaded files.
+type Config struct {
+	// Dir is the storage directory the files are saved in.
+	Dir string
+	// MaxSize is the maximum size of a file in bytes.
+	MaxSize int64
+	// Extensions are the file extensions that can be uploaded, e.g. ".pdf".
+	Extensions []string
+}
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+	config Config
+}
+
+// NewService returns a pointer to a new Service. It returns an error
+// if the content of files with an allowed extension cannot be checked.
+func NewService(store *Store, config Config) (*Service, error) {
+	extensions := make([]string, len(config.Extensions))
+	for i, ext := range config.Extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if _, ok := contentTypes[ext]; !ok {
+			return nil, errors.Errorf("files with the extension %s cannot be uploaded", ext)
+		}
+		extensions[i] = ext
+	}
+	config.Extensions = extensions
+	return &Service{
+		Store:  store,
+		config: config,
+	}, nil
+}
+
+// Config returns the settings of the service.
+func (s Service) Config() Config {
+	return s.config
+}
+
+// Save stores an uploaded file in a folder of the storage directory and tracks it in the
+// database. The content of the file has to match its extension. If a file with the same
+// name exists, a random suffix is added to the name.
+func (s Service) Save(ctx context.Context, r io.Reader, name, folder string) (*entity.File, error) {
+	folder, err := cleanFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if !s.allows(ext) {
+		return nil, ErrExtension
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, errors.WithStack(err)
+	}
+	head = head[:n]
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil || !matches(ext, mimeType) {
+		return nil, ErrContentType
+	}
+
+	dir := filepath.Join(s.config.Dir, filepath.FromSlash(folder))
+	if err = fs.EnsureDir(dir); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	out, stored, err := create(dir, storedName(name), ext)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(out, io.LimitReader(io.MultiReader(bytes.NewReader(head), r), s.config.MaxSize+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > s.config.MaxSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		_ = os.Remove(filepath.Join(dir, stored))
+		return nil, errors.WithStack(err)
+	}
+
+	file, err := s.Store.Create(ctx, &entity.File{
+		Name:     name,
+		Path:     path.Join(folder, stored),
+		MimeType: mimeType,
+		Size:     size,
+	})
+	if err != nil {
+		_ = os.Remove(filepath.Join(dir, stored))
+		return nil, err
+	}
+	return file, nil
+}
+
+// URL returns the URL a stored file is served at.
+func URL(f *entity.File) string {
+	return URLPath + f.Path
+}
+
+// allows returns true if files with the extension can be uploaded.
+func (s Service) allows(ext string) bool {
+	for _, e := range s.config.Extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the detected content type is expected for files with the extension.
+func matches(ext, mimeType string) bool {
+	for _, t := range contentTypes[ext] {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFolder returns the folder a file is stored in. Folders are relative paths
+// of simple names, they cannot leave the storage directory.
+func cleanFolder(folder string) (string, error) {
+	folder = strings.Trim(folder, "/")
+	if folder == "" {
+		return DefaultFolder, nil
+	}
+	for _, part := range strings.Split(folder, "/") {
+		if !folderPart.MatchString(part) {
+			return "", ErrFolder
+		}
+	}
+	return folder, nil
+}
+
+// storedName returns the name of an uploaded file without its extension and unsafe characters.
+func storedName(name string) string {
+	base := strings.TrimSuffix(filepath.Base(strings.ReplaceAll(name, `\`, "/")), filepath.Ext(name))
+	base = strings.Trim(unsafeName.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if len(base) > maxNameLen {
+		base = base[:maxNameLen]
+	}
+	if base == "" {
+		base = "file"
+	}
+	return base
+}
+
+// create creates a new file in the directory. If the name is taken, a random suffix is added.
+// The file is created exclusively, so concurrent uploads never overwrite each other.
+func create(dir, base, ext string) (*os.File, string, error) {
+	name := base + ext
+	for i := 0; i < maxAttempts; i++ {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return f, name, nil
+		}
+		if !os.IsExist(err) {
+			return nil, "", errors.WithStack(err)
+		}
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		name = base + "-" + hex.EncodeToString(suffix) + ext
+	}
+	return nil, "", errors.Errorf("failed to find a free name for %s%s", base, ext)
+}
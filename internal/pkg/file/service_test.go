@@ -0,0 +1,144 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+// png is the signature of a png image.
+var png = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+type setupFn func() (sqlmock.Sqlmock, *Service, string)
+
+// TestFileService tests all service methods as well as the underlying store.
+func TestFileService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service, string) {
+		dir, err := ioutil.TempDir("", "storage")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		conn, mockDB := test.MockDB(t)
+		service, err := NewService(NewStore(conn, audit.NewMockAuditor(), func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}), Config{Dir: dir, MaxSize: 16, Extensions: []string{"PNG", ".txt"}})
+		require.NoError(t, err)
+		return mockDB, service, dir
+	}
+
+	t.Run("Save", save(setup))
+	t.Run("SaveCollision", saveCollision(setup))
+	t.Run("SaveRejected", saveRejected(setup))
+}
+
+func expectInsert(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO files").WillReturnResult(sqlmock.NewResult(id, 1))
+	mock.ExpectCommit()
+}
+
+func save(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, dir := setup()
+		expectInsert(mock, 1)
+
+		f, err := service.Save(context.Background(), bytes.NewReader(png), `C:\Photos\My Image!.PNG`, "images/2020")
+
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, f.ID)
+		assert.Equal(t, `C:\Photos\My Image!.PNG`, f.Name)
+		assert.Equal(t, "images/2020/my-image.png", f.Path)
+		assert.Equal(t, "image/png", f.MimeType)
+		assert.Equal(t, int64(len(png)), f.Size)
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, "images", "2020", "my-image.png"))
+		assert.NoError(t, err)
+		assert.Equal(t, png, content)
+	}
+}
+
+func saveCollision(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, dir := setup()
+		expectInsert(mock, 1)
+		expectInsert(mock, 2)
+
+		first, err := service.Save(context.Background(), strings.NewReader("first"), "notes.txt", "")
+		require.NoError(t, err)
+		second, err := service.Save(context.Background(), strings.NewReader("second"), "notes.txt", "")
+		require.NoError(t, err)
+
+		assert.Equal(t, DefaultFolder+"/notes.txt", first.Path)
+		assert.Regexp(t, "^uploads/notes-[0-9a-f]{8}\\.txt$", second.Path)
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(first.Path)))
+		assert.NoError(t, err)
+		assert.Equal(t, "first", string(content))
+	}
+}
+
+func saveRejected(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, dir := setup()
+
+		cases := []struct {
+			name    string
+			content []byte
+			folder  string
+			err     error
+		}{
+			{"large.txt", bytes.Repeat([]byte("a"), 17), "", ErrTooLarge},
+			{"image.pdf", []byte("%PDF-1.4"), "", ErrExtension},
+			{"image.png", []byte("plain text"), "", ErrContentType},
+			{"noext", []byte("plain text"), "", ErrExtension},
+			{"notes.txt", []byte("plain text"), "../secret", ErrFolder},
+			{"notes.txt", []byte("plain text"), "a/./b", ErrFolder},
+		}
+		for _, c := range cases {
+			_, err := service.Save(context.Background(), bytes.NewReader(c.content), c.name, c.folder)
+			assert.Equal(t, c.err, errors.Cause(err), c.name)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		// Rejected files are removed again.
+		files, err := ioutil.ReadDir(filepath.Join(dir, DefaultFolder))
+		assert.NoError(t, err)
+		assert.Len(t, files, 0)
+	}
+}
+
+func TestNewService(t *testing.T) {
+	_, err := NewService(nil, Config{Extensions: []string{".exe"}})
+	assert.Error(t, err)
+}
+
+func TestValidateUpload(t *testing.T) {
+	config := Config{MaxSize: 10 << 20, Extensions: []string{".png", ".pdf"}}
+
+	errs := ValidateUpload(ErrTooLarge, config)
+	assert.Equal(t, map[string]string{"max": "10 MB"}, errs.Get("file")[0].Data)
+
+	errs = ValidateUpload(ErrExtension, config)
+	assert.Equal(t, map[string]string{"extensions": ".png, .pdf"}, errs.Get("file")[0].Data)
+
+	assert.True(t, ValidateUpload(ErrFolder, config).Failed())
+	assert.False(t, ValidateUpload(os.ErrPermission, config).Failed())
+}
@@ -0,0 +1,86 @@
+package file
+
+import (
+	"context"
+	"database/sql"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+	"go-webapp-example/pkg/session"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db      *db.Connection
+	clock   *clock.Clock
+	auditor audit.ChangeAuditor
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, auditor audit.ChangeAuditor, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn, auditor: auditor}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Find finds the entity by id.
+func (s Store) Find(ctx context.Context, id int) (*entity.File, error) {
+	var file entity.File
+	err := s.db.GetContext(ctx, &file, "SELECT * FROM files WHERE id = ? LIMIT 1", id)
+	return &file, errors.WithStack(checkNotFound(err))
+}
+
+// Create creates a new entity. The authenticated user is recorded as uploader.
+func (s Store) Create(ctx context.Context, file *entity.File) (*entity.File, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return file, errors.WithStack(err)
+	}
+	file.CreatedAt = null.TimeFrom(s.clock.Now())
+	file.UpdatedAt = null.TimeFrom(s.clock.Now())
+	if u, err := session.UserFromContext(ctx); err == nil {
+		file.CreatedBy = null.IntFrom(int64(u.ID))
+	}
+	query, params, err := sq.Insert("files").SetMap(db.ColumnMap{
+		"name":       file.Name,
+		"path":       file.Path,
+		"mime_type":  file.MimeType,
+		"size":       file.Size,
+		"created_by": file.CreatedBy,
+		"created_at": file.CreatedAt,
+		"updated_at": file.UpdatedAt,
+	}).ToSql()
+	if err != nil {
+		return file, db.RollbackError(tx, errors.WithStack(err))
+	}
+	res, err := tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return file, db.RollbackError(tx, errors.WithStack(err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return file, db.RollbackError(tx, errors.WithStack(err))
+	}
+	file.ID = int(id)
+	err = s.auditor.LogCreate(ctx, tx, file)
+	if err != nil {
+		return file, db.RollbackError(tx, errors.WithStack(err))
+	}
+	return file, errors.WithStack(tx.Commit())
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
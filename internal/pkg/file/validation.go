@@ -0,0 +1,38 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+
+	"go-webapp-example/pkg/validation"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateUpload turns the errors of Save that are caused by the uploaded file or
+// the requested folder into validation errors. Other errors are not added.
+func ValidateUpload(err error, config Config) *validation.ErrorBag {
+	errs := validation.NewErrorBag("file")
+	switch errors.Cause(err) {
+	case ErrTooLarge:
+		errs.AddData("file", "file_size", map[string]string{"max": formatSize(config.MaxSize)})
+	case ErrExtension:
+		errs.AddData("file", "file_extension", map[string]string{"extensions": strings.Join(config.Extensions, ", ")})
+	case ErrContentType:
+		errs.Add("file", "file_content")
+	case ErrFolder:
+		errs.Add("folder", "folder")
+	}
+	return errs
+}
+
+// formatSize returns a size in bytes in a human readable format.
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(fmt.Sprintf("%.1f", value), "0"), ".") + " " + units[unit]
+}
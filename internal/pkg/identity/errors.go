@@ -0,0 +1,14 @@
+package identity
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a requested identity could not be found.
+	ErrNotFound = errors.New("identity not found")
+	// ErrNoAccount is returned if no user is linked to an external account and users are not created automatically.
+	ErrNoAccount = errors.New("no user is linked to this account")
+	// ErrNameTaken is returned if a user cannot be created because the name is already used.
+	ErrNameTaken = errors.New("user name is already taken")
+)
@@ -0,0 +1,190 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/user"
+
+	"github.com/pkg/errors"
+)
+
+// Config contains the settings of an identity provider.
+type Config struct {
+	// Provider is the name the identities of this provider are stored with.
+	Provider string
+	// CreateUsers creates a user on the first login of an unknown account.
+	CreateUsers bool
+	// LinkByEmail links an unknown account to the user with the same email
+	// address. The provider has to confirm that the address is verified.
+	LinkByEmail bool
+	// GroupRoles maps the lower case group names of the provider to role ids. The mapped roles
+	// are synced on every login, roles that are not part of the mapping are left untouched.
+	GroupRoles map[string]int
+}
+
+// Claims describes an authenticated account of an identity provider.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+	users userService
+}
+
+// userService defines the needed methods to look up, create and update users.
+type userService interface {
+	Find(ctx context.Context, id int) (*entity.User, error)
+	FindByName(ctx context.Context, name string) (*entity.User, error)
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	Create(ctx context.Context, u *entity.User) (*entity.User, error)
+	GetRoleIDs(ctx context.Context, userID int) ([]int, error)
+	SyncRoles(ctx context.Context, u *entity.User, roleIDs []int) (*entity.User, error)
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store, users userService) *Service {
+	return &Service{
+		Store: store,
+		users: users,
+	}
+}
+
+// Login returns the user that is linked to the external account. Unknown accounts are linked
+// or a new user is created, depending on the config. The roles of the user are synced with
+// the groups of the account.
+func (s Service) Login(ctx context.Context, config Config, claims Claims) (*entity.User, error) {
+	u, err := s.resolve(ctx, config, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.syncRoles(ctx, config, u, claims.Groups); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// resolve returns the linked user or links the account to a user.
+func (s Service) resolve(ctx context.Context, config Config, claims Claims) (*entity.User, error) {
+	i, err := s.Store.FindBySubject(ctx, config.Provider, claims.Subject)
+	if err == nil {
+		return s.users.Find(ctx, i.UserID)
+	}
+	if errors.Cause(err) != ErrNotFound {
+		return nil, err
+	}
+
+	var u *entity.User
+	if config.LinkByEmail && claims.Email != "" && claims.EmailVerified {
+		u, err = s.users.FindByEmail(ctx, claims.Email)
+		if err != nil && errors.Cause(err) != user.ErrNotFound {
+			return nil, err
+		}
+	}
+	if u == nil || u.ID == 0 {
+		if !config.CreateUsers {
+			return nil, ErrNoAccount
+		}
+		if u, err = s.createUser(ctx, config, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = s.Store.Create(ctx, &entity.Identity{
+		UserID:   u.ID,
+		Provider: config.Provider,
+		Subject:  claims.Subject,
+	})
+	return u, err
+}
+
+// createUser creates a new user for an external account. The user gets a random
+// password, it can only log in using the provider until a password reset.
+func (s Service) createUser(ctx context.Context, config Config, claims Claims) (*entity.User, error) {
+	name := claims.Username
+	if name == "" {
+		name = claims.Email
+	}
+	if name == "" {
+		name = config.Provider + "-" + claims.Subject
+	}
+	_, err := s.users.FindByName(ctx, name)
+	if err == nil {
+		return nil, ErrNameTaken
+	}
+	if errors.Cause(err) != user.ErrNotFound {
+		return nil, err
+	}
+
+	password := make([]byte, 32)
+	if _, err = rand.Read(password); err != nil {
+		return nil, errors.Wrap(err, "failed to generate password")
+	}
+	u := &entity.User{Name: name, Password: hex.EncodeToString(password)}
+	if claims.Email != "" && claims.EmailVerified {
+		u.Email.SetValid(claims.Email)
+	}
+	return s.users.Create(ctx, u)
+}
+
+// syncRoles attaches the roles that are mapped to the groups of the account and
+// removes mapped roles of groups the account is no longer a member of.
+func (s Service) syncRoles(ctx context.Context, config Config, u *entity.User, groups []string) error {
+	if len(config.GroupRoles) == 0 {
+		return nil
+	}
+	managed := make(map[int]bool)
+	for _, roleID := range config.GroupRoles {
+		managed[roleID] = true
+	}
+	granted := make(map[int]bool)
+	for _, group := range groups {
+		if roleID, ok := config.GroupRoles[strings.ToLower(group)]; ok {
+			granted[roleID] = true
+		}
+	}
+
+	current, err := s.users.GetRoleIDs(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	var roleIDs []int
+	for _, roleID := range current {
+		if !managed[roleID] {
+			roleIDs = append(roleIDs, roleID)
+		}
+	}
+	for roleID := range granted {
+		roleIDs = append(roleIDs, roleID)
+	}
+	sort.Ints(roleIDs)
+	if equalIDs(current, roleIDs) {
+		return nil
+	}
+	_, err = s.users.SyncRoles(ctx, u, roleIDs)
+	return err
+}
+
+// equalIDs returns true if both sorted slices contain the same ids.
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package identity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/pkg/clock"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+// userServiceMock keeps users and their roles in memory.
+type userServiceMock struct {
+	users []*entity.User
+	roles map[int][]int
+}
+
+func (u *userServiceMock) Find(ctx context.Context, id int) (*entity.User, error) {
+	for _, usr := range u.users {
+		if usr.ID == id {
+			return usr, nil
+		}
+	}
+	return nil, user.ErrNotFound
+}
+
+func (u *userServiceMock) FindByName(ctx context.Context, name string) (*entity.User, error) {
+	for _, usr := range u.users {
+		if usr.Name == name {
+			return usr, nil
+		}
+	}
+	return nil, user.ErrNotFound
+}
+
+func (u *userServiceMock) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
+	for _, usr := range u.users {
+		if usr.Email.String == email {
+			return usr, nil
+		}
+	}
+	return nil, user.ErrNotFound
+}
+
+func (u *userServiceMock) Create(ctx context.Context, usr *entity.User) (*entity.User, error) {
+	usr.ID = len(u.users) + 1
+	u.users = append(u.users, usr)
+	return usr, nil
+}
+
+func (u *userServiceMock) GetRoleIDs(ctx context.Context, userID int) ([]int, error) {
+	return u.roles[userID], nil
+}
+
+func (u *userServiceMock) SyncRoles(ctx context.Context, usr *entity.User, roleIDs []int) (*entity.User, error) {
+	u.roles[usr.ID] = roleIDs
+	return usr, nil
+}
+
+func setup(t *testing.T) (sqlmock.Sqlmock, *Service, *userServiceMock, *audit.MockAuditor) {
+	db, mock := test.MockDB(t)
+	auditor := audit.NewMockAuditor()
+	users := &userServiceMock{roles: make(map[int][]int)}
+	service := NewService(NewStore(db, auditor, func(store *Store) {
+		store.clock = clock.FromTime(now)
+	}), users)
+	return mock, service, users, auditor
+}
+
+func expectIdentity(mock sqlmock.Sqlmock, subject string, userID int) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "provider", "subject"})
+	if userID != 0 {
+		rows.AddRow(1, userID, "oidc", subject)
+	}
+	mock.
+		ExpectQuery("SELECT .+ FROM identities WHERE provider = . AND subject = . LIMIT 1").
+		WithArgs("oidc", subject).
+		WillReturnRows(rows)
+}
+
+func expectLink(mock sqlmock.Sqlmock, subject string, userID int) {
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("INSERT INTO identities").
+		WithArgs(userID, "oidc", subject, now, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestServiceLogin(t *testing.T) {
+	t.Run("Linked", func(t *testing.T) {
+		mock, service, users, _ := setup(t)
+		users.users = []*entity.User{{ID: 1, Name: "admin"}}
+		expectIdentity(mock, "abc", 1)
+
+		u, err := service.Login(context.Background(), Config{Provider: "oidc"}, Claims{Subject: "abc"})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, u.ID)
+	})
+
+	t.Run("NoAccount", func(t *testing.T) {
+		mock, service, _, _ := setup(t)
+		expectIdentity(mock, "abc", 0)
+
+		_, err := service.Login(context.Background(), Config{Provider: "oidc"}, Claims{Subject: "abc"})
+
+		assert.Equal(t, ErrNoAccount, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("LinkByEmail", func(t *testing.T) {
+		mock, service, users, auditor := setup(t)
+		u := &entity.User{ID: 1, Name: "admin"}
+		u.Email.SetValid("admin@example.com")
+		users.users = []*entity.User{u}
+		expectIdentity(mock, "abc", 0)
+		expectLink(mock, "abc", 1)
+
+		res, err := service.Login(context.Background(), Config{Provider: "oidc", LinkByEmail: true}, Claims{
+			Subject:       "abc",
+			Email:         "admin@example.com",
+			EmailVerified: true,
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, res.ID)
+		assert.Len(t, auditor.Created, 1)
+	})
+
+	t.Run("UnverifiedEmail", func(t *testing.T) {
+		mock, service, users, _ := setup(t)
+		u := &entity.User{ID: 1, Name: "admin"}
+		u.Email.SetValid("admin@example.com")
+		users.users = []*entity.User{u}
+		expectIdentity(mock, "abc", 0)
+
+		_, err := service.Login(context.Background(), Config{Provider: "oidc", LinkByEmail: true}, Claims{
+			Subject: "abc",
+			Email:   "admin@example.com",
+		})
+
+		assert.Equal(t, ErrNoAccount, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("CreateUser", func(t *testing.T) {
+		mock, service, users, _ := setup(t)
+		expectIdentity(mock, "abc", 0)
+		expectLink(mock, "abc", 1)
+
+		u, err := service.Login(context.Background(), Config{Provider: "oidc", CreateUsers: true}, Claims{
+			Subject:       "abc",
+			Email:         "jane@example.com",
+			EmailVerified: true,
+			Username:      "jane",
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, users.users, 1)
+		assert.Equal(t, "jane", u.Name)
+		assert.Equal(t, "jane@example.com", u.Email.String)
+		assert.NotEmpty(t, u.Password)
+	})
+
+	t.Run("CreateUserNameTaken", func(t *testing.T) {
+		mock, service, users, _ := setup(t)
+		users.users = []*entity.User{{ID: 1, Name: "jane"}}
+		expectIdentity(mock, "abc", 0)
+
+		_, err := service.Login(context.Background(), Config{Provider: "oidc", CreateUsers: true}, Claims{Subject: "abc", Username: "jane"})
+
+		assert.Equal(t, ErrNameTaken, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GroupRoles", func(t *testing.T) {
+		mock, service, users, _ := setup(t)
+		users.users = []*entity.User{{ID: 1, Name: "admin"}}
+		// Role 1 is not mapped and kept, role 3 is mapped but the group is missing.
+		users.roles[1] = []int{1, 3}
+		expectIdentity(mock, "abc", 1)
+
+		config := Config{Provider: "oidc", GroupRoles: map[string]int{"editors": 2, "admins": 3}}
+		_, err := service.Login(context.Background(), config, Claims{Subject: "abc", Groups: []string{"Editors", "other"}})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, []int{1, 2}, users.roles[1])
+	})
+}
@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db      *db.Connection
+	auditor audit.ChangeAuditor
+	clock   *clock.Clock
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, auditor audit.ChangeAuditor, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn, auditor: auditor}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// FindBySubject finds the entity by the provider and the subject at this provider.
+func (s Store) FindBySubject(ctx context.Context, provider, subject string) (*entity.Identity, error) {
+	var i entity.Identity
+	err := s.db.GetContext(ctx, &i, "SELECT * FROM identities WHERE provider = ? AND subject = ? LIMIT 1", provider, subject)
+	return &i, errors.WithStack(checkNotFound(err))
+}
+
+// Create creates a new entity.
+func (s Store) Create(ctx context.Context, i *entity.Identity) (*entity.Identity, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return i, errors.WithStack(err)
+	}
+	i.CreatedAt = null.TimeFrom(s.clock.Now())
+	i.UpdatedAt = null.TimeFrom(s.clock.Now())
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO identities (user_id, provider, subject, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		i.UserID,
+		i.Provider,
+		i.Subject,
+		i.CreatedAt,
+		i.UpdatedAt,
+	)
+	if err != nil {
+		return i, db.RollbackError(tx, errors.WithStack(err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return i, db.RollbackError(tx, errors.WithStack(err))
+	}
+	i.ID = int(id)
+	err = s.auditor.LogCreate(ctx, tx, i)
+	if err != nil {
+		return i, db.RollbackError(tx, errors.WithStack(err))
+	}
+	return i, errors.WithStack(tx.Commit())
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
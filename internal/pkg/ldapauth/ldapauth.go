@@ -0,0 +1,269 @@
+// Package ldapauth checks user credentials against an LDAP directory like OpenLDAP or Active Directory.
+package ldapauth
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/pkg/ldap"
+
+	"github.com/pkg/errors"
+)
+
+// Config contains the settings of the directory.
+type Config struct {
+	// URL is the ldap:// or ldaps:// url of the server.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS.
+	StartTLS bool
+	TLS      *tls.Config
+	Timeout  time.Duration
+	// BindDN and BindPassword are the credentials of the service account that searches
+	// for users. Anonymous searches are used if BindDN is empty.
+	BindDN       string
+	BindPassword string
+	// BaseDN is the subtree that is searched for users.
+	BaseDN string
+	// UserFilter finds the user entry, {username} is replaced with the escaped username.
+	UserFilter string
+	// SubjectAttribute holds the stable identifier of a user, e.g. entryUUID or objectGUID.
+	// The DN is used if it is empty.
+	SubjectAttribute string
+	EmailAttribute   string
+	// GroupAttribute lists the group DNs of a user entry, e.g. memberOf.
+	GroupAttribute string
+	// GroupFilter finds additional groups below GroupBaseDN, {dn} is replaced with the
+	// escaped DN of the user. No groups are searched if it is empty.
+	GroupBaseDN        string
+	GroupFilter        string
+	GroupNameAttribute string
+	// LocalUsers are checked against the database instead of the directory, e.g. the built-in
+	// admin that has to be able to log in if the directory is unavailable.
+	LocalUsers []string
+	Identity   identity.Config
+}
+
+// Authenticator checks credentials using a bind with the DN of the user. The groups of the user are
+// passed to the identity service by DN and by the name of the group, e.g. "cn=admins,ou=groups,dc=example,dc=com"
+// and "admins".
+type Authenticator struct {
+	config     Config
+	identities identityService
+	local      localAuthenticator
+}
+
+// identityService defines the needed methods to find the user of a directory account.
+type identityService interface {
+	Login(ctx context.Context, config identity.Config, claims identity.Claims) (*entity.User, error)
+}
+
+// localAuthenticator checks credentials against the database.
+type localAuthenticator interface {
+	AuthenticateLocal(ctx context.Context, username, password string) (*entity.User, error)
+}
+
+// NewAuthenticator returns a pointer to a new Authenticator.
+func NewAuthenticator(config Config, identities identityService, local localAuthenticator) *Authenticator {
+	return &Authenticator{
+		config:     config,
+		identities: identities,
+		local:      local,
+	}
+}
+
+// Authenticate checks the credentials of a user. It returns entity.ErrNotFound if the user is missing in
+// the directory or is not linked to a user, and entity.ErrUserInvalidPassword if the password is wrong.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (*entity.User, error) {
+	if a.IsLocal(username) {
+		return a.local.AuthenticateLocal(ctx, username, password)
+	}
+	if password == "" {
+		return nil, entity.ErrUserInvalidPassword
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := a.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.Bind(entry.DN, password)
+	if ldap.IsCode(err, ldap.ResultInvalidCredentials) {
+		return nil, entity.ErrUserInvalidPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := a.groups(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := a.subject(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := a.identities.Login(ctx, a.config.Identity, identity.Claims{
+		Subject: subject,
+		Email:   entry.Value(a.config.EmailAttribute),
+		// The address is maintained by the directory administrators.
+		EmailVerified: true,
+		Username:      username,
+		Groups:        groups,
+	})
+	if err == identity.ErrNoAccount {
+		return nil, entity.ErrNotFound
+	}
+	return u, err
+}
+
+// IsLocal returns true if the user is checked against the database.
+func (a *Authenticator) IsLocal(username string) bool {
+	for _, name := range a.config.LocalUsers {
+		if name == username {
+			return true
+		}
+	}
+	return false
+}
+
+// connect opens a connection that is bound as service account.
+func (a *Authenticator) connect() (*ldap.Conn, error) {
+	conn, err := ldap.Dial(a.config.URL, a.config.TLS, a.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.StartTLS {
+		if err = conn.StartTLS(a.config.TLS); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err = a.bindServiceAccount(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// bindServiceAccount authenticates the connection as service account.
+func (a *Authenticator) bindServiceAccount(conn *ldap.Conn) error {
+	if a.config.BindDN == "" {
+		return nil
+	}
+	return errors.Wrap(conn.Bind(a.config.BindDN, a.config.BindPassword), "ldap: service account bind failed")
+}
+
+// findUser returns the entry of a username.
+func (a *Authenticator) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	attrs := []string{a.config.EmailAttribute}
+	if a.config.SubjectAttribute != "" {
+		attrs = append(attrs, a.config.SubjectAttribute)
+	}
+	if a.config.GroupAttribute != "" {
+		attrs = append(attrs, a.config.GroupAttribute)
+	}
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     a.config.BaseDN,
+		Scope:      ldap.ScopeSubtree,
+		Filter:     strings.Replace(a.config.UserFilter, "{username}", ldap.EscapeFilter(username), -1),
+		Attributes: attrs,
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return nil, entity.ErrNotFound
+	case 1:
+		return entries[0], nil
+	}
+	return nil, errors.Errorf("ldap: username %q matches multiple entries", username)
+}
+
+// groups returns the group DNs and names of a user.
+func (a *Authenticator) groups(conn *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	var dns []string
+	if a.config.GroupAttribute != "" {
+		dns = append(dns, entry.Values(a.config.GroupAttribute)...)
+	}
+
+	var names []string
+	if a.config.GroupFilter != "" {
+		// The user might not be allowed to read groups.
+		if err := a.bindServiceAccount(conn); err != nil {
+			return nil, err
+		}
+		entries, err := conn.Search(ldap.SearchRequest{
+			BaseDN:     a.config.GroupBaseDN,
+			Scope:      ldap.ScopeSubtree,
+			Filter:     strings.Replace(a.config.GroupFilter, "{dn}", ldap.EscapeFilter(entry.DN), -1),
+			Attributes: []string{a.config.GroupNameAttribute},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			dns = append(dns, e.DN)
+			if name := e.Value(a.config.GroupNameAttribute); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var groups []string
+	seen := make(map[string]bool)
+	add := func(group string) {
+		if group != "" && !seen[strings.ToLower(group)] {
+			seen[strings.ToLower(group)] = true
+			groups = append(groups, group)
+		}
+	}
+	for _, dn := range dns {
+		add(dn)
+		add(rdnValue(dn))
+	}
+	for _, name := range names {
+		add(name)
+	}
+	return groups, nil
+}
+
+// subject returns the stable identifier of an entry.
+func (a *Authenticator) subject(entry *ldap.Entry) (string, error) {
+	if a.config.SubjectAttribute == "" {
+		return strings.ToLower(entry.DN), nil
+	}
+	v := entry.Value(a.config.SubjectAttribute)
+	if v == "" {
+		return "", errors.Errorf("ldap: entry %q has no %s attribute", entry.DN, a.config.SubjectAttribute)
+	}
+	// Binary identifiers like the objectGUID of Active Directory are stored hex encoded.
+	if !utf8.ValidString(v) {
+		return hex.EncodeToString([]byte(v)), nil
+	}
+	return v, nil
+}
+
+// rdnValue returns the value of the first RDN, e.g. "admins" for "cn=admins,ou=groups,dc=example,dc=com".
+func rdnValue(dn string) string {
+	rdn := strings.SplitN(dn, ",", 2)[0]
+	parts := strings.SplitN(rdn, "=", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
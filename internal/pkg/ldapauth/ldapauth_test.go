@@ -0,0 +1,168 @@
+package ldapauth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/pkg/ldap"
+	"go-webapp-example/pkg/ldap/ldaptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// identityServiceMock records the claims of the last login.
+type identityServiceMock struct {
+	claims identity.Claims
+	err    error
+}
+
+func (i *identityServiceMock) Login(ctx context.Context, config identity.Config, claims identity.Claims) (*entity.User, error) {
+	i.claims = claims
+	if i.err != nil {
+		return nil, i.err
+	}
+	return &entity.User{ID: 5, Name: claims.Username}, nil
+}
+
+// localAuthenticatorMock accepts the password "admin".
+type localAuthenticatorMock struct{}
+
+func (l localAuthenticatorMock) AuthenticateLocal(ctx context.Context, username, password string) (*entity.User, error) {
+	if password != "admin" {
+		return nil, entity.ErrUserInvalidPassword
+	}
+	return &entity.User{ID: 1, Name: username}, nil
+}
+
+func setup() (*ldaptest.Server, Config) {
+	srv := ldaptest.NewServer(
+		&ldap.Entry{DN: "cn=service,dc=example,dc=com", Attributes: map[string][]string{
+			"userPassword": {"service"},
+		}},
+		&ldap.Entry{DN: "uid=jane,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+			"objectClass":  {"person"},
+			"uid":          {"jane"},
+			"entryUUID":    {"6f1b-jane"},
+			"mail":         {"jane@example.com"},
+			"memberOf":     {"cn=Admins,ou=groups,dc=example,dc=com"},
+			"userPassword": {"secret"},
+		}},
+		&ldap.Entry{DN: "cn=editors,ou=groups,dc=example,dc=com", Attributes: map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"cn":          {"editors"},
+			"member":      {"uid=jane,ou=people,dc=example,dc=com"},
+		}},
+	)
+	return srv, Config{
+		URL:                srv.URL,
+		Timeout:            time.Second,
+		BindDN:             "cn=service,dc=example,dc=com",
+		BindPassword:       "service",
+		BaseDN:             "ou=people,dc=example,dc=com",
+		UserFilter:         "(&(objectClass=person)(uid={username}))",
+		SubjectAttribute:   "entryUUID",
+		EmailAttribute:     "mail",
+		GroupAttribute:     "memberOf",
+		GroupBaseDN:        "ou=groups,dc=example,dc=com",
+		GroupFilter:        "(&(objectClass=groupOfNames)(member={dn}))",
+		GroupNameAttribute: "cn",
+		LocalUsers:         []string{"admin"},
+		Identity:           identity.Config{Provider: "ldap"},
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	srv, config := setup()
+	defer srv.Close()
+
+	t.Run("Success", func(t *testing.T) {
+		identities := &identityServiceMock{}
+		a := NewAuthenticator(config, identities, localAuthenticatorMock{})
+
+		u, err := a.Authenticate(context.Background(), "jane", "secret")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5, u.ID)
+		assert.Equal(t, identity.Claims{
+			Subject:       "6f1b-jane",
+			Email:         "jane@example.com",
+			EmailVerified: true,
+			Username:      "jane",
+			Groups: []string{
+				"cn=Admins,ou=groups,dc=example,dc=com",
+				"Admins",
+				"cn=editors,ou=groups,dc=example,dc=com",
+				"editors",
+			},
+		}, identities.claims)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		a := NewAuthenticator(config, &identityServiceMock{}, localAuthenticatorMock{})
+
+		_, err := a.Authenticate(context.Background(), "jane", "wrong")
+		assert.Equal(t, entity.ErrUserInvalidPassword, err)
+
+		_, err = a.Authenticate(context.Background(), "jane", "")
+		assert.Equal(t, entity.ErrUserInvalidPassword, err)
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		a := NewAuthenticator(config, &identityServiceMock{}, localAuthenticatorMock{})
+
+		_, err := a.Authenticate(context.Background(), "j*", "secret")
+
+		assert.Equal(t, entity.ErrNotFound, err)
+	})
+
+	t.Run("NoAccount", func(t *testing.T) {
+		a := NewAuthenticator(config, &identityServiceMock{err: identity.ErrNoAccount}, localAuthenticatorMock{})
+
+		_, err := a.Authenticate(context.Background(), "jane", "secret")
+
+		assert.Equal(t, entity.ErrNotFound, err)
+	})
+
+	t.Run("DNSubject", func(t *testing.T) {
+		config := config
+		config.SubjectAttribute = ""
+		config.GroupFilter = ""
+		identities := &identityServiceMock{}
+		a := NewAuthenticator(config, identities, localAuthenticatorMock{})
+
+		_, err := a.Authenticate(context.Background(), "jane", "secret")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "uid=jane,ou=people,dc=example,dc=com", identities.claims.Subject)
+		assert.Equal(t, []string{"cn=Admins,ou=groups,dc=example,dc=com", "Admins"}, identities.claims.Groups)
+	})
+
+	t.Run("LocalFallback", func(t *testing.T) {
+		config := config
+		// The directory is not reachable.
+		config.URL = "ldap://127.0.0.1:1"
+		a := NewAuthenticator(config, &identityServiceMock{}, localAuthenticatorMock{})
+
+		u, err := a.Authenticate(context.Background(), "admin", "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, u.ID)
+
+		_, err = a.Authenticate(context.Background(), "jane", "secret")
+		assert.Error(t, err)
+		assert.NotEqual(t, entity.ErrNotFound, err)
+	})
+
+	t.Run("ServiceAccountFailure", func(t *testing.T) {
+		config := config
+		config.BindPassword = "wrong"
+		a := NewAuthenticator(config, &identityServiceMock{}, localAuthenticatorMock{})
+
+		_, err := a.Authenticate(context.Background(), "jane", "secret")
+
+		assert.Error(t, err)
+		assert.NotEqual(t, entity.ErrUserInvalidPassword, err)
+	})
+}
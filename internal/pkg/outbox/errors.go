@@ -0,0 +1,8 @@
+package outbox
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when a requested mail could not be found.
+var ErrNotFound = errors.New("mail not found")
@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/mail"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	// MaxAttempts is the number of delivery attempts until a mail is given up.
+	MaxAttempts = 5
+	// batchSize is the number of mails delivered per run.
+	batchSize = 20
+)
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store) *Service {
+	return &Service{
+		Store: store,
+	}
+}
+
+// Queue adds a mail to the outbox. It is sent by the mailer daemon.
+func (s Service) Queue(ctx context.Context, recipient, subject, body string) (*entity.Mail, error) {
+	return s.Store.Create(ctx, &entity.Mail{
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      body,
+	})
+}
+
+// Deliver sends all due mails using sender. Failed mails are retried later with
+// an increasing delay. It returns the number of sent mails.
+func (s Service) Deliver(ctx context.Context, sender mail.Sender, from string) (int, error) {
+	mails, err := s.Store.GetDue(ctx, MaxAttempts, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	var sent int
+	for _, m := range mails {
+		err := sender.Send(ctx, mail.Message{
+			From:    from,
+			To:      m.Recipient,
+			Subject: m.Subject,
+			Body:    m.Body,
+		})
+		now := s.clock.Now()
+		if err != nil {
+			m.Attempts++
+			m.LastError = null.StringFrom(err.Error())
+			m.NextAttemptAt = null.TimeFrom(now.Add(retryDelay(m.Attempts)))
+		} else {
+			m.SentAt = null.TimeFrom(now)
+			m.LastError = null.String{}
+			sent++
+		}
+		if _, err := s.Store.Update(ctx, m); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// retryDelay returns the delay after the nth failed delivery attempt.
+func retryDelay(attempts int) time.Duration {
+	return time.Duration(attempts*attempts) * time.Minute
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/mail"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+var cols = []string{"id", "recipient", "subject", "body", "attempts", "last_error", "next_attempt_at", "sent_at"}
+
+// senderFunc allows a function to be used as mail.Sender.
+type senderFunc func(ctx context.Context, m mail.Message) error
+
+func (f senderFunc) Send(ctx context.Context, m mail.Message) error {
+	return f(ctx, m)
+}
+
+type setupFn func() (sqlmock.Sqlmock, *Service)
+
+// TestOutboxService tests all service methods as well as the underlying store.
+func TestOutboxService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service) {
+		conn, mockDB := test.MockDB(t)
+		service := NewService(NewStore(conn, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}))
+		return mockDB, service
+	}
+
+	t.Run("Queue", queue(setup))
+	t.Run("Deliver", deliver(setup))
+	t.Run("DeliverFailed", deliverFailed(setup))
+}
+
+func queue(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service := setup()
+
+		mock.
+			ExpectExec("INSERT INTO mails").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		m, err := service.Queue(context.Background(), "admin@example.com", "Subject", "Body")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, m.ID)
+		assert.Equal(t, "admin@example.com", m.Recipient)
+		assert.False(t, m.SentAt.Valid)
+	}
+}
+
+func expectDue(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.
+		ExpectQuery("SELECT .+ FROM mails WHERE sent_at IS NULL").
+		WithArgs(MaxAttempts, now, batchSize).
+		WillReturnRows(rows)
+}
+
+func deliver(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service := setup()
+
+		expectDue(mock, sqlmock.NewRows(cols).
+			AddRow(1, "admin@example.com", "Subject", "Body", 1, "timeout", now, nil))
+		mock.
+			ExpectExec("UPDATE mails SET").
+			WithArgs(1, nil, now, now, now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		var sent []mail.Message
+		n, err := service.Deliver(context.Background(), senderFunc(func(ctx context.Context, m mail.Message) error {
+			sent = append(sent, m)
+			return nil
+		}), "noreply@example.com")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []mail.Message{{
+			From:    "noreply@example.com",
+			To:      "admin@example.com",
+			Subject: "Subject",
+			Body:    "Body",
+		}}, sent)
+	}
+}
+
+func deliverFailed(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service := setup()
+
+		expectDue(mock, sqlmock.NewRows(cols).
+			AddRow(1, "admin@example.com", "Subject", "Body", 1, nil, nil, nil))
+		mock.
+			ExpectExec("UPDATE mails SET").
+			WithArgs(2, "connection refused", now.Add(4*time.Minute), nil, now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		n, err := service.Deliver(context.Background(), senderFunc(func(ctx context.Context, m mail.Message) error {
+			return errors.New("connection refused")
+		}), "noreply@example.com")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 0, n)
+	}
+}
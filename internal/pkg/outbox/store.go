@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db    *db.Connection
+	clock *clock.Clock
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Find finds the entity by id.
+func (s Store) Find(ctx context.Context, id int) (*entity.Mail, error) {
+	var m entity.Mail
+	err := s.db.GetContext(ctx, &m, "SELECT * FROM mails WHERE id = ? LIMIT 1", id)
+	return &m, errors.WithStack(checkNotFound(err))
+}
+
+// GetDue returns unsent mails that are due for a delivery attempt.
+func (s Store) GetDue(ctx context.Context, maxAttempts, limit int) ([]*entity.Mail, error) {
+	var mails []*entity.Mail
+	err := s.db.SelectContext(
+		ctx,
+		&mails,
+		"SELECT * FROM mails WHERE sent_at IS NULL AND attempts < ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?) ORDER BY id LIMIT ?",
+		maxAttempts,
+		s.clock.Now(),
+		limit,
+	)
+	return mails, errors.WithStack(err)
+}
+
+// Create creates a new entity.
+func (s Store) Create(ctx context.Context, m *entity.Mail) (*entity.Mail, error) {
+	m.CreatedAt = null.TimeFrom(s.clock.Now())
+	m.UpdatedAt = null.TimeFrom(s.clock.Now())
+	query, params, err := sq.Insert("mails").SetMap(mapCols(m)).ToSql()
+	if err != nil {
+		return m, errors.WithStack(err)
+	}
+	res, err := s.db.ExecContext(ctx, query, params...)
+	if err != nil {
+		return m, errors.WithStack(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return m, errors.WithStack(err)
+	}
+	m.ID = int(id)
+	return m, nil
+}
+
+// Update saves the delivery state of an entity to the database.
+func (s Store) Update(ctx context.Context, m *entity.Mail) (*entity.Mail, error) {
+	if m.ID < 1 {
+		return m, errors.WithStack(db.ErrNotExists)
+	}
+	m.UpdatedAt = null.TimeFrom(s.clock.Now())
+	_, err := s.db.ExecContext(
+		ctx,
+		"UPDATE mails SET attempts = ?, last_error = ?, next_attempt_at = ?, sent_at = ?, updated_at = ? WHERE id = ?",
+		m.Attempts,
+		m.LastError,
+		m.NextAttemptAt,
+		m.SentAt,
+		m.UpdatedAt,
+		m.ID,
+	)
+	return m, errors.WithStack(err)
+}
+
+// mapCols maps the entity to all default columns.
+func mapCols(m *entity.Mail) db.ColumnMap {
+	return db.ColumnMap{
+		"recipient":       m.Recipient,
+		"subject":         m.Subject,
+		"body":            m.Body,
+		"attempts":        m.Attempts,
+		"last_error":      m.LastError,
+		"next_attempt_at": m.NextAttemptAt,
+		"sent_at":         m.SentAt,
+		"created_at":      m.CreatedAt,
+		"updated_at":      m.UpdatedAt,
+	}
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/validation"
+
+	"github.com/pkg/errors"
+)
+
+// Config contains the rules for new passwords.
+type Config struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Blocklist is a file with one rejected password per line, e.g. a list of
+	// common or breached passwords. Lines starting with # are ignored.
+	Blocklist string
+	// History is the number of previous passwords of a user that cannot be reused.
+	History int
+	// MaxAge forces a password change at the next login if the password is older. It is disabled if 0.
+	MaxAge time.Duration
+}
+
+// Policy checks new passwords against the configured rules.
+type Policy struct {
+	config  Config
+	blocked map[string]bool
+}
+
+// NewPolicy returns a pointer to a new Policy. The blocklist file is loaded immediately.
+func NewPolicy(config Config) (*Policy, error) {
+	p := &Policy{config: config, blocked: make(map[string]bool)}
+	if config.Blocklist == "" {
+		return p, nil
+	}
+	f, err := os.Open(config.Blocklist)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open password blocklist")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.blocked[strings.ToLower(line)] = true
+	}
+	return p, errors.Wrap(scanner.Err(), "failed to read password blocklist")
+}
+
+// Validate adds an error to the bag for every rule the password violates.
+func (p *Policy) Validate(errs *validation.ErrorBag, field, password string) {
+	if len([]rune(password)) < p.config.MinLength {
+		errs.AddData(field, "min_length", map[string]string{"size": strconv.Itoa(p.config.MinLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.config.RequireUpper && !upper {
+		errs.Add(field, "password_upper")
+	}
+	if p.config.RequireLower && !lower {
+		errs.Add(field, "password_lower")
+	}
+	if p.config.RequireDigit && !digit {
+		errs.Add(field, "password_digit")
+	}
+	if p.config.RequireSymbol && !symbol {
+		errs.Add(field, "password_symbol")
+	}
+	if p.blocked[strings.ToLower(password)] {
+		errs.Add(field, "password_common")
+	}
+}
+
+// Expired returns true if the password of the user has to be changed.
+func (p *Policy) Expired(u *entity.User, now time.Time) bool {
+	if p.config.MaxAge <= 0 || !u.PasswordChangedAt.Valid {
+		return false
+	}
+	return now.Sub(u.PasswordChangedAt.Time) > p.config.MaxAge
+}
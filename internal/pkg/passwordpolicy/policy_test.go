@@ -0,0 +1,83 @@
+package passwordpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwordpolicy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	require.NoError(t, ioutil.WriteFile(blocklist, []byte("# common passwords\n\nPassword1!\nletmein\n"), 0600))
+
+	policy, err := NewPolicy(Config{
+		MinLength:     8,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Blocklist:     blocklist,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		keys     []string
+	}{
+		{"Valid", "Corr3ct-Horse", nil},
+		{"TooShort", "Ab1!", []string{"validation.min_length"}},
+		{"NoUpper", "corr3ct-horse", []string{"validation.password_upper"}},
+		{"NoLower", "CORR3CT-HORSE", []string{"validation.password_lower"}},
+		{"NoDigit", "Correct-Horse", []string{"validation.password_digit"}},
+		{"NoSymbol", "Corr3ctHorse", []string{"validation.password_symbol"}},
+		{"Blocked", "password1!", []string{"validation.password_upper", "validation.password_common"}},
+		{"Unicode", "Äöü1-Äöü", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validation.NewErrorBag("user")
+			policy.Validate(errs, "password", tc.password)
+
+			var keys []string
+			for _, e := range errs.Errors()["password"] {
+				keys = append(keys, e.Message)
+			}
+			assert.Equal(t, tc.keys, keys)
+		})
+	}
+}
+
+func TestNewPolicyMissingBlocklist(t *testing.T) {
+	_, err := NewPolicy(Config{Blocklist: "/does/not/exist.txt"})
+
+	assert.Error(t, err)
+}
+
+func TestPolicyExpired(t *testing.T) {
+	now := time.Now()
+	policy, err := NewPolicy(Config{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+
+	assert.False(t, policy.Expired(&entity.User{PasswordChangedAt: null.TimeFrom(now.Add(-time.Hour))}, now))
+	assert.True(t, policy.Expired(&entity.User{PasswordChangedAt: null.TimeFrom(now.Add(-25 * time.Hour))}, now))
+	assert.False(t, policy.Expired(&entity.User{}, now))
+
+	disabled, err := NewPolicy(Config{})
+	require.NoError(t, err)
+	assert.False(t, disabled.Expired(&entity.User{PasswordChangedAt: null.TimeFrom(now.Add(-1000 * time.Hour))}, now))
+}
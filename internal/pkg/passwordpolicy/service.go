@@ -0,0 +1,53 @@
+package passwordpolicy
+
+import (
+	"context"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Service enforces the password policy. It allows access to the store and the policy by embedding them.
+type Service struct {
+	*Store
+	*Policy
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store, policy *Policy) *Service {
+	return &Service{
+		Store:  store,
+		Policy: policy,
+	}
+}
+
+// Reused returns true if the password matches one of the last passwords of the user.
+func (s Service) Reused(ctx context.Context, userID int, password string) (bool, error) {
+	if s.config.History <= 0 {
+		return false, nil
+	}
+	hashes, err := s.Store.GetHashes(ctx, userID, s.config.History)
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Remember adds the password hash to the history of the user.
+func (s Service) Remember(ctx context.Context, userID int, hash string) error {
+	if s.config.History <= 0 {
+		return nil
+	}
+	return s.Store.Create(ctx, userID, hash, s.config.History)
+}
+
+// PasswordExpired returns true if the user has to change the password.
+func (s Service) PasswordExpired(u *entity.User) bool {
+	return s.Policy.Expired(u, s.Store.clock.Now())
+}
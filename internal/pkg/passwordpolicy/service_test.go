@@ -0,0 +1,110 @@
+package passwordpolicy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+type setupFn func() (sqlmock.Sqlmock, *Service)
+
+// TestPasswordPolicyService tests the password history of the service as well as the underlying store.
+func TestPasswordPolicyService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service) {
+		conn, mockDB := test.MockDB(t)
+		policy, err := NewPolicy(Config{History: 2})
+		require.NoError(t, err)
+		service := NewService(NewStore(conn, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}), policy)
+		return mockDB, service
+	}
+
+	t.Run("Reused", reused(setup))
+	t.Run("Remember", remember(setup))
+	t.Run("RememberPrune", rememberPrune(setup))
+}
+
+func reused(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service := setup()
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			mock.
+				ExpectQuery("SELECT hash FROM password_history WHERE user_id = . ORDER BY id DESC LIMIT .").
+				WithArgs(1, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(string(hash)))
+		}
+
+		ok, err := service.Reused(context.Background(), 1, "old-password")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = service.Reused(context.Background(), 1, "new-password")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func remember(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service := setup()
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO password_history").
+			WithArgs(1, "hash", now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.
+			ExpectQuery("SELECT id FROM password_history WHERE user_id = . ORDER BY id DESC LIMIT .").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		err := service.Remember(context.Background(), 1, "hash")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func rememberPrune(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service := setup()
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO password_history").
+			WithArgs(1, "hash", now).
+			WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.
+			ExpectQuery("SELECT id FROM password_history WHERE user_id = . ORDER BY id DESC LIMIT .").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(4))
+		mock.
+			ExpectExec("DELETE FROM password_history WHERE user_id = . AND id < .").
+			WithArgs(1, 4).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err := service.Remember(context.Background(), 1, "hash")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,61 @@
+package passwordpolicy
+
+import (
+	"context"
+
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	"github.com/pkg/errors"
+)
+
+// Store handles the direct database access for the password history.
+type Store struct {
+	db    *db.Connection
+	clock *clock.Clock
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// GetHashes returns the hashes of the last passwords of a user, the newest first.
+func (s Store) GetHashes(ctx context.Context, userID, limit int) ([]string, error) {
+	var hashes []string
+	err := s.db.SelectContext(ctx, &hashes, "SELECT hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, limit)
+	return hashes, errors.WithStack(err)
+}
+
+// Create adds a password hash to the history of a user and removes all but the last keep entries.
+func (s Store) Create(ctx context.Context, userID int, hash string, keep int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO password_history (user_id, hash, created_at) VALUES (?, ?, ?)",
+		userID,
+		hash,
+		s.clock.Now(),
+	)
+	if err != nil {
+		return db.RollbackError(tx, errors.WithStack(err))
+	}
+	var ids []int
+	err = tx.SelectContext(ctx, &ids, "SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, keep)
+	if err != nil {
+		return db.RollbackError(tx, errors.WithStack(err))
+	}
+	if len(ids) == keep {
+		_, err = tx.ExecContext(ctx, "DELETE FROM password_history WHERE user_id = ? AND id < ?", userID, ids[len(ids)-1])
+		if err != nil {
+			return db.RollbackError(tx, errors.WithStack(err))
+		}
+	}
+	return errors.WithStack(tx.Commit())
+}
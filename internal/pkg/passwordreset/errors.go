@@ -0,0 +1,12 @@
+package passwordreset
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a requested password reset could not be found.
+	ErrNotFound = errors.New("password reset not found")
+	// ErrInvalidToken is returned if a token is unknown, expired or was already used.
+	ErrInvalidToken = errors.New("invalid password reset token")
+)
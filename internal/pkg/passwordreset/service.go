@@ -0,0 +1,168 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/pkg/i18n"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// tokenBytes is the number of random bytes in a token.
+const tokenBytes = 32
+
+// Config contains the settings for password resets.
+type Config struct {
+	// URL is the base url of the application, the reset link points to it.
+	URL string
+	// TTL is the time a reset token is valid.
+	TTL time.Duration
+}
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+	users   userService
+	outbox  outbox
+	limiter limiter
+	locale  *i18n.Locale
+	config  Config
+}
+
+// userService defines the needed methods to look up and update users.
+type userService interface {
+	Find(ctx context.Context, id int) (*entity.User, error)
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	Update(ctx context.Context, u *entity.User) (*entity.User, error)
+	PasswordReused(ctx context.Context, userID int, password string) (bool, error)
+}
+
+// outbox queues mails for delivery.
+type outbox interface {
+	Queue(ctx context.Context, recipient, subject, body string) (*entity.Mail, error)
+}
+
+// limiter limits the requests per email address and client IP.
+type limiter interface {
+	Limit(ctx context.Context, scope, subject, ipScope, ip string) error
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store, users userService, outbox outbox, limiter limiter, locale *i18n.Locale, config Config) *Service {
+	return &Service{
+		Store:   store,
+		users:   users,
+		outbox:  outbox,
+		limiter: limiter,
+		locale:  locale,
+		config:  config,
+	}
+}
+
+// Request sends a reset link to the user with the given email address. Unknown addresses
+// are ignored silently so the response does not reveal which accounts exist. The requests
+// per address and client IP are limited, known or not, so no address can be flooded with
+// mails. throttle.ErrLocked is returned if a limit is exceeded.
+func (s Service) Request(ctx context.Context, email, ip string) error {
+	err := s.limiter.Limit(ctx, entity.LoginScopeResetEmail, strings.ToLower(email), entity.LoginScopeResetIP, ip)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.users.FindByEmail(ctx, email)
+	if errors.Cause(err) == user.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	_, err = s.Store.Create(ctx, &entity.PasswordReset{
+		UserID:    u.ID,
+		Hash:      Hash(token),
+		ExpiresAt: null.TimeFrom(s.clock.Now().Add(s.config.TTL)),
+	})
+	if err != nil {
+		return err
+	}
+
+	vars := map[string]string{
+		"name": u.Name,
+		"url":  strings.TrimRight(s.config.URL, "/") + "/reset-password?token=" + token,
+	}
+	_, err = s.outbox.Queue(
+		ctx,
+		u.Email.String,
+		s.locale.GetVar("passwordreset.mail.subject", vars),
+		s.locale.GetVar("passwordreset.mail.body", vars),
+	)
+	return err
+}
+
+// Reset sets a new password for the user the token was issued to. Each token can only be used once.
+// A password that was used before is rejected with user.ErrPasswordReused. The token stays valid
+// if the password is rejected or could not be saved.
+func (s Service) Reset(ctx context.Context, token, password string) (*entity.User, error) {
+	p, err := s.Store.FindByHash(ctx, Hash(token))
+	if errors.Cause(err) == ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.UsedAt.Valid || !p.ExpiresAt.Time.After(s.clock.Now()) {
+		return nil, ErrInvalidToken
+	}
+	reused, err := s.users.PasswordReused(ctx, p.UserID, password)
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, user.ErrPasswordReused
+	}
+	// The token is claimed first, so it can not be used by a concurrent request.
+	if err = s.Store.MarkUsed(ctx, p); err != nil {
+		return nil, err
+	}
+
+	u, err := s.users.Find(ctx, p.UserID)
+	if err == nil {
+		u.Password = password
+		u, err = s.users.Update(ctx, u)
+	}
+	if err != nil {
+		if releaseErr := s.Store.Release(ctx, p); releaseErr != nil {
+			return nil, errors.Wrapf(releaseErr, "failed to release password reset after: %s", err)
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of a token.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateToken returns a new random token.
+func generateToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate password reset token")
+	}
+	return hex.EncodeToString(b), nil
+}
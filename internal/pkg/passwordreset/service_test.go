@@ -0,0 +1,284 @@
+package passwordreset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/i18n"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+var cols = []string{"id", "user_id", "hash", "expires_at", "used_at"}
+
+var testUser = &entity.User{ID: 1, Name: "admin", Email: null.StringFrom("admin@example.com")}
+
+// userRecorder returns the test user and records all updates.
+type userRecorder struct {
+	updated []*entity.User
+	// err is returned by Update if set.
+	err error
+}
+
+func (u *userRecorder) Find(ctx context.Context, id int) (*entity.User, error) {
+	if id != testUser.ID {
+		return nil, user.ErrNotFound
+	}
+	copied := *testUser
+	return &copied, nil
+}
+
+func (u *userRecorder) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
+	if email != testUser.Email.String {
+		return nil, user.ErrNotFound
+	}
+	return u.Find(ctx, testUser.ID)
+}
+
+func (u *userRecorder) Update(ctx context.Context, updated *entity.User) (*entity.User, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	u.updated = append(u.updated, updated)
+	return updated, nil
+}
+
+// PasswordReused treats "old-password" as used before.
+func (u *userRecorder) PasswordReused(ctx context.Context, userID int, password string) (bool, error) {
+	return password == "old-password", nil
+}
+
+// outboxRecorder records all queued mails.
+type outboxRecorder struct {
+	mails []*entity.Mail
+}
+
+func (o *outboxRecorder) Queue(ctx context.Context, recipient, subject, body string) (*entity.Mail, error) {
+	m := &entity.Mail{Recipient: recipient, Subject: subject, Body: body}
+	o.mails = append(o.mails, m)
+	return m, nil
+}
+
+// limiterRecorder records the limited requests and locks the subjects in locked.
+type limiterRecorder struct {
+	requests []string
+	locked   map[string]bool
+}
+
+func (l *limiterRecorder) Limit(ctx context.Context, scope, subject, ipScope, ip string) error {
+	l.requests = append(l.requests, scope+"="+subject, ipScope+"="+ip)
+	if l.locked[subject] || l.locked[ip] {
+		return throttle.ErrLocked
+	}
+	return nil
+}
+
+type setupFn func() (sqlmock.Sqlmock, *Service, *userRecorder, *outboxRecorder)
+
+// TestPasswordResetService tests all service methods as well as the underlying store.
+func TestPasswordResetService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service, *userRecorder, *outboxRecorder) {
+		conn, mockDB := test.MockDB(t)
+		users := &userRecorder{}
+		mails := &outboxRecorder{}
+		locale := &i18n.Locale{Data: map[string]interface{}{
+			"passwordreset": map[string]interface{}{
+				"mail": map[string]interface{}{"subject": "Reset", "body": "Hi {name}: {url}"},
+			},
+		}}
+		service := NewService(NewStore(conn, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}), users, mails, &limiterRecorder{}, locale, Config{URL: "http://localhost/", TTL: time.Hour})
+		return mockDB, service, users, mails
+	}
+
+	t.Run("Request", request(setup))
+	t.Run("RequestUnknown", requestUnknown(setup))
+	t.Run("RequestThrottled", requestThrottled(setup))
+	t.Run("Reset", reset(setup))
+	t.Run("ResetExpired", resetExpired(setup))
+	t.Run("ResetUsed", resetUsed(setup))
+	t.Run("ResetConcurrent", resetConcurrent(setup))
+	t.Run("ResetReused", resetReused(setup))
+	t.Run("ResetUpdateFailed", resetUpdateFailed(setup))
+}
+
+func request(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _, mails := setup()
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM password_resets WHERE user_id = . AND used_at IS NULL").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectExec("INSERT INTO password_resets").
+			WithArgs(1, sqlmock.AnyArg(), now.Add(time.Hour), now, now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := service.Request(context.Background(), "admin@example.com", "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, []string{"reset_email=admin@example.com", "reset_ip=127.0.0.1"}, service.limiter.(*limiterRecorder).requests)
+		assert.Len(t, mails.mails, 1)
+		if len(mails.mails) > 0 {
+			assert.Equal(t, "admin@example.com", mails.mails[0].Recipient)
+			assert.Equal(t, "Reset", mails.mails[0].Subject)
+			assert.Regexp(t, "^Hi admin: http://localhost/reset-password\\?token=[0-9a-f]{64}$", mails.mails[0].Body)
+		}
+	}
+}
+
+func requestUnknown(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _, mails := setup()
+
+		err := service.Request(context.Background(), "unknown@example.com", "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, mails.mails, 0)
+		assert.Len(t, service.limiter.(*limiterRecorder).requests, 2)
+	}
+}
+
+func requestThrottled(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _, mails := setup()
+		service.limiter.(*limiterRecorder).locked = map[string]bool{"admin@example.com": true}
+
+		err := service.Request(context.Background(), "admin@example.com", "127.0.0.1")
+
+		assert.Equal(t, throttle.ErrLocked, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, mails.mails, 0)
+	}
+}
+
+func expectFindByHash(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.
+		ExpectQuery("SELECT .+ FROM password_resets WHERE hash = . LIMIT 1").
+		WithArgs(Hash("secret")).
+		WillReturnRows(rows)
+}
+
+func reset(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, users, _ := setup()
+
+		expectFindByHash(mock, sqlmock.NewRows(cols).AddRow(1, 1, Hash("secret"), now.Add(time.Minute), nil))
+		mock.
+			ExpectExec("UPDATE password_resets SET used_at = .+ WHERE id = . AND used_at IS NULL").
+			WithArgs(now, now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		u, err := service.Reset(context.Background(), "secret", "new-password")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, u.ID)
+		assert.Len(t, users.updated, 1)
+		if len(users.updated) > 0 {
+			assert.Equal(t, "new-password", users.updated[0].Password)
+		}
+	}
+}
+
+func resetExpired(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, users, _ := setup()
+
+		expectFindByHash(mock, sqlmock.NewRows(cols).AddRow(1, 1, Hash("secret"), now, nil))
+
+		_, err := service.Reset(context.Background(), "secret", "new-password")
+
+		assert.Equal(t, ErrInvalidToken, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, users.updated, 0)
+	}
+}
+
+func resetUsed(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, users, _ := setup()
+
+		expectFindByHash(mock, sqlmock.NewRows(cols).AddRow(1, 1, Hash("secret"), now.Add(time.Minute), now))
+
+		_, err := service.Reset(context.Background(), "secret", "new-password")
+
+		assert.Equal(t, ErrInvalidToken, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, users.updated, 0)
+	}
+}
+
+func resetConcurrent(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, users, _ := setup()
+
+		expectFindByHash(mock, sqlmock.NewRows(cols).AddRow(1, 1, Hash("secret"), now.Add(time.Minute), nil))
+		mock.
+			ExpectExec("UPDATE password_resets SET used_at").
+			WithArgs(now, now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		_, err := service.Reset(context.Background(), "secret", "new-password")
+
+		assert.Equal(t, ErrInvalidToken, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, users.updated, 0)
+	}
+}
+
+func resetReused(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, users, _ := setup()
+
+		// The token is not marked as used, a new password can be chosen.
+		expectFindByHash(mock, sqlmock.NewRows(cols).AddRow(1, 1, Hash("secret"), now.Add(time.Minute), nil))
+
+		_, err := service.Reset(context.Background(), "secret", "old-password")
+
+		assert.Equal(t, user.ErrPasswordReused, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, users.updated, 0)
+	}
+}
+
+func resetUpdateFailed(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, users, _ := setup()
+		users.err = errors.New("update failed")
+
+		// The token is released again, the user can retry with the same link.
+		expectFindByHash(mock, sqlmock.NewRows(cols).AddRow(1, 1, Hash("secret"), now.Add(time.Minute), nil))
+		mock.
+			ExpectExec("UPDATE password_resets SET used_at = .+ WHERE id = . AND used_at IS NULL").
+			WithArgs(now, now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectExec("UPDATE password_resets SET used_at = NULL, updated_at = . WHERE id = .").
+			WithArgs(now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err := service.Reset(context.Background(), "secret", "new-password")
+
+		assert.EqualError(t, err, "update failed")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,117 @@
+package passwordreset
+
+import (
+	"context"
+	"database/sql"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db    *db.Connection
+	clock *clock.Clock
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// FindByHash finds the entity by its token hash.
+func (s Store) FindByHash(ctx context.Context, hash string) (*entity.PasswordReset, error) {
+	var p entity.PasswordReset
+	err := s.db.GetContext(ctx, &p, "SELECT * FROM password_resets WHERE hash = ? LIMIT 1", hash)
+	return &p, errors.WithStack(checkNotFound(err))
+}
+
+// Create creates a new entity. Unused resets of the same user are removed so
+// only the most recent token is valid.
+func (s Store) Create(ctx context.Context, p *entity.PasswordReset) (*entity.PasswordReset, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return p, errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL", p.UserID)
+	if err != nil {
+		return p, db.RollbackError(tx, errors.WithStack(err))
+	}
+	p.CreatedAt = null.TimeFrom(s.clock.Now())
+	p.UpdatedAt = null.TimeFrom(s.clock.Now())
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO password_resets (user_id, hash, expires_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		p.UserID,
+		p.Hash,
+		p.ExpiresAt,
+		p.CreatedAt,
+		p.UpdatedAt,
+	)
+	if err != nil {
+		return p, db.RollbackError(tx, errors.WithStack(err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return p, db.RollbackError(tx, errors.WithStack(err))
+	}
+	p.ID = int(id)
+	return p, errors.WithStack(tx.Commit())
+}
+
+// MarkUsed marks a reset as used. It fails with ErrInvalidToken if
+// the reset was used concurrently.
+func (s Store) MarkUsed(ctx context.Context, p *entity.PasswordReset) error {
+	now := null.TimeFrom(s.clock.Now())
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE password_resets SET used_at = ?, updated_at = ? WHERE id = ? AND used_at IS NULL",
+		now,
+		now,
+		p.ID,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if affected == 0 {
+		return ErrInvalidToken
+	}
+	p.UsedAt = now
+	p.UpdatedAt = now
+	return nil
+}
+
+// Release marks a used reset as unused again, so the token can be used once more.
+func (s Store) Release(ctx context.Context, p *entity.PasswordReset) error {
+	p.UpdatedAt = null.TimeFrom(s.clock.Now())
+	_, err := s.db.ExecContext(
+		ctx,
+		"UPDATE password_resets SET used_at = NULL, updated_at = ? WHERE id = ?",
+		p.UpdatedAt,
+		p.ID,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p.UsedAt = null.Time{}
+	return nil
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package passwordreset
+
+import (
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/pkg/validation"
+)
+
+// ValidateRequest validates a request for a password reset link.
+func ValidateRequest(email string) *validation.ErrorBag {
+	errs := validation.NewErrorBag("passwordreset")
+
+	if email == "" {
+		errs.Add("email", "required")
+	}
+
+	return errs
+}
+
+// passwordValidator checks new passwords against the password policy.
+type passwordValidator interface {
+	Validate(errs *validation.ErrorBag, field, password string)
+}
+
+// ValidateResetRequest validates a request to set a new password.
+func ValidateResetRequest(input *gqlmodels.PasswordResetInput, policy passwordValidator) *validation.ErrorBag {
+	errs := validation.NewErrorBag("passwordreset")
+
+	if input.Token == "" {
+		errs.Add("token", "required")
+	}
+	if input.Password == "" {
+		errs.Add("password", "required")
+	} else {
+		policy.Validate(errs, "password", input.Password)
+	}
+	if input.PasswordRepeat == "" {
+		errs.Add("password_repeat", "required")
+	}
+	if input.Password != input.PasswordRepeat {
+		errs.Add("password_repeat", "no_match")
+	}
+
+	return errs
+}
@@ -0,0 +1,43 @@
+package passwordreset
+
+import (
+	"testing"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPolicy only requires the minimum length.
+var testPolicy, _ = passwordpolicy.NewPolicy(passwordpolicy.Config{MinLength: 4})
+
+func TestValidateResetRequest(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		input := gqlmodels.PasswordResetInput{
+			Token:          "",
+			Password:       "abc",
+			PasswordRepeat: "abcd",
+		}
+
+		err := ValidateResetRequest(&input, testPolicy)
+
+		assert.True(t, err.Failed())
+		assert.Len(t, err.Get("token"), 1)
+		assert.Len(t, err.Get("password"), 1)
+		assert.Len(t, err.Get("password_repeat"), 1)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		input := gqlmodels.PasswordResetInput{
+			Token:          "token",
+			Password:       "password",
+			PasswordRepeat: "password",
+		}
+
+		err := ValidateResetRequest(&input, testPolicy)
+
+		assert.False(t, err.Failed())
+		assert.Len(t, err.Errors(), 0)
+	})
+}
@@ -4,5 +4,11 @@ import (
 	"github.com/pkg/errors"
 )
 
-// ErrNotFound is returned when a requested permission could not be found.
-var ErrNotFound = errors.New("permission not found")
+var (
+	// ErrUnknownCode is returned for permission codes that were not declared by any module.
+	ErrUnknownCode = errors.New("unknown permission code")
+	// ErrInvalidLevel is returned if a level cannot be granted for a permission code.
+	ErrInvalidLevel = errors.New("invalid permission level")
+	// ErrNotOwnable is returned if a permission cannot be limited to own records.
+	ErrNotOwnable = errors.New("permission cannot be limited to own records")
+)
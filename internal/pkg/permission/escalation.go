@@ -0,0 +1,118 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/pkg/session"
+)
+
+// grantChecker reads the permissions of users and roles from the auth manager.
+type grantChecker interface {
+	Can(userID int, subject, action string) bool
+	PermissionsForRole(id int) [][]string
+	InheritedPermissionsForRole(id int) [][]string
+}
+
+// EscalationError is returned if a user grants permissions they are not granted themselves.
+type EscalationError struct {
+	Permissions []*entity.Permission
+}
+
+func (e *EscalationError) Error() string {
+	return fmt.Sprintf("cannot grant permissions that are not granted to the user: %s", e.CodeLevels())
+}
+
+// CodeLevels returns the permissions that could not be granted in the "code::level" format.
+func (e *EscalationError) CodeLevels() string {
+	codes := make([]string, len(e.Permissions))
+	for i, p := range e.Permissions {
+		codes[i] = p.CodeLevel()
+	}
+	return strings.Join(codes, ", ")
+}
+
+// CheckGrant returns an EscalationError if the authenticated user grants permissions they are not
+// granted themselves. Superusers can grant all permissions, as can requests without an authenticated
+// user like logins and commands. Deny rules never grant anything, so they are not checked. Use Added
+// to turn removed deny rules into the permissions they grant again.
+func CheckGrant(ctx context.Context, a grantChecker, permissions []*entity.Permission) error {
+	u, err := session.UserFromContext(ctx)
+	if err != nil || u.IsSuperuser {
+		return nil
+	}
+	t, hasToken := token.FromContext(ctx)
+	var missing []*entity.Permission
+	seen := make(map[entity.Permission]bool)
+	for _, p := range permissions {
+		key := entity.Permission{Code: p.Code, Level: p.Level, Own: p.Own}
+		if p.Deny || p.Level == entity.PermissionLevelNone || seen[key] {
+			continue
+		}
+		seen[key] = true
+		held := a.Can(u.ID, p.Code, p.Level.Action(false))
+		if !held && p.Own {
+			held = a.Can(u.ID, p.Code, p.Level.Action(true))
+		}
+		if held && hasToken {
+			held = t.Allows(p.Code, p.Level) && (p.Own || !t.OwnOnly(p.Code, p.Level))
+		}
+		if !held {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return &EscalationError{Permissions: missing}
+	}
+	return nil
+}
+
+// CheckRoleGrant returns an EscalationError if the authenticated user assigns roles whose
+// permissions, including the inherited ones, are not granted to the user.
+func CheckRoleGrant(ctx context.Context, a grantChecker, roleIDs []int) error {
+	var permissions []*entity.Permission
+	for _, id := range roleIDs {
+		permissions = append(permissions, effective(append(a.PermissionsForRole(id), a.InheritedPermissionsForRole(id)...))...)
+	}
+	return CheckGrant(ctx, a, permissions)
+}
+
+// Added returns the permissions that are not granted by the current policies yet. Permissions a
+// subject already has can be kept by everyone who edits it, only additional grants are checked.
+// Removing a deny grants the level it blocked again, so it is returned as a granted permission.
+func Added(current [][]string, permissions []*entity.Permission) []*entity.Permission {
+	granted, _ := split(current)
+	var added []*entity.Permission
+	for _, p := range permissions {
+		kept := false
+		for _, g := range granted {
+			if g.Grants(p) {
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			added = append(added, p)
+		}
+	}
+	for _, policy := range current {
+		d := entity.PermissionFromPolicy(policy)
+		if d.Deny && !denies(permissions, d) {
+			added = append(added, &entity.Permission{Code: d.Code, Level: d.Level})
+		}
+	}
+	return added
+}
+
+// denies checks if one of the permissions still denies the level of a deny policy.
+func denies(permissions []*entity.Permission, d *entity.Permission) bool {
+	for _, p := range permissions {
+		if p.Deny && p.Code == d.Code && d.Level.Includes(p.Level) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package permission
+
+import (
+	"sort"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/pkg/errors"
+)
+
+// AllLevels can be used by modules that support every permission level.
+var AllLevels = []entity.PermissionLevel{
+	entity.PermissionLevelRead,
+	entity.PermissionLevelWrite,
+	entity.PermissionLevelManage,
+}
+
+// AuditPermissions are the permission codes of the audit log. The audit package cannot declare
+// them itself since this package depends on it. Audit logs are only read, never edited.
+var AuditPermissions = []Definition{
+	{Code: "admin.audit", Label: "audit.permissions.admin", Levels: []entity.PermissionLevel{entity.PermissionLevelRead}},
+}
+
+// Definition declares a permission code of a module.
+type Definition struct {
+	Code string
+	// Label is the translation key of the name shown in the role editor.
+	Label string
+	// Levels are the levels that can be granted, ordered from the lowest to the highest.
+	Levels []entity.PermissionLevel
+	// Ownable permissions can be limited to records created by the user. The module
+	// has to check the owner of a record if it supports this.
+	Ownable bool
+}
+
+// allows returns true if the level can be granted. The none level is always allowed.
+func (d Definition) allows(level entity.PermissionLevel) bool {
+	if level == entity.PermissionLevelNone {
+		return true
+	}
+	for _, l := range d.Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry contains the permission codes declared by all modules.
+type Registry struct {
+	definitions map[string]Definition
+}
+
+// NewRegistry returns a registry with the permissions of the given modules. Codes
+// have to be unique and must only use the known permission levels.
+func NewRegistry(modules ...[]Definition) (*Registry, error) {
+	r := &Registry{definitions: make(map[string]Definition)}
+	for _, definitions := range modules {
+		for _, d := range definitions {
+			if d.Code == "" || len(d.Levels) == 0 {
+				return nil, errors.Errorf("permission %q needs a code and at least one level", d.Code)
+			}
+			if _, ok := r.definitions[d.Code]; ok {
+				return nil, errors.Errorf("permission %q is declared twice", d.Code)
+			}
+			for _, l := range d.Levels {
+				if l == entity.PermissionLevelNone || !entity.PermissionLevelManage.Includes(l) {
+					return nil, errors.Errorf("permission %q uses the invalid level %q", d.Code, l)
+				}
+			}
+			r.definitions[d.Code] = d
+		}
+	}
+	return r, nil
+}
+
+// Definitions returns all declared permissions ordered by their code.
+func (r *Registry) Definitions() []Definition {
+	out := make([]Definition, 0, len(r.definitions))
+	for _, d := range r.definitions {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Code < out[j].Code
+	})
+	return out
+}
+
+// Check returns ErrUnknownCode, ErrInvalidLevel or ErrNotOwnable if the permission was not declared.
+func (r *Registry) Check(p *entity.Permission) error {
+	d, ok := r.definitions[p.Code]
+	if !ok {
+		return errors.Wrapf(ErrUnknownCode, "permission %q", p.Code)
+	}
+	if !d.allows(p.Level) {
+		return errors.Wrapf(ErrInvalidLevel, "permission %q", p.CodeLevel())
+	}
+	if p.Own && !d.Ownable {
+		return errors.Wrapf(ErrNotOwnable, "permission %q", p.CodeLevel())
+	}
+	return nil
+}
+
+// CheckCodeLevel checks a permission in the "permission.code::level" format.
+func (r *Registry) CheckCodeLevel(in string) error {
+	p, err := entity.ParseCodeLevel(in)
+	if err != nil {
+		return err
+	}
+	return r.Check(p)
+}
@@ -0,0 +1,72 @@
+package permission
+
+import (
+	"testing"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var testDefinitions = []Definition{
+	{Code: "admin.user", Label: "user.permissions.admin", Levels: AllLevels, Ownable: true},
+	{Code: "report", Label: "report.permissions.view", Levels: []entity.PermissionLevel{entity.PermissionLevelRead}},
+}
+
+func TestNewRegistry(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		r, err := NewRegistry(testDefinitions)
+
+		assert.NoError(t, err)
+		if assert.Len(t, r.Definitions(), 2) {
+			assert.Equal(t, "admin.user", r.Definitions()[0].Code)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		_, err := NewRegistry(testDefinitions, []Definition{{Code: "report", Levels: AllLevels}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidLevel", func(t *testing.T) {
+		_, err := NewRegistry([]Definition{{Code: "report", Levels: []entity.PermissionLevel{"edit"}}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("NoLevels", func(t *testing.T) {
+		_, err := NewRegistry([]Definition{{Code: "report"}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistryCheck(t *testing.T) {
+	r, err := NewRegistry(testDefinitions)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		permission string
+		err        error
+	}{
+		{"admin.user::manage", nil},
+		{"admin.user::none", nil},
+		{"report::read", nil},
+		{"report::write", ErrInvalidLevel},
+		{"admin.user::write:own", nil},
+		{"report::read:own", ErrNotOwnable},
+		{"admin.role::read", ErrUnknownCode},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.permission, func(t *testing.T) {
+			err := r.CheckCodeLevel(tc.permission)
+
+			assert.Equal(t, tc.err, errors.Cause(err))
+		})
+	}
+
+	assert.Error(t, r.CheckCodeLevel("admin.user"))
+}
@@ -1,14 +1,93 @@
 package permission
 
-// Service is used to interact with the entity. It
-// allows access to the store by embedding it.
+import (
+	"strings"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// Service is used to interact with the entity. It allows access
+// to the store and the registry of declared permissions by embedding them.
 type Service struct {
 	*Store
+	*Registry
 }
 
 // NewService returns a pointer to a new Service.
-func NewService(store *Store) *Service {
+func NewService(store *Store, registry *Registry) *Service {
 	return &Service{
-		Store: store,
+		Store:    store,
+		Registry: registry,
+	}
+}
+
+// OwnOnly returns true if the user was granted a permission only for records the user created.
+func (s *Service) OwnOnly(u *entity.User, code string, level entity.PermissionLevel) bool {
+	if u.IsSuperuser || s.auth.Can(u.ID, code, level.Action(false)) {
+		return false
+	}
+	return s.auth.Can(u.ID, code, level.Action(true))
+}
+
+// CanAccess checks if the user can access a record of the owner with a permission.
+// Records without an owner can only be accessed with a permission for all records.
+func (s *Service) CanAccess(u *entity.User, code string, level entity.PermissionLevel, owner null.Int) bool {
+	if u.IsSuperuser {
+		return true
+	}
+	if !owner.Valid {
+		return s.auth.Can(u.ID, code, level.Action(false))
+	}
+	return s.auth.CanOwn(u.ID, int(owner.Int64), code, level.Action(false))
+}
+
+// Granted checks if the user has a permission at least for own records. This is the same
+// check the @restricted directive uses for the permissions it requires.
+func (s *Service) Granted(u *entity.User, p *entity.Permission) bool {
+	if u.IsSuperuser {
+		return true
+	}
+	if !p.Own && s.auth.Can(u.ID, p.Code, p.Level.Action(false)) {
+		return true
+	}
+	return s.auth.Can(u.ID, p.Code, p.Level.Action(true))
+}
+
+// Explain returns the verdict of Granted together with the policies that granted
+// or denied the permission and the roles the user got them from.
+func (s *Service) Explain(u *entity.User, p *entity.Permission) *entity.PermissionExplanation {
+	ex := &entity.PermissionExplanation{Permission: p, Superuser: u.IsSuperuser}
+
+	actions := []string{p.Level.Action(false), p.Level.Action(true)}
+	if p.Own {
+		actions = actions[1:]
+	}
+	seen := make(map[string]bool)
+	for _, action := range actions {
+		allowed, matches := s.auth.Explain(u.ID, p.Code, action)
+		for _, m := range matches {
+			line := strings.Join(append([]string{"p"}, m.Policy...), ", ")
+			if seen[line] {
+				continue
+			}
+			seen[line] = true
+			ex.Policies = append(ex.Policies, &entity.PolicyMatch{
+				Permission: entity.PermissionFromPolicy(m.Policy),
+				Policy:     line,
+				RoleIDs:    m.Roles,
+			})
+		}
+		if allowed {
+			ex.Allowed = true
+			ex.Own = action != p.Level.Action(false)
+			break
+		}
+	}
+	if u.IsSuperuser {
+		ex.Allowed = true
+		ex.Own = false
 	}
+	return ex
 }
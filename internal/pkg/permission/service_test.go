@@ -3,103 +3,228 @@ package permission
 import (
 	"context"
 	"testing"
-	"time"
 
 	"go-webapp-example/internal/pkg/entity"
-	"go-webapp-example/internal/pkg/test"
 	"go-webapp-example/pkg/auth"
-	"go-webapp-example/pkg/clock"
-	"go-webapp-example/pkg/log"
+	"go-webapp-example/pkg/session"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
 )
 
-// now is used as time for all test cases.
-var now = time.Now()
+type authManagerMock struct{}
 
-// TestPermissionService tests all service methods as well as the underlying store.
-func TestPermissionService(t *testing.T) {
-	db, mock := test.MockDB(t)
-	mock.ExpectExec("CREATE TABLE IF NOT EXISTS policies .*").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("SELECT 1 FROM `policies` LIMIT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+func (a authManagerMock) PermissionsForUser(id int) [][]string {
+	perms := [][]string{{"role::1", "admin.user", "read", "allow"}, {"role::1", "admin.user", "write", "allow"}}
+	if id == 3 {
+		perms = append(perms, []string{"user::3", "admin.user", "write", "deny"})
+	}
+	return perms
+}
 
-	authManager, err := auth.New(db, log.NewNullLogger())
-	if err != nil {
-		t.Fatalf("failed to create auth manager: %s", err)
+func (a authManagerMock) PermissionsForRole(id int) [][]string {
+	perms := [][]string{{"role::1", "admin.quote", "read", "allow"}}
+	if id == 2 {
+		perms = append(perms, []string{"role::2", "admin.quote", "read", "deny"})
 	}
-	service := NewService(NewStore(db, authManager, func(store *Store) {
-		store.clock = clock.FromTime(now)
-	}))
-
-	t.Run("Get", get(mock, service))
-	t.Run("Create", create(mock, service))
-	t.Run("Update", update(mock, service))
-	t.Run("Delete", del(mock, service))
+	return perms
 }
 
-func get(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
-	return func(t *testing.T) {
-		rows := sqlmock.
-			NewRows([]string{"id", "code"}).
-			AddRow(1, "device.create").
-			AddRow(2, "device.update")
+func (a authManagerMock) UserPermissions(id int) [][]string {
+	return [][]string{{"user::5", "admin.role", "read", "deny"}}
+}
+
+func (a authManagerMock) UsersForRole(id int) []int {
+	return []int{5}
+}
 
-		mock.ExpectQuery("SELECT (.+) FROM permissions$").WillReturnRows(rows)
+// Can grants admin.user::read for all records and admin.quote::write for own records.
+func (a authManagerMock) Can(userID int, subject, action string) bool {
+	return subject+"::"+action == "admin.user::read" || subject+"::"+action == "admin.quote::write:own"
+}
 
-		permissions, err := service.Get(context.Background())
+func (a authManagerMock) CanOwn(userID, ownerID int, subject, action string) bool {
+	return a.Can(userID, subject, action) || userID == ownerID && a.Can(userID, subject, action+":own")
+}
 
-		assert.NoError(t, err)
-		assert.NoError(t, mock.ExpectationsWereMet())
-		assert.Len(t, permissions, 2)
+// Explain returns the policies of the permissions granted by Can.
+func (a authManagerMock) Explain(userID int, subject, action string) (bool, []auth.PolicyMatch) {
+	if !a.Can(userID, subject, action) {
+		return false, nil
 	}
+	return true, []auth.PolicyMatch{{Policy: []string{"role-3", subject, action, "allow"}, Roles: []int{2, 3}}}
 }
 
-func create(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
-	return func(t *testing.T) {
-		mock.
-			ExpectExec("INSERT INTO permissions").
-			WithArgs("device.delete").
-			WillReturnResult(sqlmock.NewResult(3, 1))
+func (a authManagerMock) InheritedPermissionsForRole(id int) [][]string {
+	return [][]string{{"role::2", "admin.role", "read"}, {"role::3", "admin.role", "read"}}
+}
+
+// TestPermissionService tests all service methods as well as the underlying store.
+func TestPermissionService(t *testing.T) {
+	service := NewService(NewStore(nil, authManagerMock{}), nil)
+
+	t.Run("GetForUserID", func(t *testing.T) {
+		permissions := service.GetForUserID(context.Background(), 1)
 
-		permission := &entity.Permission{Code: "device.delete"}
+		assert.Equal(t, []*entity.Permission{
+			{Code: "admin.user", Level: entity.PermissionLevelRead},
+			{Code: "admin.user", Level: entity.PermissionLevelWrite},
+		}, permissions)
+	})
 
-		result, err := service.Create(context.Background(), permission)
+	t.Run("GetByUserID", func(t *testing.T) {
+		permissions, err := service.GetByUserID(context.Background(), []int{1, 2})
 
 		assert.NoError(t, err)
-		assert.NoError(t, mock.ExpectationsWereMet())
-		assert.NotEqual(t, 0, result.ID)
-	}
-}
+		assert.Len(t, permissions[1], 2)
+		assert.Len(t, permissions[2], 2)
+	})
 
-func update(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
-	return func(t *testing.T) {
-		mock.
-			ExpectExec("UPDATE permissions SET code = .+ WHERE id = .").
-			WithArgs("New Permission", 3).
-			WillReturnResult(sqlmock.NewResult(0, 1))
+	t.Run("GetByUserIDDenied", func(t *testing.T) {
+		permissions, err := service.GetByUserID(context.Background(), []int{3})
 
-		permission := &entity.Permission{ID: 3, Code: "New Permission"}
+		assert.NoError(t, err)
+		assert.Equal(t, []*entity.Permission{{Code: "admin.user", Level: entity.PermissionLevelRead}}, permissions[3])
+	})
 
-		_, err := service.Update(context.Background(), permission)
+	t.Run("GetDirectByUserID", func(t *testing.T) {
+		permissions, err := service.GetDirectByUserID(context.Background(), []int{5})
 
 		assert.NoError(t, err)
-		assert.NoError(t, mock.ExpectationsWereMet())
-	}
-}
+		assert.Equal(t, []*entity.Permission{{Code: "admin.role", Level: entity.PermissionLevelRead, Deny: true}}, permissions[5])
+	})
 
-func del(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
-	return func(t *testing.T) {
-		mock.
-			ExpectExec("DELETE FROM permissions WHERE id = . LIMIT 1").
-			WithArgs(3).
-			WillReturnResult(sqlmock.NewResult(0, 1))
+	t.Run("GetConflictsForRoleID", func(t *testing.T) {
+		conflicts := service.GetConflictsForRoleID(context.Background(), 2)
 
-		permission := &entity.Permission{ID: 3}
+		assert.Equal(t, []*Conflict{
+			{Permission: &entity.Permission{Code: "admin.quote", Level: entity.PermissionLevelRead}},
+			{Permission: &entity.Permission{Code: "admin.role", Level: entity.PermissionLevelRead}, UserID: 5},
+		}, conflicts)
+	})
 
-		_, err := service.Delete(context.Background(), permission)
+	t.Run("GetByRoleID", func(t *testing.T) {
+		permissions, err := service.GetByRoleID(context.Background(), []int{1})
 
 		assert.NoError(t, err)
-		assert.NoError(t, mock.ExpectationsWereMet())
-	}
+		assert.Equal(t, []*entity.Permission{{Code: "admin.quote", Level: entity.PermissionLevelRead}}, permissions[1])
+	})
+
+	t.Run("GetInheritedByRoleID", func(t *testing.T) {
+		permissions, err := service.GetInheritedByRoleID(context.Background(), []int{1})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*entity.Permission{{Code: "admin.role", Level: entity.PermissionLevelRead}}, permissions[1])
+	})
+
+	t.Run("OwnOnly", func(t *testing.T) {
+		u := &entity.User{ID: 2}
+
+		assert.False(t, service.OwnOnly(u, "admin.user", entity.PermissionLevelRead))
+		assert.True(t, service.OwnOnly(u, "admin.quote", entity.PermissionLevelWrite))
+		assert.False(t, service.OwnOnly(&entity.User{ID: 1, IsSuperuser: true}, "admin.quote", entity.PermissionLevelWrite))
+	})
+
+	t.Run("CanAccess", func(t *testing.T) {
+		u := &entity.User{ID: 2}
+
+		assert.True(t, service.CanAccess(u, "admin.user", entity.PermissionLevelRead, null.IntFrom(1)))
+		assert.True(t, service.CanAccess(u, "admin.quote", entity.PermissionLevelWrite, null.IntFrom(2)))
+		assert.False(t, service.CanAccess(u, "admin.quote", entity.PermissionLevelWrite, null.IntFrom(1)))
+		assert.False(t, service.CanAccess(u, "admin.quote", entity.PermissionLevelWrite, null.Int{}))
+	})
+	t.Run("Granted", func(t *testing.T) {
+		u := &entity.User{ID: 2}
+
+		assert.True(t, service.Granted(u, &entity.Permission{Code: "admin.user", Level: entity.PermissionLevelRead}))
+		assert.True(t, service.Granted(u, &entity.Permission{Code: "admin.quote", Level: entity.PermissionLevelWrite}))
+		assert.False(t, service.Granted(u, &entity.Permission{Code: "admin.role", Level: entity.PermissionLevelRead}))
+		assert.True(t, service.Granted(&entity.User{ID: 1, IsSuperuser: true}, &entity.Permission{Code: "admin.role", Level: entity.PermissionLevelRead}))
+	})
+
+	t.Run("Explain", func(t *testing.T) {
+		u := &entity.User{ID: 2}
+
+		ex := service.Explain(u, &entity.Permission{Code: "admin.quote", Level: entity.PermissionLevelWrite})
+		assert.True(t, ex.Allowed)
+		assert.True(t, ex.Own)
+		assert.Equal(t, []*entity.PolicyMatch{{
+			Permission: &entity.Permission{Code: "admin.quote", Level: entity.PermissionLevelWrite, Own: true},
+			Policy:     "p, role-3, admin.quote, write:own, allow",
+			RoleIDs:    []int{2, 3},
+		}}, ex.Policies)
+
+		ex = service.Explain(u, &entity.Permission{Code: "admin.role", Level: entity.PermissionLevelRead})
+		assert.False(t, ex.Allowed)
+		assert.Empty(t, ex.Policies)
+	})
+}
+
+func TestCheckGrant(t *testing.T) {
+	ctx := context.WithValue(context.Background(), session.CtxKey, &entity.User{ID: 4})
+
+	t.Run("Held", func(t *testing.T) {
+		err := CheckGrant(ctx, authManagerMock{}, []*entity.Permission{
+			{Code: "admin.user", Level: entity.PermissionLevelRead},
+			{Code: "admin.quote", Level: entity.PermissionLevelWrite, Own: true},
+			{Code: "admin.role", Level: entity.PermissionLevelManage, Deny: true},
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Escalation", func(t *testing.T) {
+		err := CheckGrant(ctx, authManagerMock{}, []*entity.Permission{
+			{Code: "admin.user", Level: entity.PermissionLevelWrite},
+			{Code: "admin.quote", Level: entity.PermissionLevelWrite},
+		})
+		escalation, ok := err.(*EscalationError)
+		assert.True(t, ok)
+		assert.Equal(t, "admin.user::write, admin.quote::write", escalation.CodeLevels())
+	})
+
+	t.Run("Exempt", func(t *testing.T) {
+		permissions := []*entity.Permission{{Code: "admin.user", Level: entity.PermissionLevelManage}}
+		assert.NoError(t, CheckGrant(context.Background(), authManagerMock{}, permissions))
+
+		superuser := context.WithValue(context.Background(), session.CtxKey, &entity.User{ID: 1, IsSuperuser: true})
+		assert.NoError(t, CheckGrant(superuser, authManagerMock{}, permissions))
+	})
+
+	t.Run("Role", func(t *testing.T) {
+		err := CheckRoleGrant(ctx, authManagerMock{}, []int{2})
+		escalation, ok := err.(*EscalationError)
+		assert.True(t, ok)
+		assert.Equal(t, "admin.role::read", escalation.CodeLevels())
+	})
+
+	t.Run("Added", func(t *testing.T) {
+		added := Added([][]string{{"role-1", "admin.quote", "read", "allow"}}, []*entity.Permission{
+			{Code: "admin.quote", Level: entity.PermissionLevelRead},
+			{Code: "admin.quote", Level: entity.PermissionLevelWrite},
+		})
+		assert.Equal(t, []*entity.Permission{{Code: "admin.quote", Level: entity.PermissionLevelWrite}}, added)
+	})
+
+	t.Run("RemovedDeny", func(t *testing.T) {
+		current := [][]string{
+			{"user-4", "admin.user", "write", "deny"},
+			{"user-4", "admin.user", "manage", "deny"},
+		}
+		added := Added(current, nil)
+		assert.Equal(t, []*entity.Permission{
+			{Code: "admin.user", Level: entity.PermissionLevelWrite},
+			{Code: "admin.user", Level: entity.PermissionLevelManage},
+		}, added)
+		escalation, ok := CheckGrant(ctx, authManagerMock{}, added).(*EscalationError)
+		assert.True(t, ok)
+		assert.Equal(t, "admin.user::write, admin.user::manage", escalation.CodeLevels())
+
+		added = Added(current, []*entity.Permission{{Code: "admin.user", Level: entity.PermissionLevelManage, Deny: true}})
+		escalation, ok = CheckGrant(ctx, authManagerMock{}, added).(*EscalationError)
+		assert.True(t, ok)
+		assert.Equal(t, "admin.user::write", escalation.CodeLevels())
+
+		added = Added(current, []*entity.Permission{{Code: "admin.user", Level: entity.PermissionLevelRead, Deny: true}})
+		assert.NoError(t, CheckGrant(ctx, authManagerMock{}, added))
+	})
 }
@@ -2,21 +2,26 @@ package permission
 
 import (
 	"context"
-	"database/sql"
 
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/clock"
 	"go-webapp-example/pkg/db"
-
-	"github.com/pkg/errors"
 )
 
 type authManager interface {
 	PermissionsForUser(id int) [][]string
 	PermissionsForRole(id int) [][]string
+	InheritedPermissionsForRole(id int) [][]string
+	UserPermissions(id int) [][]string
+	UsersForRole(id int) []int
+
+	Can(userID int, subject, action string) bool
+	CanOwn(userID, ownerID int, subject, action string) bool
+	Explain(userID int, subject, action string) (bool, []auth.PolicyMatch)
 }
 
-// Store handles the direct database access for this entity.
+// Store reads the permissions granted to users and roles from the auth manager.
 type Store struct {
 	db    *db.Connection
 	auth  authManager
@@ -32,46 +37,30 @@ func NewStore(conn *db.Connection, authManager authManager, opts ...func(s *Stor
 	return s
 }
 
-// Find finds the entity by id.
-func (s Store) Find(ctx context.Context, id int) (*entity.Permission, error) {
-	var permission entity.Permission
-	err := s.db.GetContext(ctx, &permission, "SELECT * FROM permissions WHERE id = ? LIMIT 1", id)
-	return &permission, errors.WithStack(checkNotFound(err))
-}
-
-// Get returns all available entities.
-func (s Store) Get(ctx context.Context) ([]*entity.Permission, error) {
-	var permissions []*entity.Permission
-	err := s.db.SelectContext(ctx, &permissions, "SELECT * FROM permissions")
-	return permissions, errors.WithStack(err)
-}
-
-// GetByUserID returns a map of user ids to a slice of permissions.
+// GetByUserID returns a map of user ids to a slice of the permissions they are granted.
 func (s Store) GetByUserID(ctx context.Context, ids []int) (map[int][]*entity.Permission, error) {
 	ret := make(map[int][]*entity.Permission)
 	for _, id := range ids {
-		perms := s.auth.PermissionsForUser(id)
-		for _, perm := range perms {
-			ret[id] = append(ret[id], &entity.Permission{
-				Code:  perm[1],
-				Level: entity.PermissionLevel(perm[2]),
-			})
-		}
+		ret[id] = effective(s.auth.PermissionsForUser(id))
 	}
 	return ret, nil
 }
 
-// GetForUserID returns a slice of permissions for a given user.
+// GetForUserID returns a slice of permissions a given user is granted.
 func (s Store) GetForUserID(ctx context.Context, id int) []*entity.Permission {
-	var ret []*entity.Permission
-	perms := s.auth.PermissionsForUser(id)
-	for _, perm := range perms {
-		ret = append(ret, &entity.Permission{
-			Code:  perm[1],
-			Level: entity.PermissionLevel(perm[2]),
-		})
+	return effective(s.auth.PermissionsForUser(id))
+}
+
+// GetDirectByUserID returns a map of user ids to a slice of the permissions that were granted or denied
+// for the user directly instead of through a role.
+func (s Store) GetDirectByUserID(ctx context.Context, ids []int) (map[int][]*entity.Permission, error) {
+	ret := make(map[int][]*entity.Permission)
+	for _, id := range ids {
+		for _, perm := range s.auth.UserPermissions(id) {
+			ret[id] = append(ret[id], entity.PermissionFromPolicy(perm))
+		}
 	}
-	return ret
+	return ret, nil
 }
 
 // GetByRoleID returns a map of role ids to a slice of permissions.
@@ -80,51 +69,92 @@ func (s Store) GetByRoleID(ctx context.Context, ids []int) (map[int][]*entity.Pe
 	for _, id := range ids {
 		perms := s.auth.PermissionsForRole(id)
 		for _, perm := range perms {
-			ret[id] = append(ret[id], &entity.Permission{
-				Code:  perm[1],
-				Level: entity.PermissionLevel(perm[2]),
-			})
+			ret[id] = append(ret[id], entity.PermissionFromPolicy(perm))
 		}
 	}
 	return ret, nil
 }
 
-// Create creates a new entity.
-func (s Store) Create(ctx context.Context, permission *entity.Permission) (*entity.Permission, error) {
-	res, err := s.db.Exec("INSERT INTO permissions (code) VALUES (?);", permission.Code)
-	if err != nil {
-		return permission, errors.WithStack(err)
-	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return permission, errors.WithStack(err)
+// GetInheritedByRoleID returns a map of role ids to a slice of the permissions they inherit from their parents.
+func (s Store) GetInheritedByRoleID(ctx context.Context, ids []int) (map[int][]*entity.Permission, error) {
+	ret := make(map[int][]*entity.Permission)
+	for _, id := range ids {
+		seen := make(map[entity.Permission]bool)
+		for _, perm := range s.auth.InheritedPermissionsForRole(id) {
+			p := entity.PermissionFromPolicy(perm)
+			if seen[*p] {
+				continue
+			}
+			seen[*p] = true
+			ret[id] = append(ret[id], p)
+		}
 	}
-	permission.ID = int(id)
-	return permission, nil
+	return ret, nil
 }
 
-// Update saves an updated entity to the database.
-func (s Store) Update(ctx context.Context, permission *entity.Permission) (*entity.Permission, error) {
-	if permission.ID < 1 {
-		return permission, errors.WithStack(db.ErrNotExists)
+// Conflict is a permission that is granted by a role but denied by the role itself,
+// one of its parents or for one of its users.
+type Conflict struct {
+	Permission *entity.Permission
+	// UserID is the user the permission is denied for, it is 0 if it is denied for the whole role.
+	UserID int
+}
+
+// GetConflictsForRoleID returns all permissions of a role that are revoked by a deny rule.
+func (s Store) GetConflictsForRoleID(ctx context.Context, id int) []*Conflict {
+	granted, denied := split(append(s.auth.PermissionsForRole(id), s.auth.InheritedPermissionsForRole(id)...))
+	var conflicts []*Conflict
+	seen := make(map[string]bool)
+	for _, p := range granted {
+		if denied.revokes(p) && !seen[p.CodeLevel()] {
+			seen[p.CodeLevel()] = true
+			conflicts = append(conflicts, &Conflict{Permission: p})
+		}
+	}
+	for _, userID := range s.auth.UsersForRole(id) {
+		_, deniedForUser := split(s.auth.UserPermissions(userID))
+		seenForUser := make(map[string]bool)
+		for _, p := range granted {
+			if deniedForUser.revokes(p) && !seen[p.CodeLevel()] && !seenForUser[p.CodeLevel()] {
+				seenForUser[p.CodeLevel()] = true
+				conflicts = append(conflicts, &Conflict{Permission: p, UserID: userID})
+			}
+		}
 	}
-	_, err := s.db.Exec("UPDATE permissions SET code = ? WHERE id = ?", permission.Code, permission.ID)
-	return permission, errors.WithStack(err)
+	return conflicts
+}
+
+// denials contains the denied permissions in the "code::level" format.
+type denials map[string]bool
+
+// revokes checks if a granted permission is denied. Denied levels revoke permissions for own records as well.
+func (d denials) revokes(p *entity.Permission) bool {
+	return d[entity.Permission{Code: p.Code, Level: p.Level}.CodeLevel()]
 }
 
-// Delete removes an entity from the database.
-func (s Store) Delete(ctx context.Context, permission *entity.Permission) (*entity.Permission, error) {
-	if permission.ID < 1 {
-		return permission, nil
+// split separates the granted from the denied permissions of a list of policies.
+func split(policies [][]string) ([]*entity.Permission, denials) {
+	var granted []*entity.Permission
+	denied := make(denials)
+	for _, policy := range policies {
+		p := entity.PermissionFromPolicy(policy)
+		if p.Deny {
+			denied[p.CodeLevel()] = true
+		} else {
+			granted = append(granted, p)
+		}
 	}
-	_, err := s.db.Exec("DELETE FROM permissions WHERE id = ? LIMIT 1", permission.ID)
-	return permission, errors.WithStack(err)
+	return granted, denied
 }
 
-// checkNotFound returns a ErrNotFound if no rows were returned.
-func checkNotFound(err error) error {
-	if err == sql.ErrNoRows {
-		return ErrNotFound
+// effective returns the granted permissions of a list of policies without the denied ones.
+func effective(policies [][]string) []*entity.Permission {
+	granted, denied := split(policies)
+	var ret []*entity.Permission
+	for _, p := range granted {
+		if !denied.revokes(p) {
+			ret = append(ret, p)
+		}
 	}
-	return err
+	return ret
 }
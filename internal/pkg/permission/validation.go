@@ -0,0 +1,60 @@
+package permission
+
+import (
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/validation"
+
+	"github.com/pkg/errors"
+)
+
+// checker checks permissions against the codes declared by the modules.
+type checker interface {
+	Check(p *entity.Permission) error
+}
+
+// FromInput turns permission inputs into permissions.
+func FromInput(input []*gqlmodels.PermissionInput) []*entity.Permission {
+	var permissions []*entity.Permission
+	for _, p := range input {
+		if p == nil {
+			continue
+		}
+		permissions = append(permissions, &entity.Permission{
+			Code:  p.Code,
+			Level: entity.PermissionLevel(p.Level),
+			Own:   p.Own != nil && *p.Own,
+			Deny:  p.Deny != nil && *p.Deny,
+		})
+	}
+	return permissions
+}
+
+// ValidateInput adds an error to the field for every permission input that was not declared.
+func ValidateInput(errs *validation.ErrorBag, field string, input []*gqlmodels.PermissionInput, permissions checker) {
+	for _, p := range FromInput(input) {
+		if p.Own && p.Deny {
+			errs.AddData(field, "permission_deny_own", map[string]string{"permission": p.Code})
+			continue
+		}
+		err := permissions.Check(p)
+		switch errors.Cause(err) {
+		case nil:
+		case ErrInvalidLevel:
+			errs.AddData(field, "permission_level", map[string]string{"permission": p.Code, "level": string(p.Level)})
+		case ErrNotOwnable:
+			errs.AddData(field, "permission_own", map[string]string{"permission": p.Code})
+		default:
+			errs.AddData(field, "permission_unknown", map[string]string{"permission": p.Code})
+		}
+	}
+}
+
+// ValidateGrant turns an EscalationError into a validation error of the field.
+func ValidateGrant(subject, field string, err error) *validation.ErrorBag {
+	errs := validation.NewErrorBag(subject)
+	if escalation, ok := errors.Cause(err).(*EscalationError); ok {
+		errs.AddData(field, "permission_escalation", map[string]string{"permissions": escalation.CodeLevels()})
+	}
+	return errs
+}
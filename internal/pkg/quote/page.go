@@ -0,0 +1,47 @@
+package quote
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+	"gopkg.in/guregu/null.v3"
+)
+
+// sortColumns contains the columns quotes can be sorted by.
+var sortColumns = []string{"id", "author", "created_at"}
+
+// Filter limits the quotes of a page. Empty fields match all quotes.
+type Filter struct {
+	// Search matches quotes whose author or content contains the value.
+	Search       string
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+	// CreatedBy matches the quotes created by a user.
+	CreatedBy null.Int
+}
+
+// where returns the conditions of the filter.
+func (f Filter) where() []sq.Sqlizer {
+	where := []sq.Sqlizer{db.Between("created_at", f.CreatedFrom, f.CreatedUntil)}
+	if f.Search != "" {
+		where = append(where, sq.Or{db.Contains("author", f.Search), db.Contains("content", f.Search)})
+	}
+	if f.CreatedBy.Valid {
+		where = append(where, sq.Eq{"created_by": f.CreatedBy.Int64})
+	}
+	return where
+}
+
+// GetPage returns a page of the quotes that match the filter.
+func (s Store) GetPage(ctx context.Context, filter Filter, sorts []db.Sort, args db.PageArgs) ([]*entity.Quote, *db.PageInfo, error) {
+	if err := db.CheckSorts(sorts, sortColumns...); err != nil {
+		return nil, nil, err
+	}
+	var quotes []*entity.Quote
+	info, err := s.db.Paginate(ctx, &quotes, "quotes", filter.where(), sorts, args)
+	return quotes, info, err
+}
@@ -0,0 +1,10 @@
+package quote
+
+import (
+	"go-webapp-example/internal/pkg/permission"
+)
+
+// Permissions are the permission codes declared by this module.
+var Permissions = []permission.Definition{
+	{Code: "admin.quote", Label: "quote.permissions.admin", Levels: permission.AllLevels, Ownable: true},
+}
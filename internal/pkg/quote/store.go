@@ -6,12 +6,15 @@ import (
 
 	"go-webapp-example/internal/pkg/audit"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/event"
 	"go-webapp-example/pkg/clock"
 	"go-webapp-example/pkg/db"
+	"go-webapp-example/pkg/session"
 	"go-webapp-example/pkg/util"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
 )
 
 // Store handles the direct database access for this entity.
@@ -19,6 +22,7 @@ type Store struct {
 	db      *db.Connection
 	clock   *clock.Clock
 	auditor audit.ChangeAuditor
+	events  event.Publisher
 }
 
 // NewStore returns a new store instance.
@@ -30,6 +34,13 @@ func NewStore(conn *db.Connection, auditor audit.ChangeAuditor, opts ...func(s *
 	return s
 }
 
+// WithEvents publishes the changes of committed quotes to p.
+func WithEvents(p event.Publisher) func(s *Store) {
+	return func(s *Store) {
+		s.events = p
+	}
+}
+
 // Find finds the entity by id.
 func (s Store) Find(ctx context.Context, id int) (*entity.Quote, error) {
 	var quote entity.Quote
@@ -70,6 +81,9 @@ func (s Store) Create(ctx context.Context, quote *entity.Quote) (*entity.Quote,
 	}
 	quote.CreatedAt = s.clock.Now()
 	quote.UpdatedAt = s.clock.Now()
+	if u, err := session.UserFromContext(ctx); err == nil {
+		quote.CreatedBy = null.IntFrom(int64(u.ID))
+	}
 	query, params, err := sq.Insert("quotes").SetMap(mapCols(quote)).ToSql()
 	if err != nil {
 		return quote, errors.WithStack(err)
@@ -88,6 +102,7 @@ func (s Store) Create(ctx context.Context, quote *entity.Quote) (*entity.Quote,
 	if err != nil {
 		return quote, db.RollbackError(tx, errors.WithStack(err))
 	}
+	tx.AfterCommit(func() { s.publish(event.Created(quote)) })
 	return quote, errors.WithStack(tx.Commit())
 }
 
@@ -99,6 +114,7 @@ func (s Store) Update(ctx context.Context, quote *entity.Quote) (*entity.Quote,
 	}
 
 	quote.CreatedAt = current.CreatedAt
+	quote.CreatedBy = current.CreatedBy
 	quote.UpdatedAt = s.clock.Now()
 
 	query, params, err := sq.Update("quotes").SetMap(mapCols(quote)).Where(sq.Eq{"id": quote.ID}).ToSql()
@@ -119,6 +135,7 @@ func (s Store) Update(ctx context.Context, quote *entity.Quote) (*entity.Quote,
 	if err != nil {
 		return quote, db.RollbackError(tx, errors.WithStack(err))
 	}
+	tx.AfterCommit(func() { s.publish(event.Updated(current, quote)) })
 
 	return quote, errors.WithStack(tx.Commit())
 }
@@ -153,15 +170,24 @@ func (s Store) deleteEntity(ctx context.Context, tx *db.Tx, source *entity.Quote
 	if err != nil {
 		return source, errors.WithStack(err)
 	}
+	tx.AfterCommit(func() { s.publish(event.Deleted(source)) })
 	return source, nil
 }
 
+// publish sends a change to the subscribers if the store publishes events.
+func (s Store) publish(c *event.Change) {
+	if s.events != nil {
+		s.events.Publish(c)
+	}
+}
+
 // mapCols maps the entity to all default columns.
 func mapCols(quote *entity.Quote) db.ColumnMap {
 	return db.ColumnMap{
 		"id":         quote.ID,
 		"author":     quote.Author,
 		"content":    quote.Content,
+		"created_by": quote.CreatedBy,
 		"updated_at": quote.UpdatedAt,
 		"created_at": quote.CreatedAt,
 	}
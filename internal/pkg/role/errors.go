@@ -1,8 +1,25 @@
 package role
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 )
 
 // ErrNotFound is returned when a requested role could not be found.
 var ErrNotFound = errors.New("role not found")
+
+// ErrInheritanceCycle is returned when a role would inherit from itself.
+var ErrInheritanceCycle = errors.New("role inheritance contains a cycle")
+
+// ErrInheritanceDepth is returned when a chain of parent roles gets too long.
+var ErrInheritanceDepth = errors.New("role inheritance is too deep")
+
+// ParentNotFoundError is returned when a parent role does not exist.
+type ParentNotFoundError struct {
+	ID int
+}
+
+func (e *ParentNotFoundError) Error() string {
+	return fmt.Sprintf("parent role %d not found", e.ID)
+}
@@ -0,0 +1,63 @@
+package role
+
+// MaxInheritanceDepth is the longest allowed chain of parent roles. Casbin resolves
+// role links up to ten levels deep and the first level is used by the user assignment.
+const MaxInheritanceDepth = 9
+
+// inheritance maps role ids to the ids of their direct parents.
+type inheritance map[int][]int
+
+// check makes sure the parents of a role do not create a cycle and that no chain
+// of parent roles that runs through the role gets longer than MaxInheritanceDepth.
+func (g inheritance) check(id int) error {
+	for _, parent := range g[id] {
+		if parent == id || g.inherits(parent, id, map[int]bool{}) {
+			return ErrInheritanceCycle
+		}
+	}
+	if g.ancestorDepth(id)+g.descendantDepth(id) > MaxInheritanceDepth {
+		return ErrInheritanceDepth
+	}
+	return nil
+}
+
+// inherits returns true if the role inherits from the ancestor.
+func (g inheritance) inherits(id, ancestor int, visited map[int]bool) bool {
+	if visited[id] {
+		return false
+	}
+	visited[id] = true
+	for _, parent := range g[id] {
+		if parent == ancestor || g.inherits(parent, ancestor, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// ancestorDepth returns the length of the longest chain of parents above a role.
+func (g inheritance) ancestorDepth(id int) int {
+	depth := 0
+	for _, parent := range g[id] {
+		if d := g.ancestorDepth(parent) + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// descendantDepth returns the length of the longest chain of roles that inherit from a role.
+func (g inheritance) descendantDepth(id int) int {
+	depth := 0
+	for child, parents := range g {
+		for _, parent := range parents {
+			if parent != id {
+				continue
+			}
+			if d := g.descendantDepth(child) + 1; d > depth {
+				depth = d
+			}
+		}
+	}
+	return depth
+}
@@ -0,0 +1,37 @@
+package role
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInheritanceCheck(t *testing.T) {
+	chain := func(n int) inheritance {
+		g := make(inheritance)
+		for i := 2; i <= n; i++ {
+			g[i] = []int{i - 1}
+		}
+		return g
+	}
+
+	tests := []struct {
+		name  string
+		graph inheritance
+		id    int
+		err   error
+	}{
+		{"NoParents", inheritance{}, 1, nil},
+		{"Diamond", inheritance{2: {1}, 3: {1}, 4: {2, 3}}, 4, nil},
+		{"Self", inheritance{1: {1}}, 1, ErrInheritanceCycle},
+		{"Cycle", inheritance{1: {3}, 2: {1}, 3: {2}}, 1, ErrInheritanceCycle},
+		{"MaxDepth", chain(MaxInheritanceDepth + 1), 5, nil},
+		{"TooDeep", chain(MaxInheritanceDepth + 2), 5, ErrInheritanceDepth},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, tc.graph.check(tc.id))
+		})
+	}
+}
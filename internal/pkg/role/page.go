@@ -0,0 +1,46 @@
+package role
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// sortColumns contains the columns roles can be sorted by.
+var sortColumns = []string{"id", "name", "created_at"}
+
+// Filter limits the roles of a page. Empty fields match all roles.
+type Filter struct {
+	// Name matches roles whose name contains the value.
+	Name         string
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+	// ExcludeIDs removes these roles from the page.
+	ExcludeIDs []int
+}
+
+// where returns the conditions of the filter.
+func (f Filter) where() []sq.Sqlizer {
+	where := []sq.Sqlizer{db.Between("created_at", f.CreatedFrom, f.CreatedUntil)}
+	if f.Name != "" {
+		where = append(where, db.Contains("name", f.Name))
+	}
+	if len(f.ExcludeIDs) > 0 {
+		where = append(where, sq.NotEq{"id": f.ExcludeIDs})
+	}
+	return where
+}
+
+// GetPage returns a page of the roles that match the filter.
+func (s Store) GetPage(ctx context.Context, filter Filter, sorts []db.Sort, args db.PageArgs) ([]*entity.Role, *db.PageInfo, error) {
+	if err := db.CheckSorts(sorts, sortColumns...); err != nil {
+		return nil, nil, err
+	}
+	var roles []*entity.Role
+	info, err := s.db.Paginate(ctx, &roles, "roles", filter.where(), sorts, args)
+	return roles, info, err
+}
@@ -0,0 +1,10 @@
+package role
+
+import (
+	"go-webapp-example/internal/pkg/permission"
+)
+
+// Permissions are the permission codes declared by this module.
+var Permissions = []permission.Definition{
+	{Code: "admin.role", Label: "role.permissions.admin", Levels: permission.AllLevels},
+}
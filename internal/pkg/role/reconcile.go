@@ -0,0 +1,137 @@
+package role
+
+import (
+	"context"
+	"fmt"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/auth"
+	"go-webapp-example/pkg/db"
+
+	"github.com/pkg/errors"
+)
+
+// Drift is a role assignment that is only stored in one of the role_user table and the policies.
+// Assignments of the role_user table are only taken into account while they are valid.
+type Drift struct {
+	auth.Assignment
+	// Missing is true if the assignment is missing in the policies. Otherwise the
+	// policies contain an assignment that does not exist in the role_user table.
+	Missing bool
+	// Expired is true if the policies contain an assignment that expired.
+	Expired bool
+}
+
+// String returns a description of the drift.
+func (d Drift) String() string {
+	switch {
+	case d.Missing:
+		return fmt.Sprintf("user %d has role %d but the policy is missing", d.UserID, d.RoleID)
+	case d.Expired:
+		return fmt.Sprintf("role %d of user %d expired", d.RoleID, d.UserID)
+	default:
+		return fmt.Sprintf("policy assigns role %d to user %d but the user does not have the role", d.RoleID, d.UserID)
+	}
+}
+
+// CheckAssignments returns all role assignments that differ between the role_user table and the policies.
+func (s Store) CheckAssignments(ctx context.Context) ([]*Drift, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	drift, err := s.getDrift(ctx, tx)
+	if err != nil {
+		return nil, db.RollbackError(tx, err)
+	}
+	return drift, errors.WithStack(tx.Commit())
+}
+
+// ReconcileAssignments repairs all role assignments that differ between the role_user table and
+// the policies. The role_user table is the source of truth, the policies are changed to match it.
+// This grants roles whose period started and removes expired roles, which is recorded in the
+// audit log. The repaired drift is returned.
+func (s Store) ReconcileAssignments(ctx context.Context) ([]*Drift, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	drift, err := s.getDrift(ctx, tx)
+	if err != nil {
+		return nil, db.RollbackError(tx, err)
+	}
+	if len(drift) == 0 {
+		return drift, errors.WithStack(tx.Commit())
+	}
+	var missing, stale []auth.Assignment
+	for _, d := range drift {
+		if d.Missing {
+			missing = append(missing, d.Assignment)
+			continue
+		}
+		stale = append(stale, d.Assignment)
+		if d.Expired {
+			if err = s.auditor.LogExpired(ctx, tx, &entity.User{ID: d.UserID}, "roles", d.RoleID); err != nil {
+				return nil, db.RollbackError(tx, errors.WithStack(err))
+			}
+		}
+	}
+	if err = s.auth.RemoveAssignmentsTx(ctx, tx, stale); err != nil {
+		return nil, db.RollbackError(tx, err)
+	}
+	if err = s.auth.AddAssignmentsTx(ctx, tx, missing); err != nil {
+		return nil, db.RollbackError(tx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return drift, s.auth.Reload()
+}
+
+// getDrift compares the valid assignments of the role_user table with the policies.
+func (s Store) getDrift(ctx context.Context, tx *db.Tx) ([]*Drift, error) {
+	var rows []*entity.RoleAssignment
+	err := tx.SelectContext(
+		ctx,
+		&rows,
+		"SELECT user_id, role_id, valid_from, valid_until FROM role_user ORDER BY user_id, role_id",
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	policies, err := s.auth.AssignmentsTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	assigned := make(map[auth.Assignment]bool, len(rows))
+	expired := make(map[auth.Assignment]bool)
+	for _, row := range rows {
+		as := auth.Assignment{UserID: row.UserID, RoleID: row.RoleID}
+		switch {
+		case row.Active(now):
+			assigned[as] = true
+		case row.ValidUntil.Valid && !row.ValidUntil.Time.After(now):
+			expired[as] = true
+		}
+	}
+	stored := make(map[auth.Assignment]bool, len(policies))
+	for _, as := range policies {
+		stored[as] = true
+	}
+	var drift []*Drift
+	for _, row := range rows {
+		as := auth.Assignment{UserID: row.UserID, RoleID: row.RoleID}
+		if assigned[as] && !stored[as] {
+			stored[as] = true
+			drift = append(drift, &Drift{Assignment: as, Missing: true})
+		}
+	}
+	for _, as := range policies {
+		if !assigned[as] {
+			assigned[as] = true
+			drift = append(drift, &Drift{Assignment: as, Expired: expired[as]})
+		}
+	}
+	return drift, nil
+}
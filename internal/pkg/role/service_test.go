@@ -5,39 +5,117 @@ import (
 	"testing"
 	"time"
 
+	"go-webapp-example/internal/pkg/audit"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+	"go-webapp-example/pkg/session"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // now is used as time for all test cases.
 var now = time.Now()
 
-type authManagerMock struct{}
+type authManagerMock struct {
+	permissions []string
+	parents     map[int][]int
+	assignments []auth.Assignment
+	granted     map[string]bool
+}
+
+func (a *authManagerMock) AddRolePermission(id int, code, s string) bool {
+	a.permissions = append(a.permissions, code+"::"+s)
+	return true
+}
+func (a *authManagerMock) DenyRolePermission(id int, code, s string) bool {
+	a.permissions = append(a.permissions, "deny "+code+"::"+s)
+	return true
+}
+func (a *authManagerMock) Can(userID int, subject, action string) bool {
+	return a.granted[subject+"::"+action]
+}
+func (a *authManagerMock) PermissionsForRole(id int) [][]string          { return nil }
+func (a *authManagerMock) InheritedPermissionsForRole(id int) [][]string { return nil }
+func (a *authManagerMock) DeleteRole(id int)                             { a.permissions = nil }
+func (a *authManagerMock) DeleteRolePermissions(id int)                  { a.permissions = nil }
+func (a *authManagerMock) Reload() error                                 { return nil }
+func (a *authManagerMock) SetUsersForRoleTx(ctx context.Context, tx *db.Tx, roleID int, userIDs []int) error {
+	return nil
+}
+func (a *authManagerMock) AssignmentsTx(ctx context.Context, tx *db.Tx) ([]auth.Assignment, error) {
+	return a.assignments, nil
+}
+func (a *authManagerMock) AddAssignmentsTx(ctx context.Context, tx *db.Tx, assignments []auth.Assignment) error {
+	a.assignments = append(a.assignments, assignments...)
+	return nil
+}
+func (a *authManagerMock) RemoveAssignmentsTx(ctx context.Context, tx *db.Tx, assignments []auth.Assignment) error {
+	var kept []auth.Assignment
+	for _, as := range a.assignments {
+		removed := false
+		for _, r := range assignments {
+			removed = removed || as == r
+		}
+		if !removed {
+			kept = append(kept, as)
+		}
+	}
+	a.assignments = kept
+	return nil
+}
+func (a *authManagerMock) AddRoleParent(roleID, parentID int) bool {
+	a.parents[roleID] = append(a.parents[roleID], parentID)
+	return true
+}
+func (a *authManagerMock) RemoveRoleParents(roleID int) bool {
+	delete(a.parents, roleID)
+	return true
+}
+
+// changeRecorder records all published changes.
+type changeRecorder struct {
+	changes []*event.Change
+}
 
-func (a authManagerMock) AddRolePermission(id int, code, s string) bool { return true }
-func (a authManagerMock) AddRoleForUser(userID, roleID int) bool        { return true }
-func (a authManagerMock) RemoveRoleForUser(userID, roleID int) bool     { return true }
-func (a authManagerMock) DeleteRole(id int)                             {}
+func (r *changeRecorder) Publish(c *event.Change) {
+	r.changes = append(r.changes, c)
+}
 
 // TestRoleService tests all service methods as well as the underlying store.
 func TestRoleService(t *testing.T) {
 	db, mock := test.MockDB(t)
 
-	authMock := &authManagerMock{}
-	service := NewService(NewStore(db, authMock, func(store *Store) {
+	authMock := &authManagerMock{parents: make(map[int][]int)}
+	registry, err := permission.NewRegistry(Permissions)
+	if err != nil {
+		t.Fatalf("failed to create permission registry: %s", err)
+	}
+	auditor := audit.NewMockAuditor()
+	events := &changeRecorder{}
+	service := NewService(NewStore(db, authMock, registry, auditor, WithEvents(events), func(store *Store) {
 		store.clock = clock.FromTime(now)
 	}))
 
 	t.Run("Get", get(mock, service))
 	t.Run("GetByID", getByID(mock, service))
 	t.Run("Create", create(mock, service))
-	t.Run("Update", update(mock, service))
+	t.Run("Update", update(mock, service, events))
 	t.Run("Delete", del(mock, service))
 	t.Run("GetByUserID", getByUserID(mock, service))
+	t.Run("SyncPermissions", syncPermissions(service, authMock))
+	t.Run("SyncPermissionsEscalation", syncPermissionsEscalation(service, authMock))
+	t.Run("SyncParents", syncParents(mock, service, authMock))
+	t.Run("SyncParentsCycle", syncParentsCycle(mock, service, authMock))
+	t.Run("SyncParentsNotFound", syncParentsNotFound(mock, service, authMock))
+	t.Run("ReconcileAssignments", reconcileAssignments(mock, service, authMock, auditor))
 }
 
 func get(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
@@ -80,7 +158,7 @@ func create(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
 	return func(t *testing.T) {
 		mock.
 			ExpectExec("INSERT INTO roles").
-			WithArgs("Created", now, now).
+			WithArgs("Created", false, now, now).
 			WillReturnResult(sqlmock.NewResult(3, 1))
 
 		role := &entity.Role{Name: "Created"}
@@ -95,11 +173,17 @@ func create(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
 	}
 }
 
-func update(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
+func update(mock sqlmock.Sqlmock, service *Service, events *changeRecorder) func(t *testing.T) {
 	return func(t *testing.T) {
+		events.changes = nil
+		mock.
+			ExpectQuery("SELECT \\* FROM roles WHERE id = . LIMIT 1").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "require_two_factor", "created_at", "updated_at"}).
+				AddRow(3, "Role", false, now, now))
 		mock.
-			ExpectExec("UPDATE roles SET name = .+, updated_at = .+ WHERE id = .").
-			WithArgs("New Role", now, 3).
+			ExpectExec("UPDATE roles SET name = .+, require_two_factor = .+, updated_at = .+ WHERE id = .").
+			WithArgs("New Role", false, now, 3).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		role := &entity.Role{ID: 3, Name: "New Role"}
@@ -109,6 +193,10 @@ func update(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 		assert.False(t, role.UpdatedAt.IsZero())
+		if assert.Len(t, events.changes, 1) {
+			assert.Equal(t, event.ActionUpdated, events.changes[0].Action)
+			assert.Equal(t, []string{"name"}, events.changes[0].Fields)
+		}
 	}
 }
 
@@ -127,6 +215,10 @@ func del(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
 			ExpectExec("DELETE FROM role_user WHERE role_id IN \\(.*\\)").
 			WithArgs(3, 4).
 			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.
+			ExpectExec("DELETE FROM role_parent WHERE \\(role_id IN \\(.*\\) OR parent_id IN \\(.*\\)\\)").
+			WithArgs(3, 4, 3, 4).
+			WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectCommit()
 		_, err := service.Delete(context.Background(), []int{3, 4})
 
@@ -149,3 +241,178 @@ func getByUserID(mock sqlmock.Sqlmock, service *Service) func(t *testing.T) {
 		assert.Equal(t, 1, roles[4][0].ID)
 	}
 }
+
+func syncPermissions(service *Service, authMock *authManagerMock) func(t *testing.T) {
+	return func(t *testing.T) {
+		r := &entity.Role{ID: 2}
+
+		_, err := service.SyncPermissions(context.Background(), r, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelWrite},
+		})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"admin.role::read", "admin.role::write"}, authMock.permissions)
+
+		_, err = service.SyncPermissions(context.Background(), r, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelManage},
+			{Code: "admin.unknown", Level: entity.PermissionLevelRead},
+		})
+		assert.Equal(t, permission.ErrUnknownCode, errors.Cause(err))
+		assert.ElementsMatch(t, []string{"admin.role::read", "admin.role::write"}, authMock.permissions)
+
+		_, err = service.SyncPermissions(context.Background(), r, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelManage},
+			{Code: "admin.role", Level: entity.PermissionLevelWrite, Deny: true},
+		})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"admin.role::read", "admin.role::write", "admin.role::manage", "deny admin.role::write", "deny admin.role::manage",
+		}, authMock.permissions)
+	}
+}
+
+func syncPermissionsEscalation(service *Service, authMock *authManagerMock) func(t *testing.T) {
+	return func(t *testing.T) {
+		r := &entity.Role{ID: 2}
+		ctx := context.WithValue(context.Background(), session.CtxKey, &entity.User{ID: 5})
+		authMock.granted = map[string]bool{"admin.role::read": true, "admin.role::write": true}
+		defer func() { authMock.granted = nil }()
+
+		_, err := service.SyncPermissions(ctx, r, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelManage},
+			{Code: "admin.role", Level: entity.PermissionLevelRead},
+		})
+		var escalation *permission.EscalationError
+		require.True(t, errors.As(err, &escalation))
+		assert.Equal(t, "admin.role::manage", escalation.CodeLevels())
+
+		_, err = service.SyncPermissions(ctx, r, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelWrite},
+			{Code: "admin.role", Level: entity.PermissionLevelManage, Deny: true},
+		})
+		assert.NoError(t, err)
+
+		ctx = context.WithValue(context.Background(), session.CtxKey, &entity.User{ID: 5, IsSuperuser: true})
+		_, err = service.SyncPermissions(ctx, r, []*entity.Permission{
+			{Code: "admin.role", Level: entity.PermissionLevelManage},
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func syncParents(mock sqlmock.Sqlmock, service *Service, authMock *authManagerMock) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("SELECT role_id, parent_id FROM role_parent").
+			WillReturnRows(sqlmock.NewRows([]string{"role_id", "parent_id"}).AddRow(3, 2).AddRow(4, 3))
+		mock.
+			ExpectQuery("SELECT id FROM roles WHERE id IN \\(\\?\\) FOR UPDATE").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.
+			ExpectExec("DELETE FROM role_parent WHERE role_id = .").
+			WithArgs(4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectExec("INSERT INTO role_parent").
+			WithArgs(2, 4).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		_, err := service.SyncParents(context.Background(), &entity.Role{ID: 4}, []int{2, 2})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, []int{2}, authMock.parents[4])
+	}
+}
+
+func syncParentsCycle(mock sqlmock.Sqlmock, service *Service, authMock *authManagerMock) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("SELECT role_id, parent_id FROM role_parent").
+			WillReturnRows(sqlmock.NewRows([]string{"role_id", "parent_id"}).AddRow(3, 2).AddRow(4, 3))
+		mock.
+			ExpectQuery("SELECT id FROM roles WHERE id IN \\(\\?\\) FOR UPDATE").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectRollback()
+
+		_, err := service.SyncParents(context.Background(), &entity.Role{ID: 2}, []int{4})
+
+		assert.Equal(t, ErrInheritanceCycle, errors.Cause(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Empty(t, authMock.parents[2])
+	}
+}
+
+func syncParentsNotFound(mock sqlmock.Sqlmock, service *Service, authMock *authManagerMock) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery("SELECT role_id, parent_id FROM role_parent FOR UPDATE").
+			WillReturnRows(sqlmock.NewRows([]string{"role_id", "parent_id"}))
+		mock.
+			ExpectQuery("SELECT id FROM roles WHERE id IN \\(\\?,\\?\\) FOR UPDATE").
+			WithArgs(2, 99).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectRollback()
+
+		_, err := service.SyncParents(context.Background(), &entity.Role{ID: 5}, []int{2, 99})
+
+		var notFound *ParentNotFoundError
+		require.True(t, errors.As(err, &notFound))
+		assert.Equal(t, 99, notFound.ID)
+		errs := ValidateParents(err).Get("parents")
+		require.Len(t, errs, 1)
+		assert.Equal(t, "validation.role_parent_not_found", errs[0].Message)
+		assert.Equal(t, "99", errs[0].Data["id"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Empty(t, authMock.parents[5])
+	}
+}
+
+func reconcileAssignments(
+	mock sqlmock.Sqlmock,
+	service *Service,
+	authMock *authManagerMock,
+	auditor *audit.MockAuditor,
+) func(t *testing.T) {
+	return func(t *testing.T) {
+		authMock.assignments = []auth.Assignment{{UserID: 1, RoleID: 1}, {UserID: 3, RoleID: 2}, {UserID: 4, RoleID: 2}}
+		rows := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"user_id", "role_id", "valid_from", "valid_until"}).
+				AddRow(1, 1, nil, nil).
+				AddRow(2, 2, now.Add(-time.Hour), now.Add(time.Hour)).
+				AddRow(4, 2, nil, now.Add(-time.Minute)).
+				AddRow(5, 2, now.Add(time.Minute), nil)
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT user_id, role_id, valid_from, valid_until FROM role_user").WillReturnRows(rows())
+		mock.ExpectCommit()
+		drift, err := service.CheckAssignments(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*Drift{
+			{Assignment: auth.Assignment{UserID: 2, RoleID: 2}, Missing: true},
+			{Assignment: auth.Assignment{UserID: 3, RoleID: 2}},
+			{Assignment: auth.Assignment{UserID: 4, RoleID: 2}, Expired: true},
+		}, drift)
+		assert.Len(t, authMock.assignments, 3)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT user_id, role_id, valid_from, valid_until FROM role_user").WillReturnRows(rows())
+		mock.ExpectCommit()
+		drift, err = service.ReconcileAssignments(context.Background())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, drift, 3)
+		assert.Equal(t, []auth.Assignment{{UserID: 1, RoleID: 1}, {UserID: 2, RoleID: 2}}, authMock.assignments)
+		if assert.Len(t, auditor.Expired, 1) {
+			assert.Equal(t, int64(4), auditor.Expired[0].EntityID.Int64)
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"database/sql"
 
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/pkg/auth"
 	"go-webapp-example/pkg/clock"
 	"go-webapp-example/pkg/db"
 	"go-webapp-example/pkg/util"
@@ -17,28 +20,66 @@ import (
 
 type authManager interface {
 	DeleteRole(roleID int)
+	DeleteRolePermissions(roleID int)
 	AddRolePermission(roleID int, code string, s string) bool
+	DenyRolePermission(roleID int, code string, s string) bool
 
-	AddRoleForUser(userID int, roleID int) bool
-	RemoveRoleForUser(userID int, roleID int) bool
+	AddRoleParent(roleID int, parentID int) bool
+	RemoveRoleParents(roleID int) bool
+
+	Can(userID int, subject, action string) bool
+	PermissionsForRole(id int) [][]string
+	InheritedPermissionsForRole(id int) [][]string
+
+	SetUsersForRoleTx(ctx context.Context, tx *db.Tx, roleID int, userIDs []int) error
+	AssignmentsTx(ctx context.Context, tx *db.Tx) ([]auth.Assignment, error)
+	AddAssignmentsTx(ctx context.Context, tx *db.Tx, assignments []auth.Assignment) error
+	RemoveAssignmentsTx(ctx context.Context, tx *db.Tx, assignments []auth.Assignment) error
+	Reload() error
+}
+
+// expiryAuditor records role assignments that expired.
+type expiryAuditor interface {
+	LogExpired(ctx context.Context, tx *db.Tx, e entity.Entity, relation string, value interface{}) error
+}
+
+// permissionRegistry checks permissions against the codes declared by the modules.
+type permissionRegistry interface {
+	Check(p *entity.Permission) error
 }
 
 // Store handles the direct database access for this entity.
 type Store struct {
-	db    *db.Connection
-	clock *clock.Clock
-	auth  authManager
+	db          *db.Connection
+	clock       *clock.Clock
+	auth        authManager
+	permissions permissionRegistry
+	auditor     expiryAuditor
+	events      event.Publisher
 }
 
 // NewStore returns a new store instance.
-func NewStore(conn *db.Connection, auth authManager, opts ...func(s *Store)) *Store {
-	s := &Store{db: conn, auth: auth}
+func NewStore(
+	conn *db.Connection,
+	auth authManager,
+	permissions permissionRegistry,
+	auditor expiryAuditor,
+	opts ...func(s *Store),
+) *Store {
+	s := &Store{db: conn, auth: auth, permissions: permissions, auditor: auditor}
 	for _, opt := range opts {
 		opt(s)
 	}
 	return s
 }
 
+// WithEvents publishes the changes of committed roles to p.
+func WithEvents(p event.Publisher) func(s *Store) {
+	return func(s *Store) {
+		s.events = p
+	}
+}
+
 // Find finds the entity by id.
 func (s Store) Find(ctx context.Context, id int) (*entity.Role, error) {
 	var role entity.Role
@@ -94,10 +135,11 @@ func (s Store) GetByUserID(ctx context.Context, ids []int) (map[int][]*entity.Ro
 	}
 	for _, role := range roles {
 		ret[role.UserID] = append(ret[role.UserID], &entity.Role{
-			ID:        role.ID,
-			Name:      role.Name,
-			CreatedAt: role.CreatedAt,
-			UpdatedAt: role.UpdatedAt,
+			ID:               role.ID,
+			Name:             role.Name,
+			RequireTwoFactor: role.RequireTwoFactor,
+			CreatedAt:        role.CreatedAt,
+			UpdatedAt:        role.UpdatedAt,
 		})
 	}
 	return ret, errors.WithStack(checkNotFound(err))
@@ -107,7 +149,13 @@ func (s Store) GetByUserID(ctx context.Context, ids []int) (map[int][]*entity.Ro
 func (s Store) Create(ctx context.Context, role *entity.Role) (*entity.Role, error) {
 	role.CreatedAt = null.TimeFrom(s.clock.Now())
 	role.UpdatedAt = null.TimeFrom(s.clock.Now())
-	res, err := s.db.Exec("INSERT INTO roles (name, created_at, updated_at) VALUES (?, ?, ?);", role.Name, role.CreatedAt, role.UpdatedAt)
+	res, err := s.db.Exec(
+		"INSERT INTO roles (name, require_two_factor, created_at, updated_at) VALUES (?, ?, ?, ?);",
+		role.Name,
+		role.RequireTwoFactor,
+		role.CreatedAt,
+		role.UpdatedAt,
+	)
 	if err != nil {
 		return role, errors.WithStack(err)
 	}
@@ -116,6 +164,7 @@ func (s Store) Create(ctx context.Context, role *entity.Role) (*entity.Role, err
 		return role, errors.WithStack(err)
 	}
 	role.ID = int(id)
+	s.publish(event.Created(role))
 	return role, nil
 }
 
@@ -124,9 +173,24 @@ func (s Store) Update(ctx context.Context, role *entity.Role) (*entity.Role, err
 	if role.ID < 1 {
 		return role, errors.WithStack(db.ErrNotExists)
 	}
+	current, err := s.Find(ctx, role.ID)
+	if err != nil {
+		return role, err
+	}
+	role.CreatedAt = current.CreatedAt
 	role.UpdatedAt = null.TimeFrom(s.clock.Now())
-	_, err := s.db.Exec("UPDATE roles SET name = ?, updated_at = ? WHERE id = ?", role.Name, role.UpdatedAt, role.ID)
-	return role, errors.WithStack(err)
+	_, err = s.db.Exec(
+		"UPDATE roles SET name = ?, require_two_factor = ?, updated_at = ? WHERE id = ?",
+		role.Name,
+		role.RequireTwoFactor,
+		role.UpdatedAt,
+		role.ID,
+	)
+	if err != nil {
+		return role, errors.WithStack(err)
+	}
+	s.publish(event.Updated(current, role))
+	return role, nil
 }
 
 // Delete removes multiple entities from the database.
@@ -164,35 +228,234 @@ func (s Store) Delete(ctx context.Context, ids []int) ([]*entity.Role, error) {
 	if err != nil {
 		return nil, db.RollbackError(tx, errors.WithStack(err))
 	}
+	query, params, err = sq.Delete("role_parent").
+		Where(sq.Or{sq.Eq{"role_id": util.UniqueInts(ids)}, sq.Eq{"parent_id": util.UniqueInts(ids)}}).
+		ToSql()
+	if err != nil {
+		return nil, db.RollbackError(tx, errors.WithStack(err))
+	}
+	_, err = tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return nil, db.RollbackError(tx, errors.WithStack(err))
+	}
+	for _, id := range ids {
+		if err = s.auth.SetUsersForRoleTx(ctx, tx, id, nil); err != nil {
+			return nil, db.RollbackError(tx, err)
+		}
+	}
 	for _, r := range returned {
 		roles = append(roles, r)
 	}
+	if err = tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	for _, id := range ids {
 		s.auth.DeleteRole(id)
 	}
-	return roles, tx.Commit()
+	for _, r := range roles {
+		s.publish(event.Deleted(r))
+	}
+	return roles, nil
 }
 
-// SyncUsers sets the provided user IDs for a role.
-// nolint:govet
+// SyncUsers sets the provided user IDs for a role. Users that keep the role keep its period of validity.
 func (s Store) SyncUsers(ctx context.Context, source *entity.Role, userIDs []int) (*entity.Role, error) {
+	current, err := s.GetAssignmentsByRoleID(ctx, []int{source.ID})
+	if err != nil {
+		return source, err
+	}
+	periods := make(map[int]*entity.RoleAssignment)
+	for _, a := range current[source.ID] {
+		periods[a.UserID] = a
+	}
+	assignments := make([]*entity.RoleAssignment, len(userIDs))
+	for i, userID := range userIDs {
+		if a, ok := periods[userID]; ok {
+			assignments[i] = a
+			continue
+		}
+		assignments[i] = &entity.RoleAssignment{UserID: userID, RoleID: source.ID}
+	}
+	return s.SyncUserAssignments(ctx, source, assignments)
+}
+
+// SyncUserAssignments sets the users of a role together with the period of time the role is granted for.
+// Only assignments that are valid at the moment are granted, the roles daemon grants and removes
+// the others once their period starts or ends. New users can only be added and existing assignments
+// only be extended if the authenticated user is granted all permissions of the role, otherwise a
+// permission.EscalationError is returned.
+// nolint:govet
+func (s Store) SyncUserAssignments(ctx context.Context, source *entity.Role, assignments []*entity.RoleAssignment) (*entity.Role, error) {
+	current, err := s.GetAssignmentsByRoleID(ctx, []int{source.ID})
+	if err != nil {
+		return source, err
+	}
+	assigned := make(map[int]*entity.RoleAssignment, len(current[source.ID]))
+	for _, a := range current[source.ID] {
+		assigned[a.UserID] = a
+	}
+	now := s.clock.Now()
+	for _, a := range assignments {
+		if !a.Extends(assigned[a.UserID], now) {
+			continue
+		}
+		if err = permission.CheckRoleGrant(ctx, s.auth, []int{source.ID}); err != nil {
+			return source, err
+		}
+		break
+	}
 	tx, err := s.db.Begin()
 	if err != nil {
 		return source, errors.WithStack(err)
 	}
-	current, err := s.getCurrentUsers(ctx, tx, source)
+	_, err = tx.ExecContext(ctx, "DELETE FROM role_user WHERE role_id = ?", source.ID)
 	if err != nil {
 		return source, db.RollbackError(tx, errors.WithStack(err))
 	}
-	for _, userID := range current {
-		s.auth.RemoveRoleForUser(userID, source.ID)
+	var active []int
+	for _, a := range assignments {
+		query, params, err := sq.Insert("role_user").SetMap(db.ColumnMap{
+			"user_id":     a.UserID,
+			"role_id":     source.ID,
+			"valid_from":  a.ValidFrom,
+			"valid_until": a.ValidUntil,
+		}).ToSql()
+		if err != nil {
+			return source, db.RollbackError(tx, errors.WithStack(err))
+		}
+		_, err = tx.ExecContext(ctx, query, params...)
+		if err != nil {
+			return source, db.RollbackError(tx, errors.WithStack(err))
+		}
+		if a.Active(s.clock.Now()) {
+			active = append(active, a.UserID)
+		}
 	}
-	_, err = tx.ExecContext(ctx, "DELETE FROM role_user WHERE role_id = ?", source.ID)
+	err = s.auth.SetUsersForRoleTx(ctx, tx, source.ID, active)
+	if err != nil {
+		return source, db.RollbackError(tx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return source, errors.WithStack(err)
+	}
+	if err = s.auth.Reload(); err != nil {
+		return source, err
+	}
+	s.publish(event.Updated(source, source, "users", "user_assignments"))
+	return source, nil
+}
+
+// GetAssignmentsByUserID returns a map of user ids to their role assignments.
+func (s Store) GetAssignmentsByUserID(ctx context.Context, ids []int) (map[int][]*entity.RoleAssignment, error) {
+	assignments, err := s.getAssignments(ctx, sq.Eq{"user_id": ids})
+	ret := make(map[int][]*entity.RoleAssignment)
+	for _, a := range assignments {
+		ret[a.UserID] = append(ret[a.UserID], a)
+	}
+	return ret, err
+}
+
+// GetAssignmentsByRoleID returns a map of role ids to the assignments of their users.
+func (s Store) GetAssignmentsByRoleID(ctx context.Context, ids []int) (map[int][]*entity.RoleAssignment, error) {
+	assignments, err := s.getAssignments(ctx, sq.Eq{"role_id": ids})
+	ret := make(map[int][]*entity.RoleAssignment)
+	for _, a := range assignments {
+		ret[a.RoleID] = append(ret[a.RoleID], a)
+	}
+	return ret, err
+}
+
+// getAssignments returns all role assignments that match the condition.
+func (s Store) getAssignments(ctx context.Context, where sq.Sqlizer) ([]*entity.RoleAssignment, error) {
+	var assignments []*entity.RoleAssignment
+	query, params, err := sq.
+		Select("user_id, role_id, valid_from, valid_until").
+		From("role_user").
+		Where(where).
+		OrderBy("user_id", "role_id").
+		ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	err = s.db.SelectContext(ctx, &assignments, query, params...)
+	return assignments, errors.WithStack(err)
+}
+
+// GetParentsByRoleID returns a map of role ids to a slice of their direct parent roles.
+func (s Store) GetParentsByRoleID(ctx context.Context, ids []int) (map[int][]*entity.Role, error) {
+	type result struct {
+		entity.Role
+		ChildID int `json:"child_id"`
+	}
+	var roles []*result
+	ret := make(map[int][]*entity.Role)
+	query, params, err := sq.
+		Select("roles.*, role_parent.role_id as child_id").
+		From("role_parent").
+		Join("roles ON role_parent.parent_id = roles.id").
+		Where(sq.Eq{"role_parent.role_id": ids}).
+		OrderBy("roles.id").
+		ToSql()
+	if err != nil {
+		return ret, errors.WithStack(err)
+	}
+	err = s.db.SelectContext(ctx, &roles, query, params...)
+	if err != nil {
+		return ret, errors.WithStack(err)
+	}
+	for _, role := range roles {
+		ret[role.ChildID] = append(ret[role.ChildID], &entity.Role{
+			ID:               role.ID,
+			Name:             role.Name,
+			RequireTwoFactor: role.RequireTwoFactor,
+			CreatedAt:        role.CreatedAt,
+			UpdatedAt:        role.UpdatedAt,
+		})
+	}
+	return ret, nil
+}
+
+// SyncParents sets the roles a role inherits its permissions from. It returns ErrInheritanceCycle,
+// ErrInheritanceDepth, a ParentNotFoundError or a permission.EscalationError and leaves the parents
+// untouched if the new parents are not allowed. The inheritance graph stays locked until the new
+// parents are saved, so concurrent changes can not form a cycle together.
+// nolint:govet
+func (s Store) SyncParents(ctx context.Context, source *entity.Role, parentIDs []int) (*entity.Role, error) {
+	parentIDs = util.UniqueInts(parentIDs)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return source, errors.WithStack(err)
+	}
+	graph, err := s.getInheritance(ctx, tx)
+	if err != nil {
+		return source, db.RollbackError(tx, err)
+	}
+	if err = s.lockParents(ctx, tx, parentIDs); err != nil {
+		return source, db.RollbackError(tx, err)
+	}
+	current := make(map[int]bool, len(graph[source.ID]))
+	for _, parentID := range graph[source.ID] {
+		current[parentID] = true
+	}
+	var added []int
+	for _, parentID := range parentIDs {
+		if !current[parentID] {
+			added = append(added, parentID)
+		}
+	}
+	if err := permission.CheckRoleGrant(ctx, s.auth, added); err != nil {
+		return source, db.RollbackError(tx, err)
+	}
+	graph[source.ID] = parentIDs
+	if err := graph.check(source.ID); err != nil {
+		return source, db.RollbackError(tx, err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM role_parent WHERE role_id = ?", source.ID)
 	if err != nil {
 		return source, db.RollbackError(tx, errors.WithStack(err))
 	}
-	for _, userID := range userIDs {
-		query, params, err := sq.Insert("role_user").SetMap(db.ColumnMap{"user_id": userID, "role_id": source.ID}).ToSql()
+	for _, parentID := range parentIDs {
+		query, params, err := sq.Insert("role_parent").SetMap(db.ColumnMap{"role_id": source.ID, "parent_id": parentID}).ToSql()
 		if err != nil {
 			return source, db.RollbackError(tx, errors.WithStack(err))
 		}
@@ -200,68 +463,121 @@ func (s Store) SyncUsers(ctx context.Context, source *entity.Role, userIDs []int
 		if err != nil {
 			return source, db.RollbackError(tx, errors.WithStack(err))
 		}
-		s.auth.AddRoleForUser(userID, source.ID)
 	}
-	return source, errors.WithStack(tx.Commit())
+	if err := tx.Commit(); err != nil {
+		return source, errors.WithStack(err)
+	}
+	s.auth.RemoveRoleParents(source.ID)
+	for _, parentID := range parentIDs {
+		s.auth.AddRoleParent(source.ID, parentID)
+	}
+	s.publish(event.Updated(source, source, "parents", "inherited_permissions"))
+	return source, nil
 }
 
-// getCurrentUsers returns all currently attached call type ids.
-func (s Store) getCurrentUsers(ctx context.Context, tx *db.Tx, source *entity.Role) ([]int, error) {
+// getInheritance returns the parent ids of all roles and locks them until the transaction ends.
+func (s Store) getInheritance(ctx context.Context, tx *db.Tx) (inheritance, error) {
 	type result struct {
-		UserID int `json:"user_id"`
+		RoleID   int `json:"role_id"`
+		ParentID int `json:"parent_id"`
 	}
 	var r []*result
-	err := s.db.SelectContext(ctx, &r, "SELECT user_id FROM role_user WHERE role_id = ?", source.ID)
+	err := tx.SelectContext(ctx, &r, "SELECT role_id, parent_id FROM role_parent FOR UPDATE")
 	if err != nil {
-		return nil, db.RollbackError(tx, errors.WithStack(err))
+		return nil, errors.WithStack(err)
 	}
-	var current []int
+	graph := make(inheritance)
 	for _, res := range r {
-		current = append(current, res.UserID)
+		graph[res.RoleID] = append(graph[res.RoleID], res.ParentID)
+	}
+	return graph, nil
+}
+
+// lockParents locks the parent roles until the transaction ends, so they can not be deleted in between.
+// It returns a ParentNotFoundError for the first parent that does not exist.
+func (s Store) lockParents(ctx context.Context, tx *db.Tx, parentIDs []int) error {
+	if len(parentIDs) == 0 {
+		return nil
+	}
+	query, params, err := sq.Select("id").From("roles").Where(sq.Eq{"id": parentIDs}).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var ids []int
+	if err = tx.SelectContext(ctx, &ids, query, params...); err != nil {
+		return errors.WithStack(err)
+	}
+	found := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		found[id] = true
+	}
+	for _, id := range parentIDs {
+		if !found[id] {
+			return errors.WithStack(&ParentNotFoundError{ID: id})
+		}
 	}
-	return current, nil
+	return nil
 }
 
-// permissionsMap contains all permissions with a map of their active levels.
-type permissionsMap map[string]map[entity.PermissionLevel]bool
+// permissionsMap contains all permissions with a map of their active actions.
+type permissionsMap map[string]map[string]bool
 
 // SyncPermissions sets the permissions for a role. It makes sure that all lower levels are also present for easier assertions.
+// Denied permissions include all higher levels. The permissions of the role stay untouched if a permission was not
+// declared by any module or if the authenticated user adds a permission they are not granted themselves.
 func (s Store) SyncPermissions(ctx context.Context, u *entity.Role, permissions []*entity.Permission) (*entity.Role, error) {
-	s.auth.DeleteRole(u.ID)
-	perms := make(permissionsMap)
-	// Make sure all lower permissions are included as well.
-	for _, permission := range permissions {
-		if permission.Level == entity.PermissionLevelNone {
-			continue
+	for _, p := range permissions {
+		if err := s.permissions.Check(p); err != nil {
+			return u, err
 		}
-		if _, ok := perms[permission.Code]; !ok {
-			perms[permission.Code] = make(map[entity.PermissionLevel]bool)
+	}
+	if err := permission.CheckGrant(ctx, s.auth, permission.Added(s.auth.PermissionsForRole(u.ID), permissions)); err != nil {
+		return u, err
+	}
+	s.auth.DeleteRolePermissions(u.ID)
+	allowed, denied := make(permissionsMap), make(permissionsMap)
+	// Make sure all lower permissions are included as well.
+	for _, p := range permissions {
+		if p.Deny {
+			denied = ensureLevelPermissions(denied, p)
+		} else {
+			allowed = ensureLevelPermissions(allowed, p)
 		}
-		perms = ensureLevelPermissions(perms, permission)
 	}
 	// Add the permissions for the role.
-	for code, levels := range perms {
-		for level := range levels {
-			s.auth.AddRolePermission(u.ID, code, string(level))
+	for code, actions := range allowed {
+		for action := range actions {
+			s.auth.AddRolePermission(u.ID, code, action)
 		}
 	}
+	for code, actions := range denied {
+		for action := range actions {
+			s.auth.DenyRolePermission(u.ID, code, action)
+		}
+	}
+	s.publish(event.Updated(u, u, "permissions", "conflicts"))
 	return u, nil
 }
 
 // ensureLevelPermissions make sure that all lower levels for a permission are included as well.
+// Lower levels of a permission that is limited to own records are limited as well.
 func ensureLevelPermissions(perms permissionsMap, permission *entity.Permission) permissionsMap {
-	levels := []entity.PermissionLevel{entity.PermissionLevelRead, entity.PermissionLevelWrite, entity.PermissionLevelManage}
-	if permission.Level == entity.PermissionLevelWrite {
-		levels = levels[:2]
-	} else if permission.Level == entity.PermissionLevelRead {
-		levels = levels[:1]
-	}
-	for _, level := range levels {
-		perms[permission.Code][level] = true
+	for _, action := range permission.Actions() {
+		if _, ok := perms[permission.Code]; !ok {
+			perms[permission.Code] = make(map[string]bool)
+		}
+		perms[permission.Code][action] = true
 	}
 	return perms
 }
 
+// publish sends a change to the subscribers if the store publishes events.
+func (s Store) publish(c *event.Change) {
+	if s.events != nil {
+		s.events.Publish(c)
+	}
+}
+
 // checkNotFound returns a ErrNotFound if no rows were returned.
 func checkNotFound(err error) error {
 	if err == sql.ErrNoRows {
@@ -1,22 +1,90 @@
 package role
 
 import (
+	"strconv"
+
 	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/pkg/validation"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
 )
 
 // ValidateCreateRequest validates a create request of this entity.
-func ValidateCreateRequest(input *gqlmodels.RoleInput) *validation.ErrorBag {
+func ValidateCreateRequest(input *gqlmodels.RoleInput, permissions permissionRegistry) *validation.ErrorBag {
 	errs := validation.NewErrorBag("role")
 
 	if input.Name == "" {
 		errs.Add("name", "required")
 	}
+	permission.ValidateInput(errs, "permissions", input.Permissions, permissions)
+	ValidateAssignments(errs, "users", input.Users)
 
 	return errs
 }
 
 // ValidateUpdateRequest validates a create request of this entity.
-func ValidateUpdateRequest(input *gqlmodels.RoleInput) *validation.ErrorBag {
-	return ValidateCreateRequest(input)
+func ValidateUpdateRequest(input *gqlmodels.RoleInput, permissions permissionRegistry) *validation.ErrorBag {
+	return ValidateCreateRequest(input, permissions)
+}
+
+// ValidateParents turns the errors returned by SyncParents into validation errors.
+// The returned error bag is empty for all other errors.
+func ValidateParents(err error) *validation.ErrorBag {
+	errs := validation.NewErrorBag("role")
+
+	var notFound *ParentNotFoundError
+	if errors.As(err, &notFound) {
+		errs.AddData("parents", "role_parent_not_found", map[string]string{"id": strconv.Itoa(notFound.ID)})
+		return errs
+	}
+	switch errors.Cause(err) {
+	case ErrInheritanceCycle:
+		errs.Add("parents", "role_cycle")
+	case ErrInheritanceDepth:
+		errs.AddData("parents", "role_depth", map[string]string{"depth": strconv.Itoa(MaxInheritanceDepth)})
+	default:
+		return permission.ValidateGrant("role", "parents", err)
+	}
+
+	return errs
+}
+
+// RolesFromInput turns the role inputs of a user into role assignments.
+func RolesFromInput(userID int, input []*gqlmodels.AssignmentInput) []*entity.RoleAssignment {
+	assignments := make([]*entity.RoleAssignment, 0, len(input))
+	for _, a := range input {
+		assignments = append(assignments, &entity.RoleAssignment{
+			UserID:     userID,
+			RoleID:     a.ID,
+			ValidFrom:  null.TimeFromPtr(a.ValidFrom),
+			ValidUntil: null.TimeFromPtr(a.ValidUntil),
+		})
+	}
+	return assignments
+}
+
+// UsersFromInput turns the user inputs of a role into role assignments.
+func UsersFromInput(roleID int, input []*gqlmodels.AssignmentInput) []*entity.RoleAssignment {
+	assignments := make([]*entity.RoleAssignment, 0, len(input))
+	for _, a := range input {
+		assignments = append(assignments, &entity.RoleAssignment{
+			UserID:     a.ID,
+			RoleID:     roleID,
+			ValidFrom:  null.TimeFromPtr(a.ValidFrom),
+			ValidUntil: null.TimeFromPtr(a.ValidUntil),
+		})
+	}
+	return assignments
+}
+
+// ValidateAssignments adds an error to the field for every assignment that ends before it starts.
+func ValidateAssignments(errs *validation.ErrorBag, field string, input []*gqlmodels.AssignmentInput) {
+	for _, a := range input {
+		if a.ValidFrom != nil && a.ValidUntil != nil && !a.ValidUntil.After(*a.ValidFrom) {
+			errs.AddData(field, "assignment_period", map[string]string{"id": strconv.Itoa(a.ID)})
+		}
+	}
 }
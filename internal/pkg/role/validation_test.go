@@ -4,28 +4,64 @@ import (
 	"testing"
 
 	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/permission"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var testRegistry, _ = permission.NewRegistry(Permissions)
+
 func TestValidateCreateRequest(t *testing.T) {
 	t.Run("Invalid", func(t *testing.T) {
 		input := gqlmodels.RoleInput{
 			Name: "",
 		}
 
-		err := ValidateCreateRequest(&input)
+		err := ValidateCreateRequest(&input, testRegistry)
 
 		assert.True(t, err.Failed())
 		assert.Len(t, err.Get("name"), 1)
 	})
 
-	t.Run("Valid", func(t *testing.T) {
+	t.Run("UnknownPermission", func(t *testing.T) {
 		input := gqlmodels.RoleInput{
 			Name: "user",
+			Permissions: []*gqlmodels.PermissionInput{
+				{Code: "admin.role", Level: "read"},
+				{Code: "admin.unknown", Level: "read"},
+				{Code: "admin.role", Level: "delete"},
+			},
+		}
+
+		err := ValidateCreateRequest(&input, testRegistry)
+
+		if assert.Len(t, err.Get("permissions"), 2) {
+			assert.Equal(t, "validation.permission_unknown", err.Get("permissions")[0].Message)
+			assert.Equal(t, "validation.permission_level", err.Get("permissions")[1].Message)
+		}
+	})
+
+	t.Run("NotOwnable", func(t *testing.T) {
+		own := true
+		input := gqlmodels.RoleInput{
+			Name:        "user",
+			Permissions: []*gqlmodels.PermissionInput{{Code: "admin.role", Level: "write", Own: &own}},
+		}
+
+		err := ValidateCreateRequest(&input, testRegistry)
+
+		if assert.Len(t, err.Get("permissions"), 1) {
+			assert.Equal(t, "validation.permission_own", err.Get("permissions")[0].Message)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		input := gqlmodels.RoleInput{
+			Name:        "user",
+			Permissions: []*gqlmodels.PermissionInput{{Code: "admin.role", Level: "manage"}},
 		}
 
-		err := ValidateCreateRequest(&input)
+		err := ValidateCreateRequest(&input, testRegistry)
 
 		assert.False(t, err.Failed())
 		assert.Len(t, err.Errors(), 0)
@@ -40,7 +76,7 @@ func TestValidateUpdateRequest(t *testing.T) {
 			Name: "",
 		}
 
-		err := ValidateUpdateRequest(&input)
+		err := ValidateUpdateRequest(&input, testRegistry)
 
 		assert.True(t, err.Failed())
 		assert.Len(t, err.Get("name"), 1)
@@ -52,7 +88,7 @@ func TestValidateUpdateRequest(t *testing.T) {
 			Name: "user",
 		}
 
-		err := ValidateUpdateRequest(&input)
+		err := ValidateUpdateRequest(&input, testRegistry)
 
 		assert.False(t, err.Failed())
 		assert.Len(t, err.Errors(), 0)
@@ -2,19 +2,39 @@ package pkg
 
 import (
 	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/file"
+	"go-webapp-example/internal/pkg/identity"
+	"go-webapp-example/internal/pkg/outbox"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/passwordreset"
 	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/internal/pkg/quote"
 	"go-webapp-example/internal/pkg/role"
+	"go-webapp-example/internal/pkg/throttle"
+	"go-webapp-example/internal/pkg/token"
+	"go-webapp-example/internal/pkg/twofactor"
 	"go-webapp-example/internal/pkg/user"
+	"go-webapp-example/internal/pkg/usersession"
 	"go-webapp-example/pkg/db"
 )
 
 // Services holds all the required services for the graphql server.
 type Services struct {
-	User       *user.Service
-	Role       *role.Service
-	Permission *permission.Service
-	Audit      *audit.Service
-	Quote      *quote.Service
-	DB         *db.Connection
+	User           *user.Service
+	Role           *role.Service
+	Permission     *permission.Service
+	Audit          *audit.Service
+	Quote          *quote.Service
+	Token          *token.Service
+	Throttle       *throttle.Service
+	TwoFactor      *twofactor.Service
+	Outbox         *outbox.Service
+	PasswordReset  *passwordreset.Service
+	PasswordPolicy *passwordpolicy.Service
+	Session        *usersession.Service
+	Identity       *identity.Service
+	File           *file.Service
+	Events         *event.Broker
+	DB             *db.Connection
 }
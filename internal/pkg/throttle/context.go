@@ -0,0 +1,43 @@
+package throttle
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// ctxKey is used to store the client IP of the current request in a context.
+var ctxKey = &contextKey{"client_ip"}
+
+type contextKey struct {
+	name string
+}
+
+// ClientIP returns the IP of the client without the port. The RealIP middleware already
+// replaced the remote address with the forwarded IP if the request was proxied.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Middleware adds the client IP to the context of a request, so requests can be
+// limited per IP outside of HTTP handlers.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), ClientIP(r))))
+	})
+}
+
+// WithClientIP returns a copy of ctx that carries the client IP.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKey, ip)
+}
+
+// ClientIPFromContext returns the client IP of the current request, it is empty if unknown.
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxKey).(string)
+	return ip
+}
@@ -0,0 +1,14 @@
+package throttle
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when no failed logins were recorded for a subject.
+	ErrNotFound = errors.New("login attempt not found")
+	// ErrThrottled is returned if a login is attempted before the delay of the last failure passed.
+	ErrThrottled = errors.New("too many failed logins, try again later")
+	// ErrLocked is returned if a username or client IP is temporarily locked.
+	ErrLocked = errors.New("account is temporarily locked")
+)
@@ -0,0 +1,227 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/db"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Config defines the limits of the login throttling.
+type Config struct {
+	// MaxFailures is the number of failed logins until a username is locked. 0 disables the lockout.
+	MaxFailures int
+	// MaxFailuresIP is the number of failed logins until a client IP is locked. 0 disables the lockout.
+	MaxFailuresIP int
+	// Delay is the wait time after the first failed login. It doubles with every further failure.
+	Delay time.Duration
+	// MaxDelay caps the exponential delay.
+	MaxDelay time.Duration
+	// Lockout is the duration of a lockout. Failures older than this are forgotten.
+	Lockout time.Duration
+}
+
+// auditLogger persists audit log entries.
+type auditLogger interface {
+	Create(ctx context.Context, tx *db.Tx, l *entity.AuditLog) (*entity.AuditLog, error)
+}
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+	auditor auditLogger
+	config  Config
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store, auditor auditLogger, config Config) *Service {
+	return &Service{
+		Store:   store,
+		auditor: auditor,
+		config:  config,
+	}
+}
+
+// Check returns ErrLocked or ErrThrottled if a login for username from ip is not allowed
+// right now. The returned duration is the time until the next attempt is allowed.
+func (s Service) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	var wait time.Duration
+	var result error
+	for _, sub := range subjects(username, ip) {
+		attempt, err := s.Store.Find(ctx, sub.scope, sub.value)
+		if errors.Cause(err) == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		d, err := s.wait(attempt)
+		if err == nil {
+			continue
+		}
+		if d > wait {
+			wait = d
+		}
+		// A lockout takes precedence over a delay.
+		if result != ErrLocked {
+			result = err
+		}
+	}
+	return wait, result
+}
+
+// Fail records a failed login for username from ip. A username or ip is locked if
+// it reaches its maximum number of failures.
+func (s Service) Fail(ctx context.Context, username, ip string) error {
+	meta := fmt.Sprintf("username=%s ip=%s", username, ip)
+
+	if err := s.log(ctx, audit.ActionLoginFailed, null.Int{}, meta); err != nil {
+		return err
+	}
+
+	for _, sub := range subjects(username, ip) {
+		max := s.config.MaxFailures
+		if sub.scope == entity.LoginScopeIP {
+			max = s.config.MaxFailuresIP
+		}
+		attempt, err := s.Store.Increment(ctx, sub.scope, sub.value, max, s.config.Lockout)
+		if err != nil {
+			return err
+		}
+
+		if max > 0 && attempt.Failures >= max {
+			meta := fmt.Sprintf("%s=%s until=%s", sub.scope, sub.value, attempt.LockedUntil.Time.Format(time.RFC3339))
+			if err := s.log(ctx, audit.ActionLockedOut, null.Int{}, meta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Limit counts a request for subject and ip in the given scopes. It returns ErrLocked if either
+// exceeded its maximum of the config within the lockout duration, MaxFailures applies to the subject
+// and MaxFailuresIP to the ip. Unlike failed logins the requests are not delayed or audited.
+func (s Service) Limit(ctx context.Context, scope, subject, ipScope, ip string) error {
+	limits := []struct {
+		scope, value string
+		max          int
+	}{
+		{scope, subject, s.config.MaxFailures},
+		{ipScope, ip, s.config.MaxFailuresIP},
+	}
+	var result error
+	for _, l := range limits {
+		if l.value == "" || l.max <= 0 {
+			continue
+		}
+		attempt, err := s.Store.Increment(ctx, l.scope, l.value, l.max, s.config.Lockout)
+		if err != nil {
+			return err
+		}
+		if attempt.Failures > l.max {
+			result = ErrLocked
+		}
+	}
+	return result
+}
+
+// Succeed resets the failed logins of username after a successful login. The failures
+// of the client IP are kept, a single valid account must not reset them.
+func (s Service) Succeed(ctx context.Context, username string) error {
+	return s.Store.Delete(ctx, entity.LoginScopeUsername, username)
+}
+
+// Unlock removes the lockout and all failed logins of a user.
+func (s Service) Unlock(ctx context.Context, u *entity.User) error {
+	if err := s.Store.Delete(ctx, entity.LoginScopeUsername, u.Name); err != nil {
+		return err
+	}
+	return s.log(ctx, audit.ActionUnlocked, null.IntFrom(int64(u.ID)), fmt.Sprintf("username=%s", u.Name))
+}
+
+// wait returns the remaining wait time of a subject.
+func (s Service) wait(attempt *entity.LoginAttempt) (time.Duration, error) {
+	if s.expired(attempt) {
+		return 0, nil
+	}
+	now := s.clock.Now()
+	if attempt.LockedUntil.Valid {
+		return attempt.LockedUntil.Time.Sub(now), ErrLocked
+	}
+	if !attempt.LastFailureAt.Valid {
+		return 0, nil
+	}
+	if d := s.delay(attempt.Failures) - now.Sub(attempt.LastFailureAt.Time); d > 0 {
+		return d, ErrThrottled
+	}
+	return 0, nil
+}
+
+// expired returns true if a lockout is over or the last failure is older than the lockout duration.
+func (s Service) expired(attempt *entity.LoginAttempt) bool {
+	now := s.clock.Now()
+	if attempt.LockedUntil.Valid {
+		return !now.Before(attempt.LockedUntil.Time)
+	}
+	return s.config.Lockout > 0 && attempt.LastFailureAt.Valid && now.Sub(attempt.LastFailureAt.Time) > s.config.Lockout
+}
+
+// delay returns the exponential delay after n failed logins.
+func (s Service) delay(n int) time.Duration {
+	if s.config.Delay <= 0 || n < 1 {
+		return 0
+	}
+	d := s.config.Delay
+	for i := 1; i < n; i++ {
+		// Without a maximum delay it stops growing before it would overflow.
+		if d > math.MaxInt64/2 {
+			break
+		}
+		d *= 2
+		if s.config.MaxDelay > 0 && d >= s.config.MaxDelay {
+			return s.config.MaxDelay
+		}
+	}
+	if s.config.MaxDelay > 0 && d > s.config.MaxDelay {
+		return s.config.MaxDelay
+	}
+	return d
+}
+
+// log creates an audit log entry for a user account.
+func (s Service) log(ctx context.Context, action string, userID null.Int, meta string) error {
+	_, err := s.auditor.Create(ctx, nil, &entity.AuditLog{
+		EntityType: entity.KindUser,
+		EntityID:   userID,
+		Action:     action,
+		Meta:       meta,
+	})
+	return errors.Wrap(err, "failed to create audit log entry")
+}
+
+// subject is a username or client IP the failed logins are counted for.
+type subject struct {
+	scope string
+	value string
+}
+
+// subjects returns the subjects the failed logins are counted for.
+func subjects(username, ip string) []subject {
+	var out []subject
+	if username != "" {
+		out = append(out, subject{entity.LoginScopeUsername, username})
+	}
+	if ip != "" {
+		out = append(out, subject{entity.LoginScopeIP, ip})
+	}
+	return out
+}
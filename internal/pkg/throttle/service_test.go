@@ -0,0 +1,232 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+var cols = []string{"id", "scope", "subject", "failures", "last_failure_at", "locked_until"}
+
+var config = Config{
+	MaxFailures:   3,
+	MaxFailuresIP: 10,
+	Delay:         time.Second,
+	MaxDelay:      10 * time.Second,
+	Lockout:       time.Hour,
+}
+
+// auditRecorder records all created audit log entries.
+type auditRecorder struct {
+	logs []*entity.AuditLog
+}
+
+func (a *auditRecorder) Create(ctx context.Context, tx *db.Tx, l *entity.AuditLog) (*entity.AuditLog, error) {
+	a.logs = append(a.logs, l)
+	return l, nil
+}
+
+type setupFn func() (sqlmock.Sqlmock, *Service, *auditRecorder)
+
+// TestThrottleService tests all service methods as well as the underlying store.
+func TestThrottleService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service, *auditRecorder) {
+		conn, mockDB := test.MockDB(t)
+		auditor := &auditRecorder{}
+		service := NewService(NewStore(conn, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}), auditor, config)
+		return mockDB, service, auditor
+	}
+
+	t.Run("CheckUnknown", checkUnknown(setup))
+	t.Run("CheckThrottled", checkThrottled(setup))
+	t.Run("CheckLocked", checkLocked(setup))
+	t.Run("CheckLockExpired", checkLockExpired(setup))
+	t.Run("Fail", fail(setup))
+	t.Run("FailLockout", failLockout(setup))
+	t.Run("Limit", limit(setup))
+	t.Run("Unlock", unlock(setup))
+}
+
+func expectFind(mock sqlmock.Sqlmock, scope, subject string, rows *sqlmock.Rows) {
+	mock.
+		ExpectQuery("SELECT .+ FROM login_attempts WHERE scope = . AND subject = . LIMIT 1").
+		WithArgs(scope, subject).
+		WillReturnRows(rows)
+}
+
+func checkUnknown(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, entity.LoginScopeUsername, "admin", sqlmock.NewRows(cols))
+		expectFind(mock, entity.LoginScopeIP, "127.0.0.1", sqlmock.NewRows(cols))
+
+		wait, err := service.Check(context.Background(), "admin", "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, time.Duration(0), wait)
+	}
+}
+
+func checkThrottled(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		// The third failure results in a delay of four seconds, one second passed already.
+		expectFind(mock, entity.LoginScopeUsername, "admin", sqlmock.NewRows(cols).
+			AddRow(1, entity.LoginScopeUsername, "admin", 3, now.Add(-time.Second), nil))
+		expectFind(mock, entity.LoginScopeIP, "127.0.0.1", sqlmock.NewRows(cols).
+			AddRow(2, entity.LoginScopeIP, "127.0.0.1", 1, now.Add(-time.Second), nil))
+
+		wait, err := service.Check(context.Background(), "admin", "127.0.0.1")
+
+		assert.Equal(t, ErrThrottled, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 3*time.Second, wait)
+	}
+}
+
+func checkLocked(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, entity.LoginScopeUsername, "admin", sqlmock.NewRows(cols))
+		expectFind(mock, entity.LoginScopeIP, "127.0.0.1", sqlmock.NewRows(cols).
+			AddRow(2, entity.LoginScopeIP, "127.0.0.1", 10, now, now.Add(time.Minute)))
+
+		wait, err := service.Check(context.Background(), "admin", "127.0.0.1")
+
+		assert.Equal(t, ErrLocked, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, time.Minute, wait)
+	}
+}
+
+func checkLockExpired(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, entity.LoginScopeUsername, "admin", sqlmock.NewRows(cols).
+			AddRow(1, entity.LoginScopeUsername, "admin", 3, now.Add(-time.Hour), now.Add(-time.Second)))
+
+		wait, err := service.Check(context.Background(), "admin", "")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, time.Duration(0), wait)
+	}
+}
+
+func expectIncrement(mock sqlmock.Sqlmock, scope, subject string, max int, rows *sqlmock.Rows) {
+	mock.
+		ExpectExec("UPDATE login_attempts SET failures = 0, locked_until = NULL, updated_at = .+ WHERE scope = . AND subject = .").
+		WithArgs(now, scope, subject, now, true, now.Add(-time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectExec("INSERT INTO login_attempts .+ ON DUPLICATE KEY UPDATE locked_until = .+ failures = failures \\+ 1").
+		WithArgs(now, 1, now, nil, scope, subject, now, max, max, now.Add(time.Hour)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectFind(mock, scope, subject, rows)
+}
+
+func fail(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		expectIncrement(mock, entity.LoginScopeUsername, "admin", 3, sqlmock.NewRows(cols).
+			AddRow(1, entity.LoginScopeUsername, "admin", 1, now, nil))
+		expectIncrement(mock, entity.LoginScopeIP, "127.0.0.1", 10, sqlmock.NewRows(cols).
+			AddRow(2, entity.LoginScopeIP, "127.0.0.1", 5, now, nil))
+
+		err := service.Fail(context.Background(), "admin", "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.logs, 1)
+		assert.Equal(t, audit.ActionLoginFailed, auditor.logs[0].Action)
+		assert.Equal(t, "username=admin ip=127.0.0.1", auditor.logs[0].Meta)
+	}
+}
+
+func failLockout(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		expectIncrement(mock, entity.LoginScopeUsername, "admin", 3, sqlmock.NewRows(cols).
+			AddRow(1, entity.LoginScopeUsername, "admin", 3, now, now.Add(time.Hour)))
+
+		err := service.Fail(context.Background(), "admin", "")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.logs, 2)
+		assert.Equal(t, audit.ActionLockedOut, auditor.logs[1].Action)
+	}
+}
+
+func limit(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		expectIncrement(mock, entity.LoginScopeResetEmail, "admin@example.com", 3, sqlmock.NewRows(cols).
+			AddRow(1, entity.LoginScopeResetEmail, "admin@example.com", 4, now, now.Add(time.Hour)))
+		expectIncrement(mock, entity.LoginScopeResetIP, "127.0.0.1", 10, sqlmock.NewRows(cols).
+			AddRow(2, entity.LoginScopeResetIP, "127.0.0.1", 1, now, nil))
+
+		err := service.Limit(context.Background(), entity.LoginScopeResetEmail, "admin@example.com", entity.LoginScopeResetIP, "127.0.0.1")
+
+		assert.Equal(t, ErrLocked, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.logs, 0)
+	}
+}
+
+func unlock(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		mock.
+			ExpectExec("DELETE FROM login_attempts WHERE scope = . AND subject = .").
+			WithArgs(entity.LoginScopeUsername, "admin").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := service.Unlock(context.Background(), &entity.User{ID: 1, Name: "admin"})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.logs, 1)
+		assert.Equal(t, audit.ActionUnlocked, auditor.logs[0].Action)
+		assert.Equal(t, int64(1), auditor.logs[0].EntityID.Int64)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	service := NewService(nil, nil, config)
+
+	assert.Equal(t, time.Duration(0), service.delay(0))
+	assert.Equal(t, time.Second, service.delay(1))
+	assert.Equal(t, 2*time.Second, service.delay(2))
+	assert.Equal(t, 8*time.Second, service.delay(4))
+	assert.Equal(t, 10*time.Second, service.delay(5))
+	assert.Equal(t, 10*time.Second, service.delay(100))
+
+	unlimited := NewService(nil, nil, Config{Delay: time.Second})
+	assert.Equal(t, 1024*time.Second, unlimited.delay(11))
+	assert.Equal(t, unlimited.delay(40), unlimited.delay(1000))
+	assert.True(t, unlimited.delay(1000) > unlimited.delay(33))
+}
@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db    *db.Connection
+	clock *clock.Clock
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Find finds the failed logins of a subject.
+func (s Store) Find(ctx context.Context, scope, subject string) (*entity.LoginAttempt, error) {
+	var attempt entity.LoginAttempt
+	err := s.db.GetContext(ctx, &attempt, "SELECT * FROM login_attempts WHERE scope = ? AND subject = ? LIMIT 1", scope, subject)
+	return &attempt, errors.WithStack(checkNotFound(err))
+}
+
+// Increment counts a failed login of a subject and returns its updated failures. The failures of
+// an expired lockout are reset first. The counter is incremented by the database, so concurrent
+// failures are never lost. A subject is locked for the lockout duration once it reaches maxFailures,
+// 0 disables the lockout.
+func (s Store) Increment(ctx context.Context, scope, subject string, maxFailures int, lockout time.Duration) (*entity.LoginAttempt, error) {
+	now := s.clock.Now()
+	// A lockout is over or the last failure is older than the lockout duration.
+	_, err := s.db.ExecContext(ctx, `UPDATE login_attempts SET failures = 0, locked_until = NULL, updated_at = ?
+		WHERE scope = ? AND subject = ? AND (locked_until <= ? OR (locked_until IS NULL AND ? AND last_failure_at < ?))`,
+		now, scope, subject, now, lockout > 0, now.Add(-lockout))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var lockedUntil null.Time
+	if maxFailures == 1 {
+		lockedUntil = null.TimeFrom(now.Add(lockout))
+	}
+	// locked_until is assigned before failures, it sees the old value in MySQL as well.
+	query, params, err := sq.
+		Insert("login_attempts").
+		SetMap(db.ColumnMap{
+			"scope":           scope,
+			"subject":         subject,
+			"failures":        1,
+			"last_failure_at": now,
+			"locked_until":    lockedUntil,
+			"created_at":      now,
+			"updated_at":      now,
+		}).
+		Suffix(`ON DUPLICATE KEY UPDATE
+			locked_until = IF(? > 0 AND failures + 1 >= ?, ?, locked_until),
+			failures = failures + 1,
+			last_failure_at = VALUES(last_failure_at),
+			updated_at = VALUES(updated_at)`, maxFailures, maxFailures, now.Add(lockout)).
+		ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err = s.db.ExecContext(ctx, query, params...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.Find(ctx, scope, subject)
+}
+
+// Delete removes the failed logins of a subject.
+func (s Store) Delete(ctx context.Context, scope, subject string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE scope = ? AND subject = ?", scope, subject)
+	return errors.WithStack(err)
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_IncrementConcurrent(t *testing.T) {
+	conn, cleanup := test.DB(t)
+	defer cleanup()
+	service := NewService(NewStore(conn), &auditRecorder{}, Config{MaxFailures: 5, Lockout: time.Hour})
+
+	// Parallel guesses must be counted, otherwise they all read the same count and never reach the lockout.
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Store.Increment(context.Background(), entity.LoginScopeUsername, "admin", 5, time.Hour)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	attempt, err := service.Store.Find(context.Background(), entity.LoginScopeUsername, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, n, attempt.Failures)
+	assert.True(t, attempt.LockedUntil.Valid)
+
+	_, err = service.Check(context.Background(), "admin", "")
+	assert.Equal(t, ErrLocked, err)
+}
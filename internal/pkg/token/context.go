@@ -0,0 +1,26 @@
+package token
+
+import (
+	"context"
+
+	"go-webapp-example/internal/pkg/entity"
+)
+
+// ctxKey is used to store the token of the current request in a context.
+var ctxKey = &contextKey{"token"}
+
+type contextKey struct {
+	name string
+}
+
+// WithContext returns a copy of ctx that carries the token.
+func WithContext(ctx context.Context, token *entity.Token) context.Context {
+	return context.WithValue(ctx, ctxKey, token)
+}
+
+// FromContext returns the token the current request was authenticated with.
+// It returns false for requests that are authenticated by a session.
+func FromContext(ctx context.Context) (*entity.Token, bool) {
+	token, ok := ctx.Value(ctxKey).(*entity.Token)
+	return token, ok
+}
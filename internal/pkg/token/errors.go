@@ -0,0 +1,11 @@
+package token
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when a requested token could not be found.
+var ErrNotFound = errors.New("token not found")
+
+// ErrExpired is returned when an expired token is used.
+var ErrExpired = errors.New("token expired")
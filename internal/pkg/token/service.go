@@ -0,0 +1,89 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/pkg/errors"
+)
+
+// secretPrefix is prepended to every secret so leaked tokens are easy to recognize.
+const secretPrefix = "gwa_"
+
+// secretBytes is the amount of random bytes in a secret.
+const secretBytes = 32
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store) *Service {
+	return &Service{
+		Store: store,
+	}
+}
+
+// Create creates a new token and returns its secret. The secret is only
+// available at this point, only its hash is stored in the database.
+func (s Service) Create(ctx context.Context, token *entity.Token) (*entity.Token, string, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return token, "", errors.WithStack(err)
+	}
+	token.Hash = Hash(secret)
+	token, err = s.Store.Create(ctx, token)
+	if err != nil {
+		return token, "", errors.WithStack(err)
+	}
+	return token, secret, nil
+}
+
+// Authenticate returns the token that belongs to a secret. Expired tokens are rejected.
+func (s Service) Authenticate(ctx context.Context, secret string) (*entity.Token, error) {
+	token, err := s.Store.FindByHash(ctx, Hash(secret))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if token.ExpiresAt.Valid && !token.ExpiresAt.Time.After(s.clock.Now()) {
+		return nil, ErrExpired
+	}
+	if err = s.Store.Touch(ctx, token); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return token, nil
+}
+
+// Check returns ErrNotFound if a token that authenticated a request was revoked in the meantime
+// and ErrExpired once it expired. Long running requests like subscriptions check their token with it.
+func (s Service) Check(ctx context.Context, token *entity.Token) error {
+	current, err := s.Store.Find(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if current.ExpiresAt.Valid && !current.ExpiresAt.Time.After(s.clock.Now()) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of a secret.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateSecret returns a new random secret.
+func generateSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate token secret")
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
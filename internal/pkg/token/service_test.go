@@ -0,0 +1,168 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+type setupFn func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor)
+
+// TestTokenService tests all service methods as well as the underlying store.
+func TestTokenService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor) {
+		db, mockDB := test.MockDB(t)
+		mockAuditor := audit.NewMockAuditor()
+		service := NewService(NewStore(db, mockAuditor, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}))
+		return mockDB, service, mockAuditor
+	}
+
+	t.Run("GetByUserID", getByUserID(setup))
+	t.Run("Create", create(setup))
+	t.Run("Delete", del(setup))
+	t.Run("Authenticate", authenticate(setup))
+	t.Run("AuthenticateExpired", authenticateExpired(setup))
+	t.Run("Check", check(setup))
+}
+
+func getByUserID(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		rows := sqlmock.
+			NewRows([]string{"id", "user_id", "name", "permissions"}).
+			AddRow(1, 2, "script", "admin.quote::read,admin.user::write").
+			AddRow(2, 2, "ci", nil)
+
+		mock.ExpectQuery("SELECT .+ FROM tokens WHERE user_id = .").WithArgs(2).WillReturnRows(rows)
+
+		tokens, err := service.GetByUserID(context.Background(), 2)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, tokens, 2)
+		assert.Equal(t, entity.TokenPermissions{"admin.quote::read", "admin.user::write"}, tokens[0].Permissions)
+		assert.Len(t, tokens[1].Permissions, 0)
+	}
+}
+
+func create(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO tokens").
+			WithArgs(now, now, sqlmock.AnyArg(), nil, "script", "admin.quote::read", now, 2).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectCommit()
+
+		token := &entity.Token{
+			UserID:      2,
+			Name:        "script",
+			Permissions: entity.TokenPermissions{"admin.quote::read"},
+			ExpiresAt:   null.TimeFrom(now),
+		}
+
+		result, secret, err := service.Create(context.Background(), token)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 3, result.ID)
+		assert.NotEmpty(t, secret)
+		assert.Equal(t, Hash(secret), result.Hash)
+		assert.NotContains(t, result.Hash, secret)
+		assert.Len(t, auditor.Created, 1)
+	}
+}
+
+func del(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM tokens WHERE id = . LIMIT 1").
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		_, err := service.Delete(context.Background(), &entity.Token{ID: 3})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.Deleted, 1)
+	}
+}
+
+func authenticate(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		mock.
+			ExpectQuery("SELECT .+ FROM tokens WHERE hash = . LIMIT 1").
+			WithArgs(Hash("secret")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow(3, 2, now.Add(time.Hour)))
+		mock.
+			ExpectExec("UPDATE tokens SET last_used_at = . WHERE id = .").
+			WithArgs(now, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		token, err := service.Authenticate(context.Background(), "secret")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 2, token.UserID)
+	}
+}
+
+func authenticateExpired(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		mock.
+			ExpectQuery("SELECT .+ FROM tokens WHERE hash = . LIMIT 1").
+			WithArgs(Hash("secret")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow(3, 2, now.Add(-time.Hour)))
+
+		token, err := service.Authenticate(context.Background(), "secret")
+
+		assert.Equal(t, ErrExpired, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Nil(t, token)
+	}
+}
+
+func check(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+		expect := func(rows *sqlmock.Rows) {
+			mock.ExpectQuery("SELECT .+ FROM tokens WHERE id = . LIMIT 1").WithArgs(3).WillReturnRows(rows)
+		}
+
+		expect(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow(3, 2, nil))
+		assert.NoError(t, service.Check(context.Background(), &entity.Token{ID: 3}))
+
+		expect(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow(3, 2, now.Add(-time.Hour)))
+		assert.Equal(t, ErrExpired, service.Check(context.Background(), &entity.Token{ID: 3}))
+
+		expect(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}))
+		assert.Equal(t, ErrNotFound, errors.Cause(service.Check(context.Background(), &entity.Token{ID: 3})))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
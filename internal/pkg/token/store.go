@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db      *db.Connection
+	clock   *clock.Clock
+	auditor audit.ChangeAuditor
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, auditor audit.ChangeAuditor, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn, auditor: auditor}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Find finds the entity by id.
+func (s Store) Find(ctx context.Context, id int) (*entity.Token, error) {
+	var token entity.Token
+	err := s.db.GetContext(ctx, &token, "SELECT * FROM tokens WHERE id = ? LIMIT 1", id)
+	return &token, errors.WithStack(checkNotFound(err))
+}
+
+// FindByHash finds the entity by the hash of its secret.
+func (s Store) FindByHash(ctx context.Context, hash string) (*entity.Token, error) {
+	var token entity.Token
+	err := s.db.GetContext(ctx, &token, "SELECT * FROM tokens WHERE hash = ? LIMIT 1", hash)
+	return &token, errors.WithStack(checkNotFound(err))
+}
+
+// GetByUserID returns all tokens of a user.
+func (s Store) GetByUserID(ctx context.Context, userID int) ([]*entity.Token, error) {
+	var tokens []*entity.Token
+	err := s.db.SelectContext(ctx, &tokens, "SELECT * FROM tokens WHERE user_id = ? ORDER BY id", userID)
+	return tokens, errors.WithStack(err)
+}
+
+// Create creates a new entity.
+func (s Store) Create(ctx context.Context, token *entity.Token) (*entity.Token, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return token, errors.WithStack(err)
+	}
+	token.CreatedAt = null.TimeFrom(s.clock.Now())
+	token.UpdatedAt = null.TimeFrom(s.clock.Now())
+	query, params, err := sq.Insert("tokens").SetMap(mapCols(token)).ToSql()
+	if err != nil {
+		return token, db.RollbackError(tx, errors.WithStack(err))
+	}
+	res, err := tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return token, db.RollbackError(tx, errors.WithStack(err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return token, db.RollbackError(tx, errors.WithStack(err))
+	}
+	token.ID = int(id)
+	err = s.auditor.LogCreate(ctx, tx, token)
+	if err != nil {
+		return token, db.RollbackError(tx, errors.WithStack(err))
+	}
+	return token, errors.WithStack(tx.Commit())
+}
+
+// Delete removes an entity from the database.
+func (s Store) Delete(ctx context.Context, token *entity.Token) (*entity.Token, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return token, errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM tokens WHERE id = ? LIMIT 1", token.ID)
+	if err != nil {
+		return token, db.RollbackError(tx, errors.WithStack(err))
+	}
+	err = s.auditor.LogDelete(ctx, tx, token)
+	if err != nil {
+		return token, db.RollbackError(tx, errors.WithStack(err))
+	}
+	return token, errors.WithStack(tx.Commit())
+}
+
+// Touch sets the last usage time of a token.
+func (s Store) Touch(ctx context.Context, token *entity.Token) error {
+	token.LastUsedAt = null.TimeFrom(s.clock.Now())
+	_, err := s.db.ExecContext(ctx, "UPDATE tokens SET last_used_at = ? WHERE id = ?", token.LastUsedAt, token.ID)
+	return errors.WithStack(err)
+}
+
+// mapCols maps the entity to all default columns.
+func mapCols(token *entity.Token) db.ColumnMap {
+	return db.ColumnMap{
+		"user_id":      token.UserID,
+		"name":         token.Name,
+		"hash":         token.Hash,
+		"permissions":  token.Permissions,
+		"expires_at":   token.ExpiresAt,
+		"last_used_at": token.LastUsedAt,
+		"created_at":   token.CreatedAt,
+		"updated_at":   token.UpdatedAt,
+	}
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
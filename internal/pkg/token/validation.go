@@ -0,0 +1,49 @@
+package token
+
+import (
+	"fmt"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/validation"
+)
+
+// ValidateCreateRequest validates a create request of this entity. The requested
+// permissions have to be part of the granted permissions unless the user is a superuser.
+func ValidateCreateRequest(input *gqlmodels.TokenInput, u *entity.User, granted []*entity.Permission, now time.Time) *validation.ErrorBag {
+	errs := validation.NewErrorBag("token")
+
+	if input.Name == "" {
+		errs.Add("name", "required")
+	}
+	if input.ExpiresAt.IsZero() {
+		errs.Add("expires_at", "required")
+	} else if !input.ExpiresAt.After(now) {
+		errs.Add("expires_at", "future")
+	}
+
+	for i, p := range input.Permissions {
+		field := fmt.Sprintf("permissions.%d", i)
+		requested, err := entity.ParseCodeLevel(p)
+		if err != nil {
+			errs.AddData(field, "format", map[string]string{"format": "permission.code::level"})
+			continue
+		}
+		if !u.IsSuperuser && !isGranted(requested, granted) {
+			errs.AddData(field, "not_granted", map[string]string{"permission": p})
+		}
+	}
+
+	return errs
+}
+
+// isGranted checks if a permission is part of the granted permissions.
+func isGranted(requested *entity.Permission, granted []*entity.Permission) bool {
+	for _, p := range granted {
+		if p.Grants(requested) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCreateRequest(t *testing.T) {
+	user := &entity.User{ID: 2}
+	granted := []*entity.Permission{
+		{Code: "admin.quote", Level: entity.PermissionLevelWrite},
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		input := gqlmodels.TokenInput{
+			Name:        "",
+			ExpiresAt:   now.Add(-time.Hour),
+			Permissions: []string{"admin.quote", "admin.quote::manage", "admin.user::read"},
+		}
+
+		err := ValidateCreateRequest(&input, user, granted, now)
+
+		assert.True(t, err.Failed())
+		assert.Len(t, err.Get("name"), 1)
+		assert.Len(t, err.Get("expires_at"), 1)
+		assert.Len(t, err.Get("permissions.0"), 1)
+		assert.Len(t, err.Get("permissions.1"), 1)
+		assert.Len(t, err.Get("permissions.2"), 1)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		input := gqlmodels.TokenInput{
+			Name:        "script",
+			ExpiresAt:   now.Add(time.Hour),
+			Permissions: []string{"admin.quote::read", "admin.quote::write"},
+		}
+
+		err := ValidateCreateRequest(&input, user, granted, now)
+
+		assert.False(t, err.Failed())
+		assert.Len(t, err.Errors(), 0)
+	})
+
+	t.Run("Superuser", func(t *testing.T) {
+		input := gqlmodels.TokenInput{
+			Name:        "script",
+			ExpiresAt:   now.Add(time.Hour),
+			Permissions: []string{"admin.user::manage"},
+		}
+
+		err := ValidateCreateRequest(&input, &entity.User{ID: 1, IsSuperuser: true}, nil, now)
+
+		assert.False(t, err.Failed())
+	})
+}
@@ -0,0 +1,23 @@
+package twofactor
+
+import "context"
+
+// ctxKey marks requests of users that have to set up two-factor authentication.
+var ctxKey = &contextKey{"twofactor_setup"}
+
+type contextKey struct {
+	name string
+}
+
+// WithSetupRequired marks the request of a user that has to set up two-factor
+// authentication before accessing restricted data.
+func WithSetupRequired(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey, true)
+}
+
+// SetupRequiredFromContext returns true if the user of the request has to set up
+// two-factor authentication.
+func SetupRequiredFromContext(ctx context.Context) bool {
+	required, _ := ctx.Value(ctxKey).(bool)
+	return required
+}
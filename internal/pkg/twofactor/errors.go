@@ -0,0 +1,16 @@
+package twofactor
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a user has not enrolled in two-factor authentication.
+	ErrNotFound = errors.New("two-factor authentication not found")
+	// ErrInvalidCode is returned if neither a valid TOTP code nor an unused recovery code was provided.
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	// ErrAlreadyEnabled is returned if a user with enabled two-factor authentication starts a new enrollment.
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrRequired is returned if a user tries to disable two-factor authentication that is mandatory for one of their roles.
+	ErrRequired = errors.New("two-factor authentication is required for this user")
+)
@@ -0,0 +1,194 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/totp"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// skew is the number of time steps a code may be off to allow for clock drift.
+	skew = 1
+	// recoveryCodeCount is the number of recovery codes generated on confirmation.
+	recoveryCodeCount = 10
+)
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+	// issuer is shown as account name in authenticator apps.
+	issuer string
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store, issuer string) *Service {
+	return &Service{
+		Store:  store,
+		issuer: issuer,
+	}
+}
+
+// Enabled returns true if the user confirmed the two-factor enrollment.
+func (s Service) Enabled(ctx context.Context, userID int) (bool, error) {
+	tf, err := s.Store.FindByUserID(ctx, userID)
+	if errors.Cause(err) == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled(), nil
+}
+
+// SetupRequired returns true if one of the user's roles requires two-factor
+// authentication but the user has not enabled it yet.
+func (s Service) SetupRequired(ctx context.Context, userID int) (bool, error) {
+	required, err := s.Store.RequiredForUser(ctx, userID)
+	if err != nil || !required {
+		return false, err
+	}
+	enabled, err := s.Enabled(ctx, userID)
+	return !enabled, err
+}
+
+// Enroll starts the two-factor enrollment of a user. The returned provisioning
+// URI has to be confirmed with a valid code before the second factor is enabled.
+func (s Service) Enroll(ctx context.Context, u *entity.User) (*entity.TwoFactor, string, error) {
+	enabled, err := s.Enabled(ctx, u.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if enabled {
+		return nil, "", ErrAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	tf, err := s.Store.Create(ctx, &entity.TwoFactor{UserID: u.ID, Secret: secret})
+	if err != nil {
+		return nil, "", err
+	}
+	return tf, totp.URI(s.issuer, u.Name, secret), nil
+}
+
+// Confirm enables the two-factor authentication if code is valid for the pending
+// enrollment. The returned recovery codes are only available once.
+func (s Service) Confirm(ctx context.Context, u *entity.User, code string) ([]string, error) {
+	tf, err := s.Store.FindByUserID(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled() {
+		return nil, ErrAlreadyEnabled
+	}
+	step, ok := totp.Validate(tf.Secret, code, s.clock.Now(), skew)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	tf.LastStep = step
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+
+	if _, err = s.Store.Confirm(ctx, tf, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or an unused recovery code of a user. Every code can
+// only be used once.
+func (s Service) Verify(ctx context.Context, userID int, code string) error {
+	tf, err := s.Store.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled() {
+		return ErrNotFound
+	}
+	if step, ok := totp.Validate(tf.Secret, code, s.clock.Now(), skew); ok {
+		if step <= tf.LastStep {
+			return ErrInvalidCode
+		}
+		updated, err := s.Store.UpdateStep(ctx, tf, step)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+	used, err := s.Store.UseRecoveryCode(ctx, userID, HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// Disable removes the two-factor authentication of a user after checking a
+// valid code. It is not possible if a role of the user requires it.
+func (s Service) Disable(ctx context.Context, u *entity.User, code string) error {
+	required, err := s.Store.RequiredForUser(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrRequired
+	}
+	if err := s.Verify(ctx, u.ID, code); err != nil {
+		return err
+	}
+	return s.Reset(ctx, u.ID)
+}
+
+// Reset removes the two-factor authentication of a user without a code. It is
+// used by superusers to help users that lost their second factor.
+func (s Service) Reset(ctx context.Context, userID int) error {
+	tf, err := s.Store.FindByUserID(ctx, userID)
+	if errors.Cause(err) == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.Store.Delete(ctx, tf)
+	return err
+}
+
+// HashRecoveryCode returns the hash of a normalized recovery code.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCode returns a random code in the format xxxxx-xxxxx.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate recovery code")
+	}
+	code := hex.EncodeToString(b)
+	return fmt.Sprintf("%s-%s", code[:5], code[5:]), nil
+}
@@ -0,0 +1,230 @@
+package twofactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/totp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+// secret is the TOTP secret of all test cases.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+var cols = []string{"id", "user_id", "secret", "confirmed_at", "last_step"}
+
+type setupFn func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor)
+
+// TestTwoFactorService tests all service methods as well as the underlying store.
+func TestTwoFactorService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor) {
+		db, mockDB := test.MockDB(t)
+		mockAuditor := audit.NewMockAuditor()
+		service := NewService(NewStore(db, mockAuditor, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}), "test")
+		return mockDB, service, mockAuditor
+	}
+
+	t.Run("Enroll", enroll(setup))
+	t.Run("EnrollEnabled", enrollEnabled(setup))
+	t.Run("Confirm", confirm(setup))
+	t.Run("ConfirmInvalid", confirmInvalid(setup))
+	t.Run("Verify", verify(setup))
+	t.Run("VerifyReplay", verifyReplay(setup))
+	t.Run("VerifyRecoveryCode", verifyRecoveryCode(setup))
+	t.Run("DisableRequired", disableRequired(setup))
+	t.Run("Reset", reset(setup))
+}
+
+func code(t *testing.T, at time.Time) string {
+	c, err := totp.Code(secret, totp.Step(at))
+	assert.NoError(t, err)
+	return c
+}
+
+func expectFind(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.
+		ExpectQuery("SELECT .+ FROM two_factors WHERE user_id = . LIMIT 1").
+		WithArgs(2).
+		WillReturnRows(rows)
+}
+
+func enroll(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols))
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM two_factors WHERE user_id = . AND confirmed_at IS NULL").
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectExec("INSERT INTO two_factors").
+			WithArgs(nil, now, 0, sqlmock.AnyArg(), now, 2).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		tf, uri, err := service.Enroll(context.Background(), &entity.User{ID: 2, Name: "user"})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, tf.ID)
+		assert.False(t, tf.Enabled())
+		assert.Contains(t, uri, "otpauth://totp/test:user?")
+		assert.Contains(t, uri, "secret="+tf.Secret)
+	}
+}
+
+func enrollEnabled(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, now, 0))
+
+		_, _, err := service.Enroll(context.Background(), &entity.User{ID: 2, Name: "user"})
+
+		assert.Equal(t, ErrAlreadyEnabled, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func confirm(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, nil, 0))
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("UPDATE two_factors SET confirmed_at = .+ WHERE id = .").
+			WithArgs(now, totp.Step(now), now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectExec("DELETE FROM recovery_codes WHERE user_id = .").
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectExec("INSERT INTO recovery_codes").
+			WillReturnResult(sqlmock.NewResult(1, 10))
+		mock.ExpectCommit()
+
+		codes, err := service.Confirm(context.Background(), &entity.User{ID: 2}, code(t, now))
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, codes, recoveryCodeCount)
+		assert.Regexp(t, "^[0-9a-f]{5}-[0-9a-f]{5}$", codes[0])
+		assert.Len(t, auditor.Created, 1)
+	}
+}
+
+func confirmInvalid(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, nil, 0))
+
+		_, err := service.Confirm(context.Background(), &entity.User{ID: 2}, code(t, now.Add(-time.Hour)))
+
+		assert.Equal(t, ErrInvalidCode, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func verify(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, now, 0))
+		mock.
+			ExpectExec("UPDATE two_factors SET last_step = .+ WHERE id = . AND last_step < .").
+			WithArgs(totp.Step(now), now, 1, totp.Step(now)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := service.Verify(context.Background(), 2, code(t, now))
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func verifyReplay(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, now, totp.Step(now)))
+
+		err := service.Verify(context.Background(), 2, code(t, now))
+
+		assert.Equal(t, ErrInvalidCode, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func verifyRecoveryCode(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, now, 0))
+		mock.
+			ExpectExec("UPDATE recovery_codes SET used_at = .+ WHERE user_id = . AND hash = . AND used_at IS NULL").
+			WithArgs(now, now, 2, HashRecoveryCode("abcde-12345")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := service.Verify(context.Background(), 2, "ABCDE-12345")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func disableRequired(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		mock.
+			ExpectQuery("SELECT COUNT.+ FROM role_user INNER JOIN roles .+ WHERE role_user.user_id = . AND roles.require_two_factor = 1").
+			WithArgs(2, now, now).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		err := service.Disable(context.Background(), &entity.User{ID: 2}, code(t, now))
+
+		assert.Equal(t, ErrRequired, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func reset(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		expectFind(mock, sqlmock.NewRows(cols).AddRow(1, 2, secret, now, 0))
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM recovery_codes WHERE user_id = .").
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.
+			ExpectExec("DELETE FROM two_factors WHERE user_id = .").
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := service.Reset(context.Background(), 2)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.Deleted, 1)
+	}
+}
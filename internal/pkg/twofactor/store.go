@@ -0,0 +1,209 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db      *db.Connection
+	clock   *clock.Clock
+	auditor audit.ChangeAuditor
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, auditor audit.ChangeAuditor, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn, auditor: auditor}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// FindByUserID finds the entity of a user.
+func (s Store) FindByUserID(ctx context.Context, userID int) (*entity.TwoFactor, error) {
+	var tf entity.TwoFactor
+	err := s.db.GetContext(ctx, &tf, "SELECT * FROM two_factors WHERE user_id = ? LIMIT 1", userID)
+	return &tf, errors.WithStack(checkNotFound(err))
+}
+
+// Create creates a new unconfirmed entity. A previous unconfirmed enrollment of the user is replaced.
+func (s Store) Create(ctx context.Context, tf *entity.TwoFactor) (*entity.TwoFactor, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return tf, errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM two_factors WHERE user_id = ? AND confirmed_at IS NULL", tf.UserID)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	tf.CreatedAt = null.TimeFrom(s.clock.Now())
+	tf.UpdatedAt = null.TimeFrom(s.clock.Now())
+	query, params, err := sq.Insert("two_factors").SetMap(mapCols(tf)).ToSql()
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	res, err := tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	tf.ID = int(id)
+	return tf, errors.WithStack(tx.Commit())
+}
+
+// Confirm enables the two-factor authentication and replaces the recovery codes of the user.
+func (s Store) Confirm(ctx context.Context, tf *entity.TwoFactor, hashes []string) (*entity.TwoFactor, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return tf, errors.WithStack(err)
+	}
+	tf.ConfirmedAt = null.TimeFrom(s.clock.Now())
+	tf.UpdatedAt = null.TimeFrom(s.clock.Now())
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE two_factors SET confirmed_at = ?, last_step = ?, updated_at = ? WHERE id = ?",
+		tf.ConfirmedAt,
+		tf.LastStep,
+		tf.UpdatedAt,
+		tf.ID,
+	)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", tf.UserID)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	if len(hashes) > 0 {
+		insert := sq.Insert("recovery_codes").Columns("user_id", "hash", "created_at", "updated_at")
+		for _, hash := range hashes {
+			insert = insert.Values(tf.UserID, hash, tf.UpdatedAt, tf.UpdatedAt)
+		}
+		query, params, err := insert.ToSql()
+		if err != nil {
+			return tf, db.RollbackError(tx, errors.WithStack(err))
+		}
+		_, err = tx.ExecContext(ctx, query, params...)
+		if err != nil {
+			return tf, db.RollbackError(tx, errors.WithStack(err))
+		}
+	}
+	err = s.auditor.LogCreate(ctx, tx, tf)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	return tf, errors.WithStack(tx.Commit())
+}
+
+// UpdateStep stores the time step of the last accepted code. It returns false if
+// a newer code was accepted in the meantime.
+func (s Store) UpdateStep(ctx context.Context, tf *entity.TwoFactor, step int64) (bool, error) {
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE two_factors SET last_step = ?, updated_at = ? WHERE id = ? AND last_step < ?",
+		step,
+		s.clock.Now(),
+		tf.ID,
+		step,
+	)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	tf.LastStep = step
+	return affected == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It returns
+// false if no unused code with this hash exists.
+func (s Store) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	now := s.clock.Now()
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE recovery_codes SET used_at = ?, updated_at = ? WHERE user_id = ? AND hash = ? AND used_at IS NULL",
+		now,
+		now,
+		userID,
+		hash,
+	)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, errors.WithStack(err)
+}
+
+// Delete removes the two-factor authentication and the recovery codes of a user.
+func (s Store) Delete(ctx context.Context, tf *entity.TwoFactor) (*entity.TwoFactor, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return tf, errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", tf.UserID)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM two_factors WHERE user_id = ?", tf.UserID)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	err = s.auditor.LogDelete(ctx, tx, tf)
+	if err != nil {
+		return tf, db.RollbackError(tx, errors.WithStack(err))
+	}
+	return tf, errors.WithStack(tx.Commit())
+}
+
+// RequiredForUser returns true if one of the user's roles requires two-factor authentication.
+// Roles that are not valid at the moment are ignored.
+func (s Store) RequiredForUser(ctx context.Context, userID int) (bool, error) {
+	var count int
+	now := s.clock.Now()
+	err := s.db.GetContext(
+		ctx,
+		&count,
+		`SELECT COUNT(*) FROM role_user INNER JOIN roles ON roles.id = role_user.role_id
+		WHERE role_user.user_id = ? AND roles.require_two_factor = 1
+		AND (role_user.valid_from IS NULL OR role_user.valid_from <= ?)
+		AND (role_user.valid_until IS NULL OR role_user.valid_until > ?)`,
+		userID, now, now,
+	)
+	return count > 0, errors.WithStack(err)
+}
+
+// mapCols maps the entity to all default columns.
+func mapCols(tf *entity.TwoFactor) db.ColumnMap {
+	return db.ColumnMap{
+		"user_id":      tf.UserID,
+		"secret":       tf.Secret,
+		"confirmed_at": tf.ConfirmedAt,
+		"last_step":    tf.LastStep,
+		"created_at":   tf.CreatedAt,
+		"updated_at":   tf.UpdatedAt,
+	}
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
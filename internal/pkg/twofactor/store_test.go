@@ -0,0 +1,44 @@
+package twofactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_RequiredForUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	conn, cleanup := test.DB(t)
+	defer cleanup()
+	store := NewStore(conn, audit.NewMockAuditor())
+	ctx := context.Background()
+
+	_, err := conn.Exec("UPDATE roles SET require_two_factor = 1 WHERE id = 2")
+	require.NoError(t, err)
+
+	periods := []struct {
+		from, until interface{}
+		required    bool
+	}{
+		{nil, nil, true},
+		{time.Now().Add(-time.Hour), time.Now().Add(time.Hour), true},
+		{nil, time.Now().Add(-time.Minute), false},
+		{time.Now().Add(time.Minute), nil, false},
+	}
+	for _, p := range periods {
+		_, err = conn.Exec("UPDATE role_user SET valid_from = ?, valid_until = ? WHERE user_id = 2 AND role_id = 2", p.from, p.until)
+		require.NoError(t, err)
+		required, err := store.RequiredForUser(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, p.required, required, "from %v until %v", p.from, p.until)
+	}
+}
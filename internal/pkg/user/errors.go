@@ -4,5 +4,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-// ErrNotFound is returned when a requested user could not be found.
-var ErrNotFound = errors.New("user not found")
+var (
+	// ErrNotFound is returned when a requested user could not be found.
+	ErrNotFound = errors.New("user not found")
+	// ErrPasswordReused is returned if a new password was already used before.
+	ErrPasswordReused = errors.New("password was used before")
+)
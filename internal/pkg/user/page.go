@@ -0,0 +1,55 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/db"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// sortColumns contains the columns users can be sorted by.
+var sortColumns = []string{"id", "name", "created_at"}
+
+// Filter limits the users of a page. Empty fields match all users.
+type Filter struct {
+	// Name matches users whose name contains the value.
+	Name         string
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+	// RoleIDs matches users that have at least one of the roles.
+	RoleIDs []int
+	// WithoutSuperusers removes superusers from the page.
+	WithoutSuperusers bool
+}
+
+// where returns the conditions of the filter.
+func (f Filter) where() []sq.Sqlizer {
+	where := []sq.Sqlizer{db.Between("created_at", f.CreatedFrom, f.CreatedUntil)}
+	if f.Name != "" {
+		where = append(where, db.Contains("name", f.Name))
+	}
+	if len(f.RoleIDs) > 0 {
+		ids := make([]interface{}, len(f.RoleIDs))
+		for i, id := range f.RoleIDs {
+			ids[i] = id
+		}
+		where = append(where, sq.Expr("id IN (SELECT user_id FROM role_user WHERE role_id IN ("+sq.Placeholders(len(ids))+"))", ids...))
+	}
+	if f.WithoutSuperusers {
+		where = append(where, sq.Eq{"is_superuser": false})
+	}
+	return where
+}
+
+// GetPage returns a page of the users that match the filter.
+func (s Store) GetPage(ctx context.Context, filter Filter, sorts []db.Sort, args db.PageArgs) ([]*entity.User, *db.PageInfo, error) {
+	if err := db.CheckSorts(sorts, sortColumns...); err != nil {
+		return nil, nil, err
+	}
+	var users []*entity.User
+	info, err := s.db.Paginate(ctx, &users, "users", filter.where(), sorts, args)
+	return users, info, err
+}
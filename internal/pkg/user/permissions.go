@@ -0,0 +1,10 @@
+package user
+
+import (
+	"go-webapp-example/internal/pkg/permission"
+)
+
+// Permissions are the permission codes declared by this module.
+var Permissions = []permission.Definition{
+	{Code: "admin.user", Label: "user.permissions.admin", Levels: permission.AllLevels},
+}
@@ -15,20 +15,49 @@ import (
 // allows access to the store by embedding it.
 type Service struct {
 	*Store
-	Session sessionHandler
+	Session       sessionHandler
+	sessions      sessionRevoker
+	passwords     passwordHistory
+	authenticator Authenticator
+}
+
+// Authenticator checks the credentials of a user. It returns entity.ErrNotFound
+// for unknown users and entity.ErrUserInvalidPassword for a wrong password.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (*entity.User, error)
 }
 
 // sessionHandler defines the needed methods to store user sessions.
 type sessionHandler interface {
 	RenewToken(ctx context.Context) error
 	Put(ctx context.Context, key string, val interface{})
+	Remove(ctx context.Context, key string)
+}
+
+// sessionRevoker ends the sessions of a user.
+type sessionRevoker interface {
+	RevokeOthers(ctx context.Context, userID int) error
+}
+
+// passwordHistory prevents the reuse of old passwords and expires passwords.
+type passwordHistory interface {
+	Reused(ctx context.Context, userID int, password string) (bool, error)
+	Remember(ctx context.Context, userID int, hash string) error
+	PasswordExpired(u *entity.User) bool
+}
+
+// localUsers is implemented by authenticators that check some users against the database.
+type localUsers interface {
+	IsLocal(username string) bool
 }
 
 // NewService returns a pointer to a new Service.
-func NewService(store *Store, sess sessionHandler) *Service {
+func NewService(store *Store, sess sessionHandler, sessions sessionRevoker, passwords passwordHistory) *Service {
 	return &Service{
-		Store:   store,
-		Session: sess,
+		Store:     store,
+		Session:   sess,
+		sessions:  sessions,
+		passwords: passwords,
 	}
 }
 
@@ -39,19 +68,56 @@ func (s Service) Create(ctx context.Context, u *entity.User) (*entity.User, erro
 		return u, errors.WithStack(err)
 	}
 
-	return s.Store.Create(ctx, u)
+	u, err = s.Store.Create(ctx, u)
+	if err != nil {
+		return u, err
+	}
+	return u, errors.Wrap(s.passwords.Remember(ctx, u.ID, u.Password), "failed to store password history")
 }
 
-// Update updates a user account. The password is hashed automatically.
+// Update updates a user account. The password is hashed automatically and ErrPasswordReused
+// is returned if it was used before. If the password is changed, all other sessions of the user are ended.
 func (s Service) Update(ctx context.Context, u *entity.User) (*entity.User, error) {
-	if u.Password != "" {
-		hashed, err := hashPassword(u)
-		if err != nil {
-			return hashed, errors.WithStack(err)
-		}
+	if u.Password == "" {
+		return s.Store.Update(ctx, u)
+	}
+
+	reused, err := s.PasswordReused(ctx, u.ID, u.Password)
+	if err != nil {
+		return u, err
+	}
+	if reused {
+		return u, ErrPasswordReused
+	}
+
+	hashed, err := hashPassword(u)
+	if err != nil {
+		return hashed, errors.WithStack(err)
 	}
+	u, err = s.Store.Update(ctx, hashed)
+	if err != nil {
+		return u, err
+	}
+	if err = s.passwords.Remember(ctx, u.ID, u.Password); err != nil {
+		return u, errors.Wrap(err, "failed to store password history")
+	}
+	return u, errors.Wrap(s.sessions.RevokeOthers(ctx, u.ID), "failed to end user sessions")
+}
+
+// PasswordReused returns true if the password matches one of the last passwords of the user.
+func (s Service) PasswordReused(ctx context.Context, userID int, password string) (bool, error) {
+	return s.passwords.Reused(ctx, userID, password)
+}
 
-	return s.Store.Update(ctx, u)
+// PasswordExpired returns true if the user has to change the password before the login is completed.
+// Only passwords that are checked against the database expire.
+func (s Service) PasswordExpired(u *entity.User) bool {
+	if s.authenticator != nil {
+		if l, ok := s.authenticator.(localUsers); !ok || !l.IsLocal(u.Name) {
+			return false
+		}
+	}
+	return s.passwords.PasswordExpired(u)
 }
 
 // hashPassword hashes and sets the user's password.
@@ -66,6 +132,33 @@ func hashPassword(u *entity.User) (*entity.User, error) {
 
 // Login checks if a user with given credentials exists in the database and creates a session.
 func (s Service) Login(ctx context.Context, username, password string) (*entity.User, error) {
+	user, err := s.Authenticate(ctx, username, password)
+	if err != nil {
+		return user, err
+	}
+	if err := s.StartSession(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UseAuthenticator replaces the password check against the database with
+// another authenticator, e.g. a directory server.
+func (s *Service) UseAuthenticator(a Authenticator) {
+	s.authenticator = a
+}
+
+// Authenticate checks the credentials of a user using the configured authenticator. Other
+// than Login, it does not create a session.
+func (s Service) Authenticate(ctx context.Context, username, password string) (*entity.User, error) {
+	if s.authenticator != nil {
+		return s.authenticator.Authenticate(ctx, username, password)
+	}
+	return s.AuthenticateLocal(ctx, username, password)
+}
+
+// AuthenticateLocal checks if a user with given credentials exists in the database.
+func (s Service) AuthenticateLocal(ctx context.Context, username, password string) (*entity.User, error) {
 	user, err := s.Store.FindByName(ctx, username)
 	if err != nil {
 		return user, entity.ErrNotFound
@@ -76,14 +169,40 @@ func (s Service) Login(ctx context.Context, username, password string) (*entity.
 		return nil, entity.ErrUserInvalidPassword
 	}
 
+	return user, nil
+}
+
+// StartSession stores the user in the session of the current request.
+func (s Service) StartSession(ctx context.Context, user *entity.User) error {
 	// First renew the session token.
-	err = s.Session.RenewToken(ctx)
+	err := s.Session.RenewToken(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to renew session token")
+		return errors.Wrap(err, "failed to renew session token")
 	}
 
 	// Then make the privilege-level change.
 	s.Session.Put(ctx, session.AuthKey, user.ID)
 
-	return user, nil
+	return nil
+}
+
+// Impersonate lets the superuser of the current session act as another user. The superuser
+// is kept in the session, so the impersonation can be ended and changes are audited for both.
+func (s Service) Impersonate(ctx context.Context, superuser, user *entity.User) error {
+	if err := s.Session.RenewToken(ctx); err != nil {
+		return errors.Wrap(err, "failed to renew session token")
+	}
+	s.Session.Put(ctx, session.ImpersonatorKey, superuser.ID)
+	s.Session.Put(ctx, session.AuthKey, user.ID)
+	return nil
+}
+
+// StopImpersonation switches the current session back to the superuser that started the impersonation.
+func (s Service) StopImpersonation(ctx context.Context, superuser *entity.User) error {
+	if err := s.Session.RenewToken(ctx); err != nil {
+		return errors.Wrap(err, "failed to renew session token")
+	}
+	s.Session.Put(ctx, session.AuthKey, superuser.ID)
+	s.Session.Remove(ctx, session.ImpersonatorKey)
+	return nil
 }
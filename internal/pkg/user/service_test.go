@@ -9,6 +9,7 @@ import (
 	"go-webapp-example/internal/pkg/entity"
 	"go-webapp-example/internal/pkg/test"
 	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
 	"go-webapp-example/pkg/session"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -25,8 +26,49 @@ type setupFn func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor)
 
 type authManagerMock struct{}
 
-func (a authManagerMock) AddRoleForUser(int, int) bool    { return true }
-func (a authManagerMock) RemoveRoleForUser(int, int) bool { return true }
+func (a authManagerMock) Reload() error { return nil }
+func (a authManagerMock) SetRolesForUserTx(context.Context, *db.Tx, int, []int) error {
+	return nil
+}
+func (a authManagerMock) AllowUser(int, string, string) bool { return true }
+func (a authManagerMock) DenyUser(int, string, string) bool  { return true }
+func (a authManagerMock) DeleteUserPermissions(int)          {}
+func (a authManagerMock) UserPermissions(int) [][]string     { return nil }
+func (a authManagerMock) Can(int, string, string) bool       { return false }
+func (a authManagerMock) PermissionsForRole(int) [][]string  { return nil }
+func (a authManagerMock) InheritedPermissionsForRole(int) [][]string {
+	return nil
+}
+
+// sessionRevokerMock records the users whose sessions were ended.
+type sessionRevokerMock struct {
+	revoked []int
+}
+
+func (s *sessionRevokerMock) RevokeOthers(ctx context.Context, userID int) error {
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
+
+// passwordHistoryMock treats the passwords in used as reused.
+type passwordHistoryMock struct {
+	used       map[string]bool
+	remembered []int
+	expired    bool
+}
+
+func (p *passwordHistoryMock) Reused(ctx context.Context, userID int, password string) (bool, error) {
+	return p.used[password], nil
+}
+
+func (p *passwordHistoryMock) Remember(ctx context.Context, userID int, hash string) error {
+	p.remembered = append(p.remembered, userID)
+	return nil
+}
+
+func (p *passwordHistoryMock) PasswordExpired(u *entity.User) bool {
+	return p.expired
+}
 
 // TestUserService tests all service methods as well as the underlying store.
 func TestUserService(t *testing.T) {
@@ -39,6 +81,8 @@ func TestUserService(t *testing.T) {
 				store.clock = clock.FromTime(now)
 			}),
 			session.NewMockSessionManager(),
+			&sessionRevokerMock{},
+			&passwordHistoryMock{},
 		)
 
 		return mockDB, service, mockAuditor
@@ -100,7 +144,7 @@ func create(setup setupFn) func(t *testing.T) {
 		mock.ExpectBegin()
 		mock.
 			ExpectExec("INSERT INTO users").
-			WithArgs("Edited", "password", true, now, now).
+			WithArgs("Edited", nil, "password", true, now, now, now).
 			WillReturnResult(sqlmock.NewResult(3, 1))
 		mock.ExpectCommit()
 
@@ -129,8 +173,8 @@ func update(setup setupFn) func(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.
-			ExpectExec("UPDATE users SET name = .+, password = .+, is_superuser = .+, updated_at = .+ WHERE id = ?").
-			WithArgs("New User", "password", true, now, 3).
+			ExpectExec("UPDATE users SET name = .+, email = .+, password = .+, is_superuser = .+, password_changed_at = .+, updated_at = .+ WHERE id = ?").
+			WithArgs("New User", nil, "password", true, now, now, 3).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 
@@ -145,6 +189,48 @@ func update(setup setupFn) func(t *testing.T) {
 	}
 }
 
+// TestUserServiceUpdatePassword makes sure a password change ends the user's other sessions.
+func TestUserServiceUpdatePassword(t *testing.T) {
+	db, mock := test.MockDB(t)
+	revoker := &sessionRevokerMock{}
+	passwords := &passwordHistoryMock{used: map[string]bool{"old": true}}
+	service := NewService(
+		NewStore(db, &authManagerMock{}, audit.NewMockAuditor(), func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}),
+		session.NewMockSessionManager(),
+		revoker,
+		passwords,
+	)
+
+	expectUpdate := func() {
+		mock.
+			ExpectQuery("SELECT .+ FROM users WHERE id = .+ LIMIT 1").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password"}).AddRow(3, "User", adminPw))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE users SET").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	expectUpdate()
+	_, err := service.Update(context.Background(), &entity.User{ID: 3, Name: "User"})
+	assert.NoError(t, err)
+	assert.Len(t, revoker.revoked, 0)
+
+	expectUpdate()
+	_, err = service.Update(context.Background(), &entity.User{ID: 3, Name: "User", Password: "changed"})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, revoker.revoked)
+	assert.Equal(t, []int{3}, passwords.remembered)
+
+	// Reused passwords are rejected before the user is changed.
+	_, err = service.Update(context.Background(), &entity.User{ID: 3, Name: "User", Password: "old"})
+	assert.Equal(t, ErrPasswordReused, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func del(setup setupFn) func(t *testing.T) {
 	return func(t *testing.T) {
 		mock, service, auditor := setup()
@@ -229,3 +315,36 @@ func validateWrongLogin(setup setupFn) func(t *testing.T) {
 		assert.Nil(t, user, "Login() returned user for wrong password.")
 	}
 }
+
+// authenticatorMock accepts every password.
+type authenticatorMock struct{}
+
+func (a authenticatorMock) Authenticate(ctx context.Context, username, password string) (*entity.User, error) {
+	return &entity.User{ID: 7, Name: username}, nil
+}
+
+// TestUserServiceAuthenticator makes sure an authenticator replaces the password check against the database.
+func TestUserServiceAuthenticator(t *testing.T) {
+	db, mock := test.MockDB(t)
+	passwords := &passwordHistoryMock{expired: true}
+	service := NewService(NewStore(db, &authManagerMock{}, audit.NewMockAuditor()), session.NewMockSessionManager(), &sessionRevokerMock{}, passwords)
+	assert.True(t, service.PasswordExpired(&entity.User{Name: "jane"}))
+
+	service.UseAuthenticator(authenticatorMock{})
+	// Passwords of the directory do not expire.
+	assert.False(t, service.PasswordExpired(&entity.User{Name: "jane"}))
+
+	u, err := service.Authenticate(context.Background(), "jane", "anything")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, u.ID)
+
+	mock.
+		ExpectQuery("SELECT .+ FROM users WHERE name = . LIMIT 1").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password"}).AddRow(1, "admin", adminPw))
+
+	u, err = service.AuthenticateLocal(context.Background(), "admin", "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, u.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -6,6 +6,8 @@ import (
 
 	"go-webapp-example/internal/pkg/audit"
 	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/event"
+	"go-webapp-example/internal/pkg/permission"
 	"go-webapp-example/pkg/clock"
 	"go-webapp-example/pkg/db"
 	"go-webapp-example/pkg/util"
@@ -16,8 +18,17 @@ import (
 )
 
 type authManager interface {
-	AddRoleForUser(userID int, roleID int) bool
-	RemoveRoleForUser(userID int, roleID int) bool
+	SetRolesForUserTx(ctx context.Context, tx *db.Tx, userID int, roleIDs []int) error
+	Reload() error
+
+	AllowUser(userID int, code string, action string) bool
+	DenyUser(userID int, code string, action string) bool
+	DeleteUserPermissions(userID int)
+	UserPermissions(userID int) [][]string
+
+	Can(userID int, subject, action string) bool
+	PermissionsForRole(id int) [][]string
+	InheritedPermissionsForRole(id int) [][]string
 }
 
 // Store handles the direct database access for this entity.
@@ -26,6 +37,7 @@ type Store struct {
 	clock   *clock.Clock
 	auditor audit.ChangeAuditor
 	auth    authManager
+	events  event.Publisher
 }
 
 // NewStore returns a new store instance.
@@ -37,6 +49,13 @@ func NewStore(conn *db.Connection, auth authManager, auditor audit.ChangeAuditor
 	return s
 }
 
+// WithEvents publishes the changes of committed users to p.
+func WithEvents(p event.Publisher) func(s *Store) {
+	return func(s *Store) {
+		s.events = p
+	}
+}
+
 // Find finds the entity by id.
 func (s Store) Find(ctx context.Context, id int) (*entity.User, error) {
 	var user entity.User
@@ -51,6 +70,13 @@ func (s Store) FindByName(ctx context.Context, name string) (*entity.User, error
 	return &user, errors.WithStack(checkNotFound(err))
 }
 
+// FindByEmail finds the entity by email address.
+func (s Store) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
+	var user entity.User
+	err := s.db.GetContext(ctx, &user, "SELECT * FROM users WHERE email = ? LIMIT 1", email)
+	return &user, errors.WithStack(checkNotFound(err))
+}
+
 // Get returns all available entities.
 func (s Store) Get(ctx context.Context) ([]*entity.User, error) {
 	var users []*entity.User
@@ -103,6 +129,7 @@ func (s Store) GetByRoleID(ctx context.Context, ids []int) (map[int][]*entity.Us
 		ret[user.RoleID] = append(ret[user.RoleID], &entity.User{
 			ID:          user.ID,
 			Name:        user.Name,
+			Email:       user.Email,
 			Password:    user.Password,
 			IsSuperuser: user.IsSuperuser,
 			CreatedAt:   user.CreatedAt,
@@ -120,11 +147,14 @@ func (s Store) Create(ctx context.Context, user *entity.User) (*entity.User, err
 	}
 	user.CreatedAt = null.TimeFrom(s.clock.Now())
 	user.UpdatedAt = null.TimeFrom(s.clock.Now())
+	user.PasswordChangedAt = null.TimeFrom(s.clock.Now())
 	res, err := tx.ExecContext(ctx,
-		"INSERT INTO users (name, password, is_superuser, created_at, updated_at) VALUES (?, ?, ?, ?, ?);",
+		"INSERT INTO users (name, email, password, is_superuser, password_changed_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?);",
 		user.Name,
+		user.Email,
 		user.Password,
 		user.IsSuperuser,
+		user.PasswordChangedAt,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -140,6 +170,7 @@ func (s Store) Create(ctx context.Context, user *entity.User) (*entity.User, err
 	if err != nil {
 		return user, db.RollbackError(tx, errors.WithStack(err))
 	}
+	tx.AfterCommit(func() { s.publish(event.Created(user)) })
 	return user, errors.WithStack(tx.Commit())
 }
 
@@ -156,6 +187,10 @@ func (s Store) Update(ctx context.Context, user *entity.User) (*entity.User, err
 	if user.Password == "" {
 		user.Password = current.Password
 	}
+	user.PasswordChangedAt = current.PasswordChangedAt
+	if user.Password != current.Password {
+		user.PasswordChangedAt = null.TimeFrom(s.clock.Now())
+	}
 	user.CreatedAt = current.CreatedAt
 	user.UpdatedAt = null.TimeFrom(s.clock.Now())
 
@@ -164,10 +199,12 @@ func (s Store) Update(ctx context.Context, user *entity.User) (*entity.User, err
 		return user, errors.WithStack(err)
 	}
 	_, err = tx.ExecContext(ctx,
-		"UPDATE users SET name = ?, password = ?, is_superuser = ?, updated_at = ? WHERE id = ?",
+		"UPDATE users SET name = ?, email = ?, password = ?, is_superuser = ?, password_changed_at = ?, updated_at = ? WHERE id = ?",
 		user.Name,
+		user.Email,
 		user.Password,
 		user.IsSuperuser,
+		user.PasswordChangedAt,
 		user.UpdatedAt,
 		user.ID,
 	)
@@ -179,6 +216,7 @@ func (s Store) Update(ctx context.Context, user *entity.User) (*entity.User, err
 	if err != nil {
 		return user, db.RollbackError(tx, errors.WithStack(err))
 	}
+	tx.AfterCommit(func() { s.publish(event.Updated(current, user)) })
 
 	return user, errors.WithStack(tx.Commit())
 }
@@ -204,7 +242,10 @@ func (s Store) Delete(ctx context.Context, ids []int) ([]*entity.User, error) {
 			return result, db.RollbackError(tx, errors.WithStack(err))
 		}
 	}
-	return result, errors.WithStack(tx.Commit())
+	if err = tx.Commit(); err != nil {
+		return result, errors.WithStack(err)
+	}
+	return result, s.auth.Reload()
 }
 
 // deleteEntity removes a single entity from the database.
@@ -220,33 +261,80 @@ func (s Store) deleteEntity(ctx context.Context, tx *db.Tx, source *entity.User)
 	if err != nil {
 		return source, errors.WithStack(err)
 	}
+	err = s.auth.SetRolesForUserTx(ctx, tx, source.ID, nil)
+	if err != nil {
+		return source, err
+	}
 	err = s.auditor.LogDelete(ctx, tx, source)
 	if err != nil {
 		return source, errors.WithStack(err)
 	}
+	tx.AfterCommit(func() { s.publish(event.Deleted(source)) })
 	return source, nil
 }
 
-// SyncRoles sets the provided role IDs for a user.
-// nolint:govet
+// SyncRoles sets the provided role IDs for a user. Roles that stay assigned keep their period of validity.
 func (s Store) SyncRoles(ctx context.Context, source *entity.User, roleIDs []int) (*entity.User, error) {
-	tx, err := s.db.Begin()
+	current, err := s.getCurrentRoles(ctx, source)
 	if err != nil {
-		return source, errors.WithStack(err)
+		return source, err
+	}
+	periods := make(map[int]*entity.RoleAssignment, len(current))
+	for _, a := range current {
+		periods[a.RoleID] = a
 	}
-	current, err := s.getCurrentRoles(ctx, tx, source)
+	assignments := make([]*entity.RoleAssignment, len(roleIDs))
+	for i, roleID := range roleIDs {
+		if a, ok := periods[roleID]; ok {
+			assignments[i] = a
+			continue
+		}
+		assignments[i] = &entity.RoleAssignment{UserID: source.ID, RoleID: roleID}
+	}
+	return s.SyncRoleAssignments(ctx, source, assignments)
+}
+
+// SyncRoleAssignments sets the roles of a user together with the period of time they are granted for.
+// Only roles that are valid at the moment are granted, the roles daemon grants and removes the others
+// once their period starts or ends. New roles can only be assigned and existing assignments only be
+// extended if the authenticated user is granted all of their permissions, otherwise a
+// permission.EscalationError is returned.
+// nolint:govet
+func (s Store) SyncRoleAssignments(ctx context.Context, source *entity.User, assignments []*entity.RoleAssignment) (*entity.User, error) {
+	current, err := s.getCurrentRoles(ctx, source)
 	if err != nil {
-		return source, db.RollbackError(tx, errors.WithStack(err))
+		return source, err
+	}
+	assigned := make(map[int]*entity.RoleAssignment, len(current))
+	for _, a := range current {
+		assigned[a.RoleID] = a
+	}
+	var added []int
+	now := s.clock.Now()
+	for _, a := range assignments {
+		if a.Extends(assigned[a.RoleID], now) {
+			added = append(added, a.RoleID)
+		}
+	}
+	if err = permission.CheckRoleGrant(ctx, s.auth, added); err != nil {
+		return source, err
 	}
-	for _, roleID := range current {
-		s.auth.RemoveRoleForUser(source.ID, roleID)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return source, errors.WithStack(err)
 	}
 	_, err = tx.ExecContext(ctx, "DELETE FROM role_user WHERE user_id = ?", source.ID)
 	if err != nil {
 		return source, db.RollbackError(tx, errors.WithStack(err))
 	}
-	for _, roleID := range roleIDs {
-		query, params, err := sq.Insert("role_user").SetMap(db.ColumnMap{"user_id": source.ID, "role_id": roleID}).ToSql()
+	var active []int
+	for _, a := range assignments {
+		query, params, err := sq.Insert("role_user").SetMap(db.ColumnMap{
+			"user_id":     source.ID,
+			"role_id":     a.RoleID,
+			"valid_from":  a.ValidFrom,
+			"valid_until": a.ValidUntil,
+		}).ToSql()
 		if err != nil {
 			return source, db.RollbackError(tx, errors.WithStack(err))
 		}
@@ -254,30 +342,89 @@ func (s Store) SyncRoles(ctx context.Context, source *entity.User, roleIDs []int
 		if err != nil {
 			return source, db.RollbackError(tx, errors.WithStack(err))
 		}
-		s.auth.AddRoleForUser(source.ID, roleID)
+		if a.Active(s.clock.Now()) {
+			active = append(active, a.RoleID)
+		}
 	}
-	err = s.auditor.LogSync(ctx, tx, source, "roles", roleIDs, current)
+	err = s.auth.SetRolesForUserTx(ctx, tx, source.ID, active)
+	if err != nil {
+		return source, db.RollbackError(tx, err)
+	}
+	err = s.auditor.LogSync(ctx, tx, source, "roles", assignments, current)
 	if err != nil {
 		return source, db.RollbackError(tx, errors.WithStack(err))
 	}
-	return source, errors.WithStack(tx.Commit())
+	if err = tx.Commit(); err != nil {
+		return source, errors.WithStack(err)
+	}
+	if err = s.auth.Reload(); err != nil {
+		return source, err
+	}
+	s.publish(event.Updated(source, source, "roles", "role_assignments", "permissions"))
+	return source, nil
 }
 
-// getCurrentRoles returns all currently attached call type ids.
-func (s Store) getCurrentRoles(ctx context.Context, tx *db.Tx, source *entity.User) ([]int, error) {
-	type result struct {
-		RoleID int `json:"role_id"`
+// SyncPermissions sets the permissions that are granted or denied for a user instead of a role.
+// Granted levels include all lower levels, denied levels include all higher levels. A permission.EscalationError
+// is returned if the authenticated user adds a permission they are not granted themselves.
+func (s Store) SyncPermissions(ctx context.Context, source *entity.User, permissions []*entity.Permission) (*entity.User, error) {
+	policies := s.auth.UserPermissions(source.ID)
+	if err := permission.CheckGrant(ctx, s.auth, permission.Added(policies, permissions)); err != nil {
+		return source, err
+	}
+	var current []*entity.Permission
+	for _, policy := range policies {
+		current = append(current, entity.PermissionFromPolicy(policy))
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return source, errors.WithStack(err)
 	}
-	var r []*result
-	err := s.db.SelectContext(ctx, &r, "SELECT role_id FROM role_user WHERE user_id = ?", source.ID)
+	err = s.auditor.LogSync(ctx, tx, source, "permissions", permissions, current)
 	if err != nil {
-		return nil, db.RollbackError(tx, errors.WithStack(err))
+		return source, db.RollbackError(tx, errors.WithStack(err))
+	}
+	if err = tx.Commit(); err != nil {
+		return source, errors.WithStack(err)
 	}
-	var current []int
-	for _, res := range r {
-		current = append(current, res.RoleID)
+	s.auth.DeleteUserPermissions(source.ID)
+	for _, p := range permissions {
+		for _, action := range p.Actions() {
+			if p.Deny {
+				s.auth.DenyUser(source.ID, p.Code, action)
+			} else {
+				s.auth.AllowUser(source.ID, p.Code, action)
+			}
+		}
+	}
+	s.publish(event.Updated(source, source, "user_permissions", "permissions"))
+	return source, nil
+}
+
+// GetRoleIDs returns the ids of all roles attached to a user.
+func (s Store) GetRoleIDs(ctx context.Context, userID int) ([]int, error) {
+	var ids []int
+	err := s.db.SelectContext(ctx, &ids, "SELECT role_id FROM role_user WHERE user_id = ? ORDER BY role_id", userID)
+	return ids, errors.WithStack(err)
+}
+
+// getCurrentRoles returns all currently attached roles.
+func (s Store) getCurrentRoles(ctx context.Context, source *entity.User) ([]*entity.RoleAssignment, error) {
+	var current []*entity.RoleAssignment
+	err := s.db.SelectContext(
+		ctx,
+		&current,
+		"SELECT user_id, role_id, valid_from, valid_until FROM role_user WHERE user_id = ? ORDER BY role_id",
+		source.ID,
+	)
+	return current, errors.WithStack(err)
+}
+
+// publish sends a change to the subscribers if the store publishes events.
+func (s Store) publish(c *event.Change) {
+	if s.events != nil {
+		s.events.Publish(c)
 	}
-	return current, nil
 }
 
 // checkNotFound returns a ErrNotFound if no rows were returned.
@@ -1,21 +1,39 @@
 package user
 
 import (
+	"net/mail"
+
 	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/permission"
+	"go-webapp-example/internal/pkg/role"
 	"go-webapp-example/pkg/validation"
 )
 
+// permissionRegistry checks permissions against the codes declared by the modules.
+type permissionRegistry interface {
+	Check(p *entity.Permission) error
+}
+
+// passwordValidator checks new passwords against the password policy.
+type passwordValidator interface {
+	Validate(errs *validation.ErrorBag, field, password string)
+}
+
 // ValidateCreateRequest validates a create request of this entity.
-func ValidateCreateRequest(input *gqlmodels.UserInput) *validation.ErrorBag {
+func ValidateCreateRequest(input *gqlmodels.UserInput, policy passwordValidator, permissions permissionRegistry) *validation.ErrorBag {
 	errs := validation.NewErrorBag("user")
 
 	if input.Name == "" {
 		errs.Add("name", "required")
 	}
+	if !validEmail(input.Email) {
+		errs.Add("email", "email")
+	}
 	if input.Password == "" {
 		errs.Add("password", "required")
-	} else if len(input.Password) < 4 {
-		errs.AddData("password", "min", map[string]string{"size": "4"})
+	} else {
+		policy.Validate(errs, "password", input.Password)
 	}
 	if input.PasswordRepeat == "" {
 		errs.Add("password_repeat", "required")
@@ -24,11 +42,14 @@ func ValidateCreateRequest(input *gqlmodels.UserInput) *validation.ErrorBag {
 		errs.Add("password_repeat", "no_match")
 	}
 
+	permission.ValidateInput(errs, "permissions", input.Permissions, permissions)
+	role.ValidateAssignments(errs, "roles", input.Roles)
+
 	return errs
 }
 
 // ValidateUpdateRequest validates a create request of this entity.
-func ValidateUpdateRequest(input *gqlmodels.UserInput) *validation.ErrorBag {
+func ValidateUpdateRequest(input *gqlmodels.UserInput, policy passwordValidator, permissions permissionRegistry) *validation.ErrorBag {
 	errs := validation.NewErrorBag("user")
 
 	if input.ID == nil || *input.ID < 1 {
@@ -38,11 +59,12 @@ func ValidateUpdateRequest(input *gqlmodels.UserInput) *validation.ErrorBag {
 	if input.Name == "" {
 		errs.Add("name", "required")
 	}
+	if !validEmail(input.Email) {
+		errs.Add("email", "email")
+	}
 
 	if input.Password != "" {
-		if len(input.Password) < 4 {
-			errs.AddData("password", "min_length", map[string]string{"size": "4"})
-		}
+		policy.Validate(errs, "password", input.Password)
 		if input.PasswordRepeat == "" {
 			errs.Add("password_repeat", "required")
 		}
@@ -51,22 +73,51 @@ func ValidateUpdateRequest(input *gqlmodels.UserInput) *validation.ErrorBag {
 		}
 	}
 
+	permission.ValidateInput(errs, "permissions", input.Permissions, permissions)
+	role.ValidateAssignments(errs, "roles", input.Roles)
+
 	return errs
 }
 
-// ValidateAuthRequest validates a authentication request.
+// ValidateAuthRequest validates a authentication request. The password policy is not
+// checked, it only applies to new passwords.
 func ValidateAuthRequest(username, password string) *validation.ErrorBag {
 	errs := validation.NewErrorBag("user")
 
 	if username == "" {
 		errs.Add("username", "required")
 	}
-
 	if password == "" {
 		errs.Add("password", "required")
-	} else if len(password) < 4 {
-		errs.AddData("password", "min_length", map[string]string{"size": "4"})
 	}
 
 	return errs
 }
+
+// ValidatePasswordChangeRequest validates the new password of a user whose password expired.
+func ValidatePasswordChangeRequest(password, passwordRepeat string, policy passwordValidator) *validation.ErrorBag {
+	errs := validation.NewErrorBag("user")
+
+	if password == "" {
+		errs.Add("new_password", "required")
+	} else {
+		policy.Validate(errs, "new_password", password)
+	}
+	if passwordRepeat == "" {
+		errs.Add("password_repeat", "required")
+	}
+	if password != passwordRepeat {
+		errs.Add("password_repeat", "no_match")
+	}
+
+	return errs
+}
+
+// validEmail returns true if the optional email is empty or a plain email address.
+func validEmail(email *string) bool {
+	if email == nil || *email == "" {
+		return true
+	}
+	addr, err := mail.ParseAddress(*email)
+	return err == nil && addr.Address == *email
+}
@@ -4,10 +4,18 @@ import (
 	"testing"
 
 	"go-webapp-example/internal/graphql/gqlmodels"
+	"go-webapp-example/internal/pkg/passwordpolicy"
+	"go-webapp-example/internal/pkg/permission"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// testPolicy only requires the minimum length.
+var testPolicy, _ = passwordpolicy.NewPolicy(passwordpolicy.Config{MinLength: 4})
+
+// testRegistry contains the permissions of this module.
+var testRegistry, _ = permission.NewRegistry(Permissions)
+
 func TestValidateCreateRequest(t *testing.T) {
 	t.Run("Invalid", func(t *testing.T) {
 		input := gqlmodels.UserInput{
@@ -16,7 +24,7 @@ func TestValidateCreateRequest(t *testing.T) {
 			PasswordRepeat: "abc",
 		}
 
-		err := ValidateCreateRequest(&input)
+		err := ValidateCreateRequest(&input, testPolicy, testRegistry)
 
 		assert.True(t, err.Failed())
 		assert.Len(t, err.Get("name"), 1)
@@ -31,7 +39,7 @@ func TestValidateCreateRequest(t *testing.T) {
 			PasswordRepeat: "password",
 		}
 
-		err := ValidateCreateRequest(&input)
+		err := ValidateCreateRequest(&input, testPolicy, testRegistry)
 
 		assert.False(t, err.Failed())
 		assert.Len(t, err.Errors(), 0)
@@ -48,7 +56,7 @@ func TestValidateUpdateRequest(t *testing.T) {
 			PasswordRepeat: "tset",
 		}
 
-		err := ValidateUpdateRequest(&input)
+		err := ValidateUpdateRequest(&input, testPolicy, testRegistry)
 
 		assert.True(t, err.Failed())
 		assert.Len(t, err.Get("name"), 1)
@@ -64,12 +72,46 @@ func TestValidateUpdateRequest(t *testing.T) {
 			PasswordRepeat: "password",
 		}
 
-		err := ValidateUpdateRequest(&input)
+		err := ValidateUpdateRequest(&input, testPolicy, testRegistry)
 
 		assert.False(t, err.Failed())
 		assert.Len(t, err.Errors(), 0)
 	})
 
+	t.Run("Invalid Email", func(t *testing.T) {
+		email := "Admin <admin@example.com>"
+		input := gqlmodels.UserInput{
+			ID:    &id,
+			Name:  "user",
+			Email: &email,
+		}
+
+		err := ValidateUpdateRequest(&input, testPolicy, testRegistry)
+
+		assert.True(t, err.Failed())
+		assert.Len(t, err.Get("email"), 1)
+	})
+
+	t.Run("Invalid Permissions", func(t *testing.T) {
+		deny, own := true, true
+		input := gqlmodels.UserInput{
+			ID:   &id,
+			Name: "user",
+			Permissions: []*gqlmodels.PermissionInput{
+				{Code: "admin.user", Level: "read", Deny: &deny},
+				{Code: "admin.user", Level: "write", Deny: &deny, Own: &own},
+				{Code: "admin.unknown", Level: "read"},
+			},
+		}
+
+		err := ValidateUpdateRequest(&input, testPolicy, testRegistry)
+
+		if assert.Len(t, err.Get("permissions"), 2) {
+			assert.Equal(t, "validation.permission_deny_own", err.Get("permissions")[0].Message)
+			assert.Equal(t, "validation.permission_unknown", err.Get("permissions")[1].Message)
+		}
+	})
+
 	t.Run("Valid without Password", func(t *testing.T) {
 		input := gqlmodels.UserInput{
 			ID:             &id,
@@ -78,9 +120,23 @@ func TestValidateUpdateRequest(t *testing.T) {
 			PasswordRepeat: "",
 		}
 
-		err := ValidateUpdateRequest(&input)
+		err := ValidateUpdateRequest(&input, testPolicy, testRegistry)
 
 		assert.False(t, err.Failed())
 		assert.Len(t, err.Errors(), 0)
 	})
 }
+
+func TestValidateAuthRequest(t *testing.T) {
+	// The password policy only applies to new passwords.
+	assert.False(t, ValidateAuthRequest("admin", "abc").Failed())
+	assert.True(t, ValidateAuthRequest("admin", "").Failed())
+}
+
+func TestValidatePasswordChangeRequest(t *testing.T) {
+	err := ValidatePasswordChangeRequest("abc", "abd", testPolicy)
+	assert.Len(t, err.Get("new_password"), 1)
+	assert.Len(t, err.Get("password_repeat"), 1)
+
+	assert.False(t, ValidatePasswordChangeRequest("changed", "changed", testPolicy).Failed())
+}
@@ -0,0 +1,26 @@
+package usersession
+
+import (
+	"context"
+
+	"go-webapp-example/internal/pkg/entity"
+)
+
+// ctxKey is used to store the session of the current request in a context.
+var ctxKey = &contextKey{"session"}
+
+type contextKey struct {
+	name string
+}
+
+// WithContext returns a copy of ctx that carries the session.
+func WithContext(ctx context.Context, s *entity.Session) context.Context {
+	return context.WithValue(ctx, ctxKey, s)
+}
+
+// FromContext returns the session the current request was authenticated with.
+// It returns false for requests that are not authenticated by a session.
+func FromContext(ctx context.Context) (*entity.Session, bool) {
+	s, ok := ctx.Value(ctxKey).(*entity.Session)
+	return s, ok
+}
@@ -0,0 +1,8 @@
+package usersession
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when a requested session could not be found.
+var ErrNotFound = errors.New("session not found")
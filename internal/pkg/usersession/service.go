@@ -0,0 +1,125 @@
+package usersession
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// keyBytes is the number of random bytes in a session key.
+	keyBytes = 32
+	// touchInterval is the minimum time between two updates of the last usage time.
+	touchInterval = time.Minute
+	// maxUserAgentLength is the size of the user_agent column.
+	maxUserAgentLength = 255
+)
+
+// Service is used to interact with the entity. It
+// allows access to the store by embedding it.
+type Service struct {
+	*Store
+}
+
+// NewService returns a pointer to a new Service.
+func NewService(store *Store) *Service {
+	return &Service{
+		Store: store,
+	}
+}
+
+// Start registers a new session of a user. The key of the returned
+// session has to be stored in the session data.
+func (s Service) Start(ctx context.Context, userID int, ip, userAgent string) (*entity.Session, error) {
+	key, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return s.Store.Create(ctx, &entity.Session{
+		UserID:    userID,
+		Key:       key,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+}
+
+// Authenticate returns the session with the given key if it belongs to the user and was not
+// revoked. The last usage time is updated at most once per touchInterval.
+func (s Service) Authenticate(ctx context.Context, key string, userID int) (*entity.Session, error) {
+	if key == "" {
+		return nil, ErrNotFound
+	}
+	sess, err := s.Store.FindByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if sess.UserID != userID {
+		return nil, ErrNotFound
+	}
+	now := s.clock.Now()
+	if !sess.CreatedAt.Time.After(now.Add(-session.Lifetime)) {
+		return nil, ErrNotFound
+	}
+	if !sess.LastSeenAt.Valid || now.Sub(sess.LastSeenAt.Time) >= touchInterval {
+		if err = s.Store.Touch(ctx, sess); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return sess, nil
+}
+
+// Revoke ends a session. The user has to log in again on the next request.
+func (s Service) Revoke(ctx context.Context, sess *entity.Session) error {
+	return s.Store.Delete(ctx, sess)
+}
+
+// RevokeAll ends all sessions of a user.
+func (s Service) RevokeAll(ctx context.Context, userID int) ([]*entity.Session, error) {
+	return s.revoke(ctx, userID, "")
+}
+
+// RevokeOthers ends all sessions of a user except the session of the current request.
+func (s Service) RevokeOthers(ctx context.Context, userID int) error {
+	var keep string
+	if current, ok := FromContext(ctx); ok {
+		keep = current.Key
+	}
+	_, err := s.revoke(ctx, userID, keep)
+	return err
+}
+
+// revoke ends all sessions of a user except the one with the key keep.
+func (s Service) revoke(ctx context.Context, userID int, keep string) ([]*entity.Session, error) {
+	sessions, err := s.Store.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var revoked []*entity.Session
+	for _, sess := range sessions {
+		if keep == "" || sess.Key != keep {
+			revoked = append(revoked, sess)
+		}
+	}
+	if len(revoked) == 0 {
+		return revoked, nil
+	}
+	return revoked, s.Store.Delete(ctx, revoked...)
+}
+
+// generateKey returns a new random session key.
+func generateKey() (string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate session key")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,166 @@
+package usersession
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/session"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// now is used as time for all test cases.
+var now = time.Now()
+
+var cols = []string{"id", "user_id", "key", "ip", "user_agent", "last_seen_at", "created_at"}
+
+type setupFn func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor)
+
+// TestSessionService tests all service methods as well as the underlying store.
+func TestSessionService(t *testing.T) {
+	setup := func() (sqlmock.Sqlmock, *Service, *audit.MockAuditor) {
+		conn, mockDB := test.MockDB(t)
+		auditor := audit.NewMockAuditor()
+		service := NewService(NewStore(conn, auditor, func(store *Store) {
+			store.clock = clock.FromTime(now)
+		}))
+		return mockDB, service, auditor
+	}
+
+	t.Run("Start", start(setup))
+	t.Run("Authenticate", authenticate(setup))
+	t.Run("AuthenticateRecent", authenticateRecent(setup))
+	t.Run("AuthenticateRevoked", authenticateRevoked(setup))
+	t.Run("AuthenticateOtherUser", authenticateOtherUser(setup))
+	t.Run("RevokeOthers", revokeOthers(setup))
+}
+
+func start(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM user_sessions WHERE user_id = . AND").
+			WithArgs(1, now.Add(-session.IdleTimeout), now.Add(-session.Lifetime)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectExec("INSERT INTO user_sessions").
+			WithArgs(1, sqlmock.AnyArg(), "127.0.0.1", "Browser", now, now, now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		s, err := service.Start(context.Background(), 1, "127.0.0.1", "Browser")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, 1, s.ID)
+		assert.Len(t, s.Key, 2*keyBytes)
+	}
+}
+
+func expectFindByKey(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.
+		ExpectQuery("SELECT .+ FROM user_sessions WHERE `key` = . LIMIT 1").
+		WithArgs("key").
+		WillReturnRows(rows)
+}
+
+func authenticate(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFindByKey(mock, sqlmock.NewRows(cols).AddRow(1, 1, "key", "", "", now.Add(-time.Hour), now.Add(-time.Hour)))
+		mock.
+			ExpectExec("UPDATE user_sessions SET last_seen_at = . WHERE id = .").
+			WithArgs(now, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		s, err := service.Authenticate(context.Background(), "key", 1)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, now, s.LastSeenAt.Time)
+	}
+}
+
+func authenticateRecent(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		// The last usage time is not updated on every request.
+		expectFindByKey(mock, sqlmock.NewRows(cols).AddRow(1, 1, "key", "", "", now.Add(-time.Second), now.Add(-time.Hour)))
+
+		_, err := service.Authenticate(context.Background(), "key", 1)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func authenticateRevoked(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		mock.
+			ExpectQuery("SELECT .+ FROM user_sessions WHERE `key` = . LIMIT 1").
+			WithArgs("key").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := service.Authenticate(context.Background(), "key", 1)
+
+		assert.Equal(t, ErrNotFound, errors.Cause(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		// Sessions created before sessions were tracked have no key.
+		_, err = service.Authenticate(context.Background(), "", 1)
+		assert.Equal(t, ErrNotFound, err)
+	}
+}
+
+func authenticateOtherUser(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, _ := setup()
+
+		expectFindByKey(mock, sqlmock.NewRows(cols).AddRow(1, 2, "key", "", "", now, now))
+
+		_, err := service.Authenticate(context.Background(), "key", 1)
+
+		assert.Equal(t, ErrNotFound, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func revokeOthers(setup setupFn) func(t *testing.T) {
+	return func(t *testing.T) {
+		mock, service, auditor := setup()
+
+		mock.
+			ExpectQuery("SELECT .+ FROM user_sessions WHERE user_id = .").
+			WithArgs(1, now.Add(-session.IdleTimeout), now.Add(-session.Lifetime)).
+			WillReturnRows(sqlmock.NewRows(cols).
+				AddRow(1, 1, "current", "", "", now, now).
+				AddRow(2, 1, "other", "", "", now, now))
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM user_sessions WHERE id = . LIMIT 1").
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ctx := WithContext(context.Background(), &entity.Session{ID: 1, Key: "current"})
+		err := service.RevokeOthers(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, auditor.Deleted, 1)
+	}
+}
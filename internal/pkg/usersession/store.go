@@ -0,0 +1,149 @@
+package usersession
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-webapp-example/internal/pkg/audit"
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/pkg/clock"
+	"go-webapp-example/pkg/db"
+	"go-webapp-example/pkg/session"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Store handles the direct database access for this entity.
+type Store struct {
+	db      *db.Connection
+	auditor audit.ChangeAuditor
+	clock   *clock.Clock
+}
+
+// NewStore returns a new store instance.
+func NewStore(conn *db.Connection, auditor audit.ChangeAuditor, opts ...func(s *Store)) *Store {
+	s := &Store{db: conn, auditor: auditor}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Find finds the entity by id.
+func (s Store) Find(ctx context.Context, id int) (*entity.Session, error) {
+	var sess entity.Session
+	err := s.db.GetContext(ctx, &sess, "SELECT * FROM user_sessions WHERE id = ? LIMIT 1", id)
+	return &sess, errors.WithStack(checkNotFound(err))
+}
+
+// FindByKey finds the entity by its key.
+func (s Store) FindByKey(ctx context.Context, key string) (*entity.Session, error) {
+	var sess entity.Session
+	err := s.db.GetContext(ctx, &sess, "SELECT * FROM user_sessions WHERE `key` = ? LIMIT 1", key)
+	return &sess, errors.WithStack(checkNotFound(err))
+}
+
+// GetByUserID returns all active sessions of a user, the most recently used first.
+func (s Store) GetByUserID(ctx context.Context, userID int) ([]*entity.Session, error) {
+	var sessions []*entity.Session
+	lastSeen, created := s.activeSince()
+	err := s.db.SelectContext(
+		ctx,
+		&sessions,
+		"SELECT * FROM user_sessions WHERE user_id = ? AND last_seen_at > ? AND created_at > ? ORDER BY last_seen_at DESC, id DESC",
+		userID,
+		lastSeen,
+		created,
+	)
+	return sessions, errors.WithStack(err)
+}
+
+// Create creates a new entity. Expired sessions of the same user are removed.
+func (s Store) Create(ctx context.Context, sess *entity.Session) (*entity.Session, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return sess, errors.WithStack(err)
+	}
+	lastSeen, created := s.activeSince()
+	_, err = tx.ExecContext(
+		ctx,
+		"DELETE FROM user_sessions WHERE user_id = ? AND (last_seen_at <= ? OR created_at <= ?)",
+		sess.UserID,
+		lastSeen,
+		created,
+	)
+	if err != nil {
+		return sess, db.RollbackError(tx, errors.WithStack(err))
+	}
+	sess.LastSeenAt = null.TimeFrom(s.clock.Now())
+	sess.CreatedAt = null.TimeFrom(s.clock.Now())
+	sess.UpdatedAt = null.TimeFrom(s.clock.Now())
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO user_sessions (user_id, `key`, ip, user_agent, last_seen_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		sess.UserID,
+		sess.Key,
+		sess.IP,
+		sess.UserAgent,
+		sess.LastSeenAt,
+		sess.CreatedAt,
+		sess.UpdatedAt,
+	)
+	if err != nil {
+		return sess, db.RollbackError(tx, errors.WithStack(err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return sess, db.RollbackError(tx, errors.WithStack(err))
+	}
+	sess.ID = int(id)
+	return sess, errors.WithStack(tx.Commit())
+}
+
+// Delete removes entities from the database.
+func (s Store) Delete(ctx context.Context, sessions ...*entity.Session) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, sess := range sessions {
+		_, err = tx.ExecContext(ctx, "DELETE FROM user_sessions WHERE id = ? LIMIT 1", sess.ID)
+		if err != nil {
+			return db.RollbackError(tx, errors.WithStack(err))
+		}
+		err = s.auditor.LogDelete(ctx, tx, sess)
+		if err != nil {
+			return db.RollbackError(tx, errors.WithStack(err))
+		}
+	}
+	return errors.WithStack(tx.Commit())
+}
+
+// DeleteByKey removes the entity with the given key without an audit log entry. It is used
+// if a user ends the own session.
+func (s Store) DeleteByKey(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_sessions WHERE `key` = ? LIMIT 1", key)
+	return errors.WithStack(err)
+}
+
+// Touch sets the last usage time of a session.
+func (s Store) Touch(ctx context.Context, sess *entity.Session) error {
+	sess.LastSeenAt = null.TimeFrom(s.clock.Now())
+	_, err := s.db.ExecContext(ctx, "UPDATE user_sessions SET last_seen_at = ? WHERE id = ?", sess.LastSeenAt, sess.ID)
+	return errors.WithStack(err)
+}
+
+// activeSince returns the minimum last usage and creation time of a session that has not expired yet.
+func (s Store) activeSince() (lastSeen, created time.Time) {
+	now := s.clock.Now()
+	return now.Add(-session.IdleTimeout), now.Add(-session.Lifetime)
+}
+
+// checkNotFound returns a ErrNotFound if no rows were returned.
+func checkNotFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
@@ -2,16 +2,27 @@ package auth
 
 import (
 	"fmt"
+	"sync"
 
 	"go-webapp-example/pkg/db"
 	"go-webapp-example/pkg/log"
 
+	"github.com/Knetic/govaluate"
 	casbinsqlx "github.com/OFFLINE-GmbH/casbin-sqlx-adapter"
 	"github.com/casbin/casbin"
 	casbinlog "github.com/casbin/casbin/log"
+	"github.com/casbin/casbin/model"
+	"github.com/casbin/casbin/util"
+	"github.com/pkg/errors"
 )
 
+// OwnSuffix is appended to an action to limit it to records owned by the subject.
+const OwnSuffix = ":own"
+
 type Manager struct {
+	// mu guards the enforcer. The SyncedEnforcer of casbin v1 leaves most of the RBAC api
+	// unsynchronized, so the manager locks the enforcer itself for every call.
+	mu       sync.RWMutex
 	enforcer *casbin.Enforcer
 	logger   log.Logger
 }
@@ -36,16 +47,17 @@ CREATE TABLE IF NOT EXISTS policies
 // New returns a new instance of an auth manager.
 func New(conn *db.Connection, logger log.Logger) (*Manager, error) {
 	m := casbin.NewModel()
-	// request_definition
-	m.AddDef("r", "r", "sub, obj, act")
+	// request_definition, own contains the owner of the requested record if there is one.
+	m.AddDef("r", "r", "sub, obj, act, own")
 	// policy_definition
 	m.AddDef("p", "p", "sub, obj, act, eft")
 	// role_definition
 	m.AddDef("g", "g", "_, _")
 	// policy_effect
 	m.AddDef("e", "e", "some(where (p.eft == allow)) && !some(where (p.eft == deny))")
-	// matchers
-	m.AddDef("m", "m", "g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act")
+	// matchers, an action with the own suffix only grants access to records owned by the subject.
+	// Denied actions deny the same action on own records as well.
+	m.AddDef("m", "m", `g(r.sub, p.sub) && r.obj == p.obj && (r.act == p.act || r.own == r.sub && r.act + "`+OwnSuffix+`" == p.act || p.eft == "deny" && r.act == p.act + "`+OwnSuffix+`")`)
 
 	_, err := conn.DB.Exec(createPolicyTable)
 	if err != nil {
@@ -71,8 +83,20 @@ func New(conn *db.Connection, logger log.Logger) (*Manager, error) {
 
 // Can check if a user has the permission to execute a certain action on a subject.
 func (a *Manager) Can(userID int, subject, action string) bool {
+	return a.enforce(userID, subject, action, "")
+}
+
+// CanOwn checks if a user has the permission to execute a certain action on a record
+// of the owner. Actions limited to own records are granted if the user is the owner.
+func (a *Manager) CanOwn(userID, ownerID int, subject, action string) bool {
+	return a.enforce(userID, subject, action, userIdentifier(ownerID))
+}
+
+func (a *Manager) enforce(userID int, subject, action, owner string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	id := userIdentifier(userID)
-	if !a.enforcer.Enforce(id, subject, action) {
+	if !a.enforcer.Enforce(id, subject, action, owner) {
 		a.logger.Debugf("%+v\n", a.enforcer.GetPolicy())
 		a.logger.Infof("permission denied to %s for %s.%s\n", id, subject, action)
 		return false
@@ -82,30 +106,274 @@ func (a *Manager) Can(userID int, subject, action string) bool {
 	return true
 }
 
+// PolicyMatch is a policy that matched a permission check.
+type PolicyMatch struct {
+	// Policy contains the subject, object, action and effect of the policy.
+	Policy []string
+	// Roles contains the ids of the roles that link the user to the subject of the policy,
+	// starting with a role that is assigned to the user. It is empty for policies of the user.
+	Roles []int
+}
+
+// Explain checks a permission like Can and returns all policies that matched the request.
+//
+// It serves the same purpose as EnforceEx of casbin v2, which is not available in casbin v1.
+// Every policy is evaluated with the matcher of the model, so the matches are the policies
+// the enforcer based its verdict on.
+func (a *Manager) Explain(userID int, subject, action string) (bool, []PolicyMatch) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	id := userIdentifier(userID)
+	request := []interface{}{id, subject, action, ""}
+	allowed := a.enforcer.Enforce(request...)
+
+	matcher, err := newPolicyMatcher(a.enforcer.GetModel())
+	if err != nil {
+		a.logger.Errorf("failed to explain permission: %s", err)
+		return allowed, nil
+	}
+	var matches []PolicyMatch
+	for _, policy := range a.enforcer.GetPolicy() {
+		ok, err := matcher.match(request, policy)
+		if err != nil {
+			a.logger.Errorf("failed to match policy %v: %s", policy, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		roles, _ := a.roleChain(id, policy[0])
+		matches = append(matches, PolicyMatch{Policy: policy, Roles: roles})
+	}
+	return allowed, matches
+}
+
+// roleChain returns the shortest chain of role ids that links a subject to another one.
+func (a *Manager) roleChain(from, to string) ([]int, bool) {
+	if from == to {
+		return nil, true
+	}
+	links := make(map[string][]string)
+	for _, g := range a.enforcer.GetGroupingPolicy() {
+		links[g[0]] = append(links[g[0]], g[1])
+	}
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range links[current] {
+			if _, seen := previous[next]; seen {
+				continue
+			}
+			previous[next] = current
+			if next != to {
+				queue = append(queue, next)
+				continue
+			}
+			var roles []int
+			for s := next; s != from; s = previous[s] {
+				var id int
+				if _, err := fmt.Sscanf(s, "role-%d", &id); err == nil {
+					roles = append([]int{id}, roles...)
+				}
+			}
+			return roles, true
+		}
+	}
+	return nil, false
+}
+
+// policyMatcher evaluates the matcher of a model for a single policy, the same way the enforcer
+// does it for every policy before it merges their effects.
+type policyMatcher struct {
+	expression *govaluate.EvaluableExpression
+	rTokens    map[string]int
+	pTokens    map[string]int
+}
+
+func newPolicyMatcher(m model.Model) (*policyMatcher, error) {
+	functions := make(map[string]govaluate.ExpressionFunction)
+	for key, function := range model.LoadFunctionMap() {
+		functions[key] = function
+	}
+	for key, ast := range m["g"] {
+		functions[key] = util.GenerateGFunction(ast.RM)
+	}
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(m["m"]["m"].Value, functions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pm := &policyMatcher{
+		expression: expression,
+		rTokens:    make(map[string]int),
+		pTokens:    make(map[string]int),
+	}
+	for i, token := range m["r"]["r"].Tokens {
+		pm.rTokens[token] = i
+	}
+	for i, token := range m["p"]["p"].Tokens {
+		pm.pTokens[token] = i
+	}
+	return pm, nil
+}
+
+// match returns true if the matcher is satisfied by the request and the policy.
+func (pm *policyMatcher) match(request []interface{}, policy []string) (bool, error) {
+	result, err := pm.expression.Eval(matcherParameters{matcher: pm, request: request, policy: policy})
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	switch result := result.(type) {
+	case bool:
+		return result, nil
+	case float64:
+		return result != 0, nil
+	default:
+		return false, errors.Errorf("matcher returned %T instead of bool or float", result)
+	}
+}
+
+// matcherParameters resolves the request and policy tokens of a matcher expression.
+type matcherParameters struct {
+	matcher *policyMatcher
+	request []interface{}
+	policy  []string
+}
+
+func (p matcherParameters) Get(name string) (interface{}, error) {
+	if i, ok := p.matcher.rTokens[name]; ok && i < len(p.request) {
+		return p.request[i], nil
+	}
+	if i, ok := p.matcher.pTokens[name]; ok && i < len(p.policy) {
+		return p.policy[i], nil
+	}
+	return nil, errors.Errorf("no parameter %q found", name)
+}
+
 func (a *Manager) AllowUser(userID int, subject, action string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.enforcer.AddPermissionForUser(userIdentifier(userID), subject, action, "allow")
 }
 
+// DenyUser denies an action for a user even if one of the user's roles allows it.
+func (a *Manager) DenyUser(userID int, subject, action string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enforcer.AddPermissionForUser(userIdentifier(userID), subject, action, "deny")
+}
+
+// DeleteUserPermissions removes all permissions that were allowed or denied for a user directly.
+func (a *Manager) DeleteUserPermissions(userID int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enforcer.RemoveFilteredPolicy(0, userIdentifier(userID))
+}
+
+// UserPermissions returns the permissions that were allowed or denied for a user directly.
+func (a *Manager) UserPermissions(userID int) [][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.enforcer.GetFilteredPolicy(0, userIdentifier(userID))
+}
+
 func (a *Manager) AddRoleForUser(userID, roleID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.enforcer.AddRoleForUser(userIdentifier(userID), roleIdentifier(roleID))
 }
 func (a *Manager) RemoveRoleForUser(userID, roleID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.enforcer.DeleteRoleForUser(userIdentifier(userID), roleIdentifier(roleID))
 }
 func (a *Manager) AddRolePermission(roleID int, subject, action string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.enforcer.AddPolicy(roleIdentifier(roleID), subject, action, "allow")
 }
+
+// DenyRolePermission denies an action for all users of a role even if another role allows it.
+func (a *Manager) DenyRolePermission(roleID int, subject, action string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enforcer.AddPolicy(roleIdentifier(roleID), subject, action, "deny")
+}
 func (a *Manager) DeleteRole(roleID int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.enforcer.DeleteRole(roleIdentifier(roleID))
+	a.enforcer.RemoveFilteredGroupingPolicy(0, roleIdentifier(roleID))
+}
+
+// DeleteRolePermissions removes all permissions of a role but keeps its users and parents.
+func (a *Manager) DeleteRolePermissions(roleID int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enforcer.RemoveFilteredPolicy(0, roleIdentifier(roleID))
+}
+
+// AddRoleParent lets a role inherit all permissions of the parent role.
+func (a *Manager) AddRoleParent(roleID, parentID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enforcer.AddGroupingPolicy(roleIdentifier(roleID), roleIdentifier(parentID))
+}
+
+// RemoveRoleParents removes all parents of a role.
+func (a *Manager) RemoveRoleParents(roleID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enforcer.RemoveFilteredGroupingPolicy(0, roleIdentifier(roleID))
 }
 func (a *Manager) PermissionsForUser(userID int) [][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.enforcer.GetImplicitPermissionsForUser(userIdentifier(userID))
 }
 func (a *Manager) PermissionsForRole(roleID int) [][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.enforcer.GetFilteredPolicy(0, roleIdentifier(roleID))
 }
 
+// InheritedPermissionsForRole returns the permissions a role gets from all of its parents.
+func (a *Manager) InheritedPermissionsForRole(roleID int) [][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	id := roleIdentifier(roleID)
+	var inherited [][]string
+	for _, perm := range a.enforcer.GetImplicitPermissionsForUser(id) {
+		if perm[0] != id {
+			inherited = append(inherited, perm)
+		}
+	}
+	return inherited
+}
+
+// UsersForRole returns the ids of all users that were assigned the role directly.
+func (a *Manager) UsersForRole(roleID int) []int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	users, err := a.enforcer.GetUsersForRole(roleIdentifier(roleID))
+	if err != nil {
+		a.logger.Errorf("cannot get users for role %d: %s", roleID, err)
+		return nil
+	}
+	var ids []int
+	for _, u := range users {
+		var id int
+		if _, err := fmt.Sscanf(u, "user-%d", &id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 func (a *Manager) HasRole(userID, roleID int) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	has, err := a.enforcer.HasRoleForUser(userIdentifier(userID), roleIdentifier(roleID))
 	if err != nil {
 		a.logger.Errorf("cannot check role %s for user %d", roleID, userID)
@@ -0,0 +1,138 @@
+package auth_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/auth"
+	"go-webapp-example/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestManager_Explain checks that the policies returned by Explain lead to the verdict of
+// the enforcer for every combination of allowed, denied, own and inherited permissions.
+// nolint:funlen
+func TestManager_Explain(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	conn, cleanup := test.DB(t)
+	defer cleanup()
+
+	m, err := auth.New(conn, log.NewNullLogger())
+	require.NoError(t, err)
+
+	const (
+		user, other               = 1000, 1001
+		role, parent, grandparent = 1000, 1001, 1002
+	)
+	m.AddRoleForUser(user, role)
+	m.AddRoleParent(role, parent)
+	m.AddRoleParent(parent, grandparent)
+
+	// Allowed by the user, a role and an inherited role.
+	m.AllowUser(user, "allow.user", "read")
+	m.AddRolePermission(role, "allow.role", "read")
+	m.AddRolePermission(grandparent, "allow.inherited", "read")
+	// Denied by the user or a role while another one allows it.
+	m.AllowUser(user, "deny.role", "read")
+	m.DenyRolePermission(role, "deny.role", "read")
+	m.AddRolePermission(role, "deny.user", "read")
+	m.DenyUser(user, "deny.user", "read")
+	m.AddRolePermission(role, "deny.inherited", "read")
+	m.DenyRolePermission(parent, "deny.inherited", "read")
+	// Limited to own records and denied on own records.
+	m.AllowUser(user, "own.user", "read"+auth.OwnSuffix)
+	m.AddRolePermission(parent, "own.inherited", "read"+auth.OwnSuffix)
+	m.AddRolePermission(role, "own.denied", "read"+auth.OwnSuffix)
+	m.DenyRolePermission(grandparent, "own.denied", "read")
+	m.AllowUser(other, "allow.user", "read")
+
+	subjects := []string{
+		"allow.user", "allow.role", "allow.inherited",
+		"deny.role", "deny.user", "deny.inherited",
+		"own.user", "own.inherited", "own.denied", "unknown",
+	}
+	actions := []string{"read", "write", "read" + auth.OwnSuffix}
+	for _, userID := range []int{user, other} {
+		for _, subject := range subjects {
+			for _, action := range actions {
+				name := fmt.Sprintf("%d %s %s", userID, subject, action)
+				allowed, matches := m.Explain(userID, subject, action)
+				assert.Equal(t, m.Can(userID, subject, action), allowed, name)
+
+				var allow, deny bool
+				for _, match := range matches {
+					assert.Equal(t, subject, match.Policy[1], name)
+					allow = allow || match.Policy[3] == "allow"
+					deny = deny || match.Policy[3] == "deny"
+				}
+				assert.Equal(t, allow && !deny, allowed, name)
+			}
+		}
+	}
+
+	allowed, matches := m.Explain(user, "deny.inherited", "read")
+	assert.False(t, allowed)
+	require.Len(t, matches, 2)
+	roles := map[string][]int{}
+	for _, match := range matches {
+		roles[match.Policy[3]] = match.Roles
+	}
+	assert.Equal(t, map[string][]int{"allow": {role}, "deny": {role, parent}}, roles)
+
+	allowed, matches = m.Explain(user, "own.denied", "read"+auth.OwnSuffix)
+	assert.False(t, allowed)
+	require.Len(t, matches, 2)
+
+	allowed, matches = m.Explain(user, "allow.inherited", "read")
+	assert.True(t, allowed)
+	require.Len(t, matches, 1)
+	assert.Equal(t, []int{role, parent, grandparent}, matches[0].Roles)
+}
+
+// TestManager_ReloadConcurrent checks permissions while the policies are reloaded,
+// a check must never see the enforcer without its policies.
+func TestManager_ReloadConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	conn, cleanup := test.DB(t)
+	defer cleanup()
+
+	m, err := auth.New(conn, log.NewNullLogger())
+	require.NoError(t, err)
+	m.AddRoleForUser(1000, 1000)
+	m.AddRolePermission(1000, "admin.quote", "read")
+
+	var wg sync.WaitGroup
+	denied := make(chan struct{}, 1)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, m.Reload())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if !m.Can(1000, "admin.quote", "read") {
+					select {
+					case denied <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, denied)
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-webapp-example/pkg/db"
+
+	"github.com/pkg/errors"
+)
+
+// Assignment is a role that is assigned to a user.
+type Assignment struct {
+	UserID int
+	RoleID int
+}
+
+// SetRolesForUserTx replaces all roles that are assigned to a user.
+func (a *Manager) SetRolesForUserTx(ctx context.Context, tx *db.Tx, userID int, roleIDs []int) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM policies WHERE p_type = 'g' AND v0 = ? AND v1 LIKE 'role-%'", userIdentifier(userID))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	assignments := make([]Assignment, len(roleIDs))
+	for i, roleID := range roleIDs {
+		assignments[i] = Assignment{UserID: userID, RoleID: roleID}
+	}
+	return a.AddAssignmentsTx(ctx, tx, assignments)
+}
+
+// SetUsersForRoleTx replaces all users a role is assigned to.
+func (a *Manager) SetUsersForRoleTx(ctx context.Context, tx *db.Tx, roleID int, userIDs []int) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM policies WHERE p_type = 'g' AND v0 LIKE 'user-%' AND v1 = ?", roleIdentifier(roleID))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	assignments := make([]Assignment, len(userIDs))
+	for i, userID := range userIDs {
+		assignments[i] = Assignment{UserID: userID, RoleID: roleID}
+	}
+	return a.AddAssignmentsTx(ctx, tx, assignments)
+}
+
+// AssignmentsTx returns all role assignments that are stored in the policy table.
+func (a *Manager) AssignmentsTx(ctx context.Context, tx *db.Tx) ([]Assignment, error) {
+	var rows []struct {
+		V0 string `db:"v0"`
+		V1 string `db:"v1"`
+	}
+	err := tx.SelectContext(ctx, &rows, "SELECT v0, v1 FROM policies WHERE p_type = 'g' AND v0 LIKE 'user-%' AND v1 LIKE 'role-%'")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	assignments := make([]Assignment, 0, len(rows))
+	for _, row := range rows {
+		var as Assignment
+		if _, err := fmt.Sscanf(row.V0, "user-%d", &as.UserID); err != nil {
+			continue
+		}
+		if _, err := fmt.Sscanf(row.V1, "role-%d", &as.RoleID); err != nil {
+			continue
+		}
+		assignments = append(assignments, as)
+	}
+	return assignments, nil
+}
+
+// AddAssignmentsTx adds role assignments to the policy table.
+func (a *Manager) AddAssignmentsTx(ctx context.Context, tx *db.Tx, assignments []Assignment) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+	values := make([]string, len(assignments))
+	params := make([]interface{}, 0, len(assignments)*2)
+	for i, as := range assignments {
+		values[i] = "('g', ?, ?)"
+		params = append(params, userIdentifier(as.UserID), roleIdentifier(as.RoleID))
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO policies (p_type, v0, v1) VALUES "+strings.Join(values, ", "), params...)
+	return errors.WithStack(err)
+}
+
+// RemoveAssignmentsTx removes role assignments from the policy table.
+func (a *Manager) RemoveAssignmentsTx(ctx context.Context, tx *db.Tx, assignments []Assignment) error {
+	for _, as := range assignments {
+		_, err := tx.ExecContext(
+			ctx,
+			"DELETE FROM policies WHERE p_type = 'g' AND v0 = ? AND v1 = ?",
+			userIdentifier(as.UserID),
+			roleIdentifier(as.RoleID),
+		)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Reload loads all policies from the database into the enforcer. The methods of this file write to
+// the policy table inside of a transaction, the enforcer only sees their changes after Reload was
+// called once the transaction was committed. Permission checks wait until the policies are loaded.
+func (a *Manager) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return errors.WithStack(a.enforcer.LoadPolicy())
+}
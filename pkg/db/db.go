@@ -43,6 +43,8 @@ type Row struct {
 type Tx struct {
 	*sqlx.Tx
 	Log log.Logger
+	// afterCommit holds the functions that run once the transaction is committed.
+	afterCommit []func()
 }
 
 type Result struct {
@@ -206,8 +208,18 @@ func (tx *Tx) Commit() error {
 	err := tx.Tx.Commit()
 	if err != nil {
 		defer logErrorWithArgs(tx.Log, "ROLLBACK", nil, err)
+		return err
 	}
-	return err
+	for _, fn := range tx.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit registers a function that runs once the transaction is committed successfully.
+// It is not run if the transaction is rolled back.
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.afterCommit = append(tx.afterCommit, fn)
 }
 
 // logQueryWithArgs times and logs a executed query with arguments.
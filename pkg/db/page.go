@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPageSize is the number of rows of a page if neither first nor last is set.
+	DefaultPageSize = 20
+	// MaxPageSize is the largest number of rows a page can contain.
+	MaxPageSize = 100
+)
+
+var (
+	// ErrInvalidCursor is returned for cursors that were not created by EncodeCursor or point to a deleted row.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidPage is returned if the arguments of a page cannot be combined or are out of range.
+	ErrInvalidPage = errors.New("invalid page arguments")
+	// ErrInvalidSort is returned if a list cannot be sorted by a column.
+	ErrInvalidSort = errors.New("invalid sort column")
+)
+
+// cursorPrefix is prepended to the id of a row before it is encoded as cursor.
+const cursorPrefix = "cursor:"
+
+// Sort orders a list by a column.
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// PageArgs select a page of a list like the arguments of a Relay connection: the first
+// rows after a cursor or the last rows before a cursor.
+type PageArgs struct {
+	First  *int
+	After  *string
+	Last   *int
+	Before *string
+}
+
+// PageInfo describes a page selected by Paginate.
+type PageInfo struct {
+	HasNextPage     bool
+	HasPreviousPage bool
+	// TotalCount is the number of rows of all pages.
+	TotalCount int
+}
+
+// EncodeCursor returns the opaque cursor of the row with the id.
+func EncodeCursor(id int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s%d", cursorPrefix, id)))
+}
+
+// DecodeCursor returns the id of the row of a cursor.
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(string(raw), cursorPrefix), "%d", &id); err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// CheckSorts returns ErrInvalidSort if one of the sorts uses a column that is not in columns.
+func CheckSorts(sorts []Sort, columns ...string) error {
+	for _, s := range sorts {
+		allowed := false
+		for _, c := range columns {
+			allowed = allowed || s.Column == c
+		}
+		if !allowed {
+			return errors.Wrap(ErrInvalidSort, s.Column)
+		}
+	}
+	return nil
+}
+
+// Contains matches rows whose column contains the value.
+func Contains(column, value string) sq.Sqlizer {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return sq.Like{column: "%" + escaped + "%"}
+}
+
+// Between matches rows whose column is in a time range. Both ends are optional and inclusive.
+func Between(column string, from, until *time.Time) sq.Sqlizer {
+	and := sq.And{}
+	if from != nil {
+		and = append(and, sq.GtOrEq{column: *from})
+	}
+	if until != nil {
+		and = append(and, sq.LtOrEq{column: *until})
+	}
+	return and
+}
+
+// Paginate selects a page of the rows of a table that match the where conditions into dest, which has
+// to be a pointer to a slice. The rows are ordered by the sorts and by their id, which makes the order
+// unique, so the cursors of EncodeCursor can be used to continue after or before a row. The sort columns
+// have to be checked with CheckSorts, they are added to the query as they are. Sort columns can be
+// nullable, NULL is sorted before all other values.
+func (c *Connection) Paginate(
+	ctx context.Context,
+	dest interface{},
+	table string,
+	where []sq.Sqlizer,
+	sorts []Sort,
+	args PageArgs,
+) (*PageInfo, error) {
+	backwards := args.Last != nil || (args.First == nil && args.Before != nil)
+	size, err := pageSize(args, backwards)
+	if err != nil {
+		return nil, err
+	}
+	sorts = withID(sorts)
+
+	info := &PageInfo{}
+	count := sq.Select("COUNT(*)").From(table)
+	for _, w := range where {
+		count = count.Where(w)
+	}
+	query, params, err := count.ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = c.GetContext(ctx, &info.TotalCount, query, params...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rows := sq.Select("*").From(table).Limit(uint64(size + 1))
+	for _, w := range where {
+		rows = rows.Where(w)
+	}
+	for _, cursor := range []*string{args.After, args.Before} {
+		if cursor == nil {
+			continue
+		}
+		values, err := c.cursorValues(ctx, table, sorts, *cursor)
+		if err != nil {
+			return nil, err
+		}
+		rows = rows.Where(keyset(sorts, values, cursor == args.Before))
+	}
+	for _, s := range sorts {
+		// Backwards pages are selected in the reverse order and reversed afterwards.
+		if s.Desc != backwards {
+			rows = rows.OrderBy(s.Column + " DESC")
+		} else {
+			rows = rows.OrderBy(s.Column + " ASC")
+		}
+	}
+	query, params, err = rows.ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = c.SelectContext(ctx, dest, query, params...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	slice := reflect.ValueOf(dest).Elem()
+	more := slice.Len() > size
+	if more {
+		slice.Set(slice.Slice(0, size))
+	}
+	if backwards {
+		for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+			tmp := slice.Index(i).Interface()
+			slice.Index(i).Set(slice.Index(j))
+			slice.Index(j).Set(reflect.ValueOf(tmp))
+		}
+		info.HasPreviousPage = more
+		info.HasNextPage = args.Before != nil
+	} else {
+		info.HasNextPage = more
+		info.HasPreviousPage = args.After != nil
+	}
+	return info, nil
+}
+
+// pageSize returns the number of rows of a page.
+func pageSize(args PageArgs, backwards bool) (int, error) {
+	if args.First != nil && args.Last != nil {
+		return 0, errors.Wrap(ErrInvalidPage, "first and last cannot be combined")
+	}
+	size := DefaultPageSize
+	if args.First != nil {
+		size = *args.First
+	}
+	if backwards && args.Last != nil {
+		size = *args.Last
+	}
+	if size < 0 || size > MaxPageSize {
+		return 0, errors.Wrapf(ErrInvalidPage, "the page size has to be between 0 and %d", MaxPageSize)
+	}
+	return size, nil
+}
+
+// withID appends the id to the sorts, so the order of the rows is unique.
+func withID(sorts []Sort) []Sort {
+	for i, s := range sorts {
+		if s.Column == "id" {
+			return sorts[:i+1]
+		}
+	}
+	return append(append([]Sort{}, sorts...), Sort{Column: "id"})
+}
+
+// cursorValues returns the values of the sort columns of the row a cursor points to.
+func (c *Connection) cursorValues(ctx context.Context, table string, sorts []Sort, cursor string) ([]interface{}, error) {
+	id, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(sorts))
+	for i, s := range sorts {
+		columns[i] = s.Column
+	}
+	query, params, err := sq.Select(columns...).From(table).Where(sq.Eq{"id": id}).ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := make([]interface{}, len(sorts))
+	targets := make([]interface{}, len(sorts))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err = c.QueryRowxContext(ctx, query, params...).Scan(targets...); err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+	return values, nil
+}
+
+// keyset matches the rows that come after the values in the order of the sorts,
+// or the rows that come before them if before is true.
+func keyset(sorts []Sort, values []interface{}, before bool) sq.Sqlizer {
+	or := sq.Or{}
+	for i, s := range sorts {
+		var next sq.Sqlizer
+		if s.Desc != before {
+			next = lessThan(s.Column, values[i])
+		} else {
+			next = greaterThan(s.Column, values[i])
+		}
+		if next == nil {
+			continue
+		}
+		and := sq.And{}
+		for j := 0; j < i; j++ {
+			and = append(and, sq.Eq{sorts[j].Column: values[j]})
+		}
+		or = append(or, append(and, next))
+	}
+	return or
+}
+
+// lessThan matches the values of a column that are sorted before the value in ascending order.
+// MySQL sorts NULL before all other values, nothing is sorted before NULL and nil is returned.
+func lessThan(column string, value interface{}) sq.Sqlizer {
+	if value == nil {
+		return nil
+	}
+	return sq.Or{sq.Lt{column: value}, sq.Eq{column: nil}}
+}
+
+// greaterThan matches the values of a column that are sorted after the value in ascending order.
+func greaterThan(column string, value interface{}) sq.Sqlizer {
+	if value == nil {
+		return sq.NotEq{column: nil}
+	}
+	return sq.Gt{column: value}
+}
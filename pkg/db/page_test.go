@@ -0,0 +1,70 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-webapp-example/internal/pkg/entity"
+	"go-webapp-example/internal/pkg/test"
+	"go-webapp-example/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPaginate_Null pages through users one by one while some of them have no created_at date.
+func TestPaginate_Null(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	conn, cleanup := test.DB(t)
+	defer cleanup()
+
+	// The seeded users 1 and 2 have no created_at date.
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, createdAt := range []interface{}{now, nil, now.Add(-time.Hour), now} {
+		_, err := conn.Exec("INSERT INTO users (id, name, password, created_at) VALUES (?, ?, ?, ?)", i+3, "user", "", createdAt)
+		require.NoError(t, err)
+	}
+
+	pages := func(t *testing.T, sort db.Sort, backwards bool) []int {
+		var ids []int
+		var cursor *string
+		one := 1
+		for i := 0; i < 10; i++ {
+			var rows []*entity.User
+			args := db.PageArgs{First: &one, After: cursor}
+			if backwards {
+				args = db.PageArgs{Last: &one, Before: cursor}
+			}
+			info, err := conn.Paginate(context.Background(), &rows, "users", nil, []db.Sort{sort}, args)
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Equal(t, 6, info.TotalCount)
+			c := db.EncodeCursor(rows[0].ID)
+			cursor = &c
+			if backwards {
+				ids = append([]int{rows[0].ID}, ids...)
+				if !info.HasPreviousPage {
+					return ids
+				}
+			} else {
+				ids = append(ids, rows[0].ID)
+				if !info.HasNextPage {
+					return ids
+				}
+			}
+		}
+		t.Fatalf("pagination did not end: %v", ids)
+		return nil
+	}
+
+	asc := []int{1, 2, 4, 5, 3, 6}
+	desc := []int{3, 6, 5, 1, 2, 4}
+	assert.Equal(t, asc, pages(t, db.Sort{Column: "created_at"}, false))
+	assert.Equal(t, asc, pages(t, db.Sort{Column: "created_at"}, true))
+	assert.Equal(t, desc, pages(t, db.Sort{Column: "created_at", Desc: true}, false))
+	assert.Equal(t, desc, pages(t, db.Sort{Column: "created_at", Desc: true}, true))
+}
@@ -1,6 +1,9 @@
 package fs
 
-import "os"
+import (
+	"net/http"
+	"os"
+)
 
 func EnsureDir(path string) error {
 	isExisting, err := Exists(path)
@@ -23,3 +26,30 @@ func Exists(path string) (bool, error) {
 	}
 	return true, err
 }
+
+// FilesOnly serves the files of a file system without the listings of its directories,
+// opening a directory fails as if it did not exist.
+func FilesOnly(root http.FileSystem) http.FileSystem {
+	return filesOnly{root: root}
+}
+
+type filesOnly struct {
+	root http.FileSystem
+}
+
+func (f filesOnly) Open(name string) (http.File, error) {
+	file, err := f.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
@@ -0,0 +1,40 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-webapp-example/pkg/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, fs.EnsureDir(filepath.Join(dir, "uploads")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "uploads", "notes.txt"), []byte("notes"), 0600))
+
+	srv := http.FileServer(fs.FilesOnly(http.Dir(dir)))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/uploads/notes.txt")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "notes", w.Body.String())
+
+	for _, path := range []string{"/", "/uploads/", "/uploads", "/missing.txt"} {
+		w = get(path)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "notes.txt", path)
+	}
+}
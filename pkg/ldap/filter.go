@@ -0,0 +1,201 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"go-webapp-example/pkg/ldap/internal/ber"
+
+	"github.com/pkg/errors"
+)
+
+// Context tags of the filter choices.
+const (
+	FilterAnd            = 0
+	FilterOr             = 1
+	FilterNot            = 2
+	FilterEqualityMatch  = 3
+	FilterSubstrings     = 4
+	FilterGreaterOrEqual = 5
+	FilterLessOrEqual    = 6
+	FilterPresent        = 7
+	FilterApproxMatch    = 8
+)
+
+// Context tags of the substring choices.
+const (
+	SubstringInitial = 0
+	SubstringAny     = 1
+	SubstringFinal   = 2
+)
+
+// EscapeFilter escapes a value so it can be used in a filter string.
+func EscapeFilter(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '*', c == '(', c == ')', c == '\\', c == 0, c >= 0x80:
+			b.WriteByte('\\')
+			b.WriteString(hex.EncodeToString([]byte{c}))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// CompileFilter encodes a filter in the string representation of RFC 4515.
+func CompileFilter(filter string) (*ber.Packet, error) {
+	p, rest, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, errors.Errorf("ldap: unexpected %q at the end of the filter", rest)
+	}
+	return p, nil
+}
+
+// compileFilter encodes the first filter of s and returns the remaining string.
+func compileFilter(s string) (*ber.Packet, string, error) {
+	if len(s) < 3 || s[0] != '(' {
+		return nil, "", errors.Errorf("ldap: invalid filter %q", s)
+	}
+	s = s[1:]
+	switch s[0] {
+	case '&', '|':
+		tag := FilterAnd
+		if s[0] == '|' {
+			tag = FilterOr
+		}
+		p := ber.Sequence(ber.ClassContext, tag)
+		s = s[1:]
+		for len(s) > 0 && s[0] == '(' {
+			child, rest, err := compileFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			p.Children = append(p.Children, child)
+			s = rest
+		}
+		if len(p.Children) == 0 {
+			return nil, "", errors.New("ldap: empty filter list")
+		}
+		return closeFilter(p, s)
+	case '!':
+		child, rest, err := compileFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		return closeFilter(ber.Sequence(ber.ClassContext, FilterNot, child), rest)
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", errors.New("ldap: missing closing parenthesis")
+	}
+	p, err := compileItem(s[:end])
+	if err != nil {
+		return nil, "", err
+	}
+	return p, s[end+1:], nil
+}
+
+// closeFilter consumes the closing parenthesis of a filter.
+func closeFilter(p *ber.Packet, s string) (*ber.Packet, string, error) {
+	if len(s) == 0 || s[0] != ')' {
+		return nil, "", errors.New("ldap: missing closing parenthesis")
+	}
+	return p, s[1:], nil
+}
+
+// compileItem encodes a simple filter item like "uid=jane".
+func compileItem(item string) (*ber.Packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq < 1 {
+		return nil, errors.Errorf("ldap: invalid filter item %q", item)
+	}
+	attr, value := item[:eq], item[eq+1:]
+	tag := FilterEqualityMatch
+	switch attr[len(attr)-1] {
+	case '>':
+		tag = FilterGreaterOrEqual
+	case '<':
+		tag = FilterLessOrEqual
+	case '~':
+		tag = FilterApproxMatch
+	}
+	if tag != FilterEqualityMatch {
+		attr = attr[:len(attr)-1]
+		v, err := unescapeFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		return ber.Sequence(ber.ClassContext, tag,
+			ber.String(ber.ClassUniversal, ber.TagOctetString, attr),
+			ber.String(ber.ClassUniversal, ber.TagOctetString, v),
+		), nil
+	}
+
+	if value == "*" {
+		return ber.String(ber.ClassContext, FilterPresent, attr), nil
+	}
+	if !strings.Contains(value, "*") {
+		v, err := unescapeFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		return ber.Sequence(ber.ClassContext, FilterEqualityMatch,
+			ber.String(ber.ClassUniversal, ber.TagOctetString, attr),
+			ber.String(ber.ClassUniversal, ber.TagOctetString, v),
+		), nil
+	}
+
+	parts := strings.Split(value, "*")
+	subs := ber.Sequence(ber.ClassUniversal, ber.TagSequence)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		v, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		tag := SubstringAny
+		if i == 0 {
+			tag = SubstringInitial
+		} else if i == len(parts)-1 {
+			tag = SubstringFinal
+		}
+		subs.Children = append(subs.Children, ber.String(ber.ClassContext, tag, v))
+	}
+	return ber.Sequence(ber.ClassContext, FilterSubstrings,
+		ber.String(ber.ClassUniversal, ber.TagOctetString, attr),
+		subs,
+	), nil
+}
+
+// unescapeFilter decodes the \XX escapes of a filter value.
+func unescapeFilter(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", errors.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		c, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", errors.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		b.Write(c)
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,255 @@
+// Package ber implements the subset of the ASN.1 basic encoding rules that is used by LDAP.
+// Only definite lengths and tag numbers below 31 are supported, as required by RFC 4511.
+package ber
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Classes of a tag.
+const (
+	ClassUniversal   byte = 0x00
+	ClassApplication byte = 0x40
+	ClassContext     byte = 0x80
+)
+
+// Universal tags used by LDAP.
+const (
+	TagBoolean     = 0x01
+	TagInteger     = 0x02
+	TagOctetString = 0x04
+	TagNull        = 0x05
+	TagEnumerated  = 0x0a
+	TagSequence    = 0x10
+	TagSet         = 0x11
+)
+
+// maxLength limits the size of a single packet.
+const maxLength = 16 << 20
+
+// maxDepth limits the nesting of constructed values, LDAP messages are far less deep.
+const maxDepth = 32
+
+// Packet is a single encoded value. Constructed values contain their children, primitive values their content.
+type Packet struct {
+	Class       byte
+	Constructed bool
+	Tag         int
+	Value       []byte
+	Children    []*Packet
+}
+
+// Sequence returns a new constructed value.
+func Sequence(class byte, tag int, children ...*Packet) *Packet {
+	return &Packet{Class: class, Constructed: true, Tag: tag, Children: children}
+}
+
+// String returns a new primitive value with string content.
+func String(class byte, tag int, s string) *Packet {
+	return &Packet{Class: class, Tag: tag, Value: []byte(s)}
+}
+
+// Int returns a new primitive value with integer content.
+func Int(class byte, tag int, v int64) *Packet {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		// Stop if the remaining bits are only the sign extension of the encoded bytes.
+		if (v == 0 && b[0]&0x80 == 0) || (v == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &Packet{Class: class, Tag: tag, Value: b}
+}
+
+// Bool returns a new primitive value with boolean content.
+func Bool(class byte, tag int, v bool) *Packet {
+	if v {
+		return &Packet{Class: class, Tag: tag, Value: []byte{0xff}}
+	}
+	return &Packet{Class: class, Tag: tag, Value: []byte{0x00}}
+}
+
+// Is returns true if the packet has the given class and tag.
+func (p *Packet) Is(class byte, tag int) bool {
+	return p.Class == class && p.Tag == tag
+}
+
+// Str returns the content of a primitive value as string.
+func (p *Packet) Str() string {
+	return string(p.Value)
+}
+
+// Int returns the content of a primitive value as integer.
+func (p *Packet) Int() (int64, error) {
+	if p.Constructed || len(p.Value) == 0 || len(p.Value) > 8 {
+		return 0, errors.New("ber: invalid integer")
+	}
+	v := int64(int8(p.Value[0]))
+	for _, b := range p.Value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// Bool returns the content of a primitive value as boolean.
+func (p *Packet) Bool() bool {
+	return len(p.Value) == 1 && p.Value[0] != 0
+}
+
+// Bytes returns the encoded packet.
+func (p *Packet) Bytes() []byte {
+	content := p.Value
+	if p.Constructed {
+		content = nil
+		for _, c := range p.Children {
+			content = append(content, c.Bytes()...)
+		}
+	}
+	id := p.Class | byte(p.Tag&0x1f)
+	if p.Constructed {
+		id |= 0x20
+	}
+	out := append([]byte{id}, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+// encodeLength returns the definite length octets.
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// Read reads the next packet from r.
+func Read(r *bufio.Reader) (*Packet, error) {
+	id, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if id&0x1f == 0x1f {
+		return nil, errors.New("ber: high tag numbers are not supported")
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, length)
+	if _, err = io.ReadFull(r, content); err != nil {
+		return nil, errors.Wrap(err, "ber: failed to read content")
+	}
+	return decode(id, content, 0)
+}
+
+// Parse decodes a single packet.
+func Parse(b []byte) (*Packet, error) {
+	p, rest, err := parse(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("ber: trailing data")
+	}
+	return p, nil
+}
+
+// parse decodes the first packet of b and returns the remaining bytes.
+func parse(b []byte, depth int) (*Packet, []byte, error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("ber: truncated packet")
+	}
+	id := b[0]
+	if id&0x1f == 0x1f {
+		return nil, nil, errors.New("ber: high tag numbers are not supported")
+	}
+	br := &sliceReader{b: b[1:]}
+	length, err := readLength(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest := br.b
+	if length > len(rest) {
+		return nil, nil, errors.New("ber: truncated packet")
+	}
+	p, err := decode(id, rest[:length], depth)
+	return p, rest[length:], err
+}
+
+// decode builds a packet from the identifier octet and its content. depth is the
+// number of constructed values the packet is nested in.
+func decode(id byte, content []byte, depth int) (*Packet, error) {
+	p := &Packet{
+		Class:       id & 0xc0,
+		Constructed: id&0x20 != 0,
+		Tag:         int(id & 0x1f),
+	}
+	if !p.Constructed {
+		p.Value = content
+		return p, nil
+	}
+	if depth >= maxDepth {
+		return nil, errors.New("ber: nesting too deep")
+	}
+	for len(content) > 0 {
+		child, rest, err := parse(content, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		p.Children = append(p.Children, child)
+		content = rest
+	}
+	return p, nil
+}
+
+// readLength reads definite length octets.
+func readLength(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, errors.Wrap(err, "ber: failed to read length")
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+	n := int(b & 0x7f)
+	if n == 0 {
+		return 0, errors.New("ber: indefinite lengths are not supported")
+	}
+	if n > 4 {
+		return 0, errors.New("ber: length too large")
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, errors.Wrap(err, "ber: failed to read length")
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxLength {
+		return 0, errors.New("ber: length too large")
+	}
+	return length, nil
+}
+
+// sliceReader reads bytes from a slice.
+type sliceReader struct {
+	b []byte
+}
+
+func (s *sliceReader) ReadByte() (byte, error) {
+	if len(s.b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := s.b[0]
+	s.b = s.b[1:]
+	return b, nil
+}
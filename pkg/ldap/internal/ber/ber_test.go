@@ -0,0 +1,119 @@
+package ber_test
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"go-webapp-example/pkg/ldap/internal/ber"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInt(t *testing.T) {
+	values := map[int64][]byte{
+		0:             {0x00},
+		127:           {0x7f},
+		128:           {0x00, 0x80},
+		256:           {0x01, 0x00},
+		-1:            {0xff},
+		-128:          {0x80},
+		-129:          {0xff, 0x7f},
+		math.MaxInt64: {0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		math.MinInt64: {0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	for v, encoded := range values {
+		p := ber.Int(ber.ClassUniversal, ber.TagInteger, v)
+		assert.Equal(t, encoded, p.Value, "value %d", v)
+
+		parsed, err := ber.Parse(p.Bytes())
+		require.NoError(t, err)
+		decoded, err := parsed.Int()
+		assert.NoError(t, err)
+		assert.Equal(t, v, decoded)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	p := ber.Sequence(ber.ClassUniversal, ber.TagSequence,
+		ber.Int(ber.ClassUniversal, ber.TagInteger, 1),
+		ber.Sequence(ber.ClassApplication, 3,
+			ber.String(ber.ClassUniversal, ber.TagOctetString, "dc=example,dc=com"),
+			ber.Bool(ber.ClassUniversal, ber.TagBoolean, true),
+			ber.String(ber.ClassContext, 7, long),
+			ber.Sequence(ber.ClassUniversal, ber.TagSet),
+		),
+	)
+	encoded := p.Bytes()
+	// The content of the long string needs two length octets.
+	assert.Contains(t, string(encoded), "\x87\x82\x01\x2c"+long)
+
+	parsed, err := ber.Parse(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, encoded, parsed.Bytes())
+	require.Len(t, parsed.Children, 2)
+	assert.True(t, parsed.Constructed)
+
+	op := parsed.Children[1]
+	assert.True(t, op.Is(ber.ClassApplication, 3))
+	require.Len(t, op.Children, 4)
+	assert.Equal(t, "dc=example,dc=com", op.Children[0].Str())
+	assert.True(t, op.Children[1].Bool())
+	assert.Equal(t, long, op.Children[2].Str())
+	assert.True(t, op.Children[3].Constructed)
+	assert.Empty(t, op.Children[3].Children)
+
+	// Read decodes consecutive packets of a stream.
+	r := bufio.NewReader(bytes.NewReader(append(encoded, encoded...)))
+	for i := 0; i < 2; i++ {
+		read, err := ber.Read(r)
+		require.NoError(t, err)
+		assert.Equal(t, encoded, read.Bytes())
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	deep := ber.String(ber.ClassUniversal, ber.TagOctetString, "x")
+	for i := 0; i < 40; i++ {
+		deep = ber.Sequence(ber.ClassUniversal, ber.TagSequence, deep)
+	}
+
+	cases := map[string][]byte{
+		"empty":             {},
+		"missing length":    {0x04},
+		"truncated content": {0x04, 0x05, 'a', 'b'},
+		"truncated child":   {0x30, 0x03, 0x04, 0x05, 'a'},
+		"high tag number":   {0x1f, 0x01, 0x00},
+		"indefinite length": {0x30, 0x80, 0x00, 0x00},
+		"length octets":     {0x04, 0x85, 0x01, 0x00, 0x00, 0x00, 0x00},
+		"length too large":  {0x04, 0x84, 0x7f, 0xff, 0xff, 0xff},
+		"truncated length":  {0x04, 0x82, 0x01},
+		"trailing data":     {0x05, 0x00, 0x00},
+		"nesting too deep":  deep.Bytes(),
+	}
+	for name, b := range cases {
+		_, err := ber.Parse(b)
+		assert.Error(t, err, name)
+
+		_, err = ber.Read(bufio.NewReader(bytes.NewReader(b)))
+		if name != "trailing data" {
+			assert.Error(t, err, name)
+		}
+	}
+}
+
+func TestIntMalformed(t *testing.T) {
+	invalid := []*ber.Packet{
+		{Tag: ber.TagInteger},
+		{Tag: ber.TagInteger, Value: make([]byte, 9)},
+		ber.Sequence(ber.ClassUniversal, ber.TagInteger),
+	}
+	for _, p := range invalid {
+		_, err := p.Int()
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,361 @@
+// Package ldap implements a minimal LDAPv3 client that supports simple binds and searches. It is
+// used to check credentials against a directory like OpenLDAP or Active Directory.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-webapp-example/pkg/ldap/internal/ber"
+
+	"github.com/pkg/errors"
+)
+
+// Result codes of RFC 4511 that are handled by callers.
+const (
+	ResultSuccess            = 0
+	ResultProtocolError      = 2
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+	ResultInsufficientAccess = 50
+)
+
+// Application tags of the protocol operations.
+const (
+	appBindRequest     = 0
+	appBindResponse    = 1
+	appUnbindRequest   = 2
+	appSearchRequest   = 3
+	appSearchEntry     = 4
+	appSearchDone      = 5
+	appSearchReference = 19
+	appExtendedRequest = 23
+	appExtendedResp    = 24
+)
+
+// oidStartTLS is the name of the StartTLS extended operation.
+const oidStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// ErrEmptyPassword is returned by Bind if no password is given. Most servers accept a bind with a DN
+// but without password as anonymous bind, which would otherwise be mistaken for a successful login.
+var ErrEmptyPassword = errors.New("ldap: empty password")
+
+// Error is a result code other than success returned by the server.
+type Error struct {
+	Code      int
+	MatchedDN string
+	Message   string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// IsCode returns true if err is an Error with the given result code.
+func IsCode(err error, code int) bool {
+	e, ok := errors.Cause(err).(*Error)
+	return ok && e.Code == code
+}
+
+// Scope defines which entries below the base DN are searched.
+type Scope int
+
+// Search scopes.
+const (
+	ScopeBase Scope = iota
+	ScopeOneLevel
+	ScopeSubtree
+)
+
+// SearchRequest describes a search operation.
+type SearchRequest struct {
+	BaseDN string
+	Scope  Scope
+	// Filter is a filter in the string representation of RFC 4515, e.g. "(&(objectClass=person)(uid=jane))".
+	Filter string
+	// Attributes limits the returned attributes. All user attributes are returned if it is empty.
+	Attributes []string
+	// SizeLimit limits the number of returned entries, 0 means no limit.
+	SizeLimit int
+}
+
+// Entry is an entry returned by a search.
+type Entry struct {
+	DN string
+	// Attributes contains the values by attribute name.
+	Attributes map[string][]string
+}
+
+// Values returns all values of an attribute. Attribute names are case insensitive.
+func (e *Entry) Values(name string) []string {
+	for k, v := range e.Attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Value returns the first value of an attribute.
+func (e *Entry) Value(name string) string {
+	if v := e.Values(name); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Conn is a connection to a directory server. Requests are sent one at a time, it is not safe for concurrent use.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	msgID   int64
+}
+
+// Dial connects to the server of an ldap:// or ldaps:// url. The tls config is
+// used for ldaps, a nil config uses the system defaults. Every request has to
+// be answered within the timeout.
+func Dial(rawURL string, tlsConfig *tls.Config, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap: invalid url")
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		conn, err = dialer.Dial("tcp", hostPort(u, "389"))
+	case "ldaps":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "636"), withServerName(tlsConfig, u.Hostname()))
+	default:
+		return nil, errors.Errorf("ldap: unsupported url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap: failed to connect")
+	}
+	return &Conn{conn: conn, r: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// hostPort returns the address of the url with a default port.
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// withServerName returns a copy of the tls config that verifies the host name.
+func withServerName(config *tls.Config, host string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	return config
+}
+
+// Close ends the session and closes the connection.
+func (c *Conn) Close() error {
+	c.msgID++
+	msg := ber.Sequence(ber.ClassUniversal, ber.TagSequence,
+		ber.Int(ber.ClassUniversal, ber.TagInteger, c.msgID),
+		&ber.Packet{Class: ber.ClassApplication, Tag: appUnbindRequest},
+	)
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	_, _ = c.conn.Write(msg.Bytes())
+	return c.conn.Close()
+}
+
+// StartTLS upgrades the connection to TLS.
+func (c *Conn) StartTLS(config *tls.Config) error {
+	op := ber.Sequence(ber.ClassApplication, appExtendedRequest,
+		ber.String(ber.ClassContext, 0, oidStartTLS),
+	)
+	res, err := c.request(op, appExtendedResp)
+	if err != nil {
+		return err
+	}
+	if err = checkResult(res); err != nil {
+		return err
+	}
+	host, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	tlsConn := tls.Client(c.conn, withServerName(config, host))
+	_ = tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	if err = tlsConn.Handshake(); err != nil {
+		return errors.Wrap(err, "ldap: tls handshake failed")
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind. Wrong credentials
+// return an Error with the ResultInvalidCredentials code.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	op := ber.Sequence(ber.ClassApplication, appBindRequest,
+		ber.Int(ber.ClassUniversal, ber.TagInteger, 3),
+		ber.String(ber.ClassUniversal, ber.TagOctetString, dn),
+		ber.String(ber.ClassContext, 0, password),
+	)
+	res, err := c.request(op, appBindResponse)
+	if err != nil {
+		return err
+	}
+	return checkResult(res)
+}
+
+// Search returns all entries that match the request.
+func (c *Conn) Search(req SearchRequest) ([]*Entry, error) {
+	filter, err := CompileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attrs := ber.Sequence(ber.ClassUniversal, ber.TagSequence)
+	for _, a := range req.Attributes {
+		attrs.Children = append(attrs.Children, ber.String(ber.ClassUniversal, ber.TagOctetString, a))
+	}
+	op := ber.Sequence(ber.ClassApplication, appSearchRequest,
+		ber.String(ber.ClassUniversal, ber.TagOctetString, req.BaseDN),
+		ber.Int(ber.ClassUniversal, ber.TagEnumerated, int64(req.Scope)),
+		// Never dereference aliases.
+		ber.Int(ber.ClassUniversal, ber.TagEnumerated, 0),
+		ber.Int(ber.ClassUniversal, ber.TagInteger, int64(req.SizeLimit)),
+		ber.Int(ber.ClassUniversal, ber.TagInteger, int64(c.timeout/time.Second)),
+		ber.Bool(ber.ClassUniversal, ber.TagBoolean, false),
+		filter,
+		attrs,
+	)
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		res, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case res.Is(ber.ClassApplication, appSearchEntry):
+			entry, err := parseEntry(res)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case res.Is(ber.ClassApplication, appSearchReference):
+			// Referrals to other servers are not followed.
+		case res.Is(ber.ClassApplication, appSearchDone):
+			return entries, checkResult(res)
+		default:
+			return nil, errors.Errorf("ldap: unexpected response tag %d", res.Tag)
+		}
+	}
+}
+
+// request sends an operation and returns the response, which must have the expected tag.
+func (c *Conn) request(op *ber.Packet, tag int) (*ber.Packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Is(ber.ClassApplication, tag) {
+		return nil, errors.Errorf("ldap: unexpected response tag %d", res.Tag)
+	}
+	return res, nil
+}
+
+// send writes an operation and returns its message id.
+func (c *Conn) send(op *ber.Packet) (int64, error) {
+	c.msgID++
+	msg := ber.Sequence(ber.ClassUniversal, ber.TagSequence,
+		ber.Int(ber.ClassUniversal, ber.TagInteger, c.msgID),
+		op,
+	)
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if _, err := c.conn.Write(msg.Bytes()); err != nil {
+		return 0, errors.Wrap(err, "ldap: failed to send request")
+	}
+	return c.msgID, nil
+}
+
+// receive reads the next response to the message id and returns its protocol operation.
+func (c *Conn) receive(id int64) (*ber.Packet, error) {
+	for {
+		msg, err := ber.Read(c.r)
+		if err != nil {
+			return nil, errors.Wrap(err, "ldap: failed to read response")
+		}
+		if !msg.Constructed || len(msg.Children) < 2 {
+			return nil, errors.New("ldap: invalid response")
+		}
+		msgID, err := msg.Children[0].Int()
+		if err != nil {
+			return nil, err
+		}
+		op := msg.Children[1]
+		if msgID == 0 {
+			// Unsolicited notification, the server is about to close the connection.
+			if err := checkResult(op); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("ldap: connection closed by server")
+		}
+		if msgID == id {
+			return op, nil
+		}
+	}
+}
+
+// checkResult returns an Error if the LDAPResult of a response is not successful.
+func checkResult(op *ber.Packet) error {
+	if len(op.Children) < 3 {
+		return errors.New("ldap: invalid result")
+	}
+	code, err := op.Children[0].Int()
+	if err != nil {
+		return err
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &Error{Code: int(code), MatchedDN: op.Children[1].Str(), Message: op.Children[2].Str()}
+}
+
+// parseEntry decodes a SearchResultEntry.
+func parseEntry(op *ber.Packet) (*Entry, error) {
+	if len(op.Children) != 2 {
+		return nil, errors.New("ldap: invalid search entry")
+	}
+	entry := &Entry{DN: op.Children[0].Str(), Attributes: make(map[string][]string)}
+	for _, attr := range op.Children[1].Children {
+		if len(attr.Children) != 2 {
+			return nil, errors.New("ldap: invalid search entry attribute")
+		}
+		name := attr.Children[0].Str()
+		for _, v := range attr.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], v.Str())
+		}
+	}
+	return entry, nil
+}
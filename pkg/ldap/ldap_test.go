@@ -0,0 +1,143 @@
+package ldap_test
+
+import (
+	"testing"
+	"time"
+
+	"go-webapp-example/pkg/ldap"
+	"go-webapp-example/pkg/ldap/ldaptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer() *ldaptest.Server {
+	return ldaptest.NewServer(
+		&ldap.Entry{DN: "dc=example,dc=com"},
+		&ldap.Entry{DN: "ou=people,dc=example,dc=com"},
+		&ldap.Entry{DN: "cn=reader,dc=example,dc=com", Attributes: map[string][]string{
+			"userPassword": {"reader"},
+		}},
+		&ldap.Entry{DN: "uid=jane,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+			"objectClass":  {"person"},
+			"uid":          {"jane"},
+			"mail":         {"jane@example.com"},
+			"memberOf":     {"cn=admins,ou=groups,dc=example,dc=com", "cn=users,ou=groups,dc=example,dc=com"},
+			"userPassword": {"secret"},
+		}},
+		&ldap.Entry{DN: "uid=john,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+			"objectClass":  {"person"},
+			"uid":          {"john"},
+			"userPassword": {"secret"},
+		}},
+	)
+}
+
+func TestConn(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	conn, err := ldap.Dial(srv.URL, nil, time.Second)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	t.Run("SearchWithoutBind", func(t *testing.T) {
+		_, err := conn.Search(ldap.SearchRequest{BaseDN: "dc=example,dc=com", Scope: ldap.ScopeSubtree, Filter: "(uid=jane)"})
+		assert.True(t, ldap.IsCode(err, ldap.ResultInsufficientAccess))
+	})
+
+	t.Run("BindInvalid", func(t *testing.T) {
+		err := conn.Bind("uid=jane,ou=people,dc=example,dc=com", "wrong")
+		assert.True(t, ldap.IsCode(err, ldap.ResultInvalidCredentials))
+	})
+
+	t.Run("BindEmptyPassword", func(t *testing.T) {
+		assert.Equal(t, ldap.ErrEmptyPassword, conn.Bind("uid=jane,ou=people,dc=example,dc=com", ""))
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		assert.NoError(t, conn.Bind("cn=reader,dc=example,dc=com", "reader"))
+
+		entries, err := conn.Search(ldap.SearchRequest{
+			BaseDN:     "ou=people,dc=example,dc=com",
+			Scope:      ldap.ScopeSubtree,
+			Filter:     "(&(objectClass=person)(uid=" + ldap.EscapeFilter("jane") + "))",
+			Attributes: []string{"mail", "memberOf"},
+		})
+
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "uid=jane,ou=people,dc=example,dc=com", entries[0].DN)
+			assert.Equal(t, "jane@example.com", entries[0].Value("MAIL"))
+			assert.Len(t, entries[0].Values("memberof"), 2)
+			assert.Empty(t, entries[0].Value("uid"))
+			assert.Empty(t, entries[0].Value("userPassword"))
+		}
+	})
+
+	t.Run("SearchFilters", func(t *testing.T) {
+		tests := []struct {
+			filter string
+			count  int
+		}{
+			{"(objectClass=*)", 2},
+			{"(|(uid=jane)(uid=john))", 2},
+			{"(!(uid=jane))", 4},
+			{"(uid=j*n)", 1},
+			{"(uid=*o*)", 1},
+			{"(mail=*@example.com)", 1},
+			{"(uid=\\2a)", 0},
+		}
+		for _, tt := range tests {
+			entries, err := conn.Search(ldap.SearchRequest{BaseDN: "dc=example,dc=com", Scope: ldap.ScopeSubtree, Filter: tt.filter})
+			assert.NoError(t, err, tt.filter)
+			assert.Len(t, entries, tt.count, tt.filter)
+		}
+	})
+
+	t.Run("SearchScopes", func(t *testing.T) {
+		entries, err := conn.Search(ldap.SearchRequest{BaseDN: "dc=example,dc=com", Scope: ldap.ScopeOneLevel, Filter: "(objectClass=*)"})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 0)
+
+		entries, err = conn.Search(ldap.SearchRequest{BaseDN: "dc=example,dc=com", Scope: ldap.ScopeOneLevel, Filter: "(|(objectClass=*)(!(objectClass=*)))"})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		entries, err = conn.Search(ldap.SearchRequest{BaseDN: "uid=jane,ou=people,dc=example,dc=com", Scope: ldap.ScopeBase, Filter: "(objectClass=*)"})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("StartTLSUnsupported", func(t *testing.T) {
+		assert.True(t, ldap.IsCode(conn.StartTLS(nil), ldap.ResultProtocolError))
+	})
+}
+
+func TestCompileFilter(t *testing.T) {
+	valid := []string{
+		"(uid=jane)",
+		"(&(objectClass=person)(|(uid=jane)(mail=jane@example.com)))",
+		"(!(uid=jane))",
+		"(uid=*)",
+		"(cn=J*n*e)",
+		"(uidNumber>=1000)",
+		"(cn=\\28test\\29)",
+	}
+	for _, f := range valid {
+		_, err := ldap.CompileFilter(f)
+		assert.NoError(t, err, f)
+	}
+
+	invalid := []string{"", "uid=jane", "(uid=jane", "(&)", "(uid=jane))", "(=jane)", "(uid=\\2)"}
+	for _, f := range invalid {
+		_, err := ldap.CompileFilter(f)
+		assert.Error(t, err, f)
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	assert.Equal(t, "jane", ldap.EscapeFilter("jane"))
+	assert.Equal(t, "\\2a\\28\\29\\5c\\00", ldap.EscapeFilter("*()\\\x00"))
+}
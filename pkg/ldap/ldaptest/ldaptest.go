@@ -0,0 +1,313 @@
+// Package ldaptest provides an in-memory directory server for tests. It supports simple
+// binds and searches, entries are authenticated using their plain text userPassword.
+package ldaptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+
+	"go-webapp-example/pkg/ldap"
+	"go-webapp-example/pkg/ldap/internal/ber"
+)
+
+// PasswordAttribute holds the password of an entry. It is never returned by searches.
+const PasswordAttribute = "userPassword"
+
+// Application tags of the protocol operations.
+const (
+	appBindRequest     = 0
+	appBindResponse    = 1
+	appUnbindRequest   = 2
+	appSearchRequest   = 3
+	appSearchEntry     = 4
+	appSearchDone      = 5
+	appExtendedRequest = 23
+	appExtendedResp    = 24
+)
+
+// Server is an in-memory directory server.
+type Server struct {
+	// URL is the ldap:// url of the server.
+	URL string
+	// AllowAnonymous allows searches without a bind.
+	AllowAnonymous bool
+
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []*ldap.Entry
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a new server with the given entries. The caller has to call Close when finished.
+func NewServer(entries ...*ldap.Entry) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		URL:      "ldap://" + l.Addr().String(),
+		listener: l,
+		entries:  entries,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Add adds an entry to the directory.
+func (s *Server) Add(entry *ldap.Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+// serve accepts connections until the listener is closed.
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle answers the requests of a single connection.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	bound := false
+	for {
+		msg, err := ber.Read(r)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		id, err := msg.Children[0].Int()
+		if err != nil {
+			return
+		}
+		op := msg.Children[1]
+		var responses []*ber.Packet
+		switch {
+		case op.Is(ber.ClassApplication, appBindRequest):
+			var code int
+			code, bound = s.bind(op)
+			responses = append(responses, result(appBindResponse, code))
+		case op.Is(ber.ClassApplication, appSearchRequest):
+			if !bound && !s.AllowAnonymous {
+				responses = append(responses, result(appSearchDone, ldap.ResultInsufficientAccess))
+				break
+			}
+			responses = append(responses, s.search(op)...)
+		case op.Is(ber.ClassApplication, appExtendedRequest):
+			responses = append(responses, result(appExtendedResp, ldap.ResultProtocolError))
+		case op.Is(ber.ClassApplication, appUnbindRequest):
+			return
+		default:
+			return
+		}
+		for _, res := range responses {
+			out := ber.Sequence(ber.ClassUniversal, ber.TagSequence, ber.Int(ber.ClassUniversal, ber.TagInteger, id), res)
+			if _, err := conn.Write(out.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind checks the credentials of a simple bind.
+func (s *Server) bind(op *ber.Packet) (code int, bound bool) {
+	if len(op.Children) != 3 {
+		return ldap.ResultProtocolError, false
+	}
+	dn, password := op.Children[1].Str(), op.Children[2].Str()
+	if dn == "" && password == "" {
+		return ldap.ResultSuccess, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) && password != "" && e.Value(PasswordAttribute) == password {
+			return ldap.ResultSuccess, true
+		}
+	}
+	return ldap.ResultInvalidCredentials, false
+}
+
+// search returns the matching entries followed by the result.
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	if len(op.Children) != 8 {
+		return []*ber.Packet{result(appSearchDone, ldap.ResultProtocolError)}
+	}
+	base := strings.ToLower(op.Children[0].Str())
+	scope, _ := op.Children[1].Int()
+	filter := op.Children[6]
+	var attrs []string
+	for _, a := range op.Children[7].Children {
+		attrs = append(attrs, a.Str())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*ber.Packet
+	for _, e := range s.entries {
+		if !inScope(strings.ToLower(e.DN), base, ldap.Scope(scope)) || !matches(e, filter) {
+			continue
+		}
+		out = append(out, encodeEntry(e, attrs))
+	}
+	return append(out, result(appSearchDone, ldap.ResultSuccess))
+}
+
+// inScope returns true if the dn is part of the search scope.
+func inScope(dn, base string, scope ldap.Scope) bool {
+	if dn == base {
+		return scope != ldap.ScopeOneLevel
+	}
+	if base != "" && !strings.HasSuffix(dn, ","+base) {
+		return false
+	}
+	switch scope {
+	case ldap.ScopeBase:
+		return false
+	case ldap.ScopeOneLevel:
+		rdn := dn
+		if base != "" {
+			rdn = strings.TrimSuffix(dn, ","+base)
+		}
+		return !strings.Contains(rdn, ",")
+	}
+	return true
+}
+
+// matches evaluates a filter against an entry. Values are compared case insensitive.
+func matches(e *ldap.Entry, f *ber.Packet) bool {
+	if f.Class != ber.ClassContext {
+		return false
+	}
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			if !matches(e, c) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			if matches(e, c) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(f.Children) == 1 && !matches(e, f.Children[0])
+	case ldap.FilterPresent:
+		return len(e.Values(f.Str())) > 0
+	case ldap.FilterSubstrings:
+		return matchesSubstrings(e, f)
+	}
+	if len(f.Children) != 2 {
+		return false
+	}
+	want := strings.ToLower(f.Children[1].Str())
+	for _, v := range e.Values(f.Children[0].Str()) {
+		v = strings.ToLower(v)
+		switch {
+		case (f.Tag == ldap.FilterEqualityMatch || f.Tag == ldap.FilterApproxMatch) && v == want:
+			return true
+		case f.Tag == ldap.FilterGreaterOrEqual && v >= want:
+			return true
+		case f.Tag == ldap.FilterLessOrEqual && v <= want:
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSubstrings evaluates a substrings filter.
+func matchesSubstrings(e *ldap.Entry, f *ber.Packet) bool {
+	if len(f.Children) != 2 {
+		return false
+	}
+	for _, v := range e.Values(f.Children[0].Str()) {
+		v = strings.ToLower(v)
+		ok := true
+		for _, sub := range f.Children[1].Children {
+			part := strings.ToLower(sub.Str())
+			switch sub.Tag {
+			case ldap.SubstringInitial:
+				ok = strings.HasPrefix(v, part)
+				v = strings.TrimPrefix(v, part)
+			case ldap.SubstringFinal:
+				ok = strings.HasSuffix(v, part)
+			default:
+				i := strings.Index(v, part)
+				ok = i >= 0
+				if ok {
+					v = v[i+len(part):]
+				}
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeEntry encodes a SearchResultEntry with the requested attributes.
+func encodeEntry(e *ldap.Entry, attrs []string) *ber.Packet {
+	list := ber.Sequence(ber.ClassUniversal, ber.TagSequence)
+	for name, values := range e.Attributes {
+		if strings.EqualFold(name, PasswordAttribute) || !requested(name, attrs) {
+			continue
+		}
+		set := ber.Sequence(ber.ClassUniversal, ber.TagSet)
+		for _, v := range values {
+			set.Children = append(set.Children, ber.String(ber.ClassUniversal, ber.TagOctetString, v))
+		}
+		list.Children = append(list.Children, ber.Sequence(ber.ClassUniversal, ber.TagSequence,
+			ber.String(ber.ClassUniversal, ber.TagOctetString, name),
+			set,
+		))
+	}
+	return ber.Sequence(ber.ClassApplication, appSearchEntry,
+		ber.String(ber.ClassUniversal, ber.TagOctetString, e.DN),
+		list,
+	)
+}
+
+// requested returns true if the attribute is part of the requested list.
+func requested(name string, attrs []string) bool {
+	if len(attrs) == 0 {
+		return true
+	}
+	for _, a := range attrs {
+		if a == "*" || strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// result returns an LDAPResult with the given application tag.
+func result(tag, code int) *ber.Packet {
+	return ber.Sequence(ber.ClassApplication, tag,
+		ber.Int(ber.ClassUniversal, ber.TagEnumerated, int64(code)),
+		ber.String(ber.ClassUniversal, ber.TagOctetString, ""),
+		ber.String(ber.ClassUniversal, ber.TagOctetString, ""),
+	)
+}
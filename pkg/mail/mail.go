@@ -0,0 +1,70 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Message is a plain text mail.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mails.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SMTP delivers mails to a SMTP server. STARTTLS is used if the server supports it.
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTP returns a new SMTP sender. The authentication is skipped if no username is set.
+func NewSMTP(host, port, username, password string) *SMTP {
+	s := &SMTP{addr: net.JoinHostPort(host, port)}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// Send delivers a mail.
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	if strings.ContainsAny(m.To+m.From, "\r\n") {
+		return errors.New("mail addresses must not contain line breaks")
+	}
+	err := smtp.SendMail(s.addr, s.auth, m.From, []string{m.To}, Format(m, time.Now()))
+	return errors.Wrapf(err, "failed to send mail to %s", m.To)
+}
+
+// Format returns the RFC 5322 representation of a message.
+func Format(m Message, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	// Normalize line endings, the SMTP client escapes lines that start with a dot.
+	for _, line := range strings.Split(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n") {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
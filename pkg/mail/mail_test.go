@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP is a minimal SMTP server that records the received mail data.
+func fakeSMTP(t *testing.T) (host, port string, received chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	received = make(chan string, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		defer l.Close()
+
+		r := bufio.NewReader(conn)
+		write := func(s string) { conn.Write([]byte(s + "\r\n")) } // nolint:errcheck
+
+		write("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					write("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				write("354 go ahead")
+			case cmd == "QUIT":
+				write("221 bye")
+				return
+			default:
+				write("250 OK")
+			}
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(l.Addr().String())
+	return host, port, received
+}
+
+func TestSMTP_Send(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+
+	err := NewSMTP(host, port, "", "").Send(context.Background(), Message{
+		From:    "noreply@example.com",
+		To:      "user@example.com",
+		Subject: "Passwort zurücksetzen",
+		Body:    "Hello\n.dot",
+	})
+	assert.NoError(t, err)
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "To: user@example.com\r\n")
+		assert.Contains(t, data, "Subject: =?utf-8?q?Passwort_zur=C3=BCcksetzen?=\r\n")
+		assert.Contains(t, data, "\r\n\r\nHello\r\n..dot\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("no mail received")
+	}
+}
+
+func TestSMTP_SendHeaderInjection(t *testing.T) {
+	err := NewSMTP("127.0.0.1", "1", "", "").Send(context.Background(), Message{
+		From: "noreply@example.com",
+		To:   "user@example.com\r\nBcc: other@example.com",
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,372 @@
+// Package oidc implements an OpenID Connect relying party that uses the
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// leeway is the allowed clock skew when checking the expiry of an ID token.
+const leeway = time.Minute
+
+var (
+	// ErrInvalidToken is returned if an ID token cannot be verified.
+	ErrInvalidToken = errors.New("invalid id token")
+	// ErrUnknownKey is returned if an ID token is signed by a key the provider did not publish.
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// hashes contains the supported signing algorithms.
+var hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// Config contains the settings of the relying party.
+type Config struct {
+	// Issuer is the url of the identity provider. The provider
+	// configuration is discovered from it.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback url that receives the authorization code.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Provider contains the discovered endpoints of an identity provider.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims contains the verified claims of an ID token.
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+	// Raw contains all claims, it is used to access provider specific claims.
+	Raw map[string]interface{} `json:"-"`
+}
+
+// Strings returns a claim that contains a string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Client is the relying party of a single identity provider. The provider
+// configuration is discovered on first use.
+type Client struct {
+	config Config
+	http   *http.Client
+	now    func() time.Time
+
+	mu       sync.Mutex
+	provider *Provider
+	keys     map[string]*rsa.PublicKey
+}
+
+// NewClient returns a new client for the provider configured in config.
+func NewClient(config Config, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid"}
+	}
+	return &Client{config: config, http: httpClient, now: time.Now}
+}
+
+// AuthCodeURL returns the url of the provider's login page. The state and nonce protect against
+// forged callbacks, the verifier is the PKCE code verifier that is sent with the code exchange.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	p, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(p.AuthorizationEndpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid authorization endpoint")
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.config.ClientID)
+	q.Set("redirect_uri", c.config.RedirectURL)
+	q.Set("scope", strings.Join(c.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims of the ID token.
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	p, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if c.config.ClientSecret == "" {
+		form.Set("client_id", c.config.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := c.getJSON(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, errors.Errorf("code exchange failed with status %d: %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response contains no id token")
+	}
+	return c.Verify(ctx, token.IDToken, nonce)
+}
+
+// Verify checks the signature and the claims of a raw ID token.
+func (c *Client) Verify(ctx context.Context, raw, nonce string) (*Claims, error) {
+	p, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.Wrap(ErrInvalidToken, "malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err = decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	hash, ok := hashes[header.Alg]
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidToken, "unsupported signing algorithm %q", header.Alg)
+	}
+	key, err := c.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, "malformed signature")
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature); err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, "invalid signature")
+	}
+
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err = decodeSegment(parts[1], &claims.Raw); err != nil {
+		return nil, err
+	}
+	if err = c.checkClaims(p, &claims, nonce); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// checkClaims validates the registered claims of an ID token.
+func (c *Client) checkClaims(p *Provider, claims *Claims, nonce string) error {
+	if iss, _ := claims.Raw["iss"].(string); iss != p.Issuer {
+		return errors.Wrapf(ErrInvalidToken, "unexpected issuer %q", iss)
+	}
+	var audiences []string
+	switch aud := claims.Raw["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	default:
+		audiences = claims.Strings("aud")
+	}
+	var audOk bool
+	for _, aud := range audiences {
+		if aud == c.config.ClientID {
+			audOk = true
+		}
+	}
+	if !audOk {
+		return errors.Wrap(ErrInvalidToken, "token was issued for another client")
+	}
+	exp, _ := claims.Raw["exp"].(float64)
+	if !time.Unix(int64(exp), 0).Add(leeway).After(c.now()) {
+		return errors.Wrap(ErrInvalidToken, "token is expired")
+	}
+	if claims.Nonce != nonce {
+		return errors.Wrap(ErrInvalidToken, "nonce does not match")
+	}
+	if claims.Subject == "" {
+		return errors.Wrap(ErrInvalidToken, "token has no subject")
+	}
+	return nil
+}
+
+// discover fetches the provider configuration once.
+func (c *Client) discover(ctx context.Context) (*Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil {
+		return c.provider, nil
+	}
+	wellKnown := strings.TrimSuffix(c.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var p Provider
+	status, err := c.getJSON(req, &p)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, errors.Errorf("provider discovery failed with status %d", status)
+	}
+	if p.Issuer != c.config.Issuer {
+		return nil, errors.Errorf("provider issuer %q does not match %q", p.Issuer, c.config.Issuer)
+	}
+	c.provider = &p
+	return c.provider, nil
+}
+
+// key returns the public key with the given id. The key set is fetched
+// again if the key is unknown since providers rotate their keys.
+func (c *Client) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.provider.JWKSURI, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	status, err := c.getJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, errors.Errorf("fetching the provider keys failed with status %d", status)
+	}
+	c.keys = make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		c.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	key, ok := c.keys[kid]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// getJSON sends the request and decodes the JSON response into v.
+func (c *Client) getJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.StatusCode, errors.Wrapf(err, "failed to decode response of %s", req.URL)
+	}
+	return resp.StatusCode, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.Wrap(ErrInvalidToken, "malformed segment")
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return errors.Wrap(ErrInvalidToken, "malformed segment")
+	}
+	return nil
+}
+
+// RandomString returns a random base64url string that is used as state, nonce or code verifier.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate random string")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge returns the S256 PKCE code challenge of a code verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-webapp-example/pkg/oidc"
+	"go-webapp-example/pkg/oidc/oidctest"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const redirectURL = "http://localhost/callback"
+
+// authorize runs the authorization request and returns the code from the redirect.
+func authorize(t *testing.T, c *oidc.Client, state, nonce, verifier string) string {
+	authURL, err := c.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("failed to build auth url: %s", err)
+	}
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), redirectURL) {
+		t.Fatalf("unexpected redirect %q", resp.Header.Get("Location"))
+	}
+	assert.Equal(t, state, location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func TestClient(t *testing.T) {
+	idp := oidctest.NewServer("client", "secret")
+	defer idp.Close()
+	idp.Claims = map[string]interface{}{
+		"sub":            "123",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"groups":         []string{"admins", "staff"},
+	}
+
+	t.Run("Exchange", func(t *testing.T) {
+		c := oidc.NewClient(idp.Config(redirectURL), nil)
+		code := authorize(t, c, "state", "nonce", "verifier")
+
+		claims, err := c.Exchange(context.Background(), code, "verifier", "nonce")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "123", claims.Subject)
+		assert.Equal(t, "user@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+		assert.Equal(t, []string{"admins", "staff"}, claims.Strings("groups"))
+	})
+
+	t.Run("WrongVerifier", func(t *testing.T) {
+		c := oidc.NewClient(idp.Config(redirectURL), nil)
+		code := authorize(t, c, "state", "nonce", "verifier")
+
+		_, err := c.Exchange(context.Background(), code, "other", "nonce")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("WrongNonce", func(t *testing.T) {
+		c := oidc.NewClient(idp.Config(redirectURL), nil)
+		code := authorize(t, c, "state", "nonce", "verifier")
+
+		_, err := c.Exchange(context.Background(), code, "verifier", "other")
+
+		assert.Equal(t, oidc.ErrInvalidToken, errors.Cause(err))
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		config := idp.Config(redirectURL)
+		config.ClientSecret = "wrong"
+		c := oidc.NewClient(config, nil)
+		code := authorize(t, c, "state", "nonce", "verifier")
+
+		_, err := c.Exchange(context.Background(), code, "verifier", "nonce")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestClientVerify(t *testing.T) {
+	idp := oidctest.NewServer("client", "secret")
+	defer idp.Close()
+	c := oidc.NewClient(idp.Config(redirectURL), nil)
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   idp.URL,
+			"aud":   "client",
+			"sub":   "123",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		result error
+	}{
+		{"Valid", idp.SignToken(claims(nil)), nil},
+		{"AudienceList", idp.SignToken(claims(map[string]interface{}{"aud": []string{"other", "client"}})), nil},
+		{"WrongAudience", idp.SignToken(claims(map[string]interface{}{"aud": "other"})), oidc.ErrInvalidToken},
+		{"WrongIssuer", idp.SignToken(claims(map[string]interface{}{"iss": "http://evil"})), oidc.ErrInvalidToken},
+		{"Expired", idp.SignToken(claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), oidc.ErrInvalidToken},
+		{"NoSubject", idp.SignToken(claims(map[string]interface{}{"sub": ""})), oidc.ErrInvalidToken},
+		{"Tampered", idp.SignToken(claims(nil)) + "x", oidc.ErrInvalidToken},
+		{"Malformed", "abc", oidc.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.Verify(context.Background(), tt.token, "nonce")
+			assert.Equal(t, tt.result, errors.Cause(err))
+		})
+	}
+}
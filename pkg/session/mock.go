@@ -26,6 +26,20 @@ func (s *MockSessionManager) Put(ctx context.Context, key string, val interface{
 	s.keys[key] = val
 }
 
+// Get returns a value from the session.
+func (s *MockSessionManager) Get(ctx context.Context, key string) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.keys[key]
+}
+
+// Remove removes a value from the session.
+func (s *MockSessionManager) Remove(ctx context.Context, key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.keys, key)
+}
+
 // RenewToken renews the user's session token.
 func (s *MockSessionManager) RenewToken(ctx context.Context) error {
 	// not implemented
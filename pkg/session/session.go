@@ -16,6 +16,13 @@ import (
 // CookieName is the name of the session cookie.
 const CookieName = "gowebapp_session"
 
+const (
+	// Lifetime is the maximum lifetime of a session.
+	Lifetime = 24 * 7 * time.Hour
+	// IdleTimeout ends a session if it was not used for this duration.
+	IdleTimeout = time.Hour
+)
+
 // CtxKey is used to derive the current user from a context.
 var CtxKey = &contextKey{"user"}
 
@@ -34,17 +41,47 @@ func UserFromContext(ctx context.Context) (*entity.User, error) {
 	return user, nil
 }
 
+// ImpersonatorCtxKey is used to derive the superuser that impersonates the current user from a context.
+var ImpersonatorCtxKey = &contextKey{"impersonator"}
+
+// ImpersonatorFromContext returns the superuser that impersonates the currently
+// logged in user. It returns false if no impersonation is active.
+func ImpersonatorFromContext(ctx context.Context) (*entity.User, bool) {
+	user, ok := ctx.Value(ImpersonatorCtxKey).(*entity.User)
+	return user, ok
+}
+
 // AuthKey is the session key that contains the current user's id.
 var AuthKey = "user_id"
 
+// ImpersonatorKey is the session key that contains the id of the superuser that
+// logged in and impersonates the user of AuthKey.
+var ImpersonatorKey = "impersonator_id"
+
+// IDKey is the session key that contains the key of the session entity. It is
+// used to list and revoke the sessions of a user.
+var IDKey = "session_key"
+
+// PendingAuthKey is the session key that contains the id of a user that passed
+// the password check but still has to provide the second factor.
+var PendingAuthKey = "pending_user_id"
+
+// PendingPasswordChangeKey is set if the user of PendingAuthKey passed all login
+// steps but has to change the expired password.
+var PendingPasswordChangeKey = "pending_password_change"
+
+// PendingAuthExpiryKey is the session key that contains the unix time until the
+// second factor has to be provided.
+var PendingAuthExpiryKey = "pending_expires_at"
+
 type Store struct {
 	session *scs.SessionManager
 }
 
 func New(db *sql.DB) *Store {
 	sess := scs.New()
-	sess.Lifetime = 24 * 7 * time.Hour
-	sess.IdleTimeout = time.Hour
+	sess.Lifetime = Lifetime
+	sess.IdleTimeout = IdleTimeout
 	sess.Cookie.Name = CookieName
 	sess.Cookie.HttpOnly = true
 	sess.Cookie.Persist = true
@@ -66,6 +103,10 @@ func (s *Store) Get(ctx context.Context, key string) interface{} {
 	return s.session.Get(ctx, key)
 }
 
+func (s *Store) Remove(ctx context.Context, key string) {
+	s.session.Remove(ctx, key)
+}
+
 func (s *Store) RenewToken(ctx context.Context) error {
 	return s.session.RenewToken(ctx)
 }